@@ -2,22 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"image"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/Dev-AustinPeter/url-shortner-go/config"
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
 	"github.com/Dev-AustinPeter/url-shortner-go/db"
 	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
 	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
 	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/bloomfilter"
 	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/clickcounter"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/destpolicy"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/healthcheck"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linkauth"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metadata"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/qrcode"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/readiness"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/taskqueue"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/urlvalidator"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/cors"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type APIServer struct {
@@ -36,71 +59,423 @@ func (s *APIServer) Run() error {
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"}, // Change this to specific origins in production
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Origin", "Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Origin", "Content-Type", "Authorization", constants.REQUEST_ID_HEADER},
+		ExposedHeaders:   []string{constants.REQUEST_ID_HEADER},
 		AllowCredentials: true,
 	})
 
-	// Apply CORS Middleware
-	handler := corsMiddleware.Handler(router)
-
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	// Every route is measured, the redirects and admin routes outside of /api/v1 included
+	router.Use(middleware.Metrics)
 
-	ctx := context.Background()
-	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	// ctx : parent of the work that outlives a request, cancelled on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger, requestLogOptions, err := newLogger()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	// Client IPs are taken from X-Forwarded-For only behind these proxies
+	if err := utils.SetTrustedProxies(config.Envs.TrustedProxies); err != nil {
+		logger.Error().Err(err).Msg("failed to parse TRUSTED_PROXIES")
+		return err
+	}
+	// Code running outside of a request logs through zerolog.Ctx to the logger of the server
+	zerolog.DefaultContextLogger = &logger
 
-	rateLimiter := middleware.NewRateLimiter(1*time.Second, 5*time.Minute, &logger)
+	// metricsServer : Prometheus metrics on their own listener, so they are not exposed with the API
+	if config.Envs.MetricsAddr != "" {
+		metricsRouter := http.NewServeMux()
+		metricsRouter.Handle("/metrics", metrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(config.Envs.MetricsAddr, metricsRouter); err != nil {
+				logger.Error().Err(err).Msg("metrics server stopped")
+			}
+		}()
+	}
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	go func() {
-		<-stop
-		rateLimiter.StopCleanup() // Stop background cleanup
-		log.Println("Server shutting down...")
-		os.Exit(0)
-	}()
+	// tracer : spans of requests, cache and repository calls, exported as configured
+	traceExporter, err := newTraceExporter(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to configure tracing")
+		return err
+	}
+	tracingOptions := tracing.DefaultOptions()
+	tracingOptions.ServiceName = config.Envs.TracingServiceName
+	tracer, err := tracing.NewProvider(traceExporter, tracingOptions)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to configure tracing")
+		return err
+	}
+	tracing.SetProvider(tracer)
+	router.Use(middleware.Tracing)
+	// requestLogger : X-Request-ID and a logger of the request in its context, one line per request including
+	// those no route matched; the route template is added once matched
+	requestLogger := middleware.NewRequestLogger(logger, requestLogOptions)
+	router.Use(requestLogger.Route)
 
-	db := db.NewConnection("localhost", "5432", "postgres", "", "url_shortner_go", "postgres")
-	if db == nil {
-		logger.Error().Msg("failed to connect to database")
-		return fmt.Errorf("%s", "failed to connect to database")
+	rateLimiter := middleware.NewRateLimiter(1*time.Second, 5*time.Minute, &logger)
+	// redirectLimiter : visitors follow links much more often than they call the API, and in bursts
+	var redirectInterval time.Duration
+	if config.Envs.RedirectRateLimit > 0 {
+		redirectInterval = time.Second / time.Duration(config.Envs.RedirectRateLimit)
 	}
+	redirectLimiter := middleware.NewBurstRateLimiter(redirectInterval, config.Envs.RedirectRateBurst, 5*time.Minute, &logger)
 
-	defer db.DB.Close()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	validatorOptions := urlvalidator.DefaultOptions()
+	validatorOptions.AllowedSchemes = config.Envs.AllowedSchemes
+	validatorOptions.MaxLength = config.Envs.MaxUrlLength
+	validatorOptions.StripTrackingParams = config.Envs.StripTrackingParams
+	urlValidator := urlvalidator.NewValidator(validatorOptions)
 
-	if err := db.DB.Ping(); err != nil {
-		fmt.Println("failed to connect to database")
-		logger.Error().Msg("failed to connect to database")
+	policy, err := newDestinationPolicy(logger, urlValidator)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load destination policy")
 		return err
 	}
+	go policy.Watch(time.Duration(config.Envs.PolicyReloadInterval) * time.Second)
 
-	// repository : SQL query are written here
-	repository := repository.NewRepository(db)
+	// store : repositories of the backend selected by STORAGE_BACKEND, SQL queries are written there
+	store, err := openStorage(ctx, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to open storage")
+		return err
+	}
+	defer store.close()
+	store.trace()
 
-	// cacheManager : Redis cache
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-	_, err := redisClient.Ping(ctx).Result()
+	// cacheManager : cache of the backend selected by CACHE_BACKEND
+	cacheManager, redisClient, err := newCache(ctx, logger)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to connect to redis")
+		logger.Error().Err(err).Msg("failed to connect to the cache")
 		return err
 	}
 
-	cacheManager := cachemanager.NewCacheManager(redisClient, logger)
+	// cacheLoader : concurrent misses of a key share one database query, unknown codes and tasks are cached briefly
+	loaderOptions := cachemanager.DefaultLoaderOptions()
+	loaderOptions.NegativeTTL = config.Envs.NegativeCacheTTL
+	cacheLoader := cachemanager.NewLoader(cacheManager, loaderOptions)
+
+	// urlRepository : links are read from the cache on redirect, the database is only asked on a miss
+	urlRepository := store.urls
+	// targets : split targets are cached with the links, so redirects do not ask the database for them
+	targets := store.targets
+	if config.Envs.LinkCacheTTL > 0 {
+		urlRepository = repository.WithCache(urlRepository, cacheLoader, config.Envs.LinkCacheTTL)
+		if targets != nil {
+			targets = repository.WithTargetCache(targets, cacheLoader, config.Envs.LinkCacheTTL)
+		}
+	}
+
+	// codeGuard : a Bloom filter of every short code turns down codes that do not exist before the cache is asked
+	if config.Envs.BloomExpectedCodes > 0 {
+		codeGuard := newCodeGuard(redisClient, logger)
+		go codeGuard.Listen(ctx, store.urls)
+		if config.Envs.BloomRefreshInterval > 0 {
+			go codeGuard.RefreshEvery(ctx, store.urls, time.Duration(config.Envs.BloomRefreshInterval)*time.Second)
+		}
+		urlRepository = repository.WithCodeFilter(urlRepository, codeGuard)
+	}
+
+	// clickCounter : counts redirects in Redis and reconciles them with the database
+	clickCounter := clickcounter.NewCounter(cacheManager, urlRepository, logger)
+	go clickCounter.Run(time.Duration(config.Envs.ClickSyncInterval) * time.Second)
+
+	// metadataWorker : fetches the title, description, image and favicon of new links in the background
+	metadataQueue := taskqueue.New(config.Envs.MetadataWorkers, config.Envs.MetadataQueueSize, logger)
+	fetcherOptions := metadata.DefaultOptions()
+	fetcherOptions.Timeout = time.Duration(config.Envs.MetadataTimeout) * time.Second
+	fetcherOptions.MaxBytes = int64(config.Envs.MetadataMaxBytes)
+	fetcherOptions.UserAgent = config.Envs.MetadataUserAgent
+	var metadataWorker *metadata.Worker
+	if store.metadata != nil {
+		metadataWorker = metadata.NewWorker(metadata.NewFetcher(fetcherOptions), store.metadata, metadataQueue, logger)
+	}
+
+	// healthChecker : checks the destinations of all links and marks links broken after repeated failures
+	healthOptions := healthcheck.DefaultOptions()
+	healthOptions.Workers = config.Envs.HealthCheckWorkers
+	healthOptions.HostInterval = time.Duration(config.Envs.HealthCheckHostInterval) * time.Second
+	healthOptions.Timeout = time.Duration(config.Envs.HealthCheckTimeout) * time.Second
+	healthOptions.FailureThreshold = config.Envs.HealthFailureThreshold
+	var healthChecker *healthcheck.Checker
+	if store.health != nil {
+		health := store.health
+		if config.Envs.LinkCacheTTL > 0 {
+			health = repository.WithHealthCache(health, cacheLoader)
+		}
+		healthChecker = healthcheck.NewChecker(health, healthOptions, logger)
+		if config.Envs.HealthCheckInterval > 0 {
+			go healthChecker.Run(time.Duration(config.Envs.HealthCheckInterval) * time.Minute)
+		}
+	}
+
+	// prober : liveness and readiness probes, checking the dependencies again on every readiness probe
+	proberOptions := readiness.DefaultOptions()
+	proberOptions.Timeout = time.Duration(config.Envs.ReadinessTimeout) * time.Second
+	prober := readiness.NewProber(proberOptions)
+	for name, check := range store.checks {
+		prober.Add(name, check)
+	}
+	if redisClient != nil {
+		prober.Add("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+	// A worker busy with a fetch beats again at most the fetch timeout later
+	prober.Add("task_worker", readiness.Heartbeat(metadataQueue.Heartbeat, 3*taskqueue.HeartbeatInterval+fetcherOptions.Timeout))
 
 	// handler : API routes are written here
 	// 1. shorten : POST /api/v1/shorten
 	// 2. getShorten : GET /api/v1/shorten/{shortUrl}
-	// 3. createTaskId : GET /api/v1/shorten
-	// 4. getTaskBaseOnTaskId : GET /api/v1/task/{taskId}
-	shortUrlHandler := urlshortner.NewHandler(repository, &logger, cacheManager)
+	// 3. getRuleClicks : GET /api/v1/shorten/{shortUrl}/rules
+	// 4. getVariantClicks : GET /api/v1/shorten/{shortUrl}/variants
+	// 5. createTaskId : GET /api/v1/shorten
+	// 6. getTaskBaseOnTaskId : GET /api/v1/task/{taskId}
+	// 7. createReport : POST /api/v1/reports
+	// 8. admin reports : GET /api/v1/admin/reports, POST /api/v1/admin/reports/{reportId}/{dismiss|takedown}
+	// 9. admin links : PATCH /api/v1/admin/links/{shortUrl}, GET /api/v1/links?q= (search)
+	// 10. redirect : GET /{shortUrl}, GET /{shortUrl}/{rest} (prefix links), GET /{shortUrl}+ (preview page)
+	// 11. unlockLink : POST /{shortUrl} (password form of protected links)
+	// 12. campaigns : POST /api/v1/campaigns, GET /api/v1/campaigns
+	// 13. getMetadata : GET /api/v1/shorten/{shortUrl}/metadata
+	// 14. broken links : GET /api/v1/admin/broken-links?owner=
+	// 15. qr codes : GET /api/v1/links/{shortUrl}/qr, GET /api/v1/shorten/{shortUrl}/scans
+	if config.Envs.LinkCookieSecret == "" {
+		logger.Warn().Msg("LINK_COOKIE_SECRET is not set, unlocked password protected links will not survive a restart")
+	}
+	linkSigner, err := linkauth.NewSigner(config.Envs.LinkCookieSecret, time.Duration(config.Envs.LinkUnlockTTL)*time.Minute)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to initialize link signer")
+		return err
+	}
+	if config.Envs.ClientHashSecret == "" {
+		logger.Warn().Msg("CLIENT_HASH_SECRET is not set, reporters and IP sticky visitors are told apart differently by every replica and after a restart")
+	}
+	unlockLimiter := middleware.NewAttemptLimiter(config.Envs.UnlockMaxAttempts, time.Duration(config.Envs.UnlockAttemptWindow)*time.Minute)
+
+	var qrLogo image.Image
+	if config.Envs.QrLogoFile != "" {
+		qrLogo, err = qrcode.LoadLogo(config.Envs.QrLogoFile)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to load QR code logo")
+			return err
+		}
+	}
+
+	shortUrlHandler := urlshortner.NewHandler(urlRepository, &logger, cacheManager,
+		urlshortner.WithUrlValidator(urlValidator),
+		urlshortner.WithDestinationPolicy(policy),
+		urlshortner.WithReports(store.reports, config.Envs.ReportThreshold),
+		urlshortner.WithPasswordProtection(linkSigner, unlockLimiter),
+		urlshortner.WithClientHashSecret(config.Envs.ClientHashSecret),
+		urlshortner.WithClickCounter(clickCounter),
+		urlshortner.WithSplitTargets(targets, config.Envs.SplitStickiness),
+		urlshortner.WithCampaigns(store.campaigns),
+		urlshortner.WithCountryHeader(config.Envs.CountryHeader),
+		urlshortner.WithLinkSearch(store.links),
+		urlshortner.WithMetadata(metadataWorker, store.metadata),
+		urlshortner.WithHealthChecks(store.health, config.Envs.BrokenLinkFallbackUrl),
+		urlshortner.WithQRCodes(config.Envs.PublicBaseUrl, qrLogo),
+		urlshortner.WithCacheLoader(cacheLoader),
+		urlshortner.WithBackgroundContext(ctx),
+	)
 	shortUrlHandler.RegisterRoutes(subrouter, rateLimiter)
+	shortUrlHandler.RegisterAdminRoutes(subrouter, middleware.NewAdminAuth(config.Envs.AdminToken, &logger))
+	shortUrlHandler.RegisterRedirectRoutes(router, redirectLimiter)
+
+	// Apply CORS Middleware
+	handler := corsMiddleware.Handler(requestLogger.Log(router))
+
+	// server : the probes are answered before the API router, so they are neither rate limited nor traced
+	root := http.NewServeMux()
+	root.Handle("GET /healthz", prober.LiveHandler())
+	root.Handle("GET /readyz", prober.ReadyHandler())
+	root.Handle("/", handler)
+	server := &http.Server{Addr: s.addr, Handler: root}
+
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		// Fail readiness and keep serving until load balancers have noticed, then finish the requests in flight
+		prober.Shutdown()
+		time.Sleep(time.Duration(config.Envs.ShutdownDrainDelay) * time.Second)
+		serverCtx, cancelServer := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := server.Shutdown(serverCtx); err != nil {
+			logger.Error().Err(err).Msg("failed to finish the requests in flight")
+		}
+		cancelServer()
+
+		cancel()                  // Stop the export tasks in progress
+		rateLimiter.StopCleanup() // Stop background cleanup
+		redirectLimiter.StopCleanup()
+		policy.StopWatch()
+		clickCounter.Stop()
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		clickCounter.Flush(shutdownCtx) // Persist the clicks counted since the last sync
+		metadataQueue.Stop()
+		if healthChecker != nil {
+			healthChecker.Stop()
+		}
+		tracer.Shutdown(shutdownCtx) // Export the spans still queued
+		cancelShutdown()
+		logger.Info().Msg("Server shutting down")
+		close(done)
+	}()
 
-	log.Println("[INFO]: Listening on port", s.addr)
-	return http.ListenAndServe(s.addr, handler)
+	logger.Info().Str("addr", s.addr).Msg("Listening")
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// newLogger returns the logger of the server at LOG_LEVEL and the options of the request log
+func newLogger() (zerolog.Logger, middleware.RequestLogOptions, error) {
+	opts := middleware.DefaultRequestLogOptions()
+	level, err := zerolog.ParseLevel(config.Envs.LogLevel)
+	if err != nil {
+		return zerolog.Logger{}, opts, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	if opts.Level, err = zerolog.ParseLevel(config.Envs.RequestLogLevel); err != nil {
+		return zerolog.Logger{}, opts, fmt.Errorf("invalid REQUEST_LOG_LEVEL: %w", err)
+	}
+	opts.SampleEvery = uint32(max(config.Envs.RequestLogSample, 1))
+
+	return zerolog.New(os.Stdout).Level(level).With().Timestamp().Logger(), opts, nil
+}
+
+// newDestinationPolicy builds the destination policy engine from the configured list files. Threat feed
+// URLs are canonicalized with validator, like destinations.
+func newDestinationPolicy(logger zerolog.Logger, validator *urlvalidator.Validator) (*destpolicy.Engine, error) {
+	shorteners := config.Envs.KnownShorteners
+	if len(shorteners) == 0 {
+		shorteners = destpolicy.DefaultShorteners
+	}
+	// Short links are served from PUBLIC_BASE_URL, so its host is one of ours even when SHORTENER_HOSTS misses it
+	selfHosts := slices.Clone(config.Envs.ShortenerHosts)
+	if base, err := url.Parse(config.Envs.PublicBaseUrl); err == nil && base.Host != "" {
+		selfHosts = append(selfHosts, base.Host)
+	}
+	policy := destpolicy.NewEngine(logger, destpolicy.NewLoopRule(selfHosts, shorteners))
+
+	if path := config.Envs.BlocklistFile; path != "" {
+		rule, err := destpolicy.NewDomainBlocklist(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.AddRule(rule)
+	}
+	if path := config.Envs.AllowlistFile; path != "" {
+		rule, err := destpolicy.NewDomainAllowlist(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.AddRule(rule)
+	}
+	if path := config.Envs.RegexBlocklistFile; path != "" {
+		rule, err := destpolicy.NewRegexBlocklist(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.AddRule(rule)
+	}
+	if path := config.Envs.ThreatFeedFile; path != "" {
+		rule, err := destpolicy.NewThreatFeed(path, validator)
+		if err != nil {
+			return nil, err
+		}
+		policy.AddRule(rule)
+	}
+
+	return policy, nil
+}
+
+// newTraceExporter returns the span exporter selected by TRACING_EXPORTER, nil when tracing is off
+func newTraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch config.Envs.TracingExporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		return otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(config.Envs.TracingOtlpEndpoint, "/")+"/v1/traces"),
+			otlptracehttp.WithTimeout(10*time.Second),
+		)
+	}
+	return nil, fmt.Errorf("unknown TRACING_EXPORTER %q, expected otlp, stdout or none", config.Envs.TracingExporter)
+}
+
+// newCache returns the cache of CACHE_BACKEND and the Redis client behind it, nil for the other backends
+func newCache(ctx context.Context, logger zerolog.Logger) (cachemanager.Cache, *redis.Client, error) {
+	switch config.Envs.CacheBackend {
+	case constants.CACHE_REDIS:
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: "localhost:6379",
+		})
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			return nil, nil, err
+		}
+		if config.Envs.CacheL1Size <= 0 {
+			return cachemanager.NewCacheManager(redisClient, logger), redisClient, nil
+		}
+
+		// An LRU in the process in front of Redis, replicas drop the keys deleted by any of them
+		opts := cachemanager.DefaultTieredOptions()
+		opts.Size = config.Envs.CacheL1Size
+		opts.TTL = time.Duration(config.Envs.CacheL1TTL) * time.Second
+		invalidator := cachemanager.NewRedisInvalidator(redisClient, constants.CACHE_INVALIDATION_CHANNEL)
+		cache := cachemanager.NewTieredCache(cachemanager.NewCacheManager(redisClient, logger), invalidator, opts, logger)
+		go cache.Listen(ctx)
+		return cache, redisClient, nil
+	case constants.CACHE_MEMORY:
+		return cachemanager.NewMemoryCache(config.Envs.CacheSize), nil, nil
+	case constants.CACHE_NONE:
+		logger.Warn().Msg("caching is off, click statistics do not work and links with a click limit are refused")
+		return cachemanager.NoopCache{}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unknown CACHE_BACKEND %q, expected redis, memory or none", config.Envs.CacheBackend)
+}
+
+// newCodeGuard sizes the Bloom filter of short codes. With Redis, new codes are broadcast so the replicas
+// share them, and a snapshot of the filter is kept unless BLOOM_PERSIST is off.
+func newCodeGuard(redisClient *redis.Client, logger zerolog.Logger) *bloomfilter.Guard {
+	filter := bloomfilter.New(bloomfilter.Options{
+		ExpectedItems:     config.Envs.BloomExpectedCodes,
+		FalsePositiveRate: config.Envs.BloomFalsePositiveRate,
+		MaxBytes:          config.Envs.BloomMaxBytes,
+	})
+	if redisClient == nil {
+		return bloomfilter.NewGuard(filter, nil, nil, logger)
+	}
+	var store bloomfilter.Store
+	if config.Envs.BloomPersist {
+		store = bloomfilter.NewRedisStore(redisClient, constants.BLOOM_FILTER_KEY_PREFIX, filter)
+	}
+	peers := cachemanager.NewRedisInvalidator(redisClient, constants.BLOOM_FILTER_CHANNEL)
+	return bloomfilter.NewGuard(filter, store, peers, logger)
+}
+
+// newConnection opens the database, a statementTimeout of 0 lets statements run as long as they need
+func newConnection(statementTimeout time.Duration) *db.SqlHandler {
+	return db.NewConnection("localhost", "5432", "postgres", "", "url_shortner_go", "postgres", statementTimeout)
 }
 
 func main() {
+	// migrate : url-shortener migrate up|down [N]|status|force VERSION
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize the application and run it
 	app := NewAPIServer(":8080")
 	app.Run()
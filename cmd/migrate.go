@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/migrations"
+	"github.com/rs/zerolog"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | force VERSION"
+
+// runMigrate runs the migrate subcommand, the embedded migrations are applied without a statement timeout
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn := newConnection(0)
+	if conn == nil {
+		return errors.New("failed to connect to database")
+	}
+	defer conn.DB.Close()
+
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	migrator, err := migrations.New(conn.DB, logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("down takes a positive number of migrations, got %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("force takes a version, got %q", args[1])
+		}
+		return migrator.Force(ctx, version)
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	}
+	return errors.New(migrateUsage)
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		fmt.Printf("%06d  %-8s %s\n", status.Version, state, status.Name)
+	}
+	fmt.Printf("version %d of %d", version, migrator.Latest())
+	if dirty {
+		fmt.Print(", dirty: repair the schema and run migrate force")
+	}
+	fmt.Println()
+	return nil
+}
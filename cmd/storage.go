@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/config"
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/migrations"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/readiness"
+	"github.com/rs/zerolog"
+)
+
+// storage holds the repositories of the configured backend. Only Postgres implements the repositories
+// besides UrlRepository, with the other backends they are nil and the features using them are off.
+type storage struct {
+	urls      repository.UrlRepository
+	reports   repository.ReportRepository
+	targets   repository.TargetRepository
+	campaigns repository.CampaignRepository
+	links     repository.LinkRepository
+	metadata  repository.MetadataRepository
+	health    repository.HealthRepository
+	// system is the db.system of the spans of the repositories
+	system string
+	// checks are added to the readiness probe
+	checks map[string]readiness.Check
+	close  func() error
+}
+
+// openStorage opens the backend selected by STORAGE_BACKEND
+func openStorage(ctx context.Context, logger zerolog.Logger) (*storage, error) {
+	switch config.Envs.StorageBackend {
+	case constants.STORAGE_POSTGRES:
+		return openPostgres(ctx, logger)
+	case constants.STORAGE_MEMORY:
+		logger.Warn().Msg("links are kept in memory and lost when the server stops")
+		return &storage{urls: repository.NewMemoryRepository(), system: constants.DB_SYSTEM_MEMORY, close: func() error { return nil }}, nil
+	case constants.STORAGE_SQLITE:
+		conn, err := repository.OpenSqlite(config.Envs.SqlitePath)
+		if err != nil {
+			return nil, err
+		}
+		urls, err := repository.NewSqliteRepository(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &storage{
+			urls:   urls,
+			system: constants.DB_SYSTEM_SQLITE,
+			checks: map[string]readiness.Check{"sqlite": conn.PingContext},
+			close:  conn.Close,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, expected postgres, memory or sqlite", config.Envs.StorageBackend)
+}
+
+// openPostgres connects to Postgres and refuses a schema older than the migrations built into the binary
+func openPostgres(ctx context.Context, logger zerolog.Logger) (*storage, error) {
+	conn := newConnection(time.Duration(config.Envs.DbStatementTimeout) * time.Second)
+	if conn == nil {
+		return nil, errors.New("failed to connect to database")
+	}
+	if err := conn.PingContext(ctx); err != nil {
+		conn.DB.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	migrator, err := migrations.New(conn.DB, logger)
+	if err != nil {
+		conn.DB.Close()
+		return nil, err
+	}
+	if err := migrator.Check(ctx); err != nil {
+		conn.DB.Close()
+		return nil, fmt.Errorf("database schema is not up to date: %w", err)
+	}
+
+	// database : records the latency of every query for the metrics
+	database := db.Instrument(conn)
+	return &storage{
+		urls:      repository.NewRepository(database),
+		reports:   repository.NewReportRepository(database),
+		targets:   repository.NewTargetRepository(database),
+		campaigns: repository.NewCampaignRepository(database),
+		links:     repository.NewLinkRepository(database),
+		metadata:  repository.NewMetadataRepository(database),
+		health:    repository.NewHealthRepository(database),
+		system:    constants.DB_SYSTEM_POSTGRES,
+		checks: map[string]readiness.Check{
+			"postgres":   conn.PingContext,
+			"migrations": migrator.Check,
+		},
+		close: conn.DB.Close,
+	}, nil
+}
+
+// trace wraps the repositories of the storage so every call is traced, the missing ones stay nil
+func (s *storage) trace() {
+	s.urls = repository.WithTracing(s.urls, s.system)
+	if s.reports != nil {
+		s.reports = repository.WithReportTracing(s.reports, s.system)
+	}
+	if s.targets != nil {
+		s.targets = repository.WithTargetTracing(s.targets, s.system)
+	}
+	if s.campaigns != nil {
+		s.campaigns = repository.WithCampaignTracing(s.campaigns, s.system)
+	}
+	if s.links != nil {
+		s.links = repository.WithLinkTracing(s.links, s.system)
+	}
+	if s.metadata != nil {
+		s.metadata = repository.WithMetadataTracing(s.metadata, s.system)
+	}
+	if s.health != nil {
+		s.health = repository.WithHealthTracing(s.health, s.system)
+	}
+}
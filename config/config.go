@@ -0,0 +1,266 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+)
+
+// Config holds the runtime configuration read from the environment
+type Config struct {
+	// AllowedSchemes lists the URL schemes accepted by POST /shorten
+	AllowedSchemes []string
+	// MaxUrlLength is the maximum length of a long URL
+	MaxUrlLength int
+	// StripTrackingParams removes utm_* and click-id parameters before storing a URL
+	StripTrackingParams bool
+	// BlocklistFile, AllowlistFile and RegexBlocklistFile are optional destination policy lists
+	BlocklistFile      string
+	AllowlistFile      string
+	RegexBlocklistFile string
+	// ThreatFeedFile is an optional local threat feed (one URL or domain per line)
+	ThreatFeedFile string
+	// PolicyReloadInterval is how often, in seconds, the policy files are checked for changes
+	PolicyReloadInterval int
+	// ShortenerHosts lists the hosts this service is reachable on, used for loop detection
+	ShortenerHosts []string
+	// KnownShorteners overrides the built in list of other URL shorteners refused as destinations
+	KnownShorteners []string
+	// AdminToken is the bearer token for the admin API; an empty token disables it
+	AdminToken string
+	// ReportThreshold is the number of independent reports after which a link is disabled
+	ReportThreshold int
+	// ClientHashSecret keys the hashes identifying reporters and visitors by IP; a random secret is used when empty
+	ClientHashSecret string
+	// TrustedProxies lists the IPs and CIDR ranges of the proxies whose X-Forwarded-For header is believed
+	TrustedProxies []string
+	// RedirectRateLimit is how many redirects a second a client may follow, after a burst of RedirectRateBurst; 0 turns the limit off
+	RedirectRateLimit int
+	RedirectRateBurst int
+	// LinkCookieSecret signs the unlock cookies of password protected links; a random secret is used when empty
+	LinkCookieSecret string
+	// LinkUnlockTTL is how long, in minutes, a protected link stays unlocked in a browser
+	LinkUnlockTTL int
+	// UnlockMaxAttempts wrong passwords are allowed per short code within UnlockAttemptWindow minutes
+	UnlockMaxAttempts   int
+	UnlockAttemptWindow int
+	// ClickSyncInterval is how often, in seconds, click counts are written from Redis to the database
+	ClickSyncInterval int
+	// SplitStickiness keeps visitors of split links on their variant by "cookie" or by client "ip"
+	SplitStickiness string
+	// CountryHeader is the request header carrying the visitor's country code, set by a CDN or proxy
+	CountryHeader string
+	// MetadataWorkers fetch the page details of new links, at most MetadataQueueSize fetches wait for a worker
+	MetadataWorkers   int
+	MetadataQueueSize int
+	// MetadataTimeout is how long, in seconds, fetching a page may take
+	MetadataTimeout int
+	// MetadataMaxBytes is the number of bytes of a page read at most
+	MetadataMaxBytes int
+	// MetadataUserAgent is sent when fetching pages and matched against robots.txt
+	MetadataUserAgent string
+	// HealthCheckInterval is how often, in minutes, the destinations of all links are checked; 0 turns the checks off
+	HealthCheckInterval int
+	// HealthCheckWorkers destinations are checked at once, requests to one host are HealthCheckHostInterval seconds apart
+	HealthCheckWorkers      int
+	HealthCheckHostInterval int
+	// HealthCheckTimeout is how long, in seconds, a single check may take
+	HealthCheckTimeout int
+	// HealthFailureThreshold failed checks in a row mark a link broken
+	HealthFailureThreshold int
+	// BrokenLinkFallbackUrl is where visitors of broken links without their own fallback go, "" keeps redirecting them
+	BrokenLinkFallbackUrl string
+
+	// PublicBaseUrl is the address short links are served from, it is encoded in QR codes
+	PublicBaseUrl string
+	// QrLogoFile is a PNG or JPEG logo that can be embedded in QR codes, "" disables logos
+	QrLogoFile string
+
+	// StorageBackend is where links are stored: postgres, memory or sqlite. Reports, split targets, campaigns,
+	// search, metadata and health checks need postgres and are turned off with the other backends.
+	StorageBackend string
+	// SqlitePath is the database file of the sqlite backend
+	SqlitePath string
+	// DbStatementTimeout is how long, in seconds, the database may run a single statement; 0 means no limit
+	DbStatementTimeout int
+
+	// CacheBackend is where lookups, counters and task results are cached: redis, memory or none
+	CacheBackend string
+	// CacheSize is the number of keys the memory cache holds before evicting the least recently used
+	CacheSize int
+	// CacheL1Size keys are held in process in front of Redis, 0 turns the in-process tier off
+	CacheL1Size int
+	// CacheL1TTL is how long, in seconds, a value is served from the process before Redis is asked again
+	CacheL1TTL int
+	// LinkCacheTTL is how long, in minutes, links are cached for redirects; 0 turns link caching off
+	LinkCacheTTL int
+	// NegativeCacheTTL is how long, in minutes, short codes and task ids that do not exist are cached; 0 turns it off
+	NegativeCacheTTL int
+	// BloomExpectedCodes is the number of short codes the Bloom filter is sized for, 0 turns the filter off
+	BloomExpectedCodes int
+	// BloomFalsePositiveRate is the share of codes that do not exist the filter lets through to the database
+	BloomFalsePositiveRate float64
+	// BloomMaxBytes caps the memory of the filter, 0 for no cap; a capped filter lets more codes through
+	BloomMaxBytes int
+	// BloomRefreshInterval is how often, in seconds, links added to the database by other means are read into the filter
+	BloomRefreshInterval int
+	// BloomPersist keeps a snapshot of the filter in Redis when CACHE_BACKEND is redis, so replicas starting up
+	// only read the links created since
+	BloomPersist bool
+
+	// ReadinessTimeout is how long, in seconds, each dependency check of the readiness probe may take
+	ReadinessTimeout int
+	// ShutdownDrainDelay is how long, in seconds, requests are still served after readiness starts failing on shutdown
+	ShutdownDrainDelay int
+
+	// MetricsAddr is the address Prometheus metrics are served on, "" turns them off
+	MetricsAddr string
+
+	// LogLevel is the lowest level logged: trace, debug, info, warn or error
+	LogLevel string
+	// RequestLogLevel is the level of the line logged for every request answered below 400
+	RequestLogLevel string
+	// RequestLogSample keeps the debug and info lines of one in RequestLogSample requests, warnings and errors are always kept
+	RequestLogSample int
+
+	// TracingExporter is where spans are sent: otlp, stdout or none
+	TracingExporter string
+	// TracingOtlpEndpoint is the OTLP/HTTP collector spans are sent to, without the /v1/traces path
+	TracingOtlpEndpoint string
+	TracingServiceName  string
+}
+
+// Envs is the configuration loaded once at startup
+var Envs = initConfig()
+
+func initConfig() Config {
+	return Config{
+		AllowedSchemes:      getEnvList("ALLOWED_SCHEMES", []string{"http", "https"}),
+		MaxUrlLength:        getEnvInt("MAX_URL_LENGTH", constants.MAX_URL_LENGTH),
+		StripTrackingParams: getEnvBool("STRIP_TRACKING_PARAMS", false),
+
+		BlocklistFile:        getEnv("POLICY_BLOCKLIST_FILE", ""),
+		AllowlistFile:        getEnv("POLICY_ALLOWLIST_FILE", ""),
+		RegexBlocklistFile:   getEnv("POLICY_REGEX_BLOCKLIST_FILE", ""),
+		ThreatFeedFile:       getEnv("POLICY_THREAT_FEED_FILE", ""),
+		PolicyReloadInterval: getEnvInt("POLICY_RELOAD_INTERVAL", 30),
+		ShortenerHosts:       getEnvList("SHORTENER_HOSTS", []string{"localhost:8080"}),
+		KnownShorteners:      getEnvList("KNOWN_SHORTENERS", nil),
+
+		AdminToken:      getEnv("ADMIN_TOKEN", ""),
+		ReportThreshold: getEnvInt("REPORT_THRESHOLD", 5),
+
+		ClientHashSecret: getEnv("CLIENT_HASH_SECRET", ""),
+		TrustedProxies:   getEnvList("TRUSTED_PROXIES", nil),
+
+		RedirectRateLimit: getEnvInt("REDIRECT_RATE_LIMIT", 10),
+		RedirectRateBurst: getEnvInt("REDIRECT_RATE_BURST", 20),
+
+		LinkCookieSecret:    getEnv("LINK_COOKIE_SECRET", ""),
+		LinkUnlockTTL:       getEnvInt("LINK_UNLOCK_TTL", constants.LINK_UNLOCK_TTL_DEFAULT),
+		UnlockMaxAttempts:   getEnvInt("UNLOCK_MAX_ATTEMPTS", 5),
+		UnlockAttemptWindow: getEnvInt("UNLOCK_ATTEMPT_WINDOW", 15),
+
+		ClickSyncInterval: getEnvInt("CLICK_SYNC_INTERVAL", 30),
+		SplitStickiness:   getEnv("SPLIT_STICKINESS", "cookie"),
+		CountryHeader:     getEnv("COUNTRY_HEADER", constants.COUNTRY_HEADER_DEFAULT),
+
+		MetadataWorkers:   getEnvInt("METADATA_WORKERS", 2),
+		MetadataQueueSize: getEnvInt("METADATA_QUEUE_SIZE", 100),
+		MetadataTimeout:   getEnvInt("METADATA_TIMEOUT", 5),
+		MetadataMaxBytes:  getEnvInt("METADATA_MAX_BYTES", 1<<20),
+		MetadataUserAgent: getEnv("METADATA_USER_AGENT", "UrlShortnerBot/1.0"),
+
+		HealthCheckInterval:     getEnvInt("HEALTH_CHECK_INTERVAL", 1440),
+		HealthCheckWorkers:      getEnvInt("HEALTH_CHECK_WORKERS", 8),
+		HealthCheckHostInterval: getEnvInt("HEALTH_CHECK_HOST_INTERVAL", 2),
+		HealthCheckTimeout:      getEnvInt("HEALTH_CHECK_TIMEOUT", 10),
+		HealthFailureThreshold:  getEnvInt("HEALTH_FAILURE_THRESHOLD", 3),
+		BrokenLinkFallbackUrl:   getEnv("BROKEN_LINK_FALLBACK_URL", ""),
+
+		PublicBaseUrl: getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		QrLogoFile:    getEnv("QR_LOGO_FILE", ""),
+
+		StorageBackend:     getEnv("STORAGE_BACKEND", constants.STORAGE_POSTGRES),
+		SqlitePath:         getEnv("SQLITE_PATH", "url_shortner_go.db"),
+		DbStatementTimeout: getEnvInt("DB_STATEMENT_TIMEOUT", 5),
+
+		CacheBackend:     getEnv("CACHE_BACKEND", constants.CACHE_REDIS),
+		CacheSize:        getEnvInt("CACHE_SIZE", 10000),
+		CacheL1Size:      getEnvInt("CACHE_L1_SIZE", 10000),
+		CacheL1TTL:       getEnvInt("CACHE_L1_TTL", 5),
+		LinkCacheTTL:     getEnvInt("LINK_CACHE_TTL", 5),
+		NegativeCacheTTL: getEnvInt("NEGATIVE_CACHE_TTL", 1),
+
+		BloomExpectedCodes:     getEnvInt("BLOOM_EXPECTED_CODES", 1000000),
+		BloomFalsePositiveRate: getEnvFloat("BLOOM_FALSE_POSITIVE_RATE", 0.01),
+		BloomMaxBytes:          getEnvInt("BLOOM_MAX_BYTES", 0),
+		BloomRefreshInterval:   getEnvInt("BLOOM_REFRESH_INTERVAL", 30),
+		BloomPersist:           getEnvBool("BLOOM_PERSIST", true),
+
+		ReadinessTimeout:   getEnvInt("READINESS_TIMEOUT", 2),
+		ShutdownDrainDelay: getEnvInt("SHUTDOWN_DRAIN_DELAY", 5),
+
+		MetricsAddr: getEnv("METRICS_ADDR", "127.0.0.1:9090"),
+
+		LogLevel:         getEnv("LOG_LEVEL", "info"),
+		RequestLogLevel:  getEnv("REQUEST_LOG_LEVEL", "info"),
+		RequestLogSample: getEnvInt("REQUEST_LOG_SAMPLE", 1),
+
+		TracingExporter:     getEnv("TRACING_EXPORTER", "none"),
+		TracingOtlpEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "http://localhost:4318"),
+		TracingServiceName:  getEnv("TRACING_SERVICE_NAME", "url-shortner"),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+// getEnvList reads a comma separated list, ignoring empty items
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
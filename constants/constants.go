@@ -4,4 +4,92 @@ const (
 	CACHE_TTL_DEFAULT   = 60 // 60 minutes
 	CACHE_TTL_PERMANENT = 0  // 0 minutes
 	LETTER_BYTES        = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	MAX_URL_LENGTH      = 2048 // characters accepted for a long URL
+	PAGE_SIZE_DEFAULT   = 50
+	PAGE_SIZE_MAX       = 200
+	EXPORT_BATCH_SIZE   = 1000 // links read per query by the export task
+	EXPORT_TASK_TIMEOUT = 10   // 10 minutes
+
+	LINK_PASSWORD_MIN_LEN   = 4
+	LINK_PASSWORD_MAX_LEN   = 72 // bcrypt ignores anything longer
+	LINK_UNLOCK_TTL_DEFAULT = 30 // 30 minutes
+
+	CLICK_COUNTER_KEY_PREFIX  = "clicks:"
+	RULE_CLICK_KEY_PREFIX     = "rule_clicks:"
+	VARIANT_CLICK_KEY_PREFIX  = "variant_clicks:"
+	VISITOR_COOKIE_MAX_AGE    = 365 * 24 * 60 * 60 // 1 year in seconds
+	QUERY_CONFLICT_DEFAULT    = "target"           // destination wins, see passthrough.ConflictTarget
+	CAMPAIGN_CACHE_KEY_PREFIX = "campaign:"
+	CAMPAIGN_NAME_MAX_LEN     = 100
+	COUNTRY_HEADER_DEFAULT    = "CF-IPCountry" // set by Cloudflare
+	REQUEST_ID_HEADER         = "X-Request-ID"
+	REQUEST_ID_MAX_LEN        = 128
+
+	LINK_TITLE_MAX_LEN  = 255
+	LINK_NOTES_MAX_LEN  = 2000
+	LINK_FOLDER_MAX_LEN = 255
+	LINK_TAG_MAX_LEN    = 32
+	LINK_TAGS_MAX       = 20
+	TAG_FACETS_MAX      = 20 // most used tags returned with search results
+	LINK_OWNER_MAX_LEN  = 255
+
+	QR_CACHE_KEY_PREFIX = "qr:"
+	QR_CACHE_TTL        = 24 * 60 // 24 hours in minutes
+	QR_SCAN_KEY_PREFIX  = "qr_scans:"
+	QR_SOURCE_PARAM     = "src" // query parameter marking redirects from QR codes ...
+	QR_SOURCE_VALUE     = "qr"  // ... with this value
+	QR_SIZE_DEFAULT     = 256   // pixels
+	QR_SIZE_MIN         = 64
+	QR_SIZE_MAX         = 2048
+	QR_MARGIN_MAX       = 16 // modules
+)
+
+// Moderation status of a short link
+const (
+	URL_STATUS_ACTIVE     = "active"
+	URL_STATUS_DISABLED   = "disabled" // disabled automatically, pending review
+	URL_STATUS_TAKEN_DOWN = "taken_down"
+)
+
+// Status of an abuse report
+const (
+	REPORT_STATUS_OPEN      = "open"
+	REPORT_STATUS_DISMISSED = "dismissed"
+	REPORT_STATUS_ACTIONED  = "actioned"
+	REPORT_REASON_MAX_LEN   = 1000
+)
+
+// Storage backends selected by STORAGE_BACKEND
+const (
+	STORAGE_POSTGRES = "postgres"
+	STORAGE_MEMORY   = "memory"
+	STORAGE_SQLITE   = "sqlite"
+)
+
+// db.system of the repository spans of each storage backend
+const (
+	DB_SYSTEM_POSTGRES = "postgresql"
+	DB_SYSTEM_MEMORY   = "memory"
+	DB_SYSTEM_SQLITE   = "sqlite"
+)
+
+// Cache backends selected by CACHE_BACKEND
+const (
+	CACHE_REDIS  = "redis"
+	CACHE_MEMORY = "memory"
+	CACHE_NONE   = "none"
+)
+
+// Keys of the links and split targets cached for redirects and the channel replicas invalidate cached keys on
+const (
+	LINK_CACHE_KEY_PREFIX      = "link:"
+	TARGETS_CACHE_KEY_PREFIX   = "targets:"
+	CACHE_INVALIDATION_CHANNEL = "cache:invalidate"
+)
+
+// Key prefix of the snapshot of the Bloom filter of short codes in Redis, followed by its size, and the
+// channel new codes are broadcast on
+const (
+	BLOOM_FILTER_KEY_PREFIX = "bloom:filter:"
+	BLOOM_FILTER_CHANNEL    = "bloom:codes"
 )
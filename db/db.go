@@ -1,8 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -11,18 +13,24 @@ type SqlHandler struct {
 	*sql.DB
 }
 
+// Database runs queries under a context, so a cancelled request or task stops its queries
 type Database interface {
-	Prepare(query string) (*sql.Stmt, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-	Exec(query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	Close() error
-	Ping() error
+	PingContext(ctx context.Context) error
 }
 
-func NewConnection(hostname string, port string, username string, password string, dbname string, driver string) *SqlHandler {
+// NewConnection opens a connection pool. A positive statementTimeout makes the server cancel
+// any statement running longer, whatever the context of the caller allows.
+func NewConnection(hostname string, port string, username string, password string, dbname string, driver string, statementTimeout time.Duration) *SqlHandler {
 
 	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password='' sslmode=disable", hostname, port, username, dbname)
+	if statementTimeout > 0 {
+		dataSourceName += fmt.Sprintf(" statement_timeout=%d", statementTimeout.Milliseconds())
+	}
 	fmt.Println(dataSourceName)
 	db, err := sql.Open(driver, dataSourceName)
 	if err != nil {
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+var (
+	queryDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "urlshortner_db_query_duration_seconds",
+		Help:    "Latency of database queries by operation.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"operation"})
+	queryErrors = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_db_query_errors_total",
+		Help: "Failed database queries by operation.",
+	}, []string{"operation"})
+)
+
+// instrumentedDatabase records the latency and errors of the queries run on a Database
+type instrumentedDatabase struct {
+	Database
+}
+
+// Instrument wraps database so the latency and errors of its queries are exported as metrics. Queries are
+// also logged to the logger in their context (zerolog.Ctx), at debug and failures at error.
+func Instrument(database Database) Database {
+	return &instrumentedDatabase{Database: database}
+}
+
+func (d *instrumentedDatabase) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.Database.QueryRowContext(ctx, query, args...)
+	// No rows is an answer, not a failure
+	observe(ctx, query, start, row.Err())
+	return row
+}
+
+func (d *instrumentedDatabase) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.Database.QueryContext(ctx, query, args...)
+	observe(ctx, query, start, err)
+	return rows, err
+}
+
+func (d *instrumentedDatabase) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.Database.ExecContext(ctx, query, args...)
+	observe(ctx, query, start, err)
+	return result, err
+}
+
+func observe(ctx context.Context, query string, start time.Time, err error) {
+	operation := operation(query)
+	elapsed := time.Since(start)
+	queryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+	if err != nil {
+		queryErrors.WithLabelValues(operation).Inc()
+		zerolog.Ctx(ctx).Error().Err(err).Str("operation", operation).Str("query", query).Dur("duration", elapsed).Msg("Query failed")
+		return
+	}
+	zerolog.Ctx(ctx).Debug().Str("operation", operation).Dur("duration", elapsed).Msg("Query")
+}
+
+// operation is the lower case first keyword of query, such as select or insert
+func operation(query string) string {
+	keyword, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToLower(keyword)
+}
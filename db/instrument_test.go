@@ -0,0 +1,77 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// observations returns the number of queries of operation measured so far
+func observations(t *testing.T, operation string) uint64 {
+	var m dto.Metric
+	require.NoError(t, queryDuration.WithLabelValues(operation).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestInstrument(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	database := Instrument(&SqlHandler{DB: conn})
+
+	selects, selectErrors := observations(t, "select"), testutil.ToFloat64(queryErrors.WithLabelValues("select"))
+	updates, updateErrors := observations(t, "update"), testutil.ToFloat64(queryErrors.WithLabelValues("update"))
+
+	mock.ExpectQuery("SELECT id FROM urls").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM urls").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE urls").WillReturnError(errors.New("deadlock detected"))
+
+	var id int
+	assert.NoError(t, database.QueryRowContext(context.Background(), "SELECT id FROM urls WHERE short_code = $1", "abc123").Scan(&id))
+	assert.Error(t, database.QueryRowContext(context.Background(), "SELECT id FROM urls WHERE short_code = $1", "missing").Scan(&id))
+	_, err = database.ExecContext(context.Background(), "UPDATE urls SET status = $1", "active")
+	assert.Error(t, err)
+
+	assert.Equal(t, uint64(2), observations(t, "select")-selects)
+	assert.Equal(t, float64(0), testutil.ToFloat64(queryErrors.WithLabelValues("select"))-selectErrors)
+	assert.Equal(t, uint64(1), observations(t, "update")-updates)
+	assert.Equal(t, float64(1), testutil.ToFloat64(queryErrors.WithLabelValues("update"))-updateErrors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOperation(t *testing.T) {
+	assert.Equal(t, "insert", operation("  INSERT INTO urls (short_code) VALUES ($1)"))
+	assert.Equal(t, "with", operation("WITH broken AS (SELECT 1) SELECT * FROM broken"))
+}
+
+func TestInstrument_LogsToTheRequestLogger(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	var out bytes.Buffer
+	logger := zerolog.New(&out).With().Str("request_id", "req-1").Logger()
+	ctx := logger.WithContext(context.Background())
+	database := Instrument(&SqlHandler{DB: conn})
+
+	mock.ExpectExec("UPDATE urls").WillReturnError(errors.New("deadlock detected"))
+	_, err = database.ExecContext(ctx, "UPDATE urls SET status = $1", "active")
+	assert.Error(t, err)
+
+	assert.Contains(t, out.String(), `"level":"error"`)
+	assert.Contains(t, out.String(), `"request_id":"req-1"`)
+	assert.Contains(t, out.String(), `"operation":"update"`)
+	assert.Contains(t, out.String(), `"error":"deadlock detected"`)
+	// The arguments stay out of the log
+	assert.NotContains(t, out.String(), "active")
+}
@@ -8,4 +8,4 @@ CREATE TABLE tasks (
 
 CREATE INDEX idx_task_id ON tasks(task_id);
 CREATE INDEX idx_status ON tasks(status);
-CREATE INDEX idx_created_at_task ON tasks(created_at);
\ No newline at end of file
+CREATE INDEX idx_created_at_task ON tasks(created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_urls_status;
+ALTER TABLE urls DROP COLUMN IF EXISTS status;
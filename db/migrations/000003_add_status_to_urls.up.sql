@@ -0,0 +1,6 @@
+-- Moderation status of a short link: active, disabled (automatically, pending review) or taken_down
+ALTER TABLE urls ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+CREATE INDEX idx_urls_status ON urls(status);
+
+COMMENT ON COLUMN urls.status IS 'Moderation status: active, disabled (pending review) or taken_down';
@@ -0,0 +1,17 @@
+-- Create 'reports' table for abuse reports submitted against short links
+CREATE TABLE reports (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,  -- Reported short link
+    reason TEXT NOT NULL,  -- Free text reason given by the reporter
+    reporter VARCHAR(64) NOT NULL,  -- Hash identifying the reporter, used to count independent reports
+    status VARCHAR(20) NOT NULL DEFAULT 'open',  -- Report status: open, dismissed, actioned
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP DEFAULT NULL  -- Timestamp of the admin decision
+);
+
+CREATE INDEX idx_reports_url_id ON reports(url_id);
+CREATE INDEX idx_reports_status ON reports(status);
+CREATE INDEX idx_reports_created_at ON reports(created_at);
+
+COMMENT ON TABLE reports IS 'Abuse reports submitted by third parties against short links';
+COMMENT ON COLUMN reports.reporter IS 'Hash of the reporter IP address, never the address itself';
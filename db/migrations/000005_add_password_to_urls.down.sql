@@ -0,0 +1,19 @@
+-- Protected links would become public without the column, they have to be removed by hand first
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM urls WHERE password_hash IS NOT NULL) THEN
+        RAISE EXCEPTION 'urls holds password protected links, delete them before rolling back';
+    END IF;
+END $$;
+-- Links with options may share a long URL with other links, which the old unique constraint forbids.
+-- Their short codes are in use, so they have to be removed by hand rather than dropped here.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM urls GROUP BY long_url HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'urls holds several links to the same long URL, delete the extra ones before rolling back';
+    END IF;
+END $$;
+DROP INDEX IF EXISTS urls_long_url_plain_key;
+ALTER TABLE urls ADD CONSTRAINT urls_long_url_key UNIQUE (long_url);
+ALTER TABLE urls DROP COLUMN IF EXISTS plain;
+ALTER TABLE urls DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,13 @@
+-- Optional password protecting a short link (bcrypt hash)
+ALTER TABLE urls ADD COLUMN password_hash TEXT DEFAULT NULL;
+
+-- Links without options are shared by everyone shortening their long URL, links with options are not
+ALTER TABLE urls ADD COLUMN plain BOOLEAN NOT NULL DEFAULT true;
+
+-- Password protected links may point at a destination that already has a public short link,
+-- so long_url is only unique among plain links
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_long_url_key;
+CREATE UNIQUE INDEX urls_long_url_plain_key ON urls (long_url) WHERE plain;
+
+COMMENT ON COLUMN urls.password_hash IS 'bcrypt hash of the link password, NULL for public links';
+COMMENT ON COLUMN urls.plain IS 'Link without options, returned to everyone shortening long_url; cleared once the link gets options';
@@ -0,0 +1,3 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS click_count;
+ALTER TABLE urls DROP COLUMN IF EXISTS active_from;
+ALTER TABLE urls DROP COLUMN IF EXISTS max_clicks;
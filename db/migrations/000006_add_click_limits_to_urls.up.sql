@@ -0,0 +1,10 @@
+-- Optional click limit and activation time of a short link
+ALTER TABLE urls ADD COLUMN max_clicks INTEGER DEFAULT NULL;
+ALTER TABLE urls ADD COLUMN active_from TIMESTAMP DEFAULT NULL;
+
+-- Redirect count, reconciled periodically from the Redis click counters
+ALTER TABLE urls ADD COLUMN click_count BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN urls.max_clicks IS 'Number of redirects after which the link stops working, NULL for unlimited';
+COMMENT ON COLUMN urls.active_from IS 'Timestamp before which the link does not resolve, NULL for immediately';
+COMMENT ON COLUMN urls.click_count IS 'Redirect count as of the last reconciliation with Redis';
@@ -0,0 +1,4 @@
+-- Ordered routing rules of a short link, see services/routing
+ALTER TABLE urls ADD COLUMN routing_rules JSONB DEFAULT NULL;
+
+COMMENT ON COLUMN urls.routing_rules IS 'Ordered list of routing rules evaluated on redirect, NULL to always use long_url';
@@ -0,0 +1,13 @@
+-- Create 'link_targets' table for links that split traffic across weighted destinations
+CREATE TABLE link_targets (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,  -- Split link
+    name VARCHAR(32) NOT NULL,  -- Variant name reported in analytics, unique per link
+    target_url TEXT NOT NULL,  -- Destination of the variant
+    weight INTEGER NOT NULL CHECK (weight > 0),  -- Relative share of the traffic
+    position INTEGER NOT NULL  -- Order of the variants, keeps the assignment of visitors stable
+);
+
+CREATE INDEX idx_link_targets_url_id ON link_targets(url_id);
+
+COMMENT ON TABLE link_targets IS 'Weighted destinations of A/B split links; links without rows redirect to urls.long_url';
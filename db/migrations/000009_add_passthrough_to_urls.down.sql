@@ -0,0 +1,3 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS is_prefix;
+ALTER TABLE urls DROP COLUMN IF EXISTS query_conflict;
+ALTER TABLE urls DROP COLUMN IF EXISTS forward_query;
@@ -0,0 +1,8 @@
+-- Per-link settings for carrying the incoming query string and path over to the destination
+ALTER TABLE urls ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE urls ADD COLUMN query_conflict VARCHAR(10) NOT NULL DEFAULT 'target';
+ALTER TABLE urls ADD COLUMN is_prefix BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN urls.forward_query IS 'Merge the query parameters of the short link request into the destination';
+COMMENT ON COLUMN urls.query_conflict IS 'Parameter present on both sides: target keeps the destination value, incoming replaces it, append keeps both';
+COMMENT ON COLUMN urls.is_prefix IS 'Wildcard link: the path after the short code is appended to the destination';
@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS campaign_id;
+DROP TABLE IF EXISTS campaigns;
@@ -0,0 +1,15 @@
+-- Create 'campaigns' table with the default UTM parameters of the links created under a campaign
+CREATE TABLE campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,  -- Display name, used as utm_campaign unless one is set
+    utm_source VARCHAR(100),
+    utm_medium VARCHAR(100),
+    utm_campaign VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE urls ADD COLUMN campaign_id INTEGER REFERENCES campaigns(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_urls_campaign_id ON urls(campaign_id);
+
+COMMENT ON COLUMN urls.campaign_id IS 'Campaign whose UTM parameters are added to the destination on redirect';
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_urls_folder;
+DROP INDEX IF EXISTS idx_urls_tags;
+DROP INDEX IF EXISTS idx_urls_notes_trgm;
+DROP INDEX IF EXISTS idx_urls_title_trgm;
+DROP INDEX IF EXISTS idx_urls_long_url_trgm;
+DROP INDEX IF EXISTS idx_urls_search_vector;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE urls DROP COLUMN IF EXISTS tags;
+ALTER TABLE urls DROP COLUMN IF EXISTS folder;
+ALTER TABLE urls DROP COLUMN IF EXISTS notes;
+ALTER TABLE urls DROP COLUMN IF EXISTS title;
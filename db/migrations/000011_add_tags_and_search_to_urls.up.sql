@@ -0,0 +1,23 @@
+-- Organization of links in folders and tags, and indexes for searching them
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE urls ADD COLUMN title TEXT;
+ALTER TABLE urls ADD COLUMN notes TEXT;
+ALTER TABLE urls ADD COLUMN folder VARCHAR(255);
+ALTER TABLE urls ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE urls ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', short_code), 'A') ||
+    setweight(to_tsvector('simple', coalesce(notes, '')), 'B') ||
+    setweight(to_tsvector('simple', long_url), 'C')
+) STORED;
+
+CREATE INDEX idx_urls_search_vector ON urls USING GIN (search_vector);
+CREATE INDEX idx_urls_long_url_trgm ON urls USING GIN (long_url gin_trgm_ops);
+CREATE INDEX idx_urls_title_trgm ON urls USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_urls_notes_trgm ON urls USING GIN (notes gin_trgm_ops);
+CREATE INDEX idx_urls_tags ON urls USING GIN (tags);
+CREATE INDEX idx_urls_folder ON urls (folder text_pattern_ops);
+
+COMMENT ON COLUMN urls.folder IS 'Slash separated folder path, e.g. marketing/2026';
+COMMENT ON COLUMN urls.tags IS 'Lowercase tags, searched with the GIN index on tags @> filters';
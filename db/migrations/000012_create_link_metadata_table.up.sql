@@ -0,0 +1,9 @@
+-- Create 'link_metadata' table with the page details fetched from the destination of a link
+CREATE TABLE link_metadata (
+    url_id INTEGER PRIMARY KEY REFERENCES urls(id) ON DELETE CASCADE,
+    title VARCHAR(255),
+    description VARCHAR(1000),
+    image_url VARCHAR(2048),    -- OpenGraph image
+    favicon_url VARCHAR(2048),
+    fetched_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS link_health;
+
+DROP INDEX IF EXISTS idx_urls_broken_since;
+DROP INDEX IF EXISTS idx_urls_owner;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS broken_since;
+ALTER TABLE urls DROP COLUMN IF EXISTS fallback_url;
+ALTER TABLE urls DROP COLUMN IF EXISTS owner;
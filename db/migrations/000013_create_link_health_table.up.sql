@@ -0,0 +1,20 @@
+-- Owner and fallback of a link, and the results of the destination health checks
+ALTER TABLE urls ADD COLUMN owner VARCHAR(255);
+ALTER TABLE urls ADD COLUMN fallback_url VARCHAR(2048);
+ALTER TABLE urls ADD COLUMN broken_since TIMESTAMP;
+
+CREATE INDEX idx_urls_owner ON urls(owner);
+CREATE INDEX idx_urls_broken_since ON urls(broken_since) WHERE broken_since IS NOT NULL;
+
+COMMENT ON COLUMN urls.owner IS 'Person or team responsible for the link, broken links are reported per owner';
+COMMENT ON COLUMN urls.fallback_url IS 'Destination used instead of long_url while the link is broken';
+COMMENT ON COLUMN urls.broken_since IS 'Set by the health checker after consecutive failed checks of long_url';
+
+CREATE TABLE link_health (
+    url_id INTEGER PRIMARY KEY REFERENCES urls(id) ON DELETE CASCADE,
+    status_code INTEGER,                          -- NULL when no response was received
+    latency_ms INTEGER NOT NULL,
+    error VARCHAR(255),
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    checked_at TIMESTAMP NOT NULL
+);
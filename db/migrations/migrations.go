@@ -0,0 +1,289 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID identifies the advisory lock held while migrating, so only one runner changes the schema at a time
+const lockID = 2036154207
+
+// undefinedTable is the Postgres error code of a missing table
+const undefinedTable = "42P01"
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDirty is returned when a migration failed part way outside of a transaction, e.g. with the migrate CLI.
+// The schema has to be repaired by hand and the version set with force.
+var ErrDirty = errors.New("schema is dirty, repair it and run migrate force")
+
+// Migration is a numbered schema change and the statements reverting it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations in fsys, named NNNNNN_name.up.sql and NNNNNN_name.down.sql. Versions must
+// start at 1 without gaps and every migration needs both files.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, name := range names {
+		match := fileName.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNNNNN_name.up.sql or NNNNNN_name.down.sql", name)
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Status is the state of one migration
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies the embedded migrations. The version is kept in schema_migrations, the table of the
+// migrate CLI, so databases migrated before with the CLI carry on where they are.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	log        zerolog.Logger
+}
+
+// New returns a Migrator for the migrations embedded in the binary
+func New(db *sql.DB, log zerolog.Logger) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, log: log}, nil
+}
+
+// Latest is the version the schema has once every migration is applied
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last migration applied, 0 before the first one
+func (m *Migrator) Version(ctx context.Context) (version int, dirty bool, err error) {
+	return readVersion(ctx, m.db)
+}
+
+// Check fails when the schema is dirty or behind the embedded migrations. A newer schema is accepted,
+// so instances of the previous release keep serving while a rollout migrates the database.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d: %w", version, ErrDirty)
+	}
+	if version < m.Latest() {
+		return fmt.Errorf("schema version %d is behind %d, run migrate up", version, m.Latest())
+	}
+	return nil
+}
+
+// Status lists every migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration, Applied: migration.Version <= version}
+	}
+	return statuses, nil
+}
+
+// Up applies every migration not applied yet, each in a transaction of its own
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn, version int) error {
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Applied migration")
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps migrations applied
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *sql.Conn, version int) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Down, migration.Version-1); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Reverted migration")
+			steps--
+		}
+		return nil
+	})
+}
+
+// Force sets the version without running any migration and clears the dirty flag, after the schema
+// has been repaired by hand
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("version %d is not between 0 and %d", version, m.Latest())
+	}
+
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	return m.apply(ctx, conn, "", version)
+}
+
+// locked runs fn holding the migration lock, with the current version of a clean schema
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, version int) error) error {
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	// Read again under the lock, another runner may just have migrated
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d: %w", version, ErrDirty)
+	}
+	return fn(conn, version)
+}
+
+// lock takes the advisory lock on a connection of its own, the lock belongs to the session
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+		m.unlock(conn)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	// The context of the caller may be done already, the lock has to be released anyway
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+		m.log.Error().Err(err).Msg("Failed to release the migration lock")
+	}
+	conn.Close()
+}
+
+// apply runs statements and records version in the same transaction, so a failed migration leaves
+// the schema and its version as they were
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, statements string, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if statements != "" {
+		if _, err := tx.ExecContext(ctx, statements); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version > 0 {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func readVersion(ctx context.Context, q queryer) (version int, dirty bool, err error) {
+	err = q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) && pqErr.Code == undefinedTable {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
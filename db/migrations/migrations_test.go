@@ -0,0 +1,205 @@
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/migrations"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	migrations, err := migrations.Load(fstest.MapFS{
+		"000002_create_tasks.down.sql": file("DROP TABLE tasks;"),
+		"000001_create_urls.up.sql":    file("CREATE TABLE urls ();"),
+		"000002_create_tasks.up.sql":   file("CREATE TABLE tasks ();"),
+		"000001_create_urls.down.sql":  file("DROP TABLE urls;"),
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_urls", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE tasks;", migrations[1].Down)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{"Bad name", fstest.MapFS{"000001_create-urls.up.sql": file},
+			"migration 000001_create-urls.up.sql is not named NNNNNN_name.up.sql or NNNNNN_name.down.sql"},
+		{"Gap", fstest.MapFS{"000002_b.up.sql": file, "000002_b.down.sql": file},
+			"migration 1 is missing"},
+		{"Missing down", fstest.MapFS{"000001_a.up.sql": file},
+			"migration 1_a needs an up and a down file"},
+		{"Empty down", fstest.MapFS{"000001_a.up.sql": file, "000001_a.down.sql": &fstest.MapFile{}},
+			"migration 1_a needs an up and a down file"},
+		{"Renamed", fstest.MapFS{"000001_a.up.sql": file, "000001_b.down.sql": file},
+			"migration 1 is named both a and b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrations.Load(tt.fsys)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func newMigrator(t *testing.T) (*migrations.Migrator, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := migrations.New(conn, zerolog.Nop())
+	require.NoError(t, err)
+	return migrator, mock
+}
+
+func expectVersion(mock sqlmock.Sqlmock, version int, dirty bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(version, dirty))
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectApply(mock sqlmock.Sqlmock, statements string, version int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(statements).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)")).
+		WithArgs(version).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrator, _ := newMigrator(t)
+	assert.Equal(t, 13, migrator.Latest())
+}
+
+func TestUp(t *testing.T) {
+	migrator, mock := newMigrator(t)
+
+	expectLock(mock)
+	expectVersion(mock, 11, false)
+	expectApply(mock, "CREATE TABLE link_metadata", 12)
+	expectApply(mock, "CREATE TABLE link_health", 13)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_FailedMigrationIsRolledBack(t *testing.T) {
+	migrator, mock := newMigrator(t)
+
+	expectLock(mock)
+	expectVersion(mock, 12, false)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE link_health").WillReturnError(errors.New(`relation "link_health" already exists`))
+	mock.ExpectRollback()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := migrator.Up(context.Background())
+	assert.EqualError(t, err, `migration 13_create_link_health_table: relation "link_health" already exists`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_Dirty(t *testing.T) {
+	migrator, mock := newMigrator(t)
+
+	expectLock(mock)
+	expectVersion(mock, 7, true)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, migrator.Up(context.Background()), migrations.ErrDirty)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	migrator, mock := newMigrator(t)
+
+	expectLock(mock)
+	expectVersion(mock, 13, false)
+	expectApply(mock, "DROP TABLE IF EXISTS link_health", 12)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE IF EXISTS link_metadata").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(11).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, migrator.Down(context.Background(), 2))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForce(t *testing.T) {
+	migrator, mock := newMigrator(t)
+
+	expectLock(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, migrator.Force(context.Background(), 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualError(t, migrator.Force(context.Background(), 14), "version 14 is not between 0 and 13")
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		dirty   bool
+		wantErr string
+	}{
+		{"Current", 13, false, ""},
+		{"Newer", 14, false, ""},
+		{"Behind", 12, false, "schema version 12 is behind 13, run migrate up"},
+		{"Dirty", 13, true, "migration 13: schema is dirty, repair it and run migrate force"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator, mock := newMigrator(t)
+			expectVersion(mock, tt.version, tt.dirty)
+
+			err := migrator.Check(context.Background())
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatus_NeverMigrated(t *testing.T) {
+	migrator, mock := newMigrator(t)
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnError(&pq.Error{Code: "42P01", Message: `relation "schema_migrations" does not exist`})
+
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 13)
+	assert.Equal(t, "setup_table", statuses[0].Name)
+	assert.False(t, statuses[12].Applied)
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+// Loader is the part of cachemanager.Loader links are cached with
+type Loader interface {
+	Get(ctx context.Context, key string, ttl int, load func(ctx context.Context) (string, bool, error)) (string, bool, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// cachedRepository serves GetUrl from a cache, short codes that do not exist included. The updates made
+// through it delete the cached link, changes made elsewhere (moderation) have to invalidate it with
+// LinkCacheKey or wait for the TTL. Health checks go through WithHealthCache.
+type cachedRepository struct {
+	UrlRepository
+	loader Loader
+	ttl    int
+}
+
+// cachedUrl is the cache encoding of a Url, including the fields kept out of API responses
+type cachedUrl struct {
+	Url
+	PasswordHash sql.NullString `json:"passwordHash"`
+	RoutingRules sql.NullString `json:"routingRules"`
+}
+
+// WithCache wraps repo so links are read through loader and kept in its cache for ttl minutes
+func WithCache(repo UrlRepository, loader Loader, ttl int) UrlRepository {
+	return &cachedRepository{UrlRepository: repo, loader: loader, ttl: ttl}
+}
+
+// LinkCacheKey is the key the link of shortCode is cached under
+func LinkCacheKey(shortCode string) string {
+	return constants.LINK_CACHE_KEY_PREFIX + shortCode
+}
+
+// GetUrl reads the link through the cache. Short codes that do not exist return sql.ErrNoRows like the
+// repository does.
+func (c *cachedRepository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
+	data, found, err := c.loader.Get(ctx, LinkCacheKey(shortCode), c.ttl, func(ctx context.Context) (string, bool, error) {
+		url, err := c.UrlRepository.GetUrl(ctx, shortCode)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		data, err := json.Marshal(cachedUrl{Url: url, PasswordHash: url.PasswordHash, RoutingRules: url.RoutingRules})
+		return string(data), true, err
+	})
+	if err != nil {
+		return Url{}, err
+	}
+	if !found {
+		return Url{}, sql.ErrNoRows
+	}
+
+	var cached cachedUrl
+	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+		return Url{}, err
+	}
+	url := cached.Url
+	url.PasswordHash, url.RoutingRules = cached.PasswordHash, cached.RoutingRules
+	return url, nil
+}
+
+// invalidate deletes the cached link after shortCode was created or updated. The change is kept when the
+// cache fails, the link is then stale until its TTL expires.
+func (c *cachedRepository) invalidate(ctx context.Context, shortCode string, err error) error {
+	if err == nil {
+		c.loader.Delete(ctx, LinkCacheKey(shortCode))
+	}
+	return err
+}
+
+// created forgets that a new short code did not exist, a scanner may have probed it before
+func (c *cachedRepository) created(ctx context.Context, shortCode *string, err error) (*string, error) {
+	if err == nil && shortCode != nil {
+		c.invalidate(ctx, *shortCode, nil)
+	}
+	return shortCode, err
+}
+
+func (c *cachedRepository) CreateUrl(ctx context.Context, longUrl string) (*string, error) {
+	shortCode, err := c.UrlRepository.CreateUrl(ctx, longUrl)
+	return c.created(ctx, shortCode, err)
+}
+
+func (c *cachedRepository) CreateUrlWithOptions(ctx context.Context, longUrl string, opts LinkOptions) (*string, error) {
+	shortCode, err := c.UrlRepository.CreateUrlWithOptions(ctx, longUrl, opts)
+	return c.created(ctx, shortCode, err)
+}
+
+func (c *cachedRepository) UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error {
+	return c.invalidate(ctx, shortCode, c.UrlRepository.UpdatePassthrough(ctx, shortCode, p))
+}
+
+func (c *cachedRepository) UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error {
+	return c.invalidate(ctx, shortCode, c.UrlRepository.UpdateLinkDetails(ctx, shortCode, d))
+}
+
+func (c *cachedRepository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	return c.invalidate(ctx, shortCode, c.UrlRepository.UpdateFallbackUrl(ctx, shortCode, fallbackUrl))
+}
+
+// cachedHealthRepository deletes the cached link when a health check marks it broken or working again, so
+// redirects switch to the fallback without waiting for the TTL
+type cachedHealthRepository struct {
+	HealthRepository
+	loader Loader
+}
+
+// WithHealthCache wraps repo so the links cached by WithCache follow their health
+func WithHealthCache(repo HealthRepository, loader Loader) HealthRepository {
+	return &cachedHealthRepository{HealthRepository: repo, loader: loader}
+}
+
+func (c *cachedHealthRepository) RecordCheck(ctx context.Context, link Url, check types.HealthCheck, threshold int) (bool, error) {
+	changed, err := c.HealthRepository.RecordCheck(ctx, link, check, threshold)
+	if err == nil && changed {
+		c.loader.Delete(ctx, LinkCacheKey(link.ShortCode.String))
+	}
+	return changed, err
+}
+
+// cachedTargetRepository serves GetTargets from a cache, so redirects of split links and of links without
+// targets alike do not query link_targets. ReplaceTargets deletes the cached targets.
+type cachedTargetRepository struct {
+	TargetRepository
+	loader Loader
+	ttl    int
+}
+
+// WithTargetCache wraps repo so targets are read through loader and kept in its cache for ttl minutes
+func WithTargetCache(repo TargetRepository, loader Loader, ttl int) TargetRepository {
+	return &cachedTargetRepository{TargetRepository: repo, loader: loader, ttl: ttl}
+}
+
+// TargetsCacheKey is the key the split targets of shortCode are cached under
+func TargetsCacheKey(shortCode string) string {
+	return constants.TARGETS_CACHE_KEY_PREFIX + shortCode
+}
+
+func (c *cachedTargetRepository) GetTargets(ctx context.Context, shortCode string) ([]types.LinkTarget, error) {
+	data, _, err := c.loader.Get(ctx, TargetsCacheKey(shortCode), c.ttl, func(ctx context.Context) (string, bool, error) {
+		targets, err := c.TargetRepository.GetTargets(ctx, shortCode)
+		if err != nil {
+			return "", false, err
+		}
+		data, err := json.Marshal(targets)
+		return string(data), true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []types.LinkTarget{}
+	if err := json.Unmarshal([]byte(data), &targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (c *cachedTargetRepository) ReplaceTargets(ctx context.Context, shortCode string, targets []types.LinkTarget) error {
+	err := c.TargetRepository.ReplaceTargets(ctx, shortCode, targets)
+	if err == nil {
+		c.loader.Delete(ctx, TargetsCacheKey(shortCode))
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCache(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	cache := cachemanager.NewMemoryCache(10)
+	repo := repository.WithCache(mockRepo, cachemanager.NewLoader(cache, cachemanager.DefaultLoaderOptions()), 5)
+
+	stored := repository.Url{
+		ShortCode:    sql.NullString{String: "abc123", Valid: true},
+		LongUrl:      sql.NullString{String: "https://example.com", Valid: true},
+		PasswordHash: sql.NullString{String: "$2a$10$hash", Valid: true},
+		RoutingRules: sql.NullString{String: `[]`, Valid: true},
+		Tags:         []string{"docs"},
+	}
+	mockRepo.On("GetUrl", "abc123").Return(stored, nil).Once()
+	mockRepo.On("GetUrl", "missing").Return(repository.Url{}, sql.ErrNoRows).Once()
+
+	url, err := repo.GetUrl(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, stored, url)
+
+	// Served from the cache, with the fields kept out of API responses
+	url, err = repo.GetUrl(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, stored, url)
+	mockRepo.AssertNumberOfCalls(t, "GetUrl", 1)
+
+	// Codes that do not exist are remembered too, a scanner probing them does not reach the database
+	for range 3 {
+		_, err = repo.GetUrl(ctx, "missing")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetUrl", 2)
+}
+
+func TestWithCache_CreateForgetsMissingCode(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	repo := repository.WithCache(mockRepo, cachemanager.NewLoader(cachemanager.NewMemoryCache(10), cachemanager.DefaultLoaderOptions()), 5)
+
+	code := "abc123"
+	stored := repository.Url{ShortCode: sql.NullString{String: code, Valid: true}}
+	mockRepo.On("GetUrl", code).Return(repository.Url{}, sql.ErrNoRows).Once()
+	mockRepo.On("CreateUrl", "https://example.com").Return(&code, nil)
+	mockRepo.On("GetUrl", code).Return(stored, nil).Once()
+
+	_, err := repo.GetUrl(ctx, code)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = repo.CreateUrl(ctx, "https://example.com")
+	require.NoError(t, err)
+	url, err := repo.GetUrl(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, stored, url)
+}
+
+func TestWithCache_UpdatesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	cache := cachemanager.NewMemoryCache(10)
+	repo := repository.WithCache(mockRepo, cachemanager.NewLoader(cache, cachemanager.DefaultLoaderOptions()), 5)
+
+	mockRepo.On("UpdateFallbackUrl", "abc123", "https://example.com/fallback").Return(nil)
+	mockRepo.On("UpdateFallbackUrl", "nope", "").Return(sql.ErrNoRows)
+	cache.Set(ctx, repository.LinkCacheKey("abc123"), "{}", 5)
+	cache.Set(ctx, repository.LinkCacheKey("nope"), "{}", 5)
+
+	require.NoError(t, repo.UpdateFallbackUrl(ctx, "abc123", "https://example.com/fallback"))
+	_, err := cache.Get(ctx, repository.LinkCacheKey("abc123"))
+	assert.ErrorIs(t, err, cachemanager.ErrMiss)
+
+	// A failed update leaves the cached link alone
+	assert.ErrorIs(t, repo.UpdateFallbackUrl(ctx, "nope", ""), sql.ErrNoRows)
+	_, err = cache.Get(ctx, repository.LinkCacheKey("nope"))
+	assert.NoError(t, err)
+}
+
+func TestWithHealthCache(t *testing.T) {
+	ctx := context.Background()
+	mockHealth := new(mocks.MockHealthRepository)
+	cache := cachemanager.NewMemoryCache(10)
+	health := repository.WithHealthCache(mockHealth, cachemanager.NewLoader(cache, cachemanager.DefaultLoaderOptions()))
+
+	broken := repository.Url{ShortCode: sql.NullString{String: "abc123", Valid: true}}
+	still := repository.Url{ShortCode: sql.NullString{String: "xyz789", Valid: true}}
+	check := types.HealthCheck{StatusCode: 404}
+	mockHealth.On("RecordCheck", broken, check, 3).Return(true, nil)
+	mockHealth.On("RecordCheck", still, check, 3).Return(false, nil)
+	cache.Set(ctx, repository.LinkCacheKey("abc123"), "{}", 5)
+	cache.Set(ctx, repository.LinkCacheKey("xyz789"), "{}", 5)
+
+	// Marked broken, the next redirect reads it again and goes to the fallback
+	changed, err := health.RecordCheck(ctx, broken, check, 3)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, err = cache.Get(ctx, repository.LinkCacheKey("abc123"))
+	assert.ErrorIs(t, err, cachemanager.ErrMiss)
+
+	// Nothing changed, the cached link is kept
+	_, err = health.RecordCheck(ctx, still, check, 3)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, repository.LinkCacheKey("xyz789"))
+	assert.NoError(t, err)
+}
+
+func TestWithTargetCache(t *testing.T) {
+	ctx := context.Background()
+	mockTargets := new(mocks.MockTargetRepository)
+	repo := repository.WithTargetCache(mockTargets, cachemanager.NewLoader(cachemanager.NewMemoryCache(10), cachemanager.DefaultLoaderOptions()), 5)
+
+	targets := []types.LinkTarget{{Name: "a", Url: "https://example.com/a", Weight: 70}, {Name: "b", Url: "https://example.com/b", Weight: 30}}
+	mockTargets.On("GetTargets", "abc123").Return(targets, nil).Once()
+	mockTargets.On("GetTargets", "plain1").Return([]types.LinkTarget{}, nil).Once()
+
+	// Redirects ask the database once, links without targets included
+	for range 3 {
+		got, err := repo.GetTargets(ctx, "abc123")
+		require.NoError(t, err)
+		assert.Equal(t, targets, got)
+
+		got, err = repo.GetTargets(ctx, "plain1")
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	}
+	mockTargets.AssertNumberOfCalls(t, "GetTargets", 2)
+
+	// Replaced targets are read again
+	replaced := []types.LinkTarget{{Name: "a", Url: "https://example.com/a", Weight: 100}}
+	mockTargets.On("ReplaceTargets", "abc123", replaced).Return(nil)
+	mockTargets.On("GetTargets", "abc123").Return(replaced, nil).Once()
+	require.NoError(t, repo.ReplaceTargets(ctx, "abc123", replaced))
+	got, err := repo.GetTargets(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, replaced, got)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+type CampaignRepository interface {
+	CreateCampaign(ctx context.Context, campaign types.Campaign) (*types.Campaign, error)
+	GetCampaign(ctx context.Context, id int) (types.Campaign, error)
+	ListCampaigns(ctx context.Context) ([]types.Campaign, error)
+}
+
+func NewCampaignRepository(con db.Database) CampaignRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// CreateCampaign stores a campaign and returns it with its id and creation time
+func (r *Repository) CreateCampaign(ctx context.Context, campaign types.Campaign) (*types.Campaign, error) {
+	campaign.CreatedAt = time.Now().UTC()
+
+	err := r.DB.QueryRowContext(ctx,
+		"INSERT INTO campaigns (name, utm_source, utm_medium, utm_campaign, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		campaign.Name, nullString(campaign.UtmSource), nullString(campaign.UtmMedium), nullString(campaign.UtmCampaign), campaign.CreatedAt,
+	).Scan(&campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *Repository) GetCampaign(ctx context.Context, id int) (types.Campaign, error) {
+	row := r.DB.QueryRowContext(ctx, "SELECT id, name, utm_source, utm_medium, utm_campaign, created_at FROM campaigns WHERE id = $1", id)
+	return scanCampaign(row)
+}
+
+// ListCampaigns returns every campaign ordered by name
+func (r *Repository) ListCampaigns(ctx context.Context) ([]types.Campaign, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id, name, utm_source, utm_medium, utm_campaign, created_at FROM campaigns ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := []types.Campaign{}
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+func scanCampaign(row interface{ Scan(dest ...any) error }) (types.Campaign, error) {
+	var campaign types.Campaign
+	var source, medium, name sql.NullString
+	if err := row.Scan(&campaign.ID, &campaign.Name, &source, &medium, &name, &campaign.CreatedAt); err != nil {
+		return types.Campaign{}, err
+	}
+	campaign.UtmSource, campaign.UtmMedium, campaign.UtmCampaign = source.String, medium.String, name.String
+	return campaign, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
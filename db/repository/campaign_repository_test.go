@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCampaign(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewCampaignRepository(mockDB)
+
+	mock.ExpectQuery("INSERT INTO campaigns \\(name, utm_source, utm_medium, utm_campaign, created_at\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5\\) RETURNING id").
+		WithArgs("Spring Sale", sql.NullString{String: "newsletter", Valid: true}, sql.NullString{String: "email", Valid: true}, sql.NullString{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	campaign, err := repo.CreateCampaign(context.Background(), types.Campaign{Name: "Spring Sale", UtmSource: "newsletter", UtmMedium: "email"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, campaign.ID)
+	assert.Equal(t, "Spring Sale", campaign.Name)
+	assert.False(t, campaign.CreatedAt.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCampaign(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewCampaignRepository(mockDB)
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT id, name, utm_source, utm_medium, utm_campaign, created_at FROM campaigns WHERE id = \\$1").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "utm_source", "utm_medium", "utm_campaign", "created_at"}).
+				AddRow(3, "Spring Sale", "newsletter", nil, "spring-2026", createdAt))
+
+		campaign, err := repo.GetCampaign(context.Background(), 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.Campaign{ID: 3, Name: "Spring Sale", UtmSource: "newsletter", UtmCampaign: "spring-2026", CreatedAt: createdAt}, campaign)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT id, name, utm_source, utm_medium, utm_campaign, created_at FROM campaigns WHERE id = \\$1").
+			WithArgs(4).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetCampaign(context.Background(), 4)
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListCampaigns(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewCampaignRepository(mockDB)
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT id, name, utm_source, utm_medium, utm_campaign, created_at FROM campaigns ORDER BY name").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "utm_source", "utm_medium", "utm_campaign", "created_at"}).
+			AddRow(1, "Autumn", "print", "flyer", nil, createdAt).
+			AddRow(3, "Spring Sale", "newsletter", nil, nil, createdAt))
+
+	campaigns, err := repo.ListCampaigns(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Campaign{
+		{ID: 1, Name: "Autumn", UtmSource: "print", UtmMedium: "flyer", CreatedAt: createdAt},
+		{ID: 3, Name: "Spring Sale", UtmSource: "newsletter", CreatedAt: createdAt},
+	}, campaigns)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
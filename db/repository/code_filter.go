@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// CodeFilter knows which short codes may exist, see bloomfilter.Guard
+type CodeFilter interface {
+	// MayExist reports whether shortCode may exist, false is definite
+	MayExist(shortCode string) bool
+	// FalsePositive counts a code that passed but does not exist
+	FalsePositive(shortCode string)
+	Add(ctx context.Context, shortCode string)
+}
+
+// filteredRepository turns down short codes that do not exist before the cache or the database are asked
+type filteredRepository struct {
+	UrlRepository
+	filter CodeFilter
+}
+
+// WithCodeFilter wraps repo so GetUrl returns sql.ErrNoRows for the codes filter knows do not exist.
+// Codes created through it are added to filter.
+func WithCodeFilter(repo UrlRepository, filter CodeFilter) UrlRepository {
+	return &filteredRepository{UrlRepository: repo, filter: filter}
+}
+
+func (f *filteredRepository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
+	if !f.filter.MayExist(shortCode) {
+		return Url{}, sql.ErrNoRows
+	}
+	url, err := f.UrlRepository.GetUrl(ctx, shortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		f.filter.FalsePositive(shortCode)
+	}
+	return url, err
+}
+
+func (f *filteredRepository) created(ctx context.Context, shortCode *string, err error) (*string, error) {
+	if err == nil && shortCode != nil {
+		f.filter.Add(ctx, *shortCode)
+	}
+	return shortCode, err
+}
+
+func (f *filteredRepository) CreateUrl(ctx context.Context, longUrl string) (*string, error) {
+	shortCode, err := f.UrlRepository.CreateUrl(ctx, longUrl)
+	return f.created(ctx, shortCode, err)
+}
+
+func (f *filteredRepository) CreateUrlWithOptions(ctx context.Context, longUrl string, opts LinkOptions) (*string, error) {
+	shortCode, err := f.UrlRepository.CreateUrlWithOptions(ctx, longUrl, opts)
+	return f.created(ctx, shortCode, err)
+}
@@ -0,0 +1,43 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/bloomfilter"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCodeFilter(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	guard := bloomfilter.NewGuard(bloomfilter.New(bloomfilter.Options{ExpectedItems: 100, FalsePositiveRate: 0.001}), nil, nil, zerolog.Nop())
+	mockRepo.On("ListUrls", int64(0), 5000).Return([]repository.Url{{ShortCode: sql.NullString{String: "abc123", Valid: true}}}, nil)
+	require.NoError(t, guard.Build(ctx, mockRepo))
+	repo := repository.WithCodeFilter(mockRepo, guard)
+
+	stored := repository.Url{ShortCode: sql.NullString{String: "abc123", Valid: true}}
+	mockRepo.On("GetUrl", "abc123").Return(stored, nil)
+	url, err := repo.GetUrl(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, stored, url)
+
+	// Turned down without a lookup
+	_, err = repo.GetUrl(ctx, "zzzzzz")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	mockRepo.AssertNotCalled(t, "GetUrl", "zzzzzz")
+
+	// Created codes are found right away
+	code := "new001"
+	mockRepo.On("CreateUrl", "https://example.com").Return(&code, nil)
+	mockRepo.On("GetUrl", code).Return(repository.Url{ShortCode: sql.NullString{String: code, Valid: true}}, nil)
+	_, err = repo.CreateUrl(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = repo.GetUrl(ctx, code)
+	assert.NoError(t, err)
+}
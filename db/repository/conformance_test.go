@@ -0,0 +1,244 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/migrations"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	_ "github.com/lib/pq"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backends returns a constructor of an empty repository for every storage backend available.
+// Postgres needs a database in TEST_POSTGRES_DSN.
+func backends(t *testing.T) map[string]func(t *testing.T) repository.UrlRepository {
+	backends := map[string]func(t *testing.T) repository.UrlRepository{
+		"memory": func(t *testing.T) repository.UrlRepository { return repository.NewMemoryRepository() },
+		"sqlite": func(t *testing.T) repository.UrlRepository {
+			conn, err := repository.OpenSqlite(":memory:")
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			repo, err := repository.NewSqliteRepository(context.Background(), conn)
+			require.NoError(t, err)
+			return repo
+		},
+	}
+
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) repository.UrlRepository {
+			conn, err := sql.Open("postgres", dsn)
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			migrator, err := migrations.New(conn, zerolog.Nop())
+			require.NoError(t, err)
+			require.NoError(t, migrator.Up(context.Background()))
+			_, err = conn.Exec("TRUNCATE urls, tasks RESTART IDENTITY CASCADE")
+			require.NoError(t, err)
+			return repository.NewRepository(conn)
+		}
+	}
+	return backends
+}
+
+// TestConformance runs the same cases against every backend, so they behave alike
+func TestConformance(t *testing.T) {
+	for name, newRepo := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("CreateUrl", func(t *testing.T) { testCreateUrl(t, newRepo(t)) })
+			t.Run("CreateUrlWithOptions", func(t *testing.T) { testCreateUrlWithOptions(t, newRepo(t)) })
+			t.Run("ListUrls", func(t *testing.T) { testListUrls(t, newRepo(t)) })
+			t.Run("Updates", func(t *testing.T) { testUpdates(t, newRepo(t)) })
+			t.Run("Tasks", func(t *testing.T) { testTasks(t, newRepo(t)) })
+			t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, newRepo(t)) })
+		})
+	}
+}
+
+func testCreateUrl(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+
+	first, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Len(t, *first, 6)
+
+	again, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, *first, *again, "plain links are shared")
+
+	other, err := repo.CreateUrl(ctx, "https://example.com/b")
+	require.NoError(t, err)
+	assert.NotEqual(t, *first, *other)
+
+	url, err := repo.GetUrl(ctx, *first)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", url.LongUrl.String)
+	assert.Equal(t, constants.URL_STATUS_ACTIVE, url.Status.String)
+	assert.Equal(t, constants.QUERY_CONFLICT_DEFAULT, url.QueryConflict.String)
+	assert.Equal(t, []string{}, url.Tags)
+	assert.WithinDuration(t, time.Now(), url.CreatedAt.Time, time.Minute)
+
+	long, err := repo.GetLongUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, *first, long.ShortCode.String)
+
+	_, err = repo.GetUrl(ctx, "nope")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repo.GetLongUrl(ctx, "https://example.com/missing")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testCreateUrlWithOptions(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+	activeFrom := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	shortCode, err := repo.CreateUrlWithOptions(ctx, "https://example.com/a", repository.LinkOptions{
+		MaxClicks:    10,
+		ActiveFrom:   &activeFrom,
+		RoutingRules: []byte(`[]`),
+		Passthrough:  repository.Passthrough{ForwardQuery: true, Prefix: true},
+		Details:      repository.LinkDetails{Title: "Docs", Folder: "team", Tags: []string{"a", "b"}, Owner: "web"},
+		FallbackUrl:  "https://example.com/fallback",
+	})
+	require.NoError(t, err)
+
+	url, err := repo.GetUrl(ctx, *shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), url.MaxClicks.Int64)
+	assert.True(t, activeFrom.Equal(url.ActiveFrom.Time))
+	assert.JSONEq(t, `[]`, url.RoutingRules.String)
+	assert.True(t, url.ForwardQuery.Bool)
+	assert.True(t, url.IsPrefix.Bool)
+	assert.Equal(t, constants.QUERY_CONFLICT_DEFAULT, url.QueryConflict.String)
+	assert.Equal(t, "Docs", url.Title.String)
+	assert.False(t, url.Notes.Valid)
+	assert.Equal(t, []string{"a", "b"}, url.Tags)
+	assert.Equal(t, "https://example.com/fallback", url.FallbackUrl.String)
+
+	// A link with options is never handed out for a plain one
+	_, err = repo.GetLongUrl(ctx, "https://example.com/a")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	plain, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.NotEqual(t, *shortCode, *plain)
+}
+
+func testListUrls(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+
+	var created []string
+	for i := range 5 {
+		shortCode, err := repo.CreateUrl(ctx, fmt.Sprintf("https://example.com/%d", i))
+		require.NoError(t, err)
+		created = append(created, *shortCode)
+	}
+
+	page, err := repo.ListUrls(ctx, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Equal(t, created[0], page[0].ShortCode.String)
+
+	rest, err := repo.ListUrls(ctx, page[2].ID.Int64, 3)
+	require.NoError(t, err)
+	require.Len(t, rest, 2)
+	assert.Equal(t, created[3], rest[0].ShortCode.String)
+	assert.Equal(t, "https://example.com/4", rest[1].LongUrl.String)
+
+	empty, err := repo.ListUrls(ctx, rest[1].ID.Int64, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []repository.Url{}, empty)
+}
+
+func testUpdates(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+	shortCode, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SyncClickCount(ctx, *shortCode, 7))
+	require.NoError(t, repo.SyncClickCount(ctx, *shortCode, 3))
+	require.NoError(t, repo.UpdatePassthrough(ctx, *shortCode, repository.Passthrough{ForwardQuery: true, QueryConflict: "incoming"}))
+	require.NoError(t, repo.UpdateLinkDetails(ctx, *shortCode, repository.LinkDetails{Notes: "n", Tags: []string{"x"}}))
+	require.NoError(t, repo.UpdateFallbackUrl(ctx, *shortCode, "https://example.com/fallback"))
+
+	url, err := repo.GetUrl(ctx, *shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), url.ClickCount.Int64, "click counts never go back")
+	assert.True(t, url.ForwardQuery.Bool)
+	assert.Equal(t, "incoming", url.QueryConflict.String)
+	assert.Equal(t, "n", url.Notes.String)
+	assert.Equal(t, []string{"x"}, url.Tags)
+	assert.Equal(t, "https://example.com/fallback", url.FallbackUrl.String)
+
+	// Edited links are no longer handed out for plain ones
+	_, err = repo.GetLongUrl(ctx, "https://example.com/a")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	plain, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.NotEqual(t, *shortCode, *plain)
+
+	require.NoError(t, repo.UpdateFallbackUrl(ctx, *shortCode, ""))
+	url, err = repo.GetUrl(ctx, *shortCode)
+	require.NoError(t, err)
+	assert.False(t, url.FallbackUrl.Valid)
+
+	assert.ErrorIs(t, repo.UpdatePassthrough(ctx, "nope", repository.Passthrough{}), sql.ErrNoRows)
+	assert.ErrorIs(t, repo.UpdateLinkDetails(ctx, "nope", repository.LinkDetails{}), sql.ErrNoRows)
+	assert.ErrorIs(t, repo.UpdateFallbackUrl(ctx, "nope", ""), sql.ErrNoRows)
+}
+
+func testTasks(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+
+	task, err := repo.CreateTaskId(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", task.Status)
+
+	got, err := repo.GetTask(ctx, task.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", got.Status)
+	assert.Empty(t, got.Result)
+
+	require.NoError(t, repo.UpdateTask(ctx, task.TaskID, "completed", json.RawMessage(`{"urls":[]}`)))
+	got, err = repo.GetTask(ctx, task.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", got.Status)
+	assert.JSONEq(t, `{"urls":[]}`, string(got.Result))
+
+	_, err = repo.GetTask(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testConcurrent(t *testing.T, repo repository.UrlRepository) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	codes := make([]string, 20)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shortCode, err := repo.CreateUrl(ctx, fmt.Sprintf("https://example.com/%d", i))
+			if assert.NoError(t, err) {
+				codes[i] = *shortCode
+				assert.NoError(t, repo.SyncClickCount(ctx, *shortCode, int64(i)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	urls, err := repo.ListUrls(ctx, 0, 100)
+	require.NoError(t, err)
+	assert.Len(t, urls, len(codes))
+	slices.Sort(codes)
+	assert.Len(t, slices.Compact(codes), len(urls), "short codes are unique")
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+type HealthRepository interface {
+	ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]Url, error)
+	RecordCheck(ctx context.Context, link Url, check types.HealthCheck, threshold int) (bool, error)
+	ListBrokenLinks(ctx context.Context, owner string, limit int, offset int) ([]BrokenLink, error)
+}
+
+// BrokenLink is a link marked broken by the health checker with the result of its last check
+type BrokenLink struct {
+	Url
+	Check               types.HealthCheck
+	ConsecutiveFailures int
+}
+
+func NewHealthRepository(con db.Database) HealthRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// ListCheckTargets returns up to limit active links with an id above afterID in id order, see ListUrls
+func (r *Repository) ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id, short_code, long_url FROM urls WHERE id > $1 AND status = $2 ORDER BY id LIMIT $3", afterID, constants.URL_STATUS_ACTIVE, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := []Url{}
+	for rows.Next() {
+		var url Url
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.LongUrl); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// RecordCheck stores the result of a health check of a link's long URL. A link is marked broken once
+// threshold checks in a row have failed, and is no longer broken after the first successful check. It
+// reports whether the link was marked broken or working again.
+func (r *Repository) RecordCheck(ctx context.Context, link Url, check types.HealthCheck, threshold int) (bool, error) {
+	var changed bool
+	err := r.DB.QueryRowContext(ctx, "WITH health AS (INSERT INTO link_health (url_id, status_code, latency_ms, error, consecutive_failures, checked_at) VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN 0 ELSE 1 END, $6) ON CONFLICT (url_id) DO UPDATE SET status_code = EXCLUDED.status_code, latency_ms = EXCLUDED.latency_ms, error = EXCLUDED.error, checked_at = EXCLUDED.checked_at, consecutive_failures = CASE WHEN $5 THEN 0 ELSE link_health.consecutive_failures + 1 END RETURNING url_id, consecutive_failures) UPDATE urls SET broken_since = CASE WHEN health.consecutive_failures >= $7 THEN COALESCE(urls.broken_since, $6) END FROM health, urls old WHERE urls.id = health.url_id AND old.id = urls.id RETURNING (old.broken_since IS NULL) <> (urls.broken_since IS NULL)",
+		link.ID.Int64, sql.NullInt64{Int64: int64(check.StatusCode), Valid: check.StatusCode > 0}, check.LatencyMs, nullString(check.Error), check.OK, check.CheckedAt, threshold,
+	).Scan(&changed)
+	if err == sql.ErrNoRows {
+		// The link was deleted meanwhile
+		return false, nil
+	}
+	return changed, err
+}
+
+// ListBrokenLinks returns a page of broken links ordered by owner, links without an owner last, and by how
+// long they have been broken. An empty owner returns the links of every owner.
+func (r *Repository) ListBrokenLinks(ctx context.Context, owner string, limit int, offset int) ([]BrokenLink, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT u.id, u.short_code, u.long_url, u.password_hash, u.owner, u.fallback_url, u.broken_since, h.status_code, h.latency_ms, h.error, h.consecutive_failures, h.checked_at FROM urls u JOIN link_health h ON h.url_id = u.id WHERE u.broken_since IS NOT NULL AND ($1 = '' OR u.owner = $1) ORDER BY u.owner NULLS LAST, u.broken_since, u.id LIMIT $2 OFFSET $3", owner, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []BrokenLink{}
+	for rows.Next() {
+		var (
+			link       BrokenLink
+			statusCode sql.NullInt64
+			checkError sql.NullString
+		)
+		err := rows.Scan(&link.ID, &link.ShortCode, &link.LongUrl, &link.PasswordHash, &link.Owner, &link.FallbackUrl, &link.BrokenSince,
+			&statusCode, &link.Check.LatencyMs, &checkError, &link.ConsecutiveFailures, &link.Check.CheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		link.Check.StatusCode = int(statusCode.Int64)
+		link.Check.Error = checkError.String
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
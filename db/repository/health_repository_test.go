@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCheckTargets(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewHealthRepository(mockDB)
+
+	mock.ExpectQuery("SELECT id, short_code, long_url FROM urls WHERE id > \\$1 AND status = \\$2 ORDER BY id LIMIT \\$3").
+		WithArgs(int64(10), "active", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url"}).
+			AddRow(11, "abc123", "https://example.com/a").
+			AddRow(14, "def456", "https://example.com/b"))
+
+	urls, err := repo.ListCheckTargets(context.Background(), 10, 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+	assert.Equal(t, int64(14), urls[1].ID.Int64)
+	assert.Equal(t, "https://example.com/b", urls[1].LongUrl.String)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordCheck(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewHealthRepository(mockDB)
+	checkedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	link := repository.Url{ID: sql.NullInt64{Int64: 11, Valid: true}}
+
+	t.Run("Failure", func(t *testing.T) {
+		mock.ExpectQuery("WITH health AS \\(INSERT INTO link_health .* ON CONFLICT \\(url_id\\) DO UPDATE .* RETURNING url_id, consecutive_failures\\) UPDATE urls SET broken_since = CASE WHEN health.consecutive_failures >= \\$7 THEN COALESCE\\(urls.broken_since, \\$6\\) END FROM health, urls old WHERE urls.id = health.url_id AND old.id = urls.id RETURNING \\(old.broken_since IS NULL\\) <> \\(urls.broken_since IS NULL\\)").
+			WithArgs(int64(11), sql.NullInt64{Int64: 404, Valid: true}, int64(120), sql.NullString{}, false, checkedAt, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"changed"}).AddRow(true))
+
+		changed, err := repo.RecordCheck(context.Background(), link, types.HealthCheck{StatusCode: 404, LatencyMs: 120, CheckedAt: checkedAt}, 3)
+
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No Response", func(t *testing.T) {
+		mock.ExpectQuery("WITH health AS").
+			WithArgs(int64(11), sql.NullInt64{}, int64(5000), sql.NullString{String: "timeout", Valid: true}, false, checkedAt, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"changed"}).AddRow(false))
+
+		changed, err := repo.RecordCheck(context.Background(), link, types.HealthCheck{LatencyMs: 5000, Error: "timeout", CheckedAt: checkedAt}, 3)
+
+		assert.NoError(t, err)
+		assert.False(t, changed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListBrokenLinks(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewHealthRepository(mockDB)
+	brokenSince := time.Date(2026, 2, 27, 3, 0, 0, 0, time.UTC)
+	checkedAt := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT u.id, u.short_code, .* FROM urls u JOIN link_health h ON h.url_id = u.id WHERE u.broken_since IS NOT NULL AND \\(\\$1 = '' OR u.owner = \\$1\\) ORDER BY u.owner NULLS LAST, u.broken_since, u.id LIMIT \\$2 OFFSET \\$3").
+		WithArgs("growth-team", 50, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url", "password_hash", "owner", "fallback_url", "broken_since", "status_code", "latency_ms", "error", "consecutive_failures", "checked_at"}).
+			AddRow(11, "abc123", "https://example.com/gone", nil, "growth-team", nil, brokenSince, 404, 87, nil, 4, checkedAt).
+			AddRow(14, "def456", "https://down.example.com/", nil, "growth-team", "https://example.com/", brokenSince, nil, 10000, "timeout", 3, checkedAt))
+
+	links, err := repo.ListBrokenLinks(context.Background(), "growth-team", 50, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Equal(t, "abc123", links[0].ShortCode.String)
+	assert.Equal(t, types.HealthCheck{StatusCode: 404, LatencyMs: 87, CheckedAt: checkedAt}, links[0].Check)
+	assert.Equal(t, 4, links[0].ConsecutiveFailures)
+	assert.Equal(t, "timeout", links[1].Check.Error)
+	assert.Equal(t, "https://example.com/", links[1].FallbackUrl.String)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/lib/pq"
+)
+
+// LinkQuery filters and pages links in SearchLinks. Empty fields do not filter.
+type LinkQuery struct {
+	// Text is matched with full-text search over title, short code, notes and long URL, and as a substring
+	// of long URL, title and notes through the trigram indexes
+	Text string
+	// Tags must all be present on a link
+	Tags []string
+	// Folder matches links in the folder and in its sub folders
+	Folder string
+	Limit  int
+	Offset int
+}
+
+// LinkPage is a page of search results with the total number of matches and the most used tags among them
+type LinkPage struct {
+	Links  []Url
+	Total  int
+	Facets []types.TagFacet
+}
+
+type LinkRepository interface {
+	SearchLinks(ctx context.Context, q LinkQuery) (LinkPage, error)
+}
+
+func NewLinkRepository(con db.Database) LinkRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// linkFilter restricts urls to the matches of a LinkQuery, see linkFilterArgs for the parameters
+const linkFilter = `($1 = '' OR search_vector @@ websearch_to_tsquery('simple', $1) OR short_code = $1
+		OR long_url ILIKE $2 OR title ILIKE $2 OR notes ILIKE $2)
+	AND tags @> $3 AND ($4 = '' OR folder = $4 OR folder LIKE $5)`
+
+func linkFilterArgs(q LinkQuery) []any {
+	return []any{
+		q.Text,
+		"%" + escapeLike(q.Text) + "%",
+		pq.Array(tagsOrEmpty(q.Tags)),
+		q.Folder,
+		escapeLike(q.Folder) + "/%",
+	}
+}
+
+// SearchLinks returns a page of the links matching q, best matches first when searching for text and
+// newest first otherwise, together with the total number of matches and their tag facets
+func (r *Repository) SearchLinks(ctx context.Context, q LinkQuery) (LinkPage, error) {
+	args := linkFilterArgs(q)
+	page := LinkPage{Links: []Url{}, Facets: []types.TagFacet{}}
+
+	if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM urls WHERE "+linkFilter, args...).Scan(&page.Total); err != nil {
+		return LinkPage{}, err
+	}
+	if page.Total == 0 {
+		return page, nil
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT id, short_code, long_url, created_at, status, password_hash, title, notes, folder, tags FROM urls WHERE `+linkFilter+`
+		ORDER BY CASE WHEN $1 = '' THEN 0 ELSE ts_rank(search_vector, websearch_to_tsquery('simple', $1)) + similarity(long_url, $1) END DESC, created_at DESC, id DESC
+		LIMIT $6 OFFSET $7`,
+		append(args, q.Limit, q.Offset)...,
+	)
+	if err != nil {
+		return LinkPage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url Url
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt, &url.Status, &url.PasswordHash, &url.Title, &url.Notes, &url.Folder, pq.Array(&url.Tags)); err != nil {
+			return LinkPage{}, err
+		}
+		page.Links = append(page.Links, url)
+	}
+	if err := rows.Err(); err != nil {
+		return LinkPage{}, err
+	}
+
+	facets, err := r.DB.QueryContext(ctx, "SELECT tag, COUNT(*) FROM urls CROSS JOIN LATERAL unnest(tags) AS tag WHERE "+linkFilter+" GROUP BY tag ORDER BY COUNT(*) DESC, tag LIMIT $6",
+		append(args, constants.TAG_FACETS_MAX)...,
+	)
+	if err != nil {
+		return LinkPage{}, err
+	}
+	defer facets.Close()
+
+	for facets.Next() {
+		var facet types.TagFacet
+		if err := facets.Scan(&facet.Tag, &facet.Count); err != nil {
+			return LinkPage{}, err
+		}
+		page.Facets = append(page.Facets, facet)
+	}
+	return page, facets.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
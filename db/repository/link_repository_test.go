@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchLinks(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewLinkRepository(mockDB)
+	query := repository.LinkQuery{Text: "50%_off", Tags: []string{"sale"}, Folder: "marketing", Limit: 10, Offset: 20}
+	filterArgs := []driver.Value{"50%_off", `%50\%\_off%`, pq.Array([]string{"sale"}), "marketing", "marketing/%"}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM urls WHERE \\(\\$1 = '' OR search_vector @@ websearch_to_tsquery\\('simple', \\$1\\)").
+			WithArgs(filterArgs...).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at, status, password_hash, title, notes, folder, tags FROM urls WHERE .* ORDER BY .* LIMIT \\$6 OFFSET \\$7").
+			WithArgs(append(filterArgs, 10, 20)...).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at", "status", "password_hash", "title", "notes", "folder", "tags"}).
+				AddRow(1, "abc123", "https://example.com/sale", time.Now(), "active", nil, "Sale", nil, "marketing/2026", "{sale,spring}"))
+
+		mock.ExpectQuery("SELECT tag, COUNT\\(\\*\\) FROM urls CROSS JOIN LATERAL unnest\\(tags\\) AS tag WHERE .* GROUP BY tag ORDER BY COUNT\\(\\*\\) DESC, tag LIMIT \\$6").
+			WithArgs(append(filterArgs, 20)...).
+			WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}).AddRow("sale", 21).AddRow("spring", 4))
+
+		page, err := repo.SearchLinks(context.Background(), query)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 21, page.Total)
+		if assert.Len(t, page.Links, 1) {
+			assert.Equal(t, "abc123", page.Links[0].ShortCode.String)
+			assert.Equal(t, "Sale", page.Links[0].Title.String)
+			assert.Equal(t, []string{"sale", "spring"}, page.Links[0].Tags)
+		}
+		assert.Equal(t, []types.TagFacet{{Tag: "sale", Count: 21}, {Tag: "spring", Count: 4}}, page.Facets)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No Matches", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM urls WHERE").
+			WithArgs(filterArgs...).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		page, err := repo.SearchLinks(context.Background(), query)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, page.Total)
+		assert.Empty(t, page.Links)
+		assert.Empty(t, page.Facets)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
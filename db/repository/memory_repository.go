@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gofrs/uuid"
+)
+
+// MemoryRepository keeps links and tasks in memory, for local development and tests. Everything is lost
+// when the process exits.
+type MemoryRepository struct {
+	mutex  sync.RWMutex
+	lastID int64
+	urls   map[string]*Url // by short code
+	tasks  map[string]types.Task
+}
+
+func NewMemoryRepository() UrlRepository {
+	return &MemoryRepository{
+		urls:  make(map[string]*Url),
+		tasks: make(map[string]types.Task),
+	}
+}
+
+// copyUrl returns url without sharing its tags, so callers cannot change the stored link
+func copyUrl(url *Url) Url {
+	c := *url
+	c.Tags = slices.Clone(url.Tags)
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	return c
+}
+
+// summary keeps the columns GetLongUrl and ListUrls read
+func summary(url *Url) Url {
+	return Url{ID: url.ID, ShortCode: url.ShortCode, LongUrl: url.LongUrl, CreatedAt: url.CreatedAt}
+}
+
+// isPlain reports whether url has none of the options that keep CreateUrl from sharing it
+func isPlain(url *Url) bool {
+	return !url.PasswordHash.Valid && !url.MaxClicks.Valid && !url.ActiveFrom.Valid && !url.RoutingRules.Valid &&
+		!url.ForwardQuery.Bool && !url.IsPrefix.Bool && !url.CampaignID.Valid && !url.Notes.Valid && !url.Folder.Valid &&
+		len(url.Tags) == 0 && !url.Owner.Valid && !url.FallbackUrl.Valid
+}
+
+// insert stores url under a short code not taken yet. The mutex must be held.
+func (r *MemoryRepository) insert(url Url) *string {
+	shortCode := GenerateShortCode(6)
+	for r.urls[shortCode] != nil {
+		shortCode = GenerateShortCode(6)
+	}
+
+	r.lastID++
+	url.ID = sql.NullInt64{Int64: r.lastID, Valid: true}
+	url.ShortCode = sql.NullString{String: shortCode, Valid: true}
+	url.CreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	url.Status = sql.NullString{String: constants.URL_STATUS_ACTIVE, Valid: true}
+	url.ClickCount = sql.NullInt64{Valid: true}
+	url.ForwardQuery.Valid = true
+	url.IsPrefix.Valid = true
+	if !url.QueryConflict.Valid {
+		url.QueryConflict = sql.NullString{String: constants.QUERY_CONFLICT_DEFAULT, Valid: true}
+	}
+	url.Tags = slices.Clone(tagsOrEmpty(url.Tags))
+	r.urls[shortCode] = &url
+	return &shortCode
+}
+
+func (r *MemoryRepository) CreateUrl(ctx context.Context, longUrl string) (*string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if url := r.plainLink(longUrl); url != nil {
+		shortCode := url.ShortCode.String
+		return &shortCode, nil
+	}
+	return r.insert(Url{LongUrl: sql.NullString{String: longUrl, Valid: true}}), nil
+}
+
+func (r *MemoryRepository) CreateUrlWithOptions(ctx context.Context, longUrl string, opts LinkOptions) (*string, error) {
+	url := Url{
+		LongUrl:      sql.NullString{String: longUrl, Valid: true},
+		PasswordHash: nullString(opts.PasswordHash),
+		MaxClicks:    sql.NullInt64{Int64: opts.MaxClicks, Valid: opts.MaxClicks > 0},
+		RoutingRules: sql.NullString{String: string(opts.RoutingRules), Valid: len(opts.RoutingRules) > 0},
+		ForwardQuery: sql.NullBool{Bool: opts.Passthrough.ForwardQuery},
+		IsPrefix:     sql.NullBool{Bool: opts.Passthrough.Prefix},
+		CampaignID:   sql.NullInt64{Int64: int64(opts.CampaignID), Valid: opts.CampaignID > 0},
+		Title:        nullString(opts.Details.Title),
+		Notes:        nullString(opts.Details.Notes),
+		Folder:       nullString(opts.Details.Folder),
+		Tags:         opts.Details.Tags,
+		Owner:        nullString(opts.Details.Owner),
+		FallbackUrl:  nullString(opts.FallbackUrl),
+	}
+	if opts.ActiveFrom != nil {
+		url.ActiveFrom = sql.NullTime{Time: opts.ActiveFrom.UTC(), Valid: true}
+	}
+	url.QueryConflict = nullString(opts.Passthrough.QueryConflict)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.insert(url), nil
+}
+
+func (r *MemoryRepository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	url, ok := r.urls[shortCode]
+	if !ok {
+		return Url{}, sql.ErrNoRows
+	}
+	return copyUrl(url), nil
+}
+
+// plainLink returns the oldest link without options for longUrl. The mutex must be held.
+func (r *MemoryRepository) plainLink(longUrl string) *Url {
+	var found *Url
+	for _, url := range r.urls {
+		if url.LongUrl.String == longUrl && isPlain(url) && (found == nil || url.ID.Int64 < found.ID.Int64) {
+			found = url
+		}
+	}
+	return found
+}
+
+func (r *MemoryRepository) GetLongUrl(ctx context.Context, longUrl string) (Url, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	url := r.plainLink(longUrl)
+	if url == nil {
+		return Url{}, sql.ErrNoRows
+	}
+	return summary(url), nil
+}
+
+func (r *MemoryRepository) ListUrls(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	urls := []Url{}
+	for _, url := range r.urls {
+		if url.ID.Int64 > afterID {
+			urls = append(urls, summary(url))
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ID.Int64 < urls[j].ID.Int64 })
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+func (r *MemoryRepository) SyncClickCount(ctx context.Context, shortCode string, count int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if url, ok := r.urls[shortCode]; ok {
+		url.ClickCount.Int64 = max(url.ClickCount.Int64, count)
+	}
+	return nil
+}
+
+// update changes the link of shortCode with fn, or returns sql.ErrNoRows
+func (r *MemoryRepository) update(shortCode string, fn func(url *Url)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	url, ok := r.urls[shortCode]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	fn(url)
+	return nil
+}
+
+func (r *MemoryRepository) UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error {
+	conflict := p.QueryConflict
+	if conflict == "" {
+		conflict = constants.QUERY_CONFLICT_DEFAULT
+	}
+	return r.update(shortCode, func(url *Url) {
+		url.ForwardQuery.Bool = p.ForwardQuery
+		url.QueryConflict.String = conflict
+		url.IsPrefix.Bool = p.Prefix
+	})
+}
+
+func (r *MemoryRepository) UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error {
+	return r.update(shortCode, func(url *Url) {
+		url.Title = nullString(d.Title)
+		url.Notes = nullString(d.Notes)
+		url.Folder = nullString(d.Folder)
+		url.Tags = slices.Clone(tagsOrEmpty(d.Tags))
+		url.Owner = nullString(d.Owner)
+	})
+}
+
+func (r *MemoryRepository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	return r.update(shortCode, func(url *Url) {
+		url.FallbackUrl = nullString(fallbackUrl)
+	})
+}
+
+func (r *MemoryRepository) CreateTaskId(ctx context.Context) (*types.Task, error) {
+	task := types.Task{TaskID: uuid.Must(uuid.NewV4()).String(), Status: "pending", CreatedAt: time.Now().UTC()}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tasks[task.TaskID] = task
+	return &task, nil
+}
+
+func (r *MemoryRepository) GetTask(ctx context.Context, taskId string) (types.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	task, ok := r.tasks[taskId]
+	if !ok {
+		return types.Task{}, sql.ErrNoRows
+	}
+	task.Result = slices.Clone(task.Result)
+	return task, nil
+}
+
+func (r *MemoryRepository) UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if task, ok := r.tasks[taskId]; ok {
+		task.Status = status
+		task.Result = nil
+		if len(result) > 0 {
+			task.Result = slices.Clone(result)
+		}
+		r.tasks[taskId] = task
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+type MetadataRepository interface {
+	SaveMetadata(ctx context.Context, shortCode string, metadata types.LinkMetadata) error
+	GetMetadata(ctx context.Context, shortCode string) (types.LinkMetadata, error)
+}
+
+func NewMetadataRepository(con db.Database) MetadataRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// SaveMetadata stores the page details fetched for a link, replacing earlier ones. The page title also
+// becomes the title of the link unless it already has one. It returns sql.ErrNoRows if the short code does not exist.
+func (r *Repository) SaveMetadata(ctx context.Context, shortCode string, metadata types.LinkMetadata) error {
+	res, err := r.DB.ExecContext(ctx, "WITH link AS (UPDATE urls SET title = COALESCE(title, $2) WHERE short_code = $1 RETURNING id) INSERT INTO link_metadata (url_id, title, description, image_url, favicon_url, fetched_at) SELECT id, $2, $3, $4, $5, $6 FROM link ON CONFLICT (url_id) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description, image_url = EXCLUDED.image_url, favicon_url = EXCLUDED.favicon_url, fetched_at = EXCLUDED.fetched_at",
+		shortCode, nullString(metadata.Title), nullString(metadata.Description), nullString(metadata.Image), nullString(metadata.Favicon), metadata.FetchedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// GetMetadata returns the page details of a link. It returns sql.ErrNoRows if none have been fetched.
+func (r *Repository) GetMetadata(ctx context.Context, shortCode string) (types.LinkMetadata, error) {
+	var (
+		metadata                           types.LinkMetadata
+		title, description, image, favicon sql.NullString
+	)
+	err := r.DB.QueryRowContext(ctx, "SELECT m.title, m.description, m.image_url, m.favicon_url, m.fetched_at FROM link_metadata m JOIN urls u ON u.id = m.url_id WHERE u.short_code = $1", shortCode).
+		Scan(&title, &description, &image, &favicon, &metadata.FetchedAt)
+	if err != nil {
+		return types.LinkMetadata{}, err
+	}
+
+	metadata.Title = title.String
+	metadata.Description = description.String
+	metadata.Image = image.String
+	metadata.Favicon = favicon.String
+	return metadata, nil
+}
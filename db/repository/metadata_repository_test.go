@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveMetadata(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewMetadataRepository(mockDB)
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	metadata := types.LinkMetadata{Title: "Example", Favicon: "https://example.com/favicon.ico", FetchedAt: fetchedAt}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("WITH link AS \\(UPDATE urls SET title = COALESCE\\(title, \\$2\\) WHERE short_code = \\$1 RETURNING id\\) INSERT INTO link_metadata .* ON CONFLICT \\(url_id\\) DO UPDATE").
+			WithArgs("abc123", sql.NullString{String: "Example", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{String: "https://example.com/favicon.ico", Valid: true}, fetchedAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.SaveMetadata(context.Background(), "abc123", metadata)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectExec("WITH link AS").
+			WithArgs("missing", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), fetchedAt).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.SaveMetadata(context.Background(), "missing", metadata)
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetMetadata(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewMetadataRepository(mockDB)
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT m.title, m.description, m.image_url, m.favicon_url, m.fetched_at FROM link_metadata m JOIN urls u ON u.id = m.url_id WHERE u.short_code = \\$1").
+			WithArgs("abc123").
+			WillReturnRows(sqlmock.NewRows([]string{"title", "description", "image_url", "favicon_url", "fetched_at"}).
+				AddRow("Example", nil, "https://example.com/cover.png", "https://example.com/favicon.ico", fetchedAt))
+
+		metadata, err := repo.GetMetadata(context.Background(), "abc123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.LinkMetadata{Title: "Example", Image: "https://example.com/cover.png", Favicon: "https://example.com/favicon.ico", FetchedAt: fetchedAt}, metadata)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT m.title").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetMetadata(context.Background(), "missing")
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
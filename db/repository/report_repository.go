@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+type ReportRepository interface {
+	CreateReport(ctx context.Context, shortCode string, reason string, reporter string) (*types.Report, error)
+	GetReport(ctx context.Context, id int) (types.Report, error)
+	ListReports(ctx context.Context, status string, limit int, offset int) ([]types.Report, error)
+	CountOpenReporters(ctx context.Context, shortCode string) (int, error)
+	UpdateReportStatus(ctx context.Context, id int, status string) error
+	TakedownReport(ctx context.Context, id int) error
+	SetUrlStatus(ctx context.Context, shortCode string, status string) error
+}
+
+func NewReportRepository(con db.Database) ReportRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// CreateReport stores an open report against the link identified by shortCode.
+// It returns sql.ErrNoRows if the short code does not exist.
+func (r *Repository) CreateReport(ctx context.Context, shortCode string, reason string, reporter string) (*types.Report, error) {
+	tn := time.Now().UTC()
+	report := types.Report{
+		ShortCode: shortCode,
+		Reason:    reason,
+		Status:    constants.REPORT_STATUS_OPEN,
+		CreatedAt: tn,
+	}
+
+	err := r.DB.QueryRowContext(ctx,
+		"INSERT INTO reports (url_id, reason, reporter, status, created_at) SELECT id, $2, $3, $4, $5 FROM urls WHERE short_code = $1 RETURNING id",
+		shortCode, reason, reporter, constants.REPORT_STATUS_OPEN, tn,
+	).Scan(&report.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *Repository) GetReport(ctx context.Context, id int) (types.Report, error) {
+	var report types.Report
+	var reviewedAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, "SELECT r.id, u.short_code, r.reason, r.status, r.created_at, r.reviewed_at FROM reports r JOIN urls u ON u.id = r.url_id WHERE r.id = $1", id).
+		Scan(&report.ID, &report.ShortCode, &report.Reason, &report.Status, &report.CreatedAt, &reviewedAt)
+	if err != nil {
+		return types.Report{}, err
+	}
+	if reviewedAt.Valid {
+		report.ReviewedAt = &reviewedAt.Time
+	}
+	return report, nil
+}
+
+// ListReports returns reports newest first. An empty status returns reports in every status.
+func (r *Repository) ListReports(ctx context.Context, status string, limit int, offset int) ([]types.Report, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT r.id, u.short_code, r.reason, r.status, r.created_at, r.reviewed_at FROM reports r JOIN urls u ON u.id = r.url_id WHERE ($1 = '' OR r.status = $1) ORDER BY r.created_at DESC LIMIT $2 OFFSET $3", status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []types.Report{}
+	for rows.Next() {
+		var report types.Report
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&report.ID, &report.ShortCode, &report.Reason, &report.Status, &report.CreatedAt, &reviewedAt); err != nil {
+			return nil, err
+		}
+		if reviewedAt.Valid {
+			report.ReviewedAt = &reviewedAt.Time
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// CountOpenReporters counts the independent reporters with an open report against a link
+func (r *Repository) CountOpenReporters(ctx context.Context, shortCode string) (int, error) {
+	var count int
+	err := r.DB.QueryRowContext(ctx, "SELECT COUNT(DISTINCT r.reporter) FROM reports r JOIN urls u ON u.id = r.url_id WHERE u.short_code = $1 AND r.status = $2", shortCode, constants.REPORT_STATUS_OPEN).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateReportStatus moves an open report to status. Reports that were already reviewed keep their
+// status, so the review history is not overwritten.
+// It returns sql.ErrNoRows if the report does not exist or is no longer open.
+func (r *Repository) UpdateReportStatus(ctx context.Context, id int, status string) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE reports SET status = $1, reviewed_at = $2 WHERE id = $3 AND status = $4", status, time.Now().UTC(), id, constants.REPORT_STATUS_OPEN)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// TakedownReport takes the link of a report down and marks the report and every open report against the
+// link as actioned, in a single statement so a failure leaves neither half done.
+// It returns sql.ErrNoRows if the report does not exist.
+func (r *Repository) TakedownReport(ctx context.Context, id int) error {
+	res, err := r.DB.ExecContext(ctx, "WITH link AS (UPDATE urls SET status = $1 FROM reports WHERE reports.id = $2 AND urls.id = reports.url_id RETURNING urls.id) UPDATE reports SET status = $3, reviewed_at = $4 FROM link WHERE reports.url_id = link.id AND (reports.id = $2 OR reports.status = $5)",
+		constants.URL_STATUS_TAKEN_DOWN, id, constants.REPORT_STATUS_ACTIONED, time.Now().UTC(), constants.REPORT_STATUS_OPEN,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *Repository) SetUrlStatus(ctx context.Context, shortCode string, status string) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET status = $1 WHERE short_code = $2", status, shortCode)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// expectAffected returns sql.ErrNoRows when an update did not match any row
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
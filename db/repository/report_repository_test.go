@@ -0,0 +1,137 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateReport(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewReportRepository(mockDB)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO reports \\(url_id, reason, reporter, status, created_at\\) SELECT id, \\$2, \\$3, \\$4, \\$5 FROM urls WHERE short_code = \\$1 RETURNING id").
+			WithArgs("abc123", "phishing", "reporter-hash", "open", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+
+		report, err := repo.CreateReport(context.Background(), "abc123", "phishing", "reporter-hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 42, report.ID)
+		assert.Equal(t, "open", report.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Short Code", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO reports").
+			WithArgs("nope", "phishing", "reporter-hash", "open", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		report, err := repo.CreateReport(context.Background(), "nope", "phishing", "reporter-hash")
+
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Nil(t, report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCountOpenReporters(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewReportRepository(mockDB)
+
+	mock.ExpectQuery("SELECT COUNT\\(DISTINCT r.reporter\\) FROM reports r JOIN urls u ON u.id = r.url_id WHERE u.short_code = \\$1 AND r.status = \\$2").
+		WithArgs("abc123", "open").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountOpenReporters(context.Background(), "abc123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetUrlStatus(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewReportRepository(mockDB)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET status = \\$1 WHERE short_code = \\$2").
+			WithArgs("taken_down", "abc123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.SetUrlStatus(context.Background(), "abc123", "taken_down"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Short Code", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET status = \\$1 WHERE short_code = \\$2").
+			WithArgs("taken_down", "nope").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, sql.ErrNoRows, repo.SetUrlStatus(context.Background(), "nope", "taken_down"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateReportStatus(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewReportRepository(mockDB)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("UPDATE reports SET status = \\$1, reviewed_at = \\$2 WHERE id = \\$3 AND status = \\$4").
+			WithArgs("dismissed", sqlmock.AnyArg(), 7, "open").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.UpdateReportStatus(context.Background(), 7, "dismissed"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already Reviewed", func(t *testing.T) {
+		mock.ExpectExec("UPDATE reports SET status").
+			WithArgs("dismissed", sqlmock.AnyArg(), 8, "open").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, sql.ErrNoRows, repo.UpdateReportStatus(context.Background(), 8, "dismissed"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTakedownReport(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewReportRepository(mockDB)
+
+	t.Run("Success", func(t *testing.T) {
+		// The link and its reports change in one statement
+		mock.ExpectExec("WITH link AS \\(UPDATE urls SET status = \\$1 FROM reports WHERE reports.id = \\$2 AND urls.id = reports.url_id RETURNING urls.id\\) UPDATE reports SET status = \\$3, reviewed_at = \\$4 FROM link WHERE reports.url_id = link.id AND \\(reports.id = \\$2 OR reports.status = \\$5\\)").
+			WithArgs("taken_down", 7, "actioned", sqlmock.AnyArg(), "open").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		assert.NoError(t, repo.TakedownReport(context.Background(), 7))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Report", func(t *testing.T) {
+		mock.ExpectExec("WITH link AS \\(UPDATE urls SET status").
+			WithArgs("taken_down", 9, "actioned", sqlmock.AnyArg(), "open").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, sql.ErrNoRows, repo.TakedownReport(context.Background(), 9))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"time"
@@ -9,24 +10,98 @@ import (
 	"github.com/Dev-AustinPeter/url-shortner-go/db"
 	"github.com/Dev-AustinPeter/url-shortner-go/types"
 	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
 	"golang.org/toolchain/src/math/rand"
 )
 
 type Url struct {
-	ID        sql.NullInt64  `json:"id"`
-	ShortCode sql.NullString `json:"shortCode"`
-	LongUrl   sql.NullString `json:"longUrl"`
-	CreatedAt sql.NullTime   `json:"createdAt"`
+	ID            sql.NullInt64  `json:"id"`
+	ShortCode     sql.NullString `json:"shortCode"`
+	LongUrl       sql.NullString `json:"longUrl"`
+	CreatedAt     sql.NullTime   `json:"createdAt"`
+	Status        sql.NullString `json:"status"`
+	PasswordHash  sql.NullString `json:"-"`
+	MaxClicks     sql.NullInt64  `json:"maxClicks"`
+	ActiveFrom    sql.NullTime   `json:"activeFrom"`
+	ClickCount    sql.NullInt64  `json:"clickCount"`
+	RoutingRules  sql.NullString `json:"-"`
+	ForwardQuery  sql.NullBool   `json:"forwardQuery"`
+	QueryConflict sql.NullString `json:"queryConflict"`
+	IsPrefix      sql.NullBool   `json:"prefix"`
+	CampaignID    sql.NullInt64  `json:"campaignId"`
+	Title         sql.NullString `json:"title"`
+	Notes         sql.NullString `json:"notes"`
+	Folder        sql.NullString `json:"folder"`
+	Tags          []string       `json:"tags"`
+	Owner         sql.NullString `json:"owner"`
+	FallbackUrl   sql.NullString `json:"fallbackUrl"`
+	BrokenSince   sql.NullTime   `json:"brokenSince"`
+}
+
+// LinkOptions holds the optional settings of a link created with CreateUrlWithOptions
+type LinkOptions struct {
+	// PasswordHash protects the link with a password (see linkauth.HashPassword)
+	PasswordHash string
+	// MaxClicks stops the link after this many redirects, 0 means unlimited
+	MaxClicks int64
+	// ActiveFrom keeps the link from resolving before this time
+	ActiveFrom *time.Time
+	// RoutingRules is the JSON encoded list of routing rules (see routing.Rules)
+	RoutingRules []byte
+	Passthrough  Passthrough
+	// CampaignID adds the UTM parameters of the campaign on redirect, 0 means none
+	CampaignID int
+	Details    LinkDetails
+	// FallbackUrl replaces the long URL while the health checker considers it broken
+	FallbackUrl string
+	// Targets are the weighted destinations of a split link, stored in the same statement as the link by
+	// the repositories that also implement TargetRepository
+	Targets []types.LinkTarget
+}
+
+// LinkDetails holds the descriptive fields links are organized and searched by
+type LinkDetails struct {
+	Title  string
+	Notes  string
+	Folder string
+	Tags   []string
+	// Owner is the person or team responsible for the link
+	Owner string
+}
+
+// IsZero reports whether no detail is set
+func (d LinkDetails) IsZero() bool {
+	return d.Title == "" && d.Notes == "" && d.Folder == "" && len(d.Tags) == 0 && d.Owner == ""
+}
+
+// Passthrough controls which parts of the incoming request are carried over to the destination
+type Passthrough struct {
+	// ForwardQuery merges the incoming query parameters into the destination
+	ForwardQuery bool
+	// QueryConflict resolves parameters present on both sides (see passthrough.ConflictTarget)
+	QueryConflict string
+	// Prefix appends the path after the short code to the destination
+	Prefix bool
+}
+
+// IsZero reports whether p leaves redirects unchanged
+func (p Passthrough) IsZero() bool {
+	return !p.ForwardQuery && !p.Prefix
 }
 
 type UrlRepository interface {
-	CreateUrl(url string) (*string, error)
-	GetUrl(shortCode string) (Url, error)
-	GetLongUrl(longUrl string) (Url, error)
-	CreateTaskId() (*types.Task, error)
-	GetTask(taskId string) (types.Task, error)
-	UpdateTask(taskId string, status string, result json.RawMessage) error
-	GetAllUrls() ([]Url, error)
+	CreateUrl(ctx context.Context, url string) (*string, error)
+	CreateUrlWithOptions(ctx context.Context, url string, opts LinkOptions) (*string, error)
+	GetUrl(ctx context.Context, shortCode string) (Url, error)
+	GetLongUrl(ctx context.Context, longUrl string) (Url, error)
+	CreateTaskId(ctx context.Context) (*types.Task, error)
+	GetTask(ctx context.Context, taskId string) (types.Task, error)
+	UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error
+	ListUrls(ctx context.Context, afterID int64, limit int) ([]Url, error)
+	SyncClickCount(ctx context.Context, shortCode string, count int64) error
+	UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error
+	UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error
+	UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error
 }
 
 type Repository struct {
@@ -48,8 +123,10 @@ func GenerateShortCode(n int) string {
 	return string(b)
 }
 
-func (r *Repository) CreateUrl(LongUrl string) (*string, error) {
-	url, err := r.GetLongUrl(LongUrl)
+// CreateUrl returns the plain link of LongUrl, creating it when there is none. Requests racing to create
+// it end up with the same link, the unique index on the long URL of plain links turns the others down.
+func (r *Repository) CreateUrl(ctx context.Context, LongUrl string) (*string, error) {
+	url, err := r.GetLongUrl(ctx, LongUrl)
 	if err == nil && url.LongUrl.String == LongUrl {
 		return &url.ShortCode.String, nil
 	}
@@ -57,25 +134,77 @@ func (r *Repository) CreateUrl(LongUrl string) (*string, error) {
 	shortCode := GenerateShortCode(6)
 
 	tn := time.Now().UTC()
-	_, err = r.DB.Exec("INSERT INTO urls (short_code, long_url, created_at) VALUES ($1, $2, $3)", shortCode, LongUrl, tn)
+	res, err := r.DB.ExecContext(ctx, "INSERT INTO urls (short_code, long_url, created_at) VALUES ($1, $2, $3) ON CONFLICT (long_url) WHERE plain DO NOTHING", shortCode, LongUrl, tn)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		// Created by a concurrent request
+		if url, err = r.GetLongUrl(ctx, LongUrl); err != nil {
+			return nil, err
+		}
+		return &url.ShortCode.String, nil
+	}
+	return &shortCode, nil
+}
+
+// CreateUrlWithOptions always creates a new link, even if the long URL has been shortened before,
+// because links with options are not shared between users
+func (r *Repository) CreateUrlWithOptions(ctx context.Context, LongUrl string, opts LinkOptions) (*string, error) {
+	shortCode := GenerateShortCode(6)
+
+	tn := time.Now().UTC()
+	activeFrom := sql.NullTime{}
+	if opts.ActiveFrom != nil {
+		activeFrom = sql.NullTime{Time: opts.ActiveFrom.UTC(), Valid: true}
+	}
+
+	conflict := opts.Passthrough.QueryConflict
+	if conflict == "" {
+		conflict = constants.QUERY_CONFLICT_DEFAULT
+	}
+
+	targets, err := targetRows(opts.Targets)
+	if err != nil {
+		return nil, err
+	}
+
+	// A split link is never visible without its targets
+	_, err = r.DB.ExecContext(ctx, `WITH link AS (INSERT INTO urls (short_code, long_url, created_at, password_hash, max_clicks, active_from, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, plain) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, false) RETURNING id)
+		INSERT INTO link_targets (url_id, name, target_url, weight, position)
+		SELECT link.id, t.name, t.target_url, t.weight, t.position FROM link, jsonb_to_recordset($18::jsonb) AS t(name TEXT, target_url TEXT, weight INTEGER, position INTEGER)`,
+		shortCode, LongUrl, tn,
+		sql.NullString{String: opts.PasswordHash, Valid: opts.PasswordHash != ""},
+		sql.NullInt64{Int64: opts.MaxClicks, Valid: opts.MaxClicks > 0},
+		activeFrom,
+		sql.NullString{String: string(opts.RoutingRules), Valid: len(opts.RoutingRules) > 0},
+		opts.Passthrough.ForwardQuery, conflict, opts.Passthrough.Prefix,
+		sql.NullInt64{Int64: int64(opts.CampaignID), Valid: opts.CampaignID > 0},
+		nullString(opts.Details.Title), nullString(opts.Details.Notes), nullString(opts.Details.Folder), pq.Array(tagsOrEmpty(opts.Details.Tags)),
+		nullString(opts.Details.Owner), nullString(opts.FallbackUrl), targets,
+	)
 	if err != nil {
 		return nil, err
 	}
 	return &shortCode, nil
 }
 
-func (r *Repository) GetUrl(shortCode string) (Url, error) {
+func (r *Repository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
 	var url Url
-	err := r.DB.QueryRow("SELECT id, short_code, long_url, created_at FROM urls WHERE short_code = $1", shortCode).Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt)
+	err := r.DB.QueryRowContext(ctx, "SELECT id, short_code, long_url, created_at, status, password_hash, max_clicks, active_from, click_count, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, broken_since FROM urls WHERE short_code = $1", shortCode).
+		Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt, &url.Status, &url.PasswordHash, &url.MaxClicks, &url.ActiveFrom, &url.ClickCount, &url.RoutingRules,
+			&url.ForwardQuery, &url.QueryConflict, &url.IsPrefix, &url.CampaignID, &url.Title, &url.Notes, &url.Folder, pq.Array(&url.Tags),
+			&url.Owner, &url.FallbackUrl, &url.BrokenSince)
 	if err != nil {
 		return Url{}, err
 	}
 	return url, nil
 }
 
-func (r *Repository) GetLongUrl(longUrl string) (Url, error) {
+// GetLongUrl finds the plain link of longUrl, the link without options shared by everyone shortening it
+func (r *Repository) GetLongUrl(ctx context.Context, longUrl string) (Url, error) {
 	var url Url
-	err := r.DB.QueryRow("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = $1", longUrl).
+	err := r.DB.QueryRowContext(ctx, "SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = $1 AND plain", longUrl).
 		Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt)
 	if err != nil {
 		return Url{}, err
@@ -84,64 +213,111 @@ func (r *Repository) GetLongUrl(longUrl string) (Url, error) {
 
 }
 
-func (r *Repository) GetAllUrls() ([]Url, error) {
-	rows, err := r.DB.Query("SELECT id, short_code, long_url, created_at FROM urls")
+// ListUrls returns up to limit links with an id above afterID in id order. Passing the id of the last
+// link returned pages through every link without holding them all in memory.
+func (r *Repository) ListUrls(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id, short_code, long_url, created_at FROM urls WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var urls []Url
+
+	urls := []Url{}
 	for rows.Next() {
 		var url Url
-		err := rows.Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt)
-		if err != nil {
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt); err != nil {
 			return nil, err
 		}
 		urls = append(urls, url)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return urls, rows.Err()
+}
+
+// SyncClickCount stores the redirect count kept in Redis. The count never goes backwards, so
+// replicas reconciling the same link in any order end up with the highest count.
+func (r *Repository) SyncClickCount(ctx context.Context, shortCode string, count int64) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE urls SET click_count = GREATEST(click_count, $1) WHERE short_code = $2", count, shortCode)
+	return err
+}
+
+// UpdatePassthrough changes the query string and path passthrough settings of a link, which is then no
+// longer plain. It returns sql.ErrNoRows if the short code does not exist.
+func (r *Repository) UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error {
+	conflict := p.QueryConflict
+	if conflict == "" {
+		conflict = constants.QUERY_CONFLICT_DEFAULT
+	}
+
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET forward_query = $1, query_conflict = $2, is_prefix = $3, plain = false WHERE short_code = $4", p.ForwardQuery, conflict, p.Prefix, shortCode)
+	if err != nil {
+		return err
 	}
-	if len(urls) == 0 {
-		return nil, nil
+	return expectAffected(res)
+}
+
+// UpdateLinkDetails replaces the title, notes, folder, tags and owner of a link, which is then no longer
+// plain. It returns sql.ErrNoRows if the short code does not exist.
+func (r *Repository) UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET title = $1, notes = $2, folder = $3, tags = $4, owner = $5, plain = false WHERE short_code = $6",
+		nullString(d.Title), nullString(d.Notes), nullString(d.Folder), pq.Array(tagsOrEmpty(d.Tags)), nullString(d.Owner), shortCode,
+	)
+	if err != nil {
+		return err
 	}
+	return expectAffected(res)
+}
 
-	return urls, nil
+// UpdateFallbackUrl changes the destination used while a link is broken, an empty URL removes it. The
+// link is then no longer plain. It returns sql.ErrNoRows if the short code does not exist.
+func (r *Repository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET fallback_url = $1, plain = false WHERE short_code = $2", nullString(fallbackUrl), shortCode)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
 }
 
-func (r *Repository) CreateTaskId() (*types.Task, error) {
+func (r *Repository) CreateTaskId(ctx context.Context) (*types.Task, error) {
 	taskId := uuid.Must(uuid.NewV4()).String()
-	stm, err := r.DB.Prepare("INSERT INTO tasks (task_id, status, created_at) VALUES ($1, $2, $3)") // status is default to pending
+	stm, err := r.DB.PrepareContext(ctx, "INSERT INTO tasks (task_id, status, created_at) VALUES ($1, $2, $3)") // status is default to pending
 	if err != nil {
 		return nil, err
 	}
 	defer stm.Close()
 	tn := time.Now().UTC()
-	_, err = stm.Exec(taskId, "pending", tn)
+	_, err = stm.ExecContext(ctx, taskId, "pending", tn)
 	if err != nil {
 		return nil, err
 	}
 	return &types.Task{TaskID: taskId, Status: "pending", CreatedAt: tn}, nil
 }
 
-func (r *Repository) UpdateTask(taskId string, status string, result json.RawMessage) error {
-	stm, err := r.DB.Prepare("UPDATE tasks SET status = $1, result = CASE WHEN $2::text = '' THEN NULL ELSE $2::jsonb END WHERE task_id = $3")
+func (r *Repository) UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error {
+	stm, err := r.DB.PrepareContext(ctx, "UPDATE tasks SET status = $1, result = CASE WHEN $2::text = '' THEN NULL ELSE $2::jsonb END WHERE task_id = $3")
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(status, result, taskId)
+	_, err = stm.ExecContext(ctx, status, result, taskId)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *Repository) GetTask(taskId string) (types.Task, error) {
+func (r *Repository) GetTask(ctx context.Context, taskId string) (types.Task, error) {
 	var task types.Task
-	err := r.DB.QueryRow("SELECT task_id, status, result, created_at FROM tasks WHERE task_id = $1", taskId).Scan(&task.TaskID, &task.Status, &task.Result, &task.CreatedAt)
+	err := r.DB.QueryRowContext(ctx, "SELECT task_id, status, result, created_at FROM tasks WHERE task_id = $1", taskId).Scan(&task.TaskID, &task.Status, &task.Result, &task.CreatedAt)
 	if err != nil {
 		return types.Task{}, err
 	}
 	return task, nil
 }
+
+// tagsOrEmpty stores a missing tag list as an empty array, the column is NOT NULL
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -12,6 +13,7 @@ import (
 	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
 	"github.com/Dev-AustinPeter/url-shortner-go/types"
 	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,11 +36,11 @@ func TestGetLongUrl(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
 			AddRow(expectedUrl.ID.Int64, expectedUrl.ShortCode.String, expectedUrl.LongUrl.String, expectedUrl.CreatedAt.Time)
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnRows(rows)
 
-		url, err := repo.GetLongUrl(longUrl)
+		url, err := repo.GetLongUrl(context.Background(), longUrl)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUrl.ID.Int64, url.ID.Int64)
@@ -50,11 +52,11 @@ func TestGetLongUrl(t *testing.T) {
 	t.Run("Not Found", func(t *testing.T) {
 		longUrl := "https://example.com/non-existent"
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetLongUrl(longUrl)
+		_, err := repo.GetLongUrl(context.Background(), longUrl)
 
 		assert.Error(t, err)
 		assert.Equal(t, sql.ErrNoRows, err)
@@ -65,11 +67,11 @@ func TestGetLongUrl(t *testing.T) {
 		longUrl := "https://example.com/error-url"
 		dbErr := errors.New("database connection error")
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnError(dbErr)
 
-		_, err := repo.GetLongUrl(longUrl)
+		_, err := repo.GetLongUrl(context.Background(), longUrl)
 
 		assert.Error(t, err)
 		assert.Equal(t, dbErr, err)
@@ -91,12 +93,12 @@ func TestCreateUrl(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
 			AddRow(1, existingShortCode, longUrl, time.Now())
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnRows(rows)
 
 		// Call the function
-		shortCode, err := repo.CreateUrl(longUrl)
+		shortCode, err := repo.CreateUrl(context.Background(), longUrl)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -111,17 +113,17 @@ func TestCreateUrl(t *testing.T) {
 		longUrl := "https://example.com/new-url"
 
 		// Mock GetLongUrl query - simulate URL doesn't exist yet
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnError(sql.ErrNoRows)
 
 		// Mock the INSERT query with AnyArg for the short code
-		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT \\(long_url\\) WHERE plain DO NOTHING").
 			WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1)) // 1 row affected
 
 		// Call the function
-		shortCode, err := repo.CreateUrl(longUrl)
+		shortCode, err := repo.CreateUrl(context.Background(), longUrl)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -132,23 +134,47 @@ func TestCreateUrl(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("Created Concurrently", func(t *testing.T) {
+		longUrl := "https://example.com/raced-url"
+
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
+			WithArgs(longUrl).
+			WillReturnError(sql.ErrNoRows)
+
+		// Another request inserted it first, the unique index turns this insert down
+		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT \\(long_url\\) WHERE plain DO NOTHING").
+			WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
+			WithArgs(longUrl).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
+				AddRow(2, "won123", longUrl, time.Now()))
+
+		shortCode, err := repo.CreateUrl(context.Background(), longUrl)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "won123", *shortCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Database Insert Error", func(t *testing.T) {
 		// Setup
 		longUrl := "https://example.com/error-url"
 		dbErr := errors.New("insert error")
 
 		// Mock GetLongUrl query - simulate URL doesn't exist yet
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = \\$1 AND plain").
 			WithArgs(longUrl).
 			WillReturnError(sql.ErrNoRows)
 
 		// Mock the INSERT query with an error
-		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT \\(long_url\\) WHERE plain DO NOTHING").
 			WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg()).
 			WillReturnError(dbErr)
 
 		// Call the function
-		shortCode, err := repo.CreateUrl(longUrl)
+		shortCode, err := repo.CreateUrl(context.Background(), longUrl)
 
 		// Assert the results
 		assert.Error(t, err)
@@ -160,6 +186,176 @@ func TestCreateUrl(t *testing.T) {
 	})
 }
 
+func TestCreateUrlWithOptions(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	t.Run("Password Protected", func(t *testing.T) {
+		longUrl := "https://example.com/existing-url"
+
+		// No deduplication lookup: links with options are always new
+		mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at, password_hash, max_clicks, active_from, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, plain\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7, \\$8, \\$9, \\$10, \\$11, \\$12, \\$13, \\$14, \\$15, \\$16, \\$17, false\\)").
+			WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg(), sql.NullString{String: "bcrypt-hash", Valid: true}, sql.NullInt64{}, sql.NullTime{}, sql.NullString{}, false, "target", false, sql.NullInt64{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, pq.Array([]string{}), sql.NullString{}, sql.NullString{}, "[]").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		shortCode, err := repo.CreateUrlWithOptions(context.Background(), longUrl, repository.LinkOptions{PasswordHash: "bcrypt-hash"})
+
+		assert.NoError(t, err)
+		assert.Len(t, *shortCode, 6)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Split Targets", func(t *testing.T) {
+		longUrl := "https://example.com/a"
+		targets := []types.LinkTarget{{Name: "a", Url: "https://example.com/a", Weight: 70}, {Name: "b", Url: "https://example.com/b", Weight: 30}}
+
+		// The link and its targets are inserted by one statement
+		mock.ExpectExec("WITH link AS \\(INSERT INTO urls .* RETURNING id\\)\\s+INSERT INTO link_targets \\(url_id, name, target_url, weight, position\\)\\s+SELECT link.id, .* FROM link, jsonb_to_recordset\\(\\$18::jsonb\\)").
+			WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				`[{"name":"a","target_url":"https://example.com/a","weight":70,"position":0},{"name":"b","target_url":"https://example.com/b","weight":30,"position":1}]`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		shortCode, err := repo.CreateUrlWithOptions(context.Background(), longUrl, repository.LinkOptions{Targets: targets})
+
+		assert.NoError(t, err)
+		assert.Len(t, *shortCode, 6)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateUrlWithOptions_ClickLimits(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	longUrl := "https://example.com/giveaway"
+	activeFrom := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at, password_hash, max_clicks, active_from, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, plain\\)").
+		WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg(), sql.NullString{}, sql.NullInt64{Int64: 500, Valid: true}, sql.NullTime{Time: activeFrom, Valid: true}, sql.NullString{}, false, "target", false, sql.NullInt64{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, pq.Array([]string{}), sql.NullString{}, sql.NullString{}, "[]").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	shortCode, err := repo.CreateUrlWithOptions(context.Background(), longUrl, repository.LinkOptions{MaxClicks: 500, ActiveFrom: &activeFrom})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, shortCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUrlWithOptions_RoutingRules(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	longUrl := "https://example.com/app"
+	rules := `[{"name":"ios","target":"https://apps.apple.com/app/id1","os":["ios"]}]`
+
+	mock.ExpectExec("INSERT INTO urls \\(short_code, long_url, created_at, password_hash, max_clicks, active_from, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, plain\\)").
+		WithArgs(sqlmock.AnyArg(), longUrl, sqlmock.AnyArg(), sql.NullString{}, sql.NullInt64{}, sql.NullTime{}, sql.NullString{String: rules, Valid: true}, false, "target", false, sql.NullInt64{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, pq.Array([]string{}), sql.NullString{}, sql.NullString{}, "[]").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	shortCode, err := repo.CreateUrlWithOptions(context.Background(), longUrl, repository.LinkOptions{RoutingRules: []byte(rules)})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, shortCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUrlWithOptions_Passthrough(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	mock.ExpectExec("INSERT INTO urls").
+		WithArgs(sqlmock.AnyArg(), "https://example.com/docs", sqlmock.AnyArg(), sql.NullString{}, sql.NullInt64{}, sql.NullTime{}, sql.NullString{}, true, "append", true, sql.NullInt64{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, pq.Array([]string{}), sql.NullString{}, sql.NullString{}, "[]").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	shortCode, err := repo.CreateUrlWithOptions(context.Background(), "https://example.com/docs", repository.LinkOptions{
+		Passthrough: repository.Passthrough{ForwardQuery: true, QueryConflict: "append", Prefix: true},
+	})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, shortCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUrlWithOptions_Campaign(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	mock.ExpectExec("INSERT INTO urls").
+		WithArgs(sqlmock.AnyArg(), "https://example.com/sale", sqlmock.AnyArg(), sql.NullString{}, sql.NullInt64{}, sql.NullTime{}, sql.NullString{}, false, "target", false, sql.NullInt64{Int64: 7, Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}, pq.Array([]string{}), sql.NullString{}, sql.NullString{}, "[]").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	shortCode, err := repo.CreateUrlWithOptions(context.Background(), "https://example.com/sale", repository.LinkOptions{CampaignID: 7})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, shortCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUrlWithOptions_Details(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	mock.ExpectExec("INSERT INTO urls").
+		WithArgs(sqlmock.AnyArg(), "https://example.com/sale", sqlmock.AnyArg(), sql.NullString{}, sql.NullInt64{}, sql.NullTime{}, sql.NullString{}, false, "target", false, sql.NullInt64{},
+			sql.NullString{String: "Spring Sale", Valid: true}, sql.NullString{String: "Print flyer", Valid: true}, sql.NullString{String: "marketing", Valid: true}, pq.Array([]string{"sale", "print"}),
+			sql.NullString{String: "growth-team", Valid: true}, sql.NullString{String: "https://example.com/", Valid: true}, "[]").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	shortCode, err := repo.CreateUrlWithOptions(context.Background(), "https://example.com/sale", repository.LinkOptions{
+		Details:     repository.LinkDetails{Title: "Spring Sale", Notes: "Print flyer", Folder: "marketing", Tags: []string{"sale", "print"}, Owner: "growth-team"},
+		FallbackUrl: "https://example.com/",
+	})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, shortCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePassthrough(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	mock.ExpectExec("UPDATE urls SET forward_query = \\$1, query_conflict = \\$2, is_prefix = \\$3, plain = false WHERE short_code = \\$4").
+		WithArgs(true, "target", false, "abc123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UpdatePassthrough(context.Background(), "abc123", repository.Passthrough{ForwardQuery: true}))
+
+	mock.ExpectExec("UPDATE urls SET forward_query").
+		WithArgs(false, "target", false, "nope").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, sql.ErrNoRows, repo.UpdatePassthrough(context.Background(), "nope", repository.Passthrough{}))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncClickCount(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	mock.ExpectExec("UPDATE urls SET click_count = GREATEST\\(click_count, \\$1\\) WHERE short_code = \\$2").
+		WithArgs(int64(120), "abc123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.SyncClickCount(context.Background(), "abc123", 120))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetUrl(t *testing.T) {
 	mockDB, mock := mocks.NewMockDB()
 	defer mockDB.Close()
@@ -175,31 +371,48 @@ func TestGetUrl(t *testing.T) {
 			ShortCode: sql.NullString{String: "abc123", Valid: true},
 			LongUrl:   sql.NullString{String: longUrl, Valid: true},
 			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			Status:    sql.NullString{String: "active", Valid: true},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
-			AddRow(expectedUrl.ID.Int64, expectedUrl.ShortCode.String, expectedUrl.LongUrl.String, expectedUrl.CreatedAt.Time)
+		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at", "status", "password_hash", "max_clicks", "active_from", "click_count", "routing_rules", "forward_query", "query_conflict", "is_prefix", "campaign_id", "title", "notes", "folder", "tags", "owner", "fallback_url", "broken_since"}).
+			AddRow(expectedUrl.ID.Int64, expectedUrl.ShortCode.String, expectedUrl.LongUrl.String, expectedUrl.CreatedAt.Time, expectedUrl.Status.String, nil, 500, nil, 42, []byte(`[{"name":"ios"}]`), true, "incoming", false, 7, "Docs", nil, "marketing/2026", "{launch,docs}", "growth-team", nil, nil)
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE short_code = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at, status, password_hash, max_clicks, active_from, click_count, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, broken_since FROM urls WHERE short_code = \\$1").
 			WithArgs(shortCode).
 			WillReturnRows(rows)
 
-		url, err := repo.GetUrl(shortCode)
+		url, err := repo.GetUrl(context.Background(), shortCode)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUrl.ID.Int64, url.ID.Int64)
 		assert.Equal(t, expectedUrl.ShortCode.String, url.ShortCode.String)
 		assert.Equal(t, expectedUrl.LongUrl.String, url.LongUrl.String)
+		assert.Equal(t, expectedUrl.Status.String, url.Status.String)
+		assert.False(t, url.PasswordHash.Valid)
+		assert.Equal(t, int64(500), url.MaxClicks.Int64)
+		assert.False(t, url.ActiveFrom.Valid)
+		assert.Equal(t, int64(42), url.ClickCount.Int64)
+		assert.JSONEq(t, `[{"name":"ios"}]`, url.RoutingRules.String)
+		assert.True(t, url.ForwardQuery.Bool)
+		assert.Equal(t, "incoming", url.QueryConflict.String)
+		assert.False(t, url.IsPrefix.Bool)
+		assert.Equal(t, int64(7), url.CampaignID.Int64)
+		assert.Equal(t, "Docs", url.Title.String)
+		assert.False(t, url.Notes.Valid)
+		assert.Equal(t, "marketing/2026", url.Folder.String)
+		assert.Equal(t, []string{"launch", "docs"}, url.Tags)
+		assert.Equal(t, "growth-team", url.Owner.String)
+		assert.False(t, url.BrokenSince.Valid)
 	})
 
 	// Test when URL not found
 	t.Run("Not Found", func(t *testing.T) {
 		shortCode := "abc123"
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE short_code = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at, status, password_hash, max_clicks, active_from, click_count, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, broken_since FROM urls WHERE short_code = \\$1").
 			WithArgs(shortCode).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetUrl(shortCode)
+		_, err := repo.GetUrl(context.Background(), shortCode)
 
 		assert.Error(t, err)
 		assert.Equal(t, sql.ErrNoRows, err)
@@ -210,11 +423,11 @@ func TestGetUrl(t *testing.T) {
 		shortCode := "abc123"
 		dbErr := errors.New("database connection error")
 
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE short_code = \\$1").
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at, status, password_hash, max_clicks, active_from, click_count, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, broken_since FROM urls WHERE short_code = \\$1").
 			WithArgs(shortCode).
 			WillReturnError(dbErr)
 
-		_, err := repo.GetUrl(shortCode)
+		_, err := repo.GetUrl(context.Background(), shortCode)
 
 		assert.Error(t, err)
 		assert.Equal(t, dbErr, err)
@@ -237,7 +450,7 @@ func TestCreateTaskId(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// Call the function
-		task, err := repo.CreateTaskId()
+		task, err := repo.CreateTaskId(context.Background())
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -260,7 +473,7 @@ func TestCreateTaskId(t *testing.T) {
 			WillReturnError(prepareErr)
 
 		// Call the function
-		task, err := repo.CreateTaskId()
+		task, err := repo.CreateTaskId(context.Background())
 
 		// Assert the results
 		assert.Error(t, err)
@@ -282,7 +495,7 @@ func TestCreateTaskId(t *testing.T) {
 			WillReturnError(execErr)
 
 		// Call the function
-		task, err := repo.CreateTaskId()
+		task, err := repo.CreateTaskId(context.Background())
 
 		// Assert the results
 		assert.Error(t, err)
@@ -315,7 +528,7 @@ func TestUpdateTask(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(0, 1)) // 0 last insert id, 1 row affected
 
 		// Call the function
-		err := repo.UpdateTask(taskId, status, result)
+		err := repo.UpdateTask(context.Background(), taskId, status, result)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -339,7 +552,7 @@ func TestUpdateTask(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		// Call the function
-		err := repo.UpdateTask(taskId, status, result)
+		err := repo.UpdateTask(context.Background(), taskId, status, result)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -360,7 +573,7 @@ func TestUpdateTask(t *testing.T) {
 			WillReturnError(prepareErr)
 
 		// Call the function
-		err := repo.UpdateTask(taskId, status, result)
+		err := repo.UpdateTask(context.Background(), taskId, status, result)
 
 		// Assert the results
 		assert.Error(t, err)
@@ -386,7 +599,7 @@ func TestUpdateTask(t *testing.T) {
 			WillReturnError(execErr)
 
 		// Call the function
-		err := repo.UpdateTask(taskId, status, result)
+		err := repo.UpdateTask(context.Background(), taskId, status, result)
 
 		// Assert the results
 		assert.Error(t, err)
@@ -427,7 +640,7 @@ func TestGetTask(t *testing.T) {
 			WillReturnRows(rows)
 
 		// Call the function
-		task, err := repo.GetTask(taskId)
+		task, err := repo.GetTask(context.Background(), taskId)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -464,7 +677,7 @@ func TestGetTask(t *testing.T) {
 			WillReturnRows(rows)
 
 		// Call the function
-		task, err := repo.GetTask(taskId)
+		task, err := repo.GetTask(context.Background(), taskId)
 
 		// Assert the results
 		assert.NoError(t, err)
@@ -487,7 +700,7 @@ func TestGetTask(t *testing.T) {
 			WillReturnError(sql.ErrNoRows)
 
 		// Call the function
-		task, err := repo.GetTask(taskId)
+		task, err := repo.GetTask(context.Background(), taskId)
 
 		// Assert the results
 		assert.Error(t, err)
@@ -509,7 +722,7 @@ func TestGetTask(t *testing.T) {
 			WillReturnError(dbErr)
 
 		// Call the function
-		task, err := repo.GetTask(taskId)
+		task, err := repo.GetTask(context.Background(), taskId)
 
 		// Assert the results
 		assert.Error(t, err)
@@ -522,43 +735,117 @@ func TestGetTask(t *testing.T) {
 
 }
 
-func TestGetAllUrls(t *testing.T) {
+func TestListUrls(t *testing.T) {
 	mockDB, mock := mocks.NewMockDB()
 	defer mockDB.Close()
 
 	repo := repository.NewRepository(mockDB)
 
-	// Test successful query
 	t.Run("Success", func(t *testing.T) {
-		longUrl := "https://example.com/long-url"
-		expectedUrl := repository.Url{
-			ID:        sql.NullInt64{Int64: 1, Valid: true},
-			ShortCode: sql.NullString{String: "abc123", Valid: true},
-			LongUrl:   sql.NullString{String: longUrl, Valid: true},
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		}
+		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
+			AddRow(11, "abc123", "https://example.com/long-url", time.Now()).
+			AddRow(12, "abc456", "https://example.com/long-url2", time.Now())
+
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE id > \\$1 ORDER BY id LIMIT \\$2").
+			WithArgs(int64(10), 2).
+			WillReturnRows(rows)
 
-		longUrl2 := "https://example.com/long-url2"
+		urls, err := repo.ListUrls(context.Background(), 10, 2)
 
-		expectedUrl2 := repository.Url{
-			ID:        sql.NullInt64{Int64: 2, Valid: true},
-			ShortCode: sql.NullString{String: "abc456", Valid: true},
-			LongUrl:   sql.NullString{String: longUrl2, Valid: true},
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		assert.NoError(t, err)
+		if assert.Len(t, urls, 2) {
+			assert.Equal(t, int64(11), urls[0].ID.Int64)
+			assert.Equal(t, "abc456", urls[1].ShortCode.String)
+			assert.Equal(t, "https://example.com/long-url2", urls[1].LongUrl.String)
 		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 
-		rows := sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}).
-			AddRow(expectedUrl.ID.Int64, expectedUrl.ShortCode.String, expectedUrl.LongUrl.String, expectedUrl.CreatedAt.Time).
-			AddRow(expectedUrl2.ID.Int64, expectedUrl2.ShortCode.String, expectedUrl2.LongUrl.String, expectedUrl2.CreatedAt.Time)
-
-		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls").
-			WillReturnRows(rows)
+	t.Run("Past The Last Link", func(t *testing.T) {
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE id > \\$1").
+			WithArgs(int64(12), 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}))
 
-		url, err := repo.GetAllUrls()
+		urls, err := repo.ListUrls(context.Background(), 12, 2)
 
 		assert.NoError(t, err)
-		assert.Equal(t, expectedUrl.ID.Int64, url[0].ID.Int64)
-		assert.Equal(t, expectedUrl.ShortCode.String, url[0].ShortCode.String)
-		assert.Equal(t, expectedUrl.LongUrl.String, url[0].LongUrl.String)
+		assert.Empty(t, urls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Context Deadline", func(t *testing.T) {
+		mock.ExpectQuery("SELECT id, short_code, long_url, created_at FROM urls WHERE id > \\$1").
+			WithArgs(int64(0), 2).
+			WillDelayFor(time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "long_url", "created_at"}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := repo.ListUrls(ctx, 0, 2)
+
+		// The query is cancelled at the deadline instead of running to the end
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestUpdateLinkDetails(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+	details := repository.LinkDetails{Title: "Spring Sale", Folder: "marketing/2026", Tags: []string{"sale"}, Owner: "growth-team"}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET title = \\$1, notes = \\$2, folder = \\$3, tags = \\$4, owner = \\$5, plain = false WHERE short_code = \\$6").
+			WithArgs(sql.NullString{String: "Spring Sale", Valid: true}, sql.NullString{}, sql.NullString{String: "marketing/2026", Valid: true}, pq.Array([]string{"sale"}), sql.NullString{String: "growth-team", Valid: true}, "abc123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.UpdateLinkDetails(context.Background(), "abc123", details))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Short Code", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET title").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "nope").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.UpdateLinkDetails(context.Background(), "nope", details), sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateFallbackUrl(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewRepository(mockDB)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET fallback_url = \\$1, plain = false WHERE short_code = \\$2").
+			WithArgs(sql.NullString{String: "https://example.com/", Valid: true}, "abc123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.UpdateFallbackUrl(context.Background(), "abc123", "https://example.com/"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET fallback_url = \\$1, plain = false WHERE short_code = \\$2").
+			WithArgs(sql.NullString{}, "abc123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.UpdateFallbackUrl(context.Background(), "abc123", ""))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Short Code", func(t *testing.T) {
+		mock.ExpectExec("UPDATE urls SET fallback_url").
+			WithArgs(sqlmock.AnyArg(), "nope").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.UpdateFallbackUrl(context.Background(), "nope", ""), sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
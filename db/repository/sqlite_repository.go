@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gofrs/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// SqliteDriver is the database/sql driver SQLite databases are opened with, the pure Go modernc.org/sqlite
+const SqliteDriver = "sqlite"
+
+// sqliteSchema holds the columns of urls and tasks the UrlRepository uses. Tags are stored as a JSON array.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS urls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_code TEXT UNIQUE NOT NULL,
+    long_url TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',
+    password_hash TEXT,
+    max_clicks INTEGER,
+    active_from TIMESTAMP,
+    click_count INTEGER NOT NULL DEFAULT 0,
+    routing_rules TEXT,
+    forward_query INTEGER NOT NULL DEFAULT 0,
+    query_conflict TEXT NOT NULL DEFAULT 'target',
+    is_prefix INTEGER NOT NULL DEFAULT 0,
+    campaign_id INTEGER,
+    title TEXT,
+    notes TEXT,
+    folder TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    owner TEXT,
+    fallback_url TEXT,
+    broken_since TIMESTAMP,
+    plain INTEGER NOT NULL DEFAULT 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_long_url_plain ON urls(long_url) WHERE plain;
+
+CREATE TABLE IF NOT EXISTS tasks (
+    task_id TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    result TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+`
+
+// SqliteRepository stores links in a single SQLite file, for local development and small installations
+type SqliteRepository struct {
+	DB *sql.DB
+}
+
+// OpenSqlite opens the SQLite database at path, ":memory:" for one that only lives as long as the process
+func OpenSqlite(path string) (*sql.DB, error) {
+	conn, err := sql.Open(SqliteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, and every connection to ":memory:" would open a database of its own
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+// NewSqliteRepository returns a UrlRepository on conn, creating its tables when they do not exist
+func NewSqliteRepository(ctx context.Context, conn *sql.DB) (UrlRepository, error) {
+	if _, err := conn.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, err
+	}
+	return &SqliteRepository{DB: conn}, nil
+}
+
+func (r *SqliteRepository) CreateUrl(ctx context.Context, longUrl string) (*string, error) {
+	url, err := r.GetLongUrl(ctx, longUrl)
+	if err == nil {
+		return &url.ShortCode.String, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	shortCode := GenerateShortCode(6)
+	res, err := r.DB.ExecContext(ctx, "INSERT INTO urls (short_code, long_url, created_at) VALUES (?, ?, ?) ON CONFLICT (long_url) WHERE plain DO NOTHING", shortCode, longUrl, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		if url, err = r.GetLongUrl(ctx, longUrl); err != nil {
+			return nil, err
+		}
+		return &url.ShortCode.String, nil
+	}
+	return &shortCode, nil
+}
+
+func (r *SqliteRepository) CreateUrlWithOptions(ctx context.Context, longUrl string, opts LinkOptions) (*string, error) {
+	shortCode := GenerateShortCode(6)
+
+	activeFrom := sql.NullTime{}
+	if opts.ActiveFrom != nil {
+		activeFrom = sql.NullTime{Time: opts.ActiveFrom.UTC(), Valid: true}
+	}
+	conflict := opts.Passthrough.QueryConflict
+	if conflict == "" {
+		conflict = constants.QUERY_CONFLICT_DEFAULT
+	}
+	tags, err := json.Marshal(tagsOrEmpty(opts.Details.Tags))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.DB.ExecContext(ctx, "INSERT INTO urls (short_code, long_url, created_at, password_hash, max_clicks, active_from, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, plain) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0)",
+		shortCode, longUrl, time.Now().UTC(),
+		nullString(opts.PasswordHash),
+		sql.NullInt64{Int64: opts.MaxClicks, Valid: opts.MaxClicks > 0},
+		activeFrom,
+		sql.NullString{String: string(opts.RoutingRules), Valid: len(opts.RoutingRules) > 0},
+		opts.Passthrough.ForwardQuery, conflict, opts.Passthrough.Prefix,
+		sql.NullInt64{Int64: int64(opts.CampaignID), Valid: opts.CampaignID > 0},
+		nullString(opts.Details.Title), nullString(opts.Details.Notes), nullString(opts.Details.Folder), string(tags),
+		nullString(opts.Details.Owner), nullString(opts.FallbackUrl),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &shortCode, nil
+}
+
+func (r *SqliteRepository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
+	var url Url
+	var tags string
+	err := r.DB.QueryRowContext(ctx, "SELECT id, short_code, long_url, created_at, status, password_hash, max_clicks, active_from, click_count, routing_rules, forward_query, query_conflict, is_prefix, campaign_id, title, notes, folder, tags, owner, fallback_url, broken_since FROM urls WHERE short_code = ?", shortCode).
+		Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt, &url.Status, &url.PasswordHash, &url.MaxClicks, &url.ActiveFrom, &url.ClickCount, &url.RoutingRules,
+			&url.ForwardQuery, &url.QueryConflict, &url.IsPrefix, &url.CampaignID, &url.Title, &url.Notes, &url.Folder, &tags,
+			&url.Owner, &url.FallbackUrl, &url.BrokenSince)
+	if err != nil {
+		return Url{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &url.Tags); err != nil {
+		return Url{}, err
+	}
+	return url, nil
+}
+
+// GetLongUrl finds the plain link of longUrl, so plain links can be shared
+func (r *SqliteRepository) GetLongUrl(ctx context.Context, longUrl string) (Url, error) {
+	var url Url
+	err := r.DB.QueryRowContext(ctx, "SELECT id, short_code, long_url, created_at FROM urls WHERE long_url = ? AND plain", longUrl).
+		Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt)
+	if err != nil {
+		return Url{}, err
+	}
+	return url, nil
+}
+
+func (r *SqliteRepository) ListUrls(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id, short_code, long_url, created_at FROM urls WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := []Url{}
+	for rows.Next() {
+		var url Url
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.LongUrl, &url.CreatedAt); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+func (r *SqliteRepository) SyncClickCount(ctx context.Context, shortCode string, count int64) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE urls SET click_count = MAX(click_count, ?) WHERE short_code = ?", count, shortCode)
+	return err
+}
+
+func (r *SqliteRepository) UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error {
+	conflict := p.QueryConflict
+	if conflict == "" {
+		conflict = constants.QUERY_CONFLICT_DEFAULT
+	}
+
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET forward_query = ?, query_conflict = ?, is_prefix = ?, plain = 0 WHERE short_code = ?", p.ForwardQuery, conflict, p.Prefix, shortCode)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *SqliteRepository) UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error {
+	tags, err := json.Marshal(tagsOrEmpty(d.Tags))
+	if err != nil {
+		return err
+	}
+
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET title = ?, notes = ?, folder = ?, tags = ?, owner = ?, plain = 0 WHERE short_code = ?",
+		nullString(d.Title), nullString(d.Notes), nullString(d.Folder), string(tags), nullString(d.Owner), shortCode,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *SqliteRepository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE urls SET fallback_url = ?, plain = 0 WHERE short_code = ?", nullString(fallbackUrl), shortCode)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *SqliteRepository) CreateTaskId(ctx context.Context) (*types.Task, error) {
+	task := types.Task{TaskID: uuid.Must(uuid.NewV4()).String(), Status: "pending", CreatedAt: time.Now().UTC()}
+	_, err := r.DB.ExecContext(ctx, "INSERT INTO tasks (task_id, status, created_at) VALUES (?, ?, ?)", task.TaskID, task.Status, task.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *SqliteRepository) GetTask(ctx context.Context, taskId string) (types.Task, error) {
+	var task types.Task
+	var result sql.NullString
+	err := r.DB.QueryRowContext(ctx, "SELECT task_id, status, result, created_at FROM tasks WHERE task_id = ?", taskId).
+		Scan(&task.TaskID, &task.Status, &result, &task.CreatedAt)
+	if err != nil {
+		return types.Task{}, err
+	}
+	if result.Valid {
+		task.Result = json.RawMessage(result.String)
+	}
+	return task, nil
+}
+
+func (r *SqliteRepository) UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE tasks SET status = ?, result = ? WHERE task_id = ?",
+		status, sql.NullString{String: string(result), Valid: len(result) > 0}, taskId)
+	return err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+)
+
+type TargetRepository interface {
+	GetTargets(ctx context.Context, shortCode string) ([]types.LinkTarget, error)
+	ReplaceTargets(ctx context.Context, shortCode string, targets []types.LinkTarget) error
+}
+
+func NewTargetRepository(con db.Database) TargetRepository {
+	return &Repository{
+		DB: con,
+	}
+}
+
+// GetTargets returns the weighted destinations of a split link in their configured order
+func (r *Repository) GetTargets(ctx context.Context, shortCode string) ([]types.LinkTarget, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT t.name, t.target_url, t.weight FROM link_targets t JOIN urls u ON u.id = t.url_id WHERE u.short_code = $1 ORDER BY t.position", shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []types.LinkTarget{}
+	for rows.Next() {
+		var target types.LinkTarget
+		if err := rows.Scan(&target.Name, &target.Url, &target.Weight); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// ReplaceTargets replaces the destinations of a link in a single statement, so visitors never see a
+// partially updated split. An empty list turns the split off. It returns sql.ErrNoRows if the short
+// code does not exist.
+func (r *Repository) ReplaceTargets(ctx context.Context, shortCode string, targets []types.LinkTarget) error {
+	data, err := targetRows(targets)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.DB.ExecContext(ctx, `WITH link AS (SELECT id FROM urls WHERE short_code = $1),
+		deleted AS (DELETE FROM link_targets WHERE url_id = (SELECT id FROM link))
+		INSERT INTO link_targets (url_id, name, target_url, weight, position)
+		SELECT link.id, t.name, t.target_url, t.weight, t.position FROM link, jsonb_to_recordset($2::jsonb) AS t(name TEXT, target_url TEXT, weight INTEGER, position INTEGER)`,
+		shortCode, data,
+	)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return expectAffected(res)
+}
+
+// targetRows encodes targets as the JSON array of link_targets rows the statements expand with
+// jsonb_to_recordset
+func targetRows(targets []types.LinkTarget) (string, error) {
+	type row struct {
+		Name      string `json:"name"`
+		TargetUrl string `json:"target_url"`
+		Weight    int    `json:"weight"`
+		Position  int    `json:"position"`
+	}
+
+	rows := make([]row, len(targets))
+	for i, target := range targets {
+		rows[i] = row{Name: target.Name, TargetUrl: target.Url, Weight: target.Weight, Position: i}
+	}
+	data, err := json.Marshal(rows)
+	return string(data), err
+}
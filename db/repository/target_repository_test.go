@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTargets(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewTargetRepository(mockDB)
+
+	rows := sqlmock.NewRows([]string{"name", "target_url", "weight"}).
+		AddRow("a", "https://example.com/a", 70).
+		AddRow("b", "https://example.com/b", 30)
+	mock.ExpectQuery("SELECT t.name, t.target_url, t.weight FROM link_targets t JOIN urls u ON u.id = t.url_id WHERE u.short_code = \\$1 ORDER BY t.position").
+		WithArgs("abc123").
+		WillReturnRows(rows)
+
+	targets, err := repo.GetTargets(context.Background(), "abc123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []types.LinkTarget{
+		{Name: "a", Url: "https://example.com/a", Weight: 70},
+		{Name: "b", Url: "https://example.com/b", Weight: 30},
+	}, targets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceTargets(t *testing.T) {
+	mockDB, mock := mocks.NewMockDB()
+	defer mockDB.Close()
+
+	repo := repository.NewTargetRepository(mockDB)
+	targets := []types.LinkTarget{
+		{Name: "a", Url: "https://example.com/a", Weight: 70},
+		{Name: "b", Url: "https://example.com/b", Weight: 30},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM link_targets WHERE url_id = .* INSERT INTO link_targets \\(url_id, name, target_url, weight, position\\)").
+			WithArgs("abc123", `[{"name":"a","target_url":"https://example.com/a","weight":70,"position":0},{"name":"b","target_url":"https://example.com/b","weight":30,"position":1}]`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		assert.NoError(t, repo.ReplaceTargets(context.Background(), "abc123", targets))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Short Code", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO link_targets").
+			WithArgs("nope", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, sql.ErrNoRows, repo.ReplaceTargets(context.Background(), "nope", targets))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO link_targets").
+			WithArgs("abc123", "[]").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, repo.ReplaceTargets(context.Background(), "abc123", nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanStarter starts the spans of one repository, with the db.system of the backend behind it
+type spanStarter struct {
+	repository string
+	system     string
+}
+
+func (s spanStarter) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", s.system))
+	return tracing.Start(ctx, s.repository+"."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, a missing row is an answer rather than a failure
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		span.SetAttributes(attribute.Bool("db.found", false))
+		err = nil
+	}
+	tracing.End(span, &err)
+}
+
+func shortCodeAttr(shortCode string) attribute.KeyValue {
+	return attribute.String("short_code", shortCode)
+}
+
+// tracedRepository records a span around every call to a UrlRepository
+type tracedRepository struct {
+	repo UrlRepository
+	spanStarter
+}
+
+// WithTracing wraps repo so every call is traced as a child of the span in its context. system is the
+// db.system of the spans, such as constants.DB_SYSTEM_POSTGRES.
+func WithTracing(repo UrlRepository, system string) UrlRepository {
+	return &tracedRepository{repo: repo, spanStarter: spanStarter{repository: "UrlRepository", system: system}}
+}
+
+func (t *tracedRepository) CreateUrl(ctx context.Context, url string) (*string, error) {
+	ctx, span := t.start(ctx, "CreateUrl")
+	shortCode, err := t.repo.CreateUrl(ctx, url)
+	endSpan(span, err)
+	return shortCode, err
+}
+
+func (t *tracedRepository) CreateUrlWithOptions(ctx context.Context, url string, opts LinkOptions) (*string, error) {
+	ctx, span := t.start(ctx, "CreateUrlWithOptions")
+	shortCode, err := t.repo.CreateUrlWithOptions(ctx, url, opts)
+	endSpan(span, err)
+	return shortCode, err
+}
+
+func (t *tracedRepository) GetUrl(ctx context.Context, shortCode string) (Url, error) {
+	ctx, span := t.start(ctx, "GetUrl", shortCodeAttr(shortCode))
+	url, err := t.repo.GetUrl(ctx, shortCode)
+	endSpan(span, err)
+	return url, err
+}
+
+func (t *tracedRepository) GetLongUrl(ctx context.Context, longUrl string) (Url, error) {
+	ctx, span := t.start(ctx, "GetLongUrl")
+	url, err := t.repo.GetLongUrl(ctx, longUrl)
+	endSpan(span, err)
+	return url, err
+}
+
+func (t *tracedRepository) CreateTaskId(ctx context.Context) (*types.Task, error) {
+	ctx, span := t.start(ctx, "CreateTaskId")
+	task, err := t.repo.CreateTaskId(ctx)
+	endSpan(span, err)
+	return task, err
+}
+
+func (t *tracedRepository) GetTask(ctx context.Context, taskId string) (types.Task, error) {
+	ctx, span := t.start(ctx, "GetTask", attribute.String("task.id", taskId))
+	task, err := t.repo.GetTask(ctx, taskId)
+	endSpan(span, err)
+	return task, err
+}
+
+func (t *tracedRepository) UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error {
+	ctx, span := t.start(ctx, "UpdateTask", attribute.String("task.id", taskId))
+	err := t.repo.UpdateTask(ctx, taskId, status, result)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) ListUrls(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	ctx, span := t.start(ctx, "ListUrls", attribute.Int64("after_id", afterID))
+	urls, err := t.repo.ListUrls(ctx, afterID, limit)
+	endSpan(span, err)
+	return urls, err
+}
+
+func (t *tracedRepository) SyncClickCount(ctx context.Context, shortCode string, count int64) error {
+	ctx, span := t.start(ctx, "SyncClickCount", shortCodeAttr(shortCode))
+	err := t.repo.SyncClickCount(ctx, shortCode, count)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) UpdatePassthrough(ctx context.Context, shortCode string, p Passthrough) error {
+	ctx, span := t.start(ctx, "UpdatePassthrough", shortCodeAttr(shortCode))
+	err := t.repo.UpdatePassthrough(ctx, shortCode, p)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) UpdateLinkDetails(ctx context.Context, shortCode string, d LinkDetails) error {
+	ctx, span := t.start(ctx, "UpdateLinkDetails", shortCodeAttr(shortCode))
+	err := t.repo.UpdateLinkDetails(ctx, shortCode, d)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	ctx, span := t.start(ctx, "UpdateFallbackUrl", shortCodeAttr(shortCode))
+	err := t.repo.UpdateFallbackUrl(ctx, shortCode, fallbackUrl)
+	endSpan(span, err)
+	return err
+}
+
+// tracedReportRepository records a span around every call to a ReportRepository
+type tracedReportRepository struct {
+	repo ReportRepository
+	spanStarter
+}
+
+// WithReportTracing wraps repo so every call is traced, see WithTracing
+func WithReportTracing(repo ReportRepository, system string) ReportRepository {
+	return &tracedReportRepository{repo: repo, spanStarter: spanStarter{repository: "ReportRepository", system: system}}
+}
+
+func (t *tracedReportRepository) CreateReport(ctx context.Context, shortCode string, reason string, reporter string) (*types.Report, error) {
+	ctx, span := t.start(ctx, "CreateReport", shortCodeAttr(shortCode))
+	report, err := t.repo.CreateReport(ctx, shortCode, reason, reporter)
+	endSpan(span, err)
+	return report, err
+}
+
+func (t *tracedReportRepository) GetReport(ctx context.Context, id int) (types.Report, error) {
+	ctx, span := t.start(ctx, "GetReport", attribute.Int("report.id", id))
+	report, err := t.repo.GetReport(ctx, id)
+	endSpan(span, err)
+	return report, err
+}
+
+func (t *tracedReportRepository) ListReports(ctx context.Context, status string, limit int, offset int) ([]types.Report, error) {
+	ctx, span := t.start(ctx, "ListReports")
+	reports, err := t.repo.ListReports(ctx, status, limit, offset)
+	endSpan(span, err)
+	return reports, err
+}
+
+func (t *tracedReportRepository) CountOpenReporters(ctx context.Context, shortCode string) (int, error) {
+	ctx, span := t.start(ctx, "CountOpenReporters", shortCodeAttr(shortCode))
+	count, err := t.repo.CountOpenReporters(ctx, shortCode)
+	endSpan(span, err)
+	return count, err
+}
+
+func (t *tracedReportRepository) UpdateReportStatus(ctx context.Context, id int, status string) error {
+	ctx, span := t.start(ctx, "UpdateReportStatus", attribute.Int("report.id", id))
+	err := t.repo.UpdateReportStatus(ctx, id, status)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedReportRepository) TakedownReport(ctx context.Context, id int) error {
+	ctx, span := t.start(ctx, "TakedownReport", attribute.Int("report.id", id))
+	err := t.repo.TakedownReport(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedReportRepository) SetUrlStatus(ctx context.Context, shortCode string, status string) error {
+	ctx, span := t.start(ctx, "SetUrlStatus", shortCodeAttr(shortCode))
+	err := t.repo.SetUrlStatus(ctx, shortCode, status)
+	endSpan(span, err)
+	return err
+}
+
+// tracedTargetRepository records a span around every call to a TargetRepository
+type tracedTargetRepository struct {
+	repo TargetRepository
+	spanStarter
+}
+
+// WithTargetTracing wraps repo so every call is traced, see WithTracing
+func WithTargetTracing(repo TargetRepository, system string) TargetRepository {
+	return &tracedTargetRepository{repo: repo, spanStarter: spanStarter{repository: "TargetRepository", system: system}}
+}
+
+func (t *tracedTargetRepository) GetTargets(ctx context.Context, shortCode string) ([]types.LinkTarget, error) {
+	ctx, span := t.start(ctx, "GetTargets", shortCodeAttr(shortCode))
+	targets, err := t.repo.GetTargets(ctx, shortCode)
+	endSpan(span, err)
+	return targets, err
+}
+
+func (t *tracedTargetRepository) ReplaceTargets(ctx context.Context, shortCode string, targets []types.LinkTarget) error {
+	ctx, span := t.start(ctx, "ReplaceTargets", shortCodeAttr(shortCode))
+	err := t.repo.ReplaceTargets(ctx, shortCode, targets)
+	endSpan(span, err)
+	return err
+}
+
+// tracedCampaignRepository records a span around every call to a CampaignRepository
+type tracedCampaignRepository struct {
+	repo CampaignRepository
+	spanStarter
+}
+
+// WithCampaignTracing wraps repo so every call is traced, see WithTracing
+func WithCampaignTracing(repo CampaignRepository, system string) CampaignRepository {
+	return &tracedCampaignRepository{repo: repo, spanStarter: spanStarter{repository: "CampaignRepository", system: system}}
+}
+
+func (t *tracedCampaignRepository) CreateCampaign(ctx context.Context, campaign types.Campaign) (*types.Campaign, error) {
+	ctx, span := t.start(ctx, "CreateCampaign")
+	created, err := t.repo.CreateCampaign(ctx, campaign)
+	endSpan(span, err)
+	return created, err
+}
+
+func (t *tracedCampaignRepository) GetCampaign(ctx context.Context, id int) (types.Campaign, error) {
+	ctx, span := t.start(ctx, "GetCampaign", attribute.Int("campaign.id", id))
+	campaign, err := t.repo.GetCampaign(ctx, id)
+	endSpan(span, err)
+	return campaign, err
+}
+
+func (t *tracedCampaignRepository) ListCampaigns(ctx context.Context) ([]types.Campaign, error) {
+	ctx, span := t.start(ctx, "ListCampaigns")
+	campaigns, err := t.repo.ListCampaigns(ctx)
+	endSpan(span, err)
+	return campaigns, err
+}
+
+// tracedLinkRepository records a span around every call to a LinkRepository
+type tracedLinkRepository struct {
+	repo LinkRepository
+	spanStarter
+}
+
+// WithLinkTracing wraps repo so every call is traced, see WithTracing
+func WithLinkTracing(repo LinkRepository, system string) LinkRepository {
+	return &tracedLinkRepository{repo: repo, spanStarter: spanStarter{repository: "LinkRepository", system: system}}
+}
+
+func (t *tracedLinkRepository) SearchLinks(ctx context.Context, q LinkQuery) (LinkPage, error) {
+	ctx, span := t.start(ctx, "SearchLinks")
+	page, err := t.repo.SearchLinks(ctx, q)
+	endSpan(span, err)
+	return page, err
+}
+
+// tracedMetadataRepository records a span around every call to a MetadataRepository
+type tracedMetadataRepository struct {
+	repo MetadataRepository
+	spanStarter
+}
+
+// WithMetadataTracing wraps repo so every call is traced, see WithTracing
+func WithMetadataTracing(repo MetadataRepository, system string) MetadataRepository {
+	return &tracedMetadataRepository{repo: repo, spanStarter: spanStarter{repository: "MetadataRepository", system: system}}
+}
+
+func (t *tracedMetadataRepository) SaveMetadata(ctx context.Context, shortCode string, metadata types.LinkMetadata) error {
+	ctx, span := t.start(ctx, "SaveMetadata", shortCodeAttr(shortCode))
+	err := t.repo.SaveMetadata(ctx, shortCode, metadata)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedMetadataRepository) GetMetadata(ctx context.Context, shortCode string) (types.LinkMetadata, error) {
+	ctx, span := t.start(ctx, "GetMetadata", shortCodeAttr(shortCode))
+	metadata, err := t.repo.GetMetadata(ctx, shortCode)
+	endSpan(span, err)
+	return metadata, err
+}
+
+// tracedHealthRepository records a span around every call to a HealthRepository
+type tracedHealthRepository struct {
+	repo HealthRepository
+	spanStarter
+}
+
+// WithHealthTracing wraps repo so every call is traced, see WithTracing
+func WithHealthTracing(repo HealthRepository, system string) HealthRepository {
+	return &tracedHealthRepository{repo: repo, spanStarter: spanStarter{repository: "HealthRepository", system: system}}
+}
+
+func (t *tracedHealthRepository) ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]Url, error) {
+	ctx, span := t.start(ctx, "ListCheckTargets", attribute.Int64("after_id", afterID))
+	urls, err := t.repo.ListCheckTargets(ctx, afterID, limit)
+	endSpan(span, err)
+	return urls, err
+}
+
+func (t *tracedHealthRepository) RecordCheck(ctx context.Context, link Url, check types.HealthCheck, threshold int) (bool, error) {
+	ctx, span := t.start(ctx, "RecordCheck", shortCodeAttr(link.ShortCode.String))
+	broken, err := t.repo.RecordCheck(ctx, link, check, threshold)
+	endSpan(span, err)
+	return broken, err
+}
+
+func (t *tracedHealthRepository) ListBrokenLinks(ctx context.Context, owner string, limit int, offset int) ([]BrokenLink, error) {
+	ctx, span := t.start(ctx, "ListBrokenLinks")
+	links, err := t.repo.ListBrokenLinks(ctx, owner, limit, offset)
+	endSpan(span, err)
+	return links, err
+}
@@ -0,0 +1,117 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes the spans started while the test runs go to the returned exporter
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	tracing.SetProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		tracing.SetProvider(previous)
+	})
+	return exporter
+}
+
+func TestWithReportTracing(t *testing.T) {
+	exporter := recordSpans(t)
+	mockRepo := new(mocks.MockReportRepository)
+	mockRepo.On("TakedownReport", 7).Return(nil)
+	mockRepo.On("GetReport", 8).Return(types.Report{}, sql.ErrNoRows)
+	mockRepo.On("SetUrlStatus", "abc123", "disabled").Return(errors.New("connection refused"))
+	repo := repository.WithReportTracing(mockRepo, constants.DB_SYSTEM_SQLITE)
+
+	ctx, parent := tracing.Start(context.Background(), "request")
+	require.NoError(t, repo.TakedownReport(ctx, 7))
+	_, err := repo.GetReport(ctx, 8)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Error(t, repo.SetUrlStatus(ctx, "abc123", "disabled"))
+	parent.End()
+	mockRepo.AssertExpectations(t)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	for _, span := range spans[:3] {
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		assert.Contains(t, span.Attributes, attribute.String("db.system", constants.DB_SYSTEM_SQLITE))
+	}
+
+	assert.Equal(t, "ReportRepository.TakedownReport", spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, attribute.Int("report.id", 7))
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+
+	// A missing row is an answer, not a failure
+	assert.Equal(t, "ReportRepository.GetReport", spans[1].Name)
+	assert.Contains(t, spans[1].Attributes, attribute.Bool("db.found", false))
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+
+	assert.Equal(t, "ReportRepository.SetUrlStatus", spans[2].Name)
+	assert.Contains(t, spans[2].Attributes, attribute.String("short_code", "abc123"))
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+	assert.Equal(t, "connection refused", spans[2].Status.Description)
+}
+
+func TestWithTracing_EveryRepository(t *testing.T) {
+	exporter := recordSpans(t)
+	ctx := context.Background()
+
+	targets := new(mocks.MockTargetRepository)
+	targets.On("GetTargets", "abc123").Return([]types.LinkTarget{}, nil)
+	_, err := repository.WithTargetTracing(targets, constants.DB_SYSTEM_MEMORY).GetTargets(ctx, "abc123")
+	require.NoError(t, err)
+
+	campaigns := new(mocks.MockCampaignRepository)
+	campaigns.On("GetCampaign", 3).Return(types.Campaign{}, nil)
+	_, err = repository.WithCampaignTracing(campaigns, constants.DB_SYSTEM_MEMORY).GetCampaign(ctx, 3)
+	require.NoError(t, err)
+
+	links := new(mocks.MockLinkRepository)
+	links.On("SearchLinks", repository.LinkQuery{}).Return(repository.LinkPage{}, nil)
+	_, err = repository.WithLinkTracing(links, constants.DB_SYSTEM_MEMORY).SearchLinks(ctx, repository.LinkQuery{})
+	require.NoError(t, err)
+
+	metadata := new(mocks.MockMetadataRepository)
+	metadata.On("GetMetadata", "abc123").Return(types.LinkMetadata{}, nil)
+	_, err = repository.WithMetadataTracing(metadata, constants.DB_SYSTEM_MEMORY).GetMetadata(ctx, "abc123")
+	require.NoError(t, err)
+
+	health := new(mocks.MockHealthRepository)
+	health.On("ListCheckTargets", int64(0), 10).Return([]repository.Url{}, nil)
+	_, err = repository.WithHealthTracing(health, constants.DB_SYSTEM_MEMORY).ListCheckTargets(ctx, 0, 10)
+	require.NoError(t, err)
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		names = append(names, span.Name)
+		assert.Contains(t, span.Attributes, attribute.String("db.system", constants.DB_SYSTEM_MEMORY))
+	}
+	assert.Equal(t, []string{
+		"TargetRepository.GetTargets",
+		"CampaignRepository.GetCampaign",
+		"LinkRepository.SearchLinks",
+		"MetadataRepository.GetMetadata",
+		"HealthRepository.ListCheckTargets",
+	}, names)
+}
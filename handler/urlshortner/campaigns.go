@@ -0,0 +1,173 @@
+package urlshortner
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+)
+
+var errCampaignNotFound = errors.New("Campaign not found")
+
+// CreateCampaign handles POST requests to /campaigns. It takes a JSON payload with a "name" and optional
+// "utmSource", "utmMedium" and "utmCampaign" defaults, and returns the stored campaign with a 201 Created status.
+// Links created with its "campaignId" get the UTM parameters added on redirect; utm_campaign defaults to the name.
+func (h *Handler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var payload types.Campaign
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "Name is required"))
+		return
+	}
+	for _, value := range []string{payload.Name, payload.UtmSource, payload.UtmMedium, payload.UtmCampaign} {
+		if len(value) > constants.CAMPAIGN_NAME_MAX_LEN {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Name and UTM values must be at most %d characters", constants.CAMPAIGN_NAME_MAX_LEN))
+			return
+		}
+	}
+
+	campaign, err := h.CampaignRepository.CreateCampaign(r.Context(), types.Campaign{
+		Name:        payload.Name,
+		UtmSource:   payload.UtmSource,
+		UtmMedium:   payload.UtmMedium,
+		UtmCampaign: payload.UtmCampaign,
+	})
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("campaign", payload.Name).Msg("Failed to create campaign")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.log(r.Context()).Info().Int("campaign_id", campaign.ID).Str("campaign", campaign.Name).Msg("Campaign created")
+	utils.WriteJson(w, http.StatusCreated, campaign)
+}
+
+// ListCampaigns handles GET requests to /campaigns. It returns every campaign ordered by name.
+func (h *Handler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := h.CampaignRepository.ListCampaigns(r.Context())
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Msg("Failed to list campaigns")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, campaigns)
+}
+
+// checkCampaign makes sure a link can be created under the campaign with the given id.
+// It returns the status code to answer with and an error.
+func (h *Handler) checkCampaign(ctx context.Context, id int) (int, error) {
+	if h.CampaignRepository == nil {
+		return http.StatusBadRequest, fmt.Errorf("%s", "Campaigns are not enabled")
+	}
+
+	_, err := h.CampaignRepository.GetCampaign(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusBadRequest, errCampaignNotFound
+	}
+	if err != nil {
+		h.log(ctx).Error().Err(err).Int("campaign_id", id).Msg("Failed to fetch campaign")
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// campaignFor returns the campaign of a link, or nil for links without one. Campaigns do not change once
+// created, so they are cached in Redis. Failures are logged and the redirect goes ahead without the campaign.
+func (h *Handler) campaignFor(ctx context.Context, link repository.Url) *types.Campaign {
+	if !link.CampaignID.Valid || h.CampaignRepository == nil {
+		return nil
+	}
+
+	key := constants.CAMPAIGN_CACHE_KEY_PREFIX + strconv.FormatInt(link.CampaignID.Int64, 10)
+	data, err := h.CacheManager.Get(ctx, key)
+	if err != nil && err != cachemanager.ErrMiss {
+		h.log(ctx).Error().Err(err).Str("key", key).Msg("Failed to fetch campaign from cache")
+	}
+	if err == nil {
+		var campaign types.Campaign
+		if json.Unmarshal([]byte(data), &campaign) == nil {
+			return &campaign
+		}
+	}
+
+	campaign, err := h.CampaignRepository.GetCampaign(ctx, int(link.CampaignID.Int64))
+	if err != nil {
+		h.log(ctx).Error().Err(err).Str("short_code", link.ShortCode.String).Int64("campaign_id", link.CampaignID.Int64).Msg("Failed to fetch campaign")
+		return nil
+	}
+
+	if encoded, err := json.Marshal(campaign); err == nil {
+		if err := h.CacheManager.Set(ctx, key, string(encoded), constants.CACHE_TTL_DEFAULT); err != nil {
+			h.log(ctx).Error().Err(err).Str("key", key).Msg("Failed to cache campaign")
+		}
+	}
+	return &campaign
+}
+
+// campaignParams returns the UTM parameters a campaign adds to the destinations of its links
+func campaignParams(campaign *types.Campaign) url.Values {
+	params := url.Values{}
+	if campaign.UtmSource != "" {
+		params.Set("utm_source", campaign.UtmSource)
+	}
+	if campaign.UtmMedium != "" {
+		params.Set("utm_medium", campaign.UtmMedium)
+	}
+	params.Set("utm_campaign", cmp.Or(campaign.UtmCampaign, campaign.Name))
+	return params
+}
+
+// templateValues returns the values filled into the placeholders of a destination for this click
+func (h *Handler) templateValues(r *http.Request, link repository.Url, campaign *types.Campaign) map[string]string {
+	values := map[string]string{
+		linktemplate.ShortCode: link.ShortCode.String,
+		linktemplate.Country:   h.visitorCountry(r),
+		linktemplate.ClickID:   newClickID(),
+	}
+	if campaign != nil {
+		values[linktemplate.Campaign] = campaign.Name
+	}
+	return values
+}
+
+// visitorCountry returns the two-letter country code set by the CDN or proxy in front of the service, or ""
+func (h *Handler) visitorCountry(r *http.Request) string {
+	if h.CountryHeader == "" {
+		return ""
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(h.CountryHeader)))
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return ""
+	}
+	return country
+}
+
+// newClickID returns a random id for a single click
+func newClickID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
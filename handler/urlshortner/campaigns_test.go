@@ -0,0 +1,170 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var springSale = types.Campaign{ID: 3, Name: "Spring Sale", UtmSource: "newsletter", UtmMedium: "email", UtmCampaign: "spring-2026"}
+
+func campaignUrl(longUrl string) repository.Url {
+	url := activeUrl("active")
+	url.LongUrl = sql.NullString{String: longUrl, Valid: true}
+	url.CampaignID = sql.NullInt64{Int64: 3, Valid: true}
+	return url
+}
+
+func TestCreateCampaign(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	body := []byte(`{"name": " Spring Sale ", "utmSource": "newsletter", "utmMedium": "email"}`)
+	req, _ := http.NewRequest("POST", "/campaigns", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	created := types.Campaign{ID: 3, Name: "Spring Sale", UtmSource: "newsletter", UtmMedium: "email", CreatedAt: time.Now()}
+	mockCampaigns.On("CreateCampaign", types.Campaign{Name: "Spring Sale", UtmSource: "newsletter", UtmMedium: "email"}).Return(&created, nil)
+
+	handler.CreateCampaign(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":3`)
+	mockCampaigns.AssertExpectations(t)
+}
+
+func TestCreateCampaign_MissingName(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	req, _ := http.NewRequest("POST", "/campaigns", bytes.NewBufferString(`{"utmSource": "newsletter"}`))
+	rec := httptest.NewRecorder()
+
+	handler.CreateCampaign(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockCampaigns.AssertNotCalled(t, "CreateCampaign", mock.Anything)
+}
+
+func TestShorten_WithCampaign(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"longUrl": "https://example.com/sale", "campaignId": 3}`))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockCampaigns.On("GetCampaign", 3).Return(springSale, nil)
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/sale", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return opts.CampaignID == 3
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"campaignId":3`)
+}
+
+func TestShorten_UnknownCampaign(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"longUrl": "https://example.com/sale", "campaignId": 9}`))
+	rec := httptest.NewRecorder()
+
+	mockCampaigns.On("GetCampaign", 9).Return(types.Campaign{}, sql.ErrNoRows)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestShorten_UnknownPlaceholder(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithCampaigns(new(mocks.MockCampaignRepository)))
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"longUrl": "https://example.com/?email={{email}}"}`))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrl", mock.Anything)
+}
+
+func TestRedirect_AddsCampaignParams(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	mockRepo.On("GetUrl", "abc123").Return(campaignUrl("https://example.com/sale?utm_source=site"), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRedis.On("Get", mock.Anything, "campaign:3").Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, "campaign:3", mock.Anything, mock.Anything).Return(nil)
+	mockCampaigns.On("GetCampaign", 3).Return(springSale, nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+	// The destination's own utm_source wins over the campaign default
+	assert.Equal(t, "https://example.com/sale?utm_campaign=spring-2026&utm_medium=email&utm_source=site", rec.Header().Get("Location"))
+	mockRedis.AssertCalled(t, "Set", mock.Anything, "campaign:3", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_CampaignFromCache(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	mockRepo.On("GetUrl", "abc123").Return(campaignUrl("https://example.com/sale"), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRedis.On("Get", mock.Anything, "campaign:3").Return(`{"id": 3, "name": "Autumn", "utmSource": "print"}`, nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/sale?utm_campaign=Autumn&utm_source=print", rec.Header().Get("Location"))
+	mockCampaigns.AssertNotCalled(t, "GetCampaign", mock.Anything)
+}
+
+func TestRedirect_CampaignUnavailable(t *testing.T) {
+	mockCampaigns := new(mocks.MockCampaignRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithCampaigns(mockCampaigns))
+
+	mockRepo.On("GetUrl", "abc123").Return(campaignUrl("https://example.com/sale"), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRedis.On("Get", mock.Anything, "campaign:3").Return("", redis.Nil)
+	mockCampaigns.On("GetCampaign", 3).Return(types.Campaign{}, sql.ErrConnDone)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/sale", rec.Header().Get("Location"))
+}
+
+func TestRedirect_ExpandsPlaceholders(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithCampaigns(new(mocks.MockCampaignRepository)))
+
+	url := activeUrl("active")
+	url.LongUrl = sql.NullString{String: "https://example.com/%7B%7Bcountry%7D%7D/shop?code={{short_code}}&cid={{click_id}}", Valid: true}
+	mockRepo.On("GetUrl", "abc123").Return(url, nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	req := redirectRequest()
+	req.Header.Set("CF-IPCountry", "de")
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Regexp(t, `^https://example\.com/DE/shop\?code=abc123&cid=[0-9a-f]{16}$`, rec.Header().Get("Location"))
+	assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+
+	// Every click gets its own id
+	second := httptest.NewRecorder()
+	handler.Redirect(second, redirectRequest())
+	assert.NotEqual(t, rec.Header().Get("Location"), second.Header().Get("Location"))
+	assert.Contains(t, second.Header().Get("Location"), "https://example.com//shop?")
+}
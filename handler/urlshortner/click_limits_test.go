@@ -0,0 +1,181 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/clickcounter"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func limitedUrl(maxClicks, clickCount int64) repository.Url {
+	url := activeUrl("active")
+	url.MaxClicks = sql.NullInt64{Int64: maxClicks, Valid: true}
+	url.ClickCount = sql.NullInt64{Int64: clickCount, Valid: true}
+	return url
+}
+
+func redirectRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	return mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+}
+
+func TestShorten_WithClickLimitAndActivation(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	activeFrom := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	body := []byte(`{"longUrl": "https://example.com/", "maxClicks": 3, "activeFrom": "2030-01-01T09:00:00Z"}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return opts.PasswordHash == "" && opts.MaxClicks == 3 && opts.ActiveFrom != nil && opts.ActiveFrom.Equal(activeFrom)
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"maxClicks":3`)
+	mockRepo.AssertNotCalled(t, "CreateUrl", mock.Anything)
+}
+
+func TestShorten_NegativeMaxClicks(t *testing.T) {
+	handler, _, _ := newTestHandler()
+
+	body := []byte(`{"longUrl": "https://example.com/", "maxClicks": -1}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestShorten_MaxClicksWithoutCounters(t *testing.T) {
+	logger := zerolog.Nop()
+	handler, mockRepo, _ := newTestHandler()
+	handler.ClickCounter = clickcounter.NewCounter(cachemanager.NoopCache{}, mockRepo, logger)
+
+	body := []byte(`{"longUrl": "https://example.com/", "maxClicks": 3}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "MaxClicks needs a cache that counts clicks")
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_NotActiveYet(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	url := activeUrl("active")
+	url.ActiveFrom = sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	mockRepo.On("GetUrl", "abc123").Return(url, nil)
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), "not active yet")
+	mockRedis.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_WithinClickLimit(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 0), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(3), nil)
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/", rec.Header().Get("Location"))
+}
+
+func TestRedirect_ClickLimitReached(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 0), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(4), nil)
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), "limit has been reached")
+}
+
+func TestRedirect_SeedsCounterFromDatabase(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 3), nil)
+	mockRedis.On("Eval", mock.Anything, []string{"clicks:abc123"}, []interface{}{int64(3)}).Return(int64(4), nil)
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func TestRedirect_RedisDownFailsOpen(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 1), nil)
+	mockRedis.On("Eval", mock.Anything, []string{"clicks:abc123"}, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+}
+
+func TestRedirect_RedisDownUsesReconciledCount(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 3), nil)
+	mockRedis.On("Eval", mock.Anything, []string{"clicks:abc123"}, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func TestGetShorten_ClickLimitReached(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 0), nil)
+	mockRedis.On("Get", mock.Anything, "clicks:abc123").Return("3", nil)
+
+	handler.GetShorten(rec, req)
+	assert.Equal(t, http.StatusGone, rec.Code)
+	mockRedis.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+}
+
+func TestGetShorten_ClickLimitNotCounted(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(3, 1), nil)
+	mockRedis.On("Get", mock.Anything, "clicks:abc123").Return("", redis.Nil)
+
+	handler.GetShorten(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"maxClicks":3`)
+	mockRedis.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+}
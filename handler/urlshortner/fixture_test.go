@@ -0,0 +1,51 @@
+package urlshortner_test
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+// newTestHandler returns the handler the tests drive, on a mock repository and a cache on a mock Redis
+// client. opts turn on the features under test, the way the server does.
+func newTestHandler(opts ...urlshortner.Option) (*urlshortner.Handler, *mocks.MockUrlRepository, *mocks.MockRedisClient) {
+	logger := zerolog.Nop()
+	mockRedis := new(mocks.MockRedisClient)
+	mockRepo := new(mocks.MockUrlRepository)
+
+	handler := urlshortner.NewHandler(mockRepo, &logger, cachemanager.NewCacheManager(mockRedis, logger), opts...)
+	return handler, mockRepo, mockRedis
+}
+
+// redirectRouter serves the redirect routes of handler without a rate limit
+func redirectRouter(handler *urlshortner.Handler) *mux.Router {
+	logger := zerolog.Nop()
+	router := mux.NewRouter()
+	handler.RegisterRedirectRoutes(router, middleware.NewRateLimiter(0, time.Minute, &logger))
+	return router
+}
+
+// adminRouter serves the admin routes of handler behind the token "secret"
+func adminRouter(handler *urlshortner.Handler) *mux.Router {
+	logger := zerolog.Nop()
+	router := mux.NewRouter()
+	handler.RegisterAdminRoutes(router, middleware.NewAdminAuth("secret", &logger))
+	return router
+}
+
+// activeUrl is the link abc123 to https://example.com/ in status
+func activeUrl(status string) repository.Url {
+	return repository.Url{
+		ShortCode: sql.NullString{String: "abc123", Valid: true},
+		LongUrl:   sql.NullString{String: "https://example.com/", Valid: true},
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		Status:    sql.NullString{String: status, Valid: true},
+	}
+}
@@ -0,0 +1,109 @@
+package urlshortner
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+)
+
+var errFallbackTemplate = errors.New("FallbackUrl must not contain placeholders")
+
+// ListBrokenLinks handles GET requests to /admin/broken-links. It returns the links the health checker
+// marked broken, grouped by owner with links without an owner last, together with the result of their last
+// check. "owner" limits the report to one owner; "limit" and "offset" page through the links.
+// Long URLs of password protected links are not revealed.
+func (h *Handler) ListBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	owner := strings.TrimSpace(query.Get("owner"))
+
+	links, err := h.HealthRepository.ListBrokenLinks(r.Context(), owner, limit, offset)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("owner", owner).Msg("Failed to list broken links")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Links arrive ordered by owner, so each owner is one run
+	response := []types.OwnerBrokenLinks{}
+	for _, link := range links {
+		if len(response) == 0 || response[len(response)-1].Owner != link.Owner.String {
+			response = append(response, types.OwnerBrokenLinks{Owner: link.Owner.String, Links: []types.BrokenLink{}})
+		}
+		group := &response[len(response)-1]
+		group.Links = append(group.Links, brokenLink(link))
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+func brokenLink(link repository.BrokenLink) types.BrokenLink {
+	broken := types.BrokenLink{
+		ShortCode:           link.ShortCode.String,
+		LongUrl:             link.LongUrl.String,
+		FallbackUrl:         link.FallbackUrl.String,
+		StatusCode:          link.Check.StatusCode,
+		LatencyMs:           link.Check.LatencyMs,
+		Error:               link.Check.Error,
+		ConsecutiveFailures: link.ConsecutiveFailures,
+		LastCheckedAt:       link.Check.CheckedAt,
+		BrokenSince:         link.BrokenSince.Time,
+	}
+	if link.PasswordHash.Valid {
+		broken.LongUrl = ""
+		broken.PasswordProtected = true
+	}
+	return broken
+}
+
+// fallbackFor returns where to send visitors instead of destination, or "" to use destination.
+// Only the long URL is checked, so links are only diverted while it is the chosen destination and broken.
+func (h *Handler) fallbackFor(url repository.Url, destination string) string {
+	if !url.BrokenSince.Valid || destination != url.LongUrl.String {
+		return ""
+	}
+	return cmp.Or(url.FallbackUrl.String, h.FallbackUrl)
+}
+
+// prepareFallback validates and canonicalizes the fallback URL of a link. It returns the status code to
+// respond with when the URL is refused.
+func (h *Handler) prepareFallback(fallbackUrl string) (string, int, error) {
+	if fallbackUrl == "" {
+		return "", 0, nil
+	}
+
+	normalized, err := h.UrlValidator.Normalize(fallbackUrl)
+	if err != nil {
+		return "", http.StatusBadRequest, fmt.Errorf("FallbackUrl: %w", err)
+	}
+	if linktemplate.HasPlaceholders(normalized) {
+		return "", http.StatusBadRequest, errFallbackTemplate
+	}
+	if err := h.Policy.Check(normalized); err != nil {
+		h.Logger.Warn().Err(err).Str("fallback_url", normalized).Msg("Fallback rejected by policy")
+		return "", http.StatusForbidden, err
+	}
+	return normalized, 0, nil
+}
+
+// normalizeOwner trims the owner of a link
+func normalizeOwner(owner string) (string, error) {
+	owner = strings.TrimSpace(owner)
+	if len(owner) > constants.LINK_OWNER_MAX_LEN {
+		return "", fmt.Errorf("Owner must be at most %d characters", constants.LINK_OWNER_MAX_LEN)
+	}
+	return owner, nil
+}
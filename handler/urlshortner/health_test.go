@@ -0,0 +1,155 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func brokenUrl(fallbackUrl string) repository.Url {
+	url := activeUrl("active")
+	url.BrokenSince = sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	url.FallbackUrl = sql.NullString{String: fallbackUrl, Valid: fallbackUrl != ""}
+	return url
+}
+
+func TestRedirect_BrokenLink(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            repository.Url
+		globalFallback string
+		want           string
+	}{
+		{"Link fallback", brokenUrl("https://example.com/moved"), "https://example.com/404", "https://example.com/moved"},
+		{"Global fallback", brokenUrl(""), "https://example.com/404", "https://example.com/404"},
+		{"No fallback", brokenUrl(""), "", "https://example.com/"},
+		{"Not broken", passthroughUrl("https://example.com/", "", false), "https://example.com/404", "https://example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithHealthChecks(new(mocks.MockHealthRepository), tt.globalFallback))
+			mockRepo.On("GetUrl", "abc123").Return(tt.url, nil)
+			mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+			rec := httptest.NewRecorder()
+			handler.Redirect(rec, passthroughRequest("/abc123", ""))
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestShorten_WithOwnerAndFallback(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithHealthChecks(new(mocks.MockHealthRepository), ""))
+
+	body := []byte(`{"longUrl": "https://example.com/flyer", "owner": " growth-team ", "fallbackUrl": "https://EXAMPLE.com"}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/flyer", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return opts.Details.Owner == "growth-team" && opts.FallbackUrl == "https://example.com/"
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"owner":"growth-team"`)
+	assert.Contains(t, rec.Body.String(), `"fallbackUrl":"https://example.com/"`)
+}
+
+func TestShorten_TemplatedFallback(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithHealthChecks(new(mocks.MockHealthRepository), ""))
+
+	body := []byte(`{"longUrl": "https://example.com/flyer", "fallbackUrl": "https://example.com/{{country}}"}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestUpdateLink_Fallback(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithHealthChecks(new(mocks.MockHealthRepository), ""))
+	mockRepo.On("GetUrl", "abc123").Return(brokenUrl("https://example.com/moved"), nil)
+	mockRepo.On("UpdateFallbackUrl", "abc123", "").Return(nil)
+
+	req, _ := http.NewRequest("PATCH", "/admin/links/abc123", bytes.NewBufferString(`{"fallbackUrl": ""}`))
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handler.UpdateLink(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "fallbackUrl")
+	assert.Contains(t, rec.Body.String(), `"broken":true`)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateLinkDetails", mock.Anything, mock.Anything)
+}
+
+func TestListBrokenLinks(t *testing.T) {
+	mockHealth := new(mocks.MockHealthRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithHealthChecks(mockHealth, ""))
+	brokenSince := time.Date(2026, 2, 27, 3, 0, 0, 0, time.UTC)
+	checkedAt := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+
+	link := func(code, owner string, protected bool) repository.BrokenLink {
+		url := brokenUrl("")
+		url.ShortCode = sql.NullString{String: code, Valid: true}
+		url.BrokenSince = sql.NullTime{Time: brokenSince, Valid: true}
+		url.Owner = sql.NullString{String: owner, Valid: owner != ""}
+		if protected {
+			url.PasswordHash = sql.NullString{String: "hash", Valid: true}
+		}
+		return repository.BrokenLink{Url: url, Check: types.HealthCheck{StatusCode: 404, LatencyMs: 80, CheckedAt: checkedAt}, ConsecutiveFailures: 3}
+	}
+	mockHealth.On("ListBrokenLinks", "", 50, 0).Return([]repository.BrokenLink{
+		link("aaa111", "growth-team", false),
+		link("bbb222", "growth-team", true),
+		link("ccc333", "", false),
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/admin/broken-links", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ListBrokenLinks(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response []types.OwnerBrokenLinks
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response, 2)
+	assert.Equal(t, "growth-team", response[0].Owner)
+	assert.Len(t, response[0].Links, 2)
+	assert.Equal(t, "https://example.com/", response[0].Links[0].LongUrl)
+	assert.Empty(t, response[0].Links[1].LongUrl)
+	assert.True(t, response[0].Links[1].PasswordProtected)
+	assert.Equal(t, "", response[1].Owner)
+	assert.Equal(t, 404, response[1].Links[0].StatusCode)
+	assert.Equal(t, 3, response[1].Links[0].ConsecutiveFailures)
+}
+
+func TestListBrokenLinks_InvalidLimit(t *testing.T) {
+	mockHealth := new(mocks.MockHealthRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithHealthChecks(mockHealth, ""))
+
+	req, _ := http.NewRequest("GET", "/admin/broken-links?limit=0", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ListBrokenLinks(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockHealth.AssertNotCalled(t, "ListBrokenLinks", mock.Anything, mock.Anything, mock.Anything)
+}
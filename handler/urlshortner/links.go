@@ -0,0 +1,290 @@
+package urlshortner
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/passthrough"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+// UpdateLink handles PATCH requests to /admin/links/{shortUrl}. Every field of the JSON payload is optional:
+// "targets" replaces the weighted split destinations (an empty list turns the split off), "forwardQuery",
+// "queryConflict" and "prefix" change the passthrough settings, "title", "notes", "folder", "tags" and "owner"
+// the details links are organized by, and "fallbackUrl" where visitors go while the link is broken (an empty
+// URL removes it). Fields that are left out keep their value.
+func (h *Handler) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	var payload struct {
+		Targets       *[]types.LinkTarget `json:"targets"`
+		ForwardQuery  *bool               `json:"forwardQuery"`
+		QueryConflict *string             `json:"queryConflict"`
+		Prefix        *bool               `json:"prefix"`
+
+		Title  *string   `json:"title"`
+		Notes  *string   `json:"notes"`
+		Folder *string   `json:"folder"`
+		Tags   *[]string `json:"tags"`
+		Owner  *string   `json:"owner"`
+
+		FallbackUrl *string `json:"fallbackUrl"`
+	}
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+
+	if payload.Targets != nil {
+		if h.TargetRepository == nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "Split links are not enabled"))
+			return
+		}
+		if status, err := h.prepareTargets(*payload.Targets); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	settings := repository.Passthrough{
+		ForwardQuery:  url.ForwardQuery.Bool,
+		QueryConflict: url.QueryConflict.String,
+		Prefix:        url.IsPrefix.Bool,
+	}
+	if payload.ForwardQuery != nil {
+		settings.ForwardQuery = *payload.ForwardQuery
+	}
+	if payload.QueryConflict != nil {
+		if !passthrough.ValidConflict(*payload.QueryConflict) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("QueryConflict must be %q, %q or %q", passthrough.ConflictTarget, passthrough.ConflictIncoming, passthrough.ConflictAppend))
+			return
+		}
+		settings.QueryConflict = *payload.QueryConflict
+	}
+	if payload.Prefix != nil {
+		settings.Prefix = *payload.Prefix
+	}
+
+	details := repository.LinkDetails{
+		Title:  url.Title.String,
+		Notes:  url.Notes.String,
+		Folder: url.Folder.String,
+		Tags:   url.Tags,
+		Owner:  url.Owner.String,
+	}
+	if payload.Title != nil {
+		details.Title = *payload.Title
+	}
+	if payload.Notes != nil {
+		details.Notes = *payload.Notes
+	}
+	if payload.Folder != nil {
+		details.Folder = *payload.Folder
+	}
+	if payload.Tags != nil {
+		details.Tags = *payload.Tags
+	}
+	if payload.Owner != nil {
+		details.Owner = *payload.Owner
+	}
+	if err := prepareDetails(&details); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fallbackUrl := url.FallbackUrl.String
+	if payload.FallbackUrl != nil {
+		var status int
+		if fallbackUrl, status, err = h.prepareFallback(strings.TrimSpace(*payload.FallbackUrl)); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	if payload.Targets != nil {
+		err = h.TargetRepository.ReplaceTargets(r.Context(), shortUrl, *payload.Targets)
+		if err == nil {
+			h.log(r.Context()).Info().Str("short_code", shortUrl).Int("targets", len(*payload.Targets)).Msg("Split targets updated")
+		}
+	}
+	if err == nil && (payload.ForwardQuery != nil || payload.QueryConflict != nil || payload.Prefix != nil) {
+		err = h.UrlRepository.UpdatePassthrough(r.Context(), shortUrl, settings)
+	}
+	if err == nil && (payload.Title != nil || payload.Notes != nil || payload.Folder != nil || payload.Tags != nil || payload.Owner != nil) {
+		err = h.UrlRepository.UpdateLinkDetails(r.Context(), shortUrl, details)
+	}
+	if err == nil && payload.FallbackUrl != nil {
+		err = h.UrlRepository.UpdateFallbackUrl(r.Context(), shortUrl, fallbackUrl)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to update link")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := types.ResponseUrl{
+		ShortCode:   shortUrl,
+		Prefix:      settings.Prefix,
+		Title:       details.Title,
+		Notes:       details.Notes,
+		Folder:      details.Folder,
+		Tags:        details.Tags,
+		Owner:       details.Owner,
+		FallbackUrl: fallbackUrl,
+		Broken:      url.BrokenSince.Valid,
+	}
+	if payload.Targets != nil {
+		response.Targets = *payload.Targets
+	}
+	if settings.ForwardQuery {
+		response.ForwardQuery = true
+		response.QueryConflict = settings.QueryConflict
+	}
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+// SearchLinks handles GET requests to /links. "q" searches the title, short code, notes and long URL of
+// every link, each "tag" parameter must be present on a link and "folder" matches a folder with its sub
+// folders. It returns a page of links, best matches first, with the total number of matches and the most
+// used tags among them. Long URLs of password protected links are not revealed.
+func (h *Handler) SearchLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	text := strings.TrimSpace(query.Get("q"))
+	if len(text) > constants.LINK_TITLE_MAX_LEN {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("q must be at most %d characters", constants.LINK_TITLE_MAX_LEN))
+		return
+	}
+
+	tags, err := normalizeTags(query["tag"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	folder, err := normalizeFolder(query.Get("folder"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := h.LinkRepository.SearchLinks(r.Context(), repository.LinkQuery{Text: text, Tags: tags, Folder: folder, Limit: limit, Offset: offset})
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("q", text).Msg("Failed to search links")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := types.LinkSearchResult{
+		Links:  make([]types.ResponseUrl, len(page.Links)),
+		Total:  page.Total,
+		Facets: page.Facets,
+	}
+	for i, url := range page.Links {
+		response.Links[i] = types.ResponseUrl{
+			ShortCode: url.ShortCode.String,
+			LongUrl:   url.LongUrl.String,
+			CreatedAt: url.CreatedAt.Time.UTC().String(),
+			Title:     url.Title.String,
+			Notes:     url.Notes.String,
+			Folder:    url.Folder.String,
+			Tags:      url.Tags,
+		}
+		if url.PasswordHash.Valid {
+			response.Links[i].LongUrl = ""
+			response.Links[i].PasswordProtected = true
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+// prepareDetails validates the title, notes, folder, tags and owner of a link and normalizes the folder and tags
+func prepareDetails(d *repository.LinkDetails) error {
+	d.Title = strings.TrimSpace(d.Title)
+	if len(d.Title) > constants.LINK_TITLE_MAX_LEN {
+		return fmt.Errorf("Title must be at most %d characters", constants.LINK_TITLE_MAX_LEN)
+	}
+	if len(d.Notes) > constants.LINK_NOTES_MAX_LEN {
+		return fmt.Errorf("Notes must be at most %d characters", constants.LINK_NOTES_MAX_LEN)
+	}
+
+	var err error
+	if d.Folder, err = normalizeFolder(d.Folder); err != nil {
+		return err
+	}
+	if d.Tags, err = normalizeTags(d.Tags); err != nil {
+		return err
+	}
+	if d.Owner, err = normalizeOwner(d.Owner); err != nil {
+		return err
+	}
+	if len(d.Tags) > constants.LINK_TAGS_MAX {
+		return fmt.Errorf("a link can have at most %d tags", constants.LINK_TAGS_MAX)
+	}
+	return nil
+}
+
+// normalizeTags lowercases tags and drops duplicates. Tags are made of letters, digits, '-' and '_'.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > constants.LINK_TAG_MAX_LEN || strings.IndexFunc(tag, invalidTagRune) >= 0 {
+			return nil, fmt.Errorf("tag %q must be 1 to %d letters, digits, '-' or '_'", tag, constants.LINK_TAG_MAX_LEN)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+func invalidTagRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+}
+
+// normalizeFolder trims the slashes around a folder path, e.g. "/marketing/2026/" becomes "marketing/2026".
+// Empty path segments are refused.
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if folder == "" {
+		return "", nil
+	}
+	if len(folder) > constants.LINK_FOLDER_MAX_LEN {
+		return "", fmt.Errorf("Folder must be at most %d characters", constants.LINK_FOLDER_MAX_LEN)
+	}
+
+	for _, segment := range strings.Split(folder, "/") {
+		if strings.TrimSpace(segment) == "" {
+			return "", fmt.Errorf("folder %q has an empty path segment", folder)
+		}
+	}
+	return folder, nil
+}
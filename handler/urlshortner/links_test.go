@@ -0,0 +1,143 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func adminRequest(method, target, body string) *http.Request {
+	req, _ := http.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	return req
+}
+
+func TestSearchLinks(t *testing.T) {
+	mockLinks := new(mocks.MockLinkRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithLinkSearch(mockLinks))
+	router := adminRouter(handler)
+
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockLinks.On("SearchLinks", repository.LinkQuery{Text: "spring sale", Tags: []string{"sale", "print"}, Folder: "marketing", Limit: 10, Offset: 0}).
+		Return(repository.LinkPage{
+			Links: []repository.Url{
+				{
+					ShortCode: sql.NullString{String: "abc123", Valid: true},
+					LongUrl:   sql.NullString{String: "https://example.com/sale", Valid: true},
+					CreatedAt: sql.NullTime{Time: createdAt, Valid: true},
+					Title:     sql.NullString{String: "Spring Sale", Valid: true},
+					Folder:    sql.NullString{String: "marketing/2026", Valid: true},
+					Tags:      []string{"sale", "print"},
+				},
+				{
+					ShortCode:    sql.NullString{String: "xyz789", Valid: true},
+					LongUrl:      sql.NullString{String: "https://example.com/secret", Valid: true},
+					CreatedAt:    sql.NullTime{Time: createdAt, Valid: true},
+					PasswordHash: sql.NullString{String: "hash", Valid: true},
+					Tags:         []string{"sale", "print"},
+				},
+			},
+			Total:  12,
+			Facets: []types.TagFacet{{Tag: "print", Count: 12}, {Tag: "sale", Count: 12}},
+		}, nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, adminRequest("GET", "/links?q=+spring+sale&tag=Sale&tag=print&tag=sale&folder=/marketing/&limit=10", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"links": [
+			{"shortCode": "abc123", "longUrl": "https://example.com/sale", "createdAt": "2026-03-01 12:00:00 +0000 UTC", "title": "Spring Sale", "folder": "marketing/2026", "tags": ["sale", "print"]},
+			{"shortCode": "xyz789", "createdAt": "2026-03-01 12:00:00 +0000 UTC", "passwordProtected": true, "tags": ["sale", "print"]}
+		],
+		"total": 12,
+		"facets": [{"tag": "print", "count": 12}, {"tag": "sale", "count": 12}]
+	}`, rec.Body.String())
+}
+
+func TestSearchLinks_RequiresAdminToken(t *testing.T) {
+	mockLinks := new(mocks.MockLinkRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithLinkSearch(mockLinks))
+	router := adminRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/links?q=sale", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	mockLinks.AssertNotCalled(t, "SearchLinks", mock.Anything)
+}
+
+func TestSearchLinks_InvalidFilters(t *testing.T) {
+	mockLinks := new(mocks.MockLinkRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithLinkSearch(mockLinks))
+	router := adminRouter(handler)
+
+	for _, target := range []string{"/links?tag=no+spaces", "/links?folder=a//b", "/links?limit=0"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, adminRequest("GET", target, ""))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+	mockLinks.AssertNotCalled(t, "SearchLinks", mock.Anything)
+}
+
+func TestShorten_WithDetails(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	body := `{"longUrl": "https://example.com/sale", "title": " Spring Sale ", "folder": "/marketing/2026/", "tags": ["Sale", "print", "sale"]}`
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/sale", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return assert.ObjectsAreEqual(repository.LinkDetails{Title: "Spring Sale", Folder: "marketing/2026", Tags: []string{"sale", "print"}}, opts.Details)
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"tags":["sale","print"]`)
+}
+
+func TestShorten_TooManyTags(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	tags := make([]string, 21)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("%q", fmt.Sprintf("tag%d", i))
+	}
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"longUrl": "https://example.com/", "tags": [`+strings.Join(tags, ", ")+`]}`))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestUpdateLink_Details(t *testing.T) {
+	mockLinks := new(mocks.MockLinkRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithLinkSearch(mockLinks))
+	router := adminRouter(handler)
+
+	url := activeUrl("active")
+	url.Title = sql.NullString{String: "Spring Sale", Valid: true}
+	url.Notes = sql.NullString{String: "Print flyer", Valid: true}
+	mockRepo.On("GetUrl", "abc123").Return(url, nil)
+	mockRepo.On("UpdateLinkDetails", "abc123", repository.LinkDetails{Title: "Spring Sale", Notes: "Print flyer", Folder: "archive", Tags: []string{"old"}}).Return(nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, adminRequest("PATCH", "/admin/links/abc123", `{"folder": "archive", "tags": ["OLD"]}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"shortCode": "abc123", "title": "Spring Sale", "notes": "Print flyer", "folder": "archive", "tags": ["old"]}`, rec.Body.String())
+	mockRepo.AssertNotCalled(t, "UpdatePassthrough", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,57 @@
+package urlshortner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+var errMetadataNotFound = errors.New("Metadata has not been fetched for this ShortUrl")
+
+// GetMetadata handles GET requests to /shorten/{shortUrl}/metadata. It returns the title, description,
+// OpenGraph image and favicon fetched from the destination of the link. It returns a 404 error if the link
+// does not exist or nothing has been fetched yet; the details of password protected links are never revealed.
+func (h *Handler) GetMetadata(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+	if url.PasswordHash.Valid {
+		utils.WriteError(w, http.StatusNotFound, errMetadataNotFound)
+		return
+	}
+
+	metadata, err := h.MetadataRepository.GetMetadata(r.Context(), shortUrl)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteError(w, http.StatusNotFound, errMetadataNotFound)
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch link metadata")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, metadata)
+}
+
+// enqueueMetadata schedules fetching the page details of a new link. Destinations of password protected
+// links stay private and templated destinations are not fetched, they only resolve per click.
+// A full queue only costs the metadata, so the link is created anyway.
+func (h *Handler) enqueueMetadata(ctx context.Context, shortCode string, longUrl string, protected bool) {
+	if h.MetadataWorker == nil || protected || linktemplate.HasPlaceholders(longUrl) {
+		return
+	}
+
+	if err := h.MetadataWorker.Enqueue(ctx, shortCode, longUrl); err != nil {
+		h.log(ctx).Warn().Err(err).Str("short_code", shortCode).Msg("Failed to schedule metadata fetch")
+	}
+}
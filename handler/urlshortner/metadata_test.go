@@ -0,0 +1,154 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metadata"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/taskqueue"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataWorker fetches the metadata of links into mockMetadata
+func metadataWorker(t *testing.T, mockMetadata *mocks.MockMetadataRepository) *metadata.Worker {
+	logger := zerolog.Nop()
+
+	// The destinations are httptest servers on the loopback interface
+	opts := metadata.DefaultOptions()
+	opts.AllowPrivate = true
+	opts.HostDelay = 0
+
+	queue := taskqueue.New(1, 10, logger)
+	t.Cleanup(queue.Stop)
+	return metadata.NewWorker(metadata.NewFetcher(opts), mockMetadata, queue, logger)
+}
+
+func TestShorten_FetchesMetadata(t *testing.T) {
+	var (
+		mutex     sync.Mutex
+		requested []string
+	)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, r.URL.Path)
+		mutex.Unlock()
+
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Landing page</title><meta name="description" content="All about it"></head></html>`)
+	}))
+	defer destination.Close()
+
+	mockMetadata := new(mocks.MockMetadataRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithMetadata(metadataWorker(t, mockMetadata), mockMetadata))
+
+	protectedCode, plainCode := "secret", "abc123"
+	mockRepo.On("CreateUrlWithOptions", destination.URL+"/secret", mock.Anything).Return(&protectedCode, nil)
+	mockRepo.On("CreateUrl", destination.URL+"/page").Return(&plainCode, nil)
+
+	saved := make(chan types.LinkMetadata, 1)
+	mockMetadata.On("SaveMetadata", plainCode, mock.Anything).
+		Run(func(args mock.Arguments) { saved <- args.Get(1).(types.LinkMetadata) }).
+		Return(nil)
+
+	// The destination of a password protected link is never fetched
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(fmt.Sprintf(`{"longUrl": %q, "password": "hunter2"}`, destination.URL+"/secret")))
+	handler.Shorten(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/shorten", bytes.NewBufferString(fmt.Sprintf(`{"longUrl": %q}`, destination.URL+"/page")))
+	handler.Shorten(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	select {
+	case result := <-saved:
+		assert.Equal(t, "Landing page", result.Title)
+		assert.Equal(t, "All about it", result.Description)
+		assert.Equal(t, destination.URL+"/favicon.ico", result.Favicon)
+	case <-time.After(5 * time.Second):
+		t.Fatal("metadata was not saved")
+	}
+
+	// Jobs run in order on a single worker, so a fetch of the protected link would have happened by now
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.NotContains(t, requested, "/secret")
+	mockMetadata.AssertNotCalled(t, "SaveMetadata", protectedCode, mock.Anything)
+}
+
+func TestGetMetadata(t *testing.T) {
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	request := func() *http.Request {
+		req, _ := http.NewRequest("GET", "/shorten/abc123/metadata", nil)
+		return mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockMetadata := new(mocks.MockMetadataRepository)
+		handler, mockRepo, _ := newTestHandler(urlshortner.WithMetadata(metadataWorker(t, mockMetadata), mockMetadata))
+		mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+		mockMetadata.On("GetMetadata", "abc123").Return(types.LinkMetadata{Title: "Example", Favicon: "https://example.com/favicon.ico", FetchedAt: fetchedAt}, nil)
+
+		rec := httptest.NewRecorder()
+		handler.GetMetadata(rec, request())
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"title": "Example", "favicon": "https://example.com/favicon.ico", "fetchedAt": "2026-03-01T12:00:00Z"}`, rec.Body.String())
+	})
+
+	t.Run("Not fetched yet", func(t *testing.T) {
+		mockMetadata := new(mocks.MockMetadataRepository)
+		handler, mockRepo, _ := newTestHandler(urlshortner.WithMetadata(metadataWorker(t, mockMetadata), mockMetadata))
+		mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+		mockMetadata.On("GetMetadata", "abc123").Return(types.LinkMetadata{}, sql.ErrNoRows)
+
+		rec := httptest.NewRecorder()
+		handler.GetMetadata(rec, request())
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Password protected", func(t *testing.T) {
+		mockMetadata := new(mocks.MockMetadataRepository)
+		handler, mockRepo, _ := newTestHandler(urlshortner.WithMetadata(metadataWorker(t, mockMetadata), mockMetadata))
+		url := activeUrl("active")
+		url.PasswordHash = sql.NullString{String: "hash", Valid: true}
+		mockRepo.On("GetUrl", "abc123").Return(url, nil)
+
+		rec := httptest.NewRecorder()
+		handler.GetMetadata(rec, request())
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockMetadata.AssertNotCalled(t, "GetMetadata", mock.Anything)
+	})
+
+	t.Run("Unknown short code", func(t *testing.T) {
+		mockMetadata := new(mocks.MockMetadataRepository)
+		handler, mockRepo, _ := newTestHandler(urlshortner.WithMetadata(metadataWorker(t, mockMetadata), mockMetadata))
+		mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), sql.ErrNoRows)
+
+		rec := httptest.NewRecorder()
+		handler.GetMetadata(rec, request())
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
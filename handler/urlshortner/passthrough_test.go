@@ -0,0 +1,158 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/passthrough"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func passthroughUrl(longUrl, conflict string, prefix bool) repository.Url {
+	url := activeUrl("active")
+	url.LongUrl = sql.NullString{String: longUrl, Valid: true}
+	url.ForwardQuery = sql.NullBool{Bool: conflict != "", Valid: true}
+	url.QueryConflict = sql.NullString{String: conflict, Valid: conflict != ""}
+	url.IsPrefix = sql.NullBool{Bool: prefix, Valid: true}
+	return url
+}
+
+func passthroughRequest(target, rest string) *http.Request {
+	req, _ := http.NewRequest("GET", target, nil)
+	return mux.SetURLVars(req, map[string]string{"shortUrl": "abc123", "rest": rest})
+}
+
+func TestShorten_WithPassthrough(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	body := []byte(`{"longUrl": "https://example.com/docs", "forwardQuery": true, "queryConflict": "incoming", "prefix": true}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/docs", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return opts.Passthrough == repository.Passthrough{ForwardQuery: true, QueryConflict: passthrough.ConflictIncoming, Prefix: true}
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"forwardQuery":true`)
+	assert.Contains(t, rec.Body.String(), `"queryConflict":"incoming"`)
+	assert.Contains(t, rec.Body.String(), `"prefix":true`)
+}
+
+func TestShorten_InvalidQueryConflict(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	body := []byte(`{"longUrl": "https://example.com/", "forwardQuery": true, "queryConflict": "merge"}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_ForwardsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict string
+		want     string
+	}{
+		{"Target wins", passthrough.ConflictTarget, "https://example.com/?ref=newsletter&utm_source=site"},
+		{"Incoming wins", passthrough.ConflictIncoming, "https://example.com/?ref=newsletter&utm_source=mail"},
+		{"Append keeps both", passthrough.ConflictAppend, "https://example.com/?ref=newsletter&utm_source=site&utm_source=mail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockRepo, mockRedis := newTestHandler()
+			mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/?utm_source=site", tt.conflict, false), nil)
+			mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+			rec := httptest.NewRecorder()
+			handler.Redirect(rec, passthroughRequest("/abc123?utm_source=mail&ref=newsletter", ""))
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirect_IgnoresQueryByDefault(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123?ref=newsletter", ""))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/", rec.Header().Get("Location"))
+}
+
+func TestRedirect_PrefixLinkAppendsPath(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/docs", passthrough.ConflictTarget, true), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123/guides/intro?lang=en", "guides/intro"))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/docs/guides/intro?lang=en", rec.Header().Get("Location"))
+}
+
+func TestRedirect_PathOnNonPrefixLink(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123/anything", "anything"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+}
+
+func TestRedirect_PrefixLinkRefusesDotSegments(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+	mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/docs/", "", true), nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123/../admin", "../admin"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+}
+
+func TestUpdateLink_Passthrough(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+	router := adminRouter(handler)
+
+	mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/", passthrough.ConflictAppend, false), nil)
+	mockRepo.On("UpdatePassthrough", "abc123", repository.Passthrough{ForwardQuery: true, QueryConflict: passthrough.ConflictAppend, Prefix: true}).Return(nil)
+
+	req, _ := http.NewRequest("PATCH", "/admin/links/abc123", bytes.NewBufferString(`{"prefix": true}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"shortCode": "abc123", "forwardQuery": true, "queryConflict": "append", "prefix": true}`, rec.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateLink_TargetsWithoutSplitLinks(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+
+	body := `{"targets": [{"name": "a", "url": "https://example.com/a", "weight": 1}, {"name": "b", "url": "https://example.com/b", "weight": 1}]}`
+	req, _ := http.NewRequest("PATCH", "/admin/links/abc123", bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handler.UpdateLink(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "UpdatePassthrough", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,87 @@
+package urlshortner
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linkauth"
+	"github.com/gorilla/mux"
+)
+
+// unlockCookiePrefix prefixes the name of the cookie that remembers an unlocked link
+const unlockCookiePrefix = "unlock_"
+
+// UnlockLink handles POST requests to /{shortUrl} submitted from the password form of a protected link.
+// A correct password sets a short-lived signed cookie and redirects to the long URL. Wrong passwords are
+// limited per short code; once the limit is reached the form returns 429 until the window has passed.
+func (h *Handler) UnlockLink(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.resolveUrl(r.Context(), shortUrl)
+	if err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	if pathSuffix(r) != "" && !url.IsPrefix.Bool {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !url.PasswordHash.Valid {
+		http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+		return
+	}
+
+	if !h.UnlockLimiter.Reserve(shortUrl) {
+		h.log(r.Context()).Warn().Str("short_code", shortUrl).Msg("Too many wrong password attempts")
+		h.renderPasswordForm(w, r, http.StatusTooManyRequests, shortUrl, "Too many wrong attempts. Please try again later.")
+		return
+	}
+
+	if !linkauth.CheckPassword(url.PasswordHash.String, r.PostFormValue("password")) {
+		h.renderPasswordForm(w, r, http.StatusUnauthorized, shortUrl, "Incorrect password.")
+		return
+	}
+	h.UnlockLimiter.Release(shortUrl)
+
+	if err := h.countClick(r.Context(), url); err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + shortUrl,
+		Value:    h.LinkSigner.Sign(shortUrl, url.PasswordHash.String, time.Now()),
+		Path:     "/" + shortUrl,
+		MaxAge:   int(h.LinkSigner.TTL().Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	h.redirectTo(w, r, url, http.StatusSeeOther)
+}
+
+// isUnlocked reports whether the request carries a valid unlock cookie for a protected link
+func (h *Handler) isUnlocked(r *http.Request, url repository.Url) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + url.ShortCode.String)
+	if err != nil {
+		return false
+	}
+	return h.LinkSigner.Verify(cookie.Value, url.ShortCode.String, url.PasswordHash.String, time.Now())
+}
+
+// renderPasswordForm renders the password prompt of a protected link. The form posts back to the
+// requested URL, so the path and query string of passthrough links survive the unlock.
+func (h *Handler) renderPasswordForm(w http.ResponseWriter, r *http.Request, status int, shortCode string, message string) {
+	h.renderTemplate(w, status, "password.html", struct {
+		ShortCode string
+		Action    string
+		Error     string
+	}{
+		ShortCode: shortCode,
+		Action:    r.URL.RequestURI(),
+		Error:     message,
+	})
+}
@@ -0,0 +1,207 @@
+package urlshortner_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linkauth"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// passwordProtection turns on password protected links, allowing two wrong passwords a minute
+func passwordProtection(t *testing.T) urlshortner.Option {
+	signer, err := linkauth.NewSigner("test-secret", 10*time.Minute)
+	require.NoError(t, err)
+	return urlshortner.WithPasswordProtection(signer, middleware.NewAttemptLimiter(2, time.Minute))
+}
+
+func protectedUrl(t *testing.T, password string) repository.Url {
+	hash, err := linkauth.HashPassword(password)
+	require.NoError(t, err)
+
+	return repository.Url{
+		ShortCode:    sql.NullString{String: "abc123", Valid: true},
+		LongUrl:      sql.NullString{String: "https://docs.internal.example.com/", Valid: true},
+		CreatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		Status:       sql.NullString{String: "active", Valid: true},
+		PasswordHash: sql.NullString{String: hash, Valid: true},
+	}
+}
+
+func unlockRequest(password string) *http.Request {
+	form := url.Values{"password": {password}}
+	req, _ := http.NewRequest("POST", "/abc123", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+}
+
+func TestShorten_WithPassword(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+
+	req, _ := http.NewRequest("POST", "/shorten", strings.NewReader(`{"longUrl": "https://docs.internal.example.com/", "password": "s3cret"}`))
+	rec := httptest.NewRecorder()
+
+	shortString := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://docs.internal.example.com/", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return linkauth.CheckPassword(opts.PasswordHash, "s3cret")
+	})).Return(&shortString, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"shortCode":"abc123","longUrl":"https://docs.internal.example.com/","passwordProtected":true}`, rec.Body.String())
+	mockRepo.AssertNotCalled(t, "CreateUrl", mock.Anything)
+}
+
+func TestShorten_PasswordTooShort(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+
+	req, _ := http.NewRequest("POST", "/shorten", strings.NewReader(`{"longUrl": "https://example.com/", "password": "abc"}`))
+	rec := httptest.NewRecorder()
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestGetShorten_PasswordProtectedHidesLongUrl(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	handler.GetShorten(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "docs.internal.example.com")
+	assert.Contains(t, rec.Body.String(), `"passwordProtected":true`)
+}
+
+func TestRedirect_PasswordProtectedShowsForm(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), `<form method="post" action="/abc123">`)
+	assert.NotContains(t, rec.Body.String(), "docs.internal.example.com")
+}
+
+func TestUnlockLink_CorrectPasswordSetsCookie(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(passwordProtection(t))
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	rec := httptest.NewRecorder()
+	handler.UnlockLink(rec, unlockRequest("s3cret"))
+
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "https://docs.internal.example.com/", rec.Header().Get("Location"))
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "unlock_abc123", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// The cookie unlocks the link on the next visit
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://docs.internal.example.com/", rec.Header().Get("Location"))
+}
+
+func TestUnlockLink_ForgedCookieIsIgnored(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	req.AddCookie(&http.Cookie{Name: "unlock_abc123", Value: "9999999999.forged"})
+	rec := httptest.NewRecorder()
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+}
+
+func TestUnlockLink_WrongPasswordIsRateLimited(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.UnlockLink(rec, unlockRequest("wrong"))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Incorrect password")
+		assert.Empty(t, rec.Result().Cookies())
+	}
+
+	// Even the right password is refused once the limit is reached
+	rec := httptest.NewRecorder()
+	handler.UnlockLink(rec, unlockRequest("s3cret"))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Empty(t, rec.Result().Cookies())
+}
+
+func TestUnlockLink_ConcurrentWrongPasswordsStayLimited(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(passwordProtection(t))
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	codes := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler.UnlockLink(rec, unlockRequest("wrong"))
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	counts := map[int]int{}
+	for _, code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, map[int]int{http.StatusUnauthorized: 2, http.StatusTooManyRequests: 8}, counts)
+}
+
+func TestUnlockLink_CorrectPasswordIsNotCounted(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(passwordProtection(t))
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRepo.On("GetUrl", "abc123").Return(protectedUrl(t, "s3cret"), nil)
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.UnlockLink(rec, unlockRequest("s3cret"))
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.UnlockLink(rec, unlockRequest("wrong"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,95 @@
+package urlshortner
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/routing"
+	"github.com/gorilla/mux"
+)
+
+// previewPage is the data rendered by preview.html
+type previewPage struct {
+	ShortLink string
+	// Continue is the path of the short link itself, so following it is counted like any other click
+	Continue          string
+	Title             string
+	Destination       string
+	PasswordProtected bool
+	// Alternatives are the other destinations routing rules or a split may send visitors to
+	Alternatives []string
+	Owner        string
+	CreatedAt    string
+	Clicks       int64
+	Broken       bool
+}
+
+// Preview handles GET requests to /{shortUrl}+. Instead of redirecting, it renders a page showing where the
+// link goes, when it was created, who owns it and how often it was clicked, so visitors can check a link
+// before following it. Viewing the page is not counted as a click. Links that would not redirect render
+// the same pages Redirect does, and the destination of a password protected link is not revealed.
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.resolveUrl(r.Context(), shortUrl)
+	if err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	info, err := h.linkInfo(r.Context(), url, true)
+	if err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	page := previewPage{
+		ShortLink:         r.Host + "/" + shortUrl,
+		Continue:          "/" + shortUrl,
+		Title:             info.Title,
+		Destination:       info.LongUrl,
+		PasswordProtected: info.PasswordProtected,
+		Owner:             info.Owner,
+		CreatedAt:         url.CreatedAt.Time.UTC().Format("January 2, 2006"),
+		Clicks:            info.Clicks,
+		Broken:            info.Broken,
+	}
+	if !info.PasswordProtected {
+		page.Alternatives = h.alternativeDestinations(r.Context(), url)
+	}
+
+	h.renderTemplate(w, http.StatusOK, "preview.html", page)
+}
+
+// alternativeDestinations lists the routing rule targets and split variants of url other than its long URL
+func (h *Handler) alternativeDestinations(ctx context.Context, url repository.Url) []string {
+	var destinations []string
+	add := func(destination string) {
+		if destination != url.LongUrl.String && !slices.Contains(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+
+	if url.RoutingRules.Valid {
+		rules, err := routing.Parse([]byte(url.RoutingRules.String))
+		if err != nil {
+			h.log(ctx).Error().Err(err).Str("short_code", url.ShortCode.String).Msg("Failed to parse routing rules")
+		}
+		for _, rule := range rules {
+			add(rule.Target)
+		}
+	}
+
+	if h.TargetRepository != nil {
+		targets, err := h.TargetRepository.GetTargets(ctx, url.ShortCode.String)
+		if err != nil {
+			h.log(ctx).Error().Err(err).Str("short_code", url.ShortCode.String).Msg("Failed to fetch split targets")
+		}
+		for _, target := range targets {
+			add(target.Url)
+		}
+	}
+	return destinations
+}
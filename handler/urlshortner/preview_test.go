@@ -0,0 +1,100 @@
+package urlshortner_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPreview(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithSplitTargets(mockTargets, "cookie"))
+	router := redirectRouter(handler)
+
+	url := activeUrl("active")
+	url.CreatedAt = sql.NullTime{Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Valid: true}
+	url.Title = sql.NullString{String: "Spring <Sale>", Valid: true}
+	url.Owner = sql.NullString{String: "marketing", Valid: true}
+	url.ClickCount = sql.NullInt64{Int64: 40, Valid: true}
+	mockRepo.On("GetUrl", "abc123").Return(url, nil)
+	mockTargets.On("GetTargets", "abc123").Return([]types.LinkTarget{
+		{Name: "a", Url: "https://example.com/", Weight: 50},
+		{Name: "b", Url: "https://example.com/b", Weight: 50},
+	}, nil)
+	mockRedis.On("Get", mock.Anything, "clicks:abc123").Return("42", nil)
+
+	req, _ := http.NewRequest("GET", "/abc123+", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "https://example.com/")
+	assert.Contains(t, body, "https://example.com/b")
+	assert.Contains(t, body, "Spring &lt;Sale&gt;")
+	assert.Contains(t, body, "marketing")
+	assert.Contains(t, body, "March 1, 2026")
+	assert.Contains(t, body, "<dd>42</dd>")
+	assert.Contains(t, body, `href="/abc123"`)
+	mockRedis.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+}
+
+func TestPreview_PasswordProtectedHidesDestination(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithSplitTargets(mockTargets, "cookie"))
+	router := redirectRouter(handler)
+
+	url := activeUrl("active")
+	url.LongUrl = sql.NullString{String: "https://example.com/secret", Valid: true}
+	url.PasswordHash = sql.NullString{String: "hash", Valid: true}
+	mockRepo.On("GetUrl", "abc123").Return(url, nil)
+	mockRedis.On("Get", mock.Anything, "clicks:abc123").Return("", redis.Nil)
+
+	req, _ := http.NewRequest("GET", "/abc123+", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "password protected")
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestPreview_Unavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		err    error
+		want   int
+	}{
+		{"Not found", "", sql.ErrNoRows, http.StatusNotFound},
+		{"Taken down", constants.URL_STATUS_TAKEN_DOWN, nil, http.StatusGone},
+		{"Disabled", constants.URL_STATUS_DISABLED, nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTargets := new(mocks.MockTargetRepository)
+			handler, mockRepo, _ := newTestHandler(urlshortner.WithSplitTargets(mockTargets, "cookie"))
+			router := redirectRouter(handler)
+			mockRepo.On("GetUrl", "abc123").Return(activeUrl(tt.status), tt.err)
+
+			req, _ := http.NewRequest("GET", "/abc123+", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "https://example.com/")
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package urlshortner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/qrcode"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+// qrRequest holds the parsed parameters of a QR code request
+type qrRequest struct {
+	format string
+	level  qrcode.Level
+	opts   qrcode.RenderOptions
+}
+
+// cacheKey identifies the rendered image of content for these parameters, including the logo in use
+func (q qrRequest) cacheKey(content string, logoDigest string) string {
+	if q.opts.Logo == nil {
+		logoDigest = ""
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%s|%d|%d|%s|%s|%s", content, q.format, q.level, q.opts.Size, q.opts.Margin,
+		qrcode.HexColor(q.opts.Foreground), qrcode.HexColor(q.opts.Background), logoDigest))
+	return constants.QR_CACHE_KEY_PREFIX + hex.EncodeToString(sum[:])
+}
+
+// QRCode handles GET requests to /links/{shortUrl}/qr. It returns a QR code of the short link as a PNG or,
+// with format=svg, an SVG image. "size" is the width in pixels, "ecl" the error correction level (L, M, Q or H),
+// "margin" the quiet zone in modules and "fg" and "bg" the colours as hex digits. logo=true embeds the
+// configured logo, which needs the Q or H level. The encoded link carries a marker so redirects from scans
+// are counted separately. Rendered images are cached in Redis.
+func (h *Handler) QRCode(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	req, err := h.parseQRRequest(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+	if url.Status.String == constants.URL_STATUS_TAKEN_DOWN {
+		utils.WriteError(w, http.StatusGone, errUrlTakenDown)
+		return
+	}
+
+	content := h.scanUrl(shortUrl)
+	key := req.cacheKey(content, h.qrLogoDigest)
+
+	body, err := h.CacheManager.Get(r.Context(), key)
+	if err != nil {
+		if err != cachemanager.ErrMiss {
+			h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to read cached QR code")
+		}
+
+		code, err := qrcode.Encode([]byte(content), req.level)
+		if err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to encode QR code")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		var buf bytes.Buffer
+		if req.format == "svg" {
+			err = qrcode.SVG(&buf, code, req.opts)
+		} else {
+			err = qrcode.PNG(&buf, code, req.opts)
+		}
+		if err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to render QR code")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		body = buf.String()
+		if err := h.CacheManager.Set(r.Context(), key, body, constants.QR_CACHE_TTL); err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to cache QR code")
+		}
+	}
+
+	if req.format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", shortUrl+"."+req.format))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
+
+// parseQRRequest validates the query parameters of a QR code request and fills in the defaults
+func (h *Handler) parseQRRequest(query url.Values) (qrRequest, error) {
+	req := qrRequest{format: "png", level: qrcode.Medium, opts: qrcode.DefaultOptions()}
+	req.opts.Size = constants.QR_SIZE_DEFAULT
+
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != "png" && format != "svg" {
+			return req, fmt.Errorf("format %q must be png or svg", format)
+		}
+		req.format = format
+	}
+
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < constants.QR_SIZE_MIN || n > constants.QR_SIZE_MAX {
+			return req, fmt.Errorf("size must be between %d and %d pixels", constants.QR_SIZE_MIN, constants.QR_SIZE_MAX)
+		}
+		req.opts.Size = n
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil || n < 0 || n > constants.QR_MARGIN_MAX {
+			return req, fmt.Errorf("margin must be between 0 and %d modules", constants.QR_MARGIN_MAX)
+		}
+		req.opts.Margin = n
+	}
+
+	colors := []struct {
+		name string
+		dst  *color.Color
+	}{{"fg", &req.opts.Foreground}, {"bg", &req.opts.Background}}
+	for _, c := range colors {
+		if value := query.Get(c.name); value != "" {
+			parsed, err := qrcode.ParseColor(value)
+			if err != nil {
+				return req, err
+			}
+			*c.dst = parsed
+		}
+	}
+	if qrcode.HexColor(req.opts.Foreground) == qrcode.HexColor(req.opts.Background) {
+		return req, fmt.Errorf("%s", "fg and bg must be different colours")
+	}
+
+	if logo := query.Get("logo"); logo != "" {
+		embed, err := strconv.ParseBool(logo)
+		if err != nil {
+			return req, fmt.Errorf("logo %q must be true or false", logo)
+		}
+		if embed && h.QRLogo == nil {
+			return req, fmt.Errorf("%s", "No logo has been configured")
+		}
+		if embed {
+			req.opts.Logo = h.QRLogo
+			// The logo hides modules, a higher level keeps the code readable
+			req.level = qrcode.High
+		}
+	}
+
+	if ecl := query.Get("ecl"); ecl != "" {
+		level, err := qrcode.ParseLevel(ecl)
+		if err != nil {
+			return req, err
+		}
+		if req.opts.Logo != nil && level < qrcode.Quartile {
+			return req, fmt.Errorf("%s", "A logo needs error correction level Q or H")
+		}
+		req.level = level
+	}
+
+	return req, nil
+}
+
+// scanUrl is the address encoded in the QR code of a link, marked so scans can be told apart from clicks
+func (h *Handler) scanUrl(shortCode string) string {
+	return strings.TrimRight(h.QRBaseUrl, "/") + "/" + shortCode + "?" + constants.QR_SOURCE_PARAM + "=" + constants.QR_SOURCE_VALUE
+}
+
+// countScan counts a redirect from a QR code scan and returns the request without the scan marker,
+// so it is not forwarded to the destination
+func (h *Handler) countScan(r *http.Request, shortCode string) *http.Request {
+	query := r.URL.Query()
+	values := query[constants.QR_SOURCE_PARAM]
+	if !slices.Contains(values, constants.QR_SOURCE_VALUE) {
+		return r
+	}
+
+	if err := h.ClickCounter.HitScan(r.Context(), shortCode); err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortCode).Msg("Failed to count QR code scan")
+	}
+
+	values = slices.DeleteFunc(values, func(v string) bool { return v == constants.QR_SOURCE_VALUE })
+	if len(values) == 0 {
+		query.Del(constants.QR_SOURCE_PARAM)
+	} else {
+		query[constants.QR_SOURCE_PARAM] = values
+	}
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	return r
+}
+
+// GetScans handles GET requests to /shorten/{shortUrl}/scans. It returns the number of redirects of the link
+// next to the number of them that came from scanning its QR code.
+func (h *Handler) GetScans(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+
+	clicks, err := h.ClickCounter.Count(r.Context(), shortUrl, url.ClickCount.Int64)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch click count")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	scans, err := h.ClickCounter.Scans(r.Context(), shortUrl)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch scan count")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, types.ScanCount{ShortCode: shortUrl, Clicks: clicks, Scans: scans})
+}
+
+// logoDigest identifies a logo in the QR code cache keys, so a new logo is not served from the cache
+func logoDigest(logo image.Image) string {
+	if logo == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
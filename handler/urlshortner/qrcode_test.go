@@ -0,0 +1,181 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func qrRequest(query string) *http.Request {
+	req, _ := http.NewRequest("GET", "/links/abc123/qr?"+query, nil)
+	return mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+}
+
+func TestQRCode_PNG(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Get", mock.Anything, mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "qr:") })).Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Duration(constants.QR_CACHE_TTL)*time.Minute).Return(nil)
+
+	rec := httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest("size=300&fg=1565c0"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+
+	// The rendered image is cached
+	mockRedis.AssertCalled(t, "Set", mock.Anything, mock.Anything, rec.Body.String(), mock.Anything)
+}
+
+func TestQRCode_SVGFromCache(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Get", mock.Anything, mock.Anything).Return("<svg>cached</svg>", nil)
+
+	rec := httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest("format=svg"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/svg+xml", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "<svg>cached</svg>", rec.Body.String())
+	mockRedis.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestQRCode_CacheKeyDependsOnParameters(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Get", mock.Anything, mock.Anything).Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	handler.QRCode(httptest.NewRecorder(), qrRequest("size=200"))
+	handler.QRCode(httptest.NewRecorder(), qrRequest("size=200"))
+	handler.QRCode(httptest.NewRecorder(), qrRequest("size=200&ecl=H"))
+
+	keys := map[string]bool{}
+	for _, call := range mockRedis.Calls {
+		if call.Method == "Set" {
+			keys[call.Arguments.String(1)] = true
+		}
+	}
+	assert.Len(t, keys, 2)
+}
+
+func TestQRCode_Logo(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", logo))
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Get", mock.Anything, mock.Anything).Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	rec := httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest("logo=true&format=svg"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "data:image/png;base64,")
+}
+
+func TestQRCode_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Unknown format", "format=gif"},
+		{"Size too small", "size=10"},
+		{"Size not a number", "size=big"},
+		{"Negative margin", "margin=-1"},
+		{"Unknown level", "ecl=X"},
+		{"Invalid colour", "fg=blue"},
+		{"Same colours", "fg=000000&bg=000"},
+		{"Same colours with hash", "fg=%23ffffff"},
+		{"No logo configured", "logo=true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+			rec := httptest.NewRecorder()
+			handler.QRCode(rec, qrRequest(tt.query))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	handler, _, _ := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	rec := httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest("logo=true&ecl=M"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Q or H")
+}
+
+func TestQRCode_Unavailable(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(repository.Url{}, errors.New("not found")).Once()
+
+	rec := httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest(""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl(constants.URL_STATUS_TAKEN_DOWN), nil).Once()
+	rec = httptest.NewRecorder()
+	handler.QRCode(rec, qrRequest(""))
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func TestRedirect_CountsQRScan(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/", "incoming", false), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(1), nil)
+	mockRedis.On("Incr", mock.Anything, "qr_scans:abc123").Return(int64(1), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123?src=qr&ref=print", ""))
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	// The scan marker is not forwarded to the destination
+	assert.Equal(t, "https://example.com/?ref=print", rec.Header().Get("Location"))
+	mockRedis.AssertCalled(t, "Incr", mock.Anything, "qr_scans:abc123")
+}
+
+func TestRedirect_WithoutScanMarker(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(passthroughUrl("https://example.com/", "incoming", false), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(1), nil)
+
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, passthroughRequest("/abc123?src=newsletter", ""))
+
+	assert.Equal(t, "https://example.com/?src=newsletter", rec.Header().Get("Location"))
+	mockRedis.AssertNotCalled(t, "Incr", mock.Anything, "qr_scans:abc123")
+}
+
+func TestGetScans(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithQRCodes("https://sho.rt/", nil))
+	mockRepo.On("GetUrl", "abc123").Return(limitedUrl(100, 40), nil)
+	mockRedis.On("Get", mock.Anything, "clicks:abc123").Return("42", nil)
+	mockRedis.On("Get", mock.Anything, "qr_scans:abc123").Return("12", nil)
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123/scans", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+	handler.GetScans(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"shortCode":"abc123","clicks":42,"scans":12}`, rec.Body.String())
+}
@@ -0,0 +1,163 @@
+package urlshortner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/destpolicy"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errUrlNotFound  = errors.New("ShortUrl not found")
+	errUrlDisabled  = errors.New("ShortUrl has been disabled pending review")
+	errUrlTakenDown = errors.New("ShortUrl has been taken down")
+	errUrlNotActive = errors.New("ShortUrl is not active yet")
+	errUrlExhausted = errors.New("ShortUrl has reached its click limit")
+)
+
+// resolveUrl fetches the link for shortCode and checks that it may be served.
+// The returned error can be mapped to a response status with resolveStatus.
+func (h *Handler) resolveUrl(ctx context.Context, shortCode string) (repository.Url, error) {
+	url, err := h.UrlRepository.GetUrl(ctx, shortCode)
+	if err != nil {
+		return repository.Url{}, errUrlNotFound
+	}
+
+	switch url.Status.String {
+	case constants.URL_STATUS_DISABLED:
+		return url, errUrlDisabled
+	case constants.URL_STATUS_TAKEN_DOWN:
+		return url, errUrlTakenDown
+	}
+
+	if err := h.Policy.Check(url.LongUrl.String); err != nil {
+		h.log(ctx).Warn().Err(err).Str("short_code", shortCode).Msg("Blocked destination requested")
+		return url, err
+	}
+
+	if url.ActiveFrom.Valid && time.Now().Before(url.ActiveFrom.Time) {
+		return url, errUrlNotActive
+	}
+
+	return url, nil
+}
+
+// countClick counts a redirect of url and returns errUrlExhausted once the link's click limit has been
+// passed. If Redis is unavailable the redirect is allowed unless the reconciled count already reached the limit.
+func (h *Handler) countClick(ctx context.Context, url repository.Url) error {
+	clicks, err := h.ClickCounter.Hit(ctx, url.ShortCode.String, url.ClickCount.Int64)
+	if err != nil {
+		h.log(ctx).Error().Err(err).Str("short_code", url.ShortCode.String).Msg("Failed to count click")
+		clicks = url.ClickCount.Int64 + 1
+	}
+
+	if url.MaxClicks.Valid && clicks > url.MaxClicks.Int64 {
+		return errUrlExhausted
+	}
+	return nil
+}
+
+// resolveStatus maps an error returned by resolveUrl to an HTTP status code
+func resolveStatus(err error) int {
+	switch {
+	case errors.Is(err, errUrlTakenDown), errors.Is(err, errUrlExhausted):
+		return http.StatusGone
+	case errors.Is(err, errUrlDisabled), errors.Is(err, errUrlNotActive), errors.Is(err, destpolicy.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, errUrlNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Redirect handles GET requests to /{shortUrl}. It redirects the browser to the long URL with a 302 Found.
+// Links that were disabled, taken down or point at a blocked destination render a warning page instead,
+// and password protected links render a password form unless the browser has unlocked them recently.
+// Links that are not active yet or have used up their click limit render an explanation page.
+// Links with routing rules redirect to the target of the first rule matching the request.
+// Requests to /{shortUrl}/{rest} are only served for prefix links, see applyPassthrough.
+// Visitors of links whose destination the health checker marked broken are sent to the fallback URL, if any.
+func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.resolveUrl(r.Context(), shortUrl)
+	if err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	if pathSuffix(r) != "" && !url.IsPrefix.Bool {
+		http.NotFound(w, r)
+		return
+	}
+
+	if url.PasswordHash.Valid && !h.isUnlocked(r, url) {
+		h.renderPasswordForm(w, r, http.StatusOK, shortUrl, "")
+		return
+	}
+
+	if err := h.countClick(r.Context(), url); err != nil {
+		h.renderResolveError(w, r, shortUrl, url, err)
+		return
+	}
+
+	h.redirectTo(w, r, url, http.StatusFound)
+}
+
+// pathSuffix returns the path after the short code of a request to a prefix link
+func pathSuffix(r *http.Request) string {
+	return mux.Vars(r)["rest"]
+}
+
+// renderResolveError renders the browser facing response for an error returned by resolveUrl or countClick
+func (h *Handler) renderResolveError(w http.ResponseWriter, r *http.Request, shortCode string, url repository.Url, err error) {
+	status := resolveStatus(err)
+
+	switch {
+	case status == http.StatusNotFound:
+		http.NotFound(w, r)
+	case errors.Is(err, errUrlNotActive):
+		h.renderTemplate(w, status, "unavailable.html", map[string]string{
+			"Title":      "This link is not active yet",
+			"Message":    "The page behind this link has not been launched yet. Please come back later.",
+			"ActiveFrom": url.ActiveFrom.Time.UTC().Format(time.RFC1123),
+		})
+	case errors.Is(err, errUrlExhausted):
+		h.renderTemplate(w, status, "unavailable.html", map[string]string{
+			"Title":   "This link has expired",
+			"Message": "This link could only be opened a limited number of times and that limit has been reached.",
+		})
+	default:
+		h.renderInterstitial(w, status, shortCode, err)
+	}
+}
+
+// renderInterstitial renders the warning page shown instead of redirecting to an unsafe destination
+func (h *Handler) renderInterstitial(w http.ResponseWriter, status int, shortCode string, reason error) {
+	data := struct {
+		Title     string
+		Message   string
+		ShortCode string
+	}{
+		Title:     "This link has been disabled",
+		Message:   "This short link has been reported as harmful and is being reviewed. It will not redirect until the review is complete.",
+		ShortCode: shortCode,
+	}
+
+	switch {
+	case errors.Is(reason, errUrlTakenDown):
+		data.Title = "Warning: this link has been taken down"
+		data.Message = "This short link was found to point at a harmful destination, such as a phishing or malware site, and has been taken down."
+	case errors.Is(reason, destpolicy.ErrBlocked):
+		data.Title = "Warning: blocked destination"
+		data.Message = "This short link points at a destination that is blocked by our safety policy."
+	}
+
+	h.renderTemplate(w, status, "interstitial.html", data)
+}
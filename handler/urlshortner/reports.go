@@ -0,0 +1,231 @@
+package urlshortner
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+// CreateReport handles POST requests to /reports. It takes a JSON payload with the "code" of the
+// reported link and a "reason", and stores an open abuse report. Once enough independent reporters
+// have reported the same link, the link is disabled until an admin reviews it.
+// It returns a 404 error if the code does not exist and a 201 Created with the report otherwise.
+func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code   string `json:"code"`
+		Reason string `json:"reason"`
+	}
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload.Code = strings.TrimSpace(payload.Code)
+	payload.Reason = strings.TrimSpace(payload.Reason)
+
+	if payload.Code == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "Code is required"))
+		return
+	}
+	if payload.Reason == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "Reason is required"))
+		return
+	}
+	if len(payload.Reason) > constants.REPORT_REASON_MAX_LEN {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Reason must be at most %d characters", constants.REPORT_REASON_MAX_LEN))
+		return
+	}
+
+	report, err := h.ReportRepository.CreateReport(r.Context(), payload.Code, payload.Reason, h.clientHash(r))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("%s", "ShortUrl not found"))
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", payload.Code).Msg("Failed to create report")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.log(r.Context()).Info().Str("short_code", payload.Code).Int("report_id", report.ID).Msg("Abuse report received")
+	h.applyReportThreshold(r.Context(), payload.Code)
+
+	utils.WriteJson(w, http.StatusCreated, report)
+}
+
+// applyReportThreshold disables an active link once it has been reported by enough independent reporters
+func (h *Handler) applyReportThreshold(ctx context.Context, shortCode string) {
+	if h.ReportThreshold <= 0 {
+		return
+	}
+
+	count, err := h.ReportRepository.CountOpenReporters(ctx, shortCode)
+	if err != nil {
+		h.log(ctx).Error().Err(err).Str("short_code", shortCode).Msg("Failed to count reports")
+		return
+	}
+	if count < h.ReportThreshold {
+		return
+	}
+
+	url, err := h.UrlRepository.GetUrl(ctx, shortCode)
+	if err != nil || url.Status.String != constants.URL_STATUS_ACTIVE {
+		return
+	}
+
+	if err := h.ReportRepository.SetUrlStatus(ctx, shortCode, constants.URL_STATUS_DISABLED); err != nil {
+		h.log(ctx).Error().Err(err).Str("short_code", shortCode).Msg("Failed to disable reported link")
+		return
+	}
+	h.invalidateLink(ctx, shortCode)
+	h.log(ctx).Warn().Str("short_code", shortCode).Int("reporters", count).Msg("Link disabled after reaching the report threshold")
+}
+
+// ListReports handles GET requests to /admin/reports. The optional "status" query parameter filters
+// the reports, and "limit" and "offset" page through them, newest first.
+func (h *Handler) ListReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+
+	switch status {
+	case "", constants.REPORT_STATUS_OPEN, constants.REPORT_STATUS_DISMISSED, constants.REPORT_STATUS_ACTIONED:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown report status %q", status))
+		return
+	}
+
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reports, err := h.ReportRepository.ListReports(r.Context(), status, limit, offset)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Msg("Failed to list reports")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, reports)
+}
+
+// DismissReport handles POST requests to /admin/reports/{reportId}/dismiss. The report is marked as
+// dismissed, and a link that was disabled automatically is re-enabled when it no longer reaches the
+// report threshold. Only open reports can be dismissed, it returns a 409 Conflict for reviewed ones.
+func (h *Handler) DismissReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.reviewReport(w, r, constants.REPORT_STATUS_DISMISSED, func(ctx context.Context, id int) error {
+		return h.ReportRepository.UpdateReportStatus(ctx, id, constants.REPORT_STATUS_DISMISSED)
+	})
+	if !ok {
+		return
+	}
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), report.ShortCode)
+	if err == nil && url.Status.String == constants.URL_STATUS_DISABLED {
+		count, err := h.ReportRepository.CountOpenReporters(r.Context(), report.ShortCode)
+		if err == nil && count < h.ReportThreshold {
+			if err := h.ReportRepository.SetUrlStatus(r.Context(), report.ShortCode, constants.URL_STATUS_ACTIVE); err != nil {
+				h.log(r.Context()).Error().Err(err).Str("short_code", report.ShortCode).Msg("Failed to re-enable link")
+			} else {
+				h.invalidateLink(r.Context(), report.ShortCode)
+			}
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, report)
+}
+
+// TakedownReport handles POST requests to /admin/reports/{reportId}/takedown. The reported link is
+// taken down and every open report against it is marked as actioned.
+func (h *Handler) TakedownReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.reviewReport(w, r, constants.REPORT_STATUS_ACTIONED, h.ReportRepository.TakedownReport)
+	if !ok {
+		return
+	}
+	h.invalidateLink(r.Context(), report.ShortCode)
+
+	h.log(r.Context()).Warn().Str("short_code", report.ShortCode).Int("report_id", report.ID).Msg("Link taken down")
+	utils.WriteJson(w, http.StatusOK, report)
+}
+
+// reviewReport loads the report named in the URL and reviews it, moving it to status. It writes the error
+// response itself and returns false when the review cannot go ahead.
+func (h *Handler) reviewReport(w http.ResponseWriter, r *http.Request, status string, review func(ctx context.Context, id int) error) (types.Report, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["reportId"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "ReportId must be a number"))
+		return types.Report{}, false
+	}
+
+	report, err := h.ReportRepository.GetReport(r.Context(), id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("%s", "Report not found"))
+		return types.Report{}, false
+	}
+
+	if err := review(r.Context(), id); err != nil {
+		// The report exists, so nothing matched because it was reviewed in the meantime
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("%s", "Report has already been reviewed"))
+			return types.Report{}, false
+		}
+		h.log(r.Context()).Error().Err(err).Int("report_id", id).Msg("Failed to update report")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return types.Report{}, false
+	}
+
+	report.Status = status
+	return report, true
+}
+
+// clientHash identifies a client, e.g. a reporter, without storing their IP address. The hash is keyed,
+// so it cannot be reversed by hashing every IPv4 address.
+func (h *Handler) clientHash(r *http.Request) string {
+	mac := hmac.New(sha256.New, h.ClientHashKey)
+	mac.Write([]byte(utils.GetClientIP(r)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parsePagination parses optional limit and offset query parameters
+func parsePagination(rawLimit, rawOffset string) (int, int, error) {
+	limit, offset := constants.PAGE_SIZE_DEFAULT, 0
+
+	if rawLimit != "" {
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil || n < 1 || n > constants.PAGE_SIZE_MAX {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", constants.PAGE_SIZE_MAX)
+		}
+		limit = n
+	}
+	if rawOffset != "" {
+		n, err := strconv.Atoi(rawOffset)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("%s", "offset must be a positive number")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+// invalidateLink drops the cached link of shortCode after its status changed, so every replica redirects
+// with the new status
+func (h *Handler) invalidateLink(ctx context.Context, shortCode string) {
+	if err := h.CacheManager.Delete(ctx, repository.LinkCacheKey(shortCode)); err != nil {
+		h.log(ctx).Error().Err(err).Str("short_code", shortCode).Msg("Failed to invalidate cached link")
+	}
+}
@@ -0,0 +1,241 @@
+package urlshortner_test
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateReport_MissingReason(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/reports", strings.NewReader(`{"code": "abc123"}`))
+	rec := httptest.NewRecorder()
+
+	handler.CreateReport(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error":"Reason is required"}`, rec.Body.String())
+	mockReports.AssertNotCalled(t, "CreateReport")
+}
+
+func TestCreateReport_UnknownCode(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/reports", strings.NewReader(`{"code": "nope", "reason": "phishing"}`))
+	rec := httptest.NewRecorder()
+
+	mockReports.On("CreateReport", "nope", "phishing", mock.Anything).Return((*types.Report)(nil), sql.ErrNoRows)
+
+	handler.CreateReport(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCreateReport_BelowThreshold(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/reports", strings.NewReader(`{"code": "abc123", "reason": "phishing"}`))
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec := httptest.NewRecorder()
+
+	mockReports.On("CreateReport", "abc123", "phishing", mock.Anything).Return(&types.Report{ID: 1, ShortCode: "abc123", Reason: "phishing", Status: "open"}, nil)
+	mockReports.On("CountOpenReporters", "abc123").Return(2, nil)
+
+	handler.CreateReport(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	mockReports.AssertNotCalled(t, "SetUrlStatus", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "GetUrl", mock.Anything)
+}
+
+func TestCreateReport_ReporterHashIsKeyed(t *testing.T) {
+	reporter := func(secret string) string {
+		mockReports := new(mocks.MockReportRepository)
+		handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 0), urlshortner.WithClientHashSecret(secret))
+
+		var hash string
+		mockReports.On("CreateReport", "abc123", "phishing", mock.Anything).Run(func(args mock.Arguments) {
+			hash = args.String(2)
+		}).Return(&types.Report{ID: 1, ShortCode: "abc123"}, nil)
+
+		req, _ := http.NewRequest("POST", "/reports", strings.NewReader(`{"code": "abc123", "reason": "phishing"}`))
+		req.RemoteAddr = "10.0.0.1:1234"
+		handler.CreateReport(httptest.NewRecorder(), req)
+		return hash
+	}
+
+	// The same on every replica sharing the secret, and not the plain hash of the IP
+	assert.Equal(t, reporter("secret"), reporter("secret"))
+	assert.NotEqual(t, reporter("secret"), reporter("other"))
+	plain := sha256.Sum256([]byte("10.0.0.1"))
+	assert.NotEqual(t, hex.EncodeToString(plain[:]), reporter("secret"))
+}
+
+func TestCreateReport_ReachesThreshold(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/reports", strings.NewReader(`{"code": "abc123", "reason": "phishing"}`))
+	rec := httptest.NewRecorder()
+
+	mockReports.On("CreateReport", "abc123", "phishing", mock.Anything).Return(&types.Report{ID: 3, ShortCode: "abc123", Reason: "phishing", Status: "open"}, nil)
+	mockReports.On("CountOpenReporters", "abc123").Return(3, nil)
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockReports.On("SetUrlStatus", "abc123", "disabled").Return(nil)
+	mockRedis.On("Del", mock.Anything, []string{repository.LinkCacheKey("abc123")}).Return(int64(1), nil)
+
+	handler.CreateReport(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	mockReports.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestTakedownReport(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, mockRedis := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/admin/reports/7/takedown", nil)
+	req = mux.SetURLVars(req, map[string]string{"reportId": "7"})
+	rec := httptest.NewRecorder()
+
+	mockReports.On("GetReport", 7).Return(types.Report{ID: 7, ShortCode: "abc123", Reason: "phishing", Status: "open"}, nil)
+	mockReports.On("TakedownReport", 7).Return(nil)
+	// Replicas stop redirecting to the link from their caches
+	mockRedis.On("Del", mock.Anything, []string{repository.LinkCacheKey("abc123")}).Return(int64(1), nil)
+
+	handler.TakedownReport(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"actioned"`)
+	mockReports.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestDismissReport_ReenablesLink(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/admin/reports/7/dismiss", nil)
+	req = mux.SetURLVars(req, map[string]string{"reportId": "7"})
+	rec := httptest.NewRecorder()
+
+	mockReports.On("GetReport", 7).Return(types.Report{ID: 7, ShortCode: "abc123", Reason: "spam", Status: "open"}, nil)
+	mockReports.On("UpdateReportStatus", 7, "dismissed").Return(nil)
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("disabled"), nil)
+	mockReports.On("CountOpenReporters", "abc123").Return(2, nil)
+	mockReports.On("SetUrlStatus", "abc123", "active").Return(nil)
+	mockRedis.On("Del", mock.Anything, []string{repository.LinkCacheKey("abc123")}).Return(int64(1), nil)
+
+	handler.DismissReport(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockReports.AssertExpectations(t)
+}
+
+func TestDismissReport_AlreadyReviewed(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/admin/reports/7/dismiss", nil)
+	req = mux.SetURLVars(req, map[string]string{"reportId": "7"})
+	rec := httptest.NewRecorder()
+
+	mockReports.On("GetReport", 7).Return(types.Report{ID: 7, ShortCode: "abc123", Reason: "spam", Status: "actioned"}, nil)
+	mockReports.On("UpdateReportStatus", 7, "dismissed").Return(sql.ErrNoRows)
+
+	handler.DismissReport(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	mockReports.AssertExpectations(t)
+	mockReports.AssertNotCalled(t, "SetUrlStatus", mock.Anything, mock.Anything)
+}
+
+func TestDismissReport_NotFound(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	req, _ := http.NewRequest("POST", "/admin/reports/9/dismiss", nil)
+	req = mux.SetURLVars(req, map[string]string{"reportId": "9"})
+	rec := httptest.NewRecorder()
+
+	mockReports.On("GetReport", 9).Return(types.Report{}, errors.New("not found"))
+
+	handler.DismissReport(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminRoutes_RequireToken(t *testing.T) {
+	mockReports := new(mocks.MockReportRepository)
+	handler, _, _ := newTestHandler(urlshortner.WithReports(mockReports, 3))
+
+	router := adminRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/admin/reports", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	mockReports.On("ListReports", "open", 50, 0).Return([]types.Report{}, nil)
+
+	req, _ = http.NewRequest("GET", "/admin/reports?status=open", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
+
+func TestRedirect_Success(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithReports(new(mocks.MockReportRepository), 3))
+
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(1), nil)
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/", rec.Header().Get("Location"))
+}
+
+func TestRedirect_TakenDownShowsInterstitial(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithReports(new(mocks.MockReportRepository), 3))
+
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("taken_down"), nil)
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "taken down")
+}
+
+func TestRedirect_NotFound(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithReports(new(mocks.MockReportRepository), 3))
+
+	req, _ := http.NewRequest("GET", "/nope", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "nope"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "nope").Return(repository.Url{}, sql.ErrNoRows)
+
+	handler.Redirect(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,190 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newLoggedRouter serves the handler like the server does, with the request log written to the returned buffer
+func newLoggedRouter(opts middleware.RequestLogOptions) (http.Handler, *bytes.Buffer, *mocks.MockUrlRepository) {
+	var out bytes.Buffer
+	logger := zerolog.New(&out)
+	handler, mockRepo, mockRedis := newTestHandler()
+	// Tasks are never cached yet
+	mockRedis.On("Get", mock.Anything, mock.Anything).Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestLogger := middleware.NewRequestLogger(logger, opts)
+	router := mux.NewRouter()
+	router.Use(requestLogger.Route)
+	rateLimiter := middleware.NewRateLimiter(0, time.Minute, &logger)
+	handler.RegisterRoutes(router.PathPrefix("/api/v1").Subrouter(), rateLimiter)
+	return requestLogger.Log(router), &out, mockRepo
+}
+
+// logLines decodes the JSON lines logged
+func logLines(t *testing.T, out *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func TestRequestLogger_LogsRequest(t *testing.T) {
+	router, out, mockRepo := newLoggedRouter(middleware.DefaultRequestLogOptions())
+	mockRepo.On("GetTask", "123").Return(types.Task{TaskID: "123", Status: "completed"}, nil)
+	require.NoError(t, utils.SetTrustedProxies([]string{"192.0.2.1"}))
+	t.Cleanup(func() { utils.SetTrustedProxies(nil) })
+
+	// Sent through the proxy in front of the service
+	req := httptest.NewRequest("GET", "/api/v1/task/123", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	requestID := rec.Header().Get("X-Request-ID")
+	assert.Len(t, requestID, 36)
+
+	lines := logLines(t, out)
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, requestID, line["request_id"])
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/api/v1/task/{taskId}", line["route"])
+	assert.Equal(t, "203.0.113.7", line["client_ip"])
+	assert.Equal(t, float64(http.StatusOK), line["status"])
+	assert.Equal(t, float64(rec.Body.Len()), line["bytes"])
+	assert.Contains(t, line, "latency")
+}
+
+func TestRequestLogger_HandlersLogThroughTheRequest(t *testing.T) {
+	router, out, mockRepo := newLoggedRouter(middleware.DefaultRequestLogOptions())
+	mockRepo.On("GetTask", "404").Return(types.Task{}, sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/api/v1/task/404", nil)
+	req.Header.Set("X-Request-ID", "upstream-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "upstream-42", rec.Header().Get("X-Request-ID"))
+
+	lines := logLines(t, out)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Task not found in database", lines[0]["message"])
+	assert.Equal(t, "warn", lines[1]["level"])
+	for _, line := range lines {
+		assert.Equal(t, "upstream-42", line["request_id"])
+		assert.Equal(t, "/api/v1/task/{taskId}", line["route"])
+	}
+}
+
+func TestRequestLogger_RejectsUnusableRequestID(t *testing.T) {
+	router, _, mockRepo := newLoggedRouter(middleware.DefaultRequestLogOptions())
+	mockRepo.On("GetTask", "123").Return(types.Task{TaskID: "123"}, nil)
+
+	for _, id := range []string{"two words", strings.Repeat("a", 129), "line\nbreak"} {
+		req := httptest.NewRequest("GET", "/api/v1/task/123", nil)
+		req.Header.Set("X-Request-ID", id)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.NotEqual(t, id, rec.Header().Get("X-Request-ID"))
+		assert.Len(t, rec.Header().Get("X-Request-ID"), 36)
+	}
+}
+
+func TestRequestLogger_LogsUnmatchedRequests(t *testing.T) {
+	router, out, _ := newLoggedRouter(middleware.DefaultRequestLogOptions())
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/api/v1/nope", http.StatusNotFound},
+		{"DELETE", "/api/v1/task/123", http.StatusMethodNotAllowed},
+	} {
+		out.Reset()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		require.Equal(t, tc.status, rec.Code)
+
+		lines := logLines(t, out)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "unmatched", lines[0]["route"])
+		assert.Equal(t, float64(tc.status), lines[0]["status"])
+		assert.Equal(t, rec.Header().Get("X-Request-ID"), lines[0]["request_id"])
+	}
+}
+
+func TestRequestLogger_SamplesWholeRequests(t *testing.T) {
+	var out bytes.Buffer
+	requestLogger := middleware.NewRequestLogger(zerolog.New(&out), middleware.RequestLogOptions{Level: zerolog.InfoLevel, SampleEvery: 2})
+	router := mux.NewRouter()
+	router.Use(requestLogger.Route)
+	router.HandleFunc("/work", func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Info().Msg("Step one")
+		zerolog.Ctx(r.Context()).Info().Msg("Step two")
+	})
+	handler := requestLogger.Log(router)
+
+	for range 4 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/work", nil))
+	}
+
+	// Two requests with their three lines each, never part of a request
+	lines := logLines(t, &out)
+	require.Len(t, lines, 6)
+	perRequest := map[any]int{}
+	for _, line := range lines {
+		perRequest[line["request_id"]]++
+		assert.Equal(t, "/work", line["route"])
+	}
+	assert.Len(t, perRequest, 2)
+	for _, count := range perRequest {
+		assert.Equal(t, 3, count)
+	}
+}
+
+func TestRequestLogger_Sampling(t *testing.T) {
+	opts := middleware.DefaultRequestLogOptions()
+	opts.SampleEvery = 2
+	router, out, mockRepo := newLoggedRouter(opts)
+	mockRepo.On("GetTask", "123").Return(types.Task{TaskID: "123"}, nil)
+	mockRepo.On("GetTask", "404").Return(types.Task{}, sql.ErrNoRows)
+
+	for range 4 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/task/123", nil))
+	}
+	assert.Len(t, logLines(t, out), 2)
+
+	// Warnings are always kept
+	out.Reset()
+	for range 2 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/task/404", nil))
+	}
+	assert.Len(t, logLines(t, out), 4)
+}
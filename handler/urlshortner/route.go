@@ -1,41 +1,287 @@
 package urlshortner
 
 import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"net/http"
+	"time"
 
 	"github.com/Dev-AustinPeter/url-shortner-go/constants"
 	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
 	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
 	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/clickcounter"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/destpolicy"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linkauth"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metadata"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/passthrough"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/routing"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/urlvalidator"
 	"github.com/Dev-AustinPeter/url-shortner-go/types"
 	"github.com/Dev-AustinPeter/url-shortner-go/utils"
 	"github.com/gorilla/mux"
-	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Handler struct {
-	UrlRepository repository.UrlRepository
-	Logger        *zerolog.Logger
-	CacheManager  *cachemanager.CacheManager
+	UrlRepository    repository.UrlRepository
+	ReportRepository repository.ReportRepository
+	Logger           *zerolog.Logger
+	CacheManager     cachemanager.Cache
+	// CacheLoader reads tasks through CacheManager, coalescing concurrent misses and remembering unknown ids
+	CacheLoader     *cachemanager.Loader
+	UrlValidator    *urlvalidator.Validator
+	Policy          *destpolicy.Engine
+	ReportThreshold int
+	LinkSigner      *linkauth.Signer
+	UnlockLimiter   *middleware.AttemptLimiter
+	// ClientHashKey keys the hashes identifying reporters and visitors of split links by IP
+	ClientHashKey    []byte
+	ClickCounter     *clickcounter.Counter
+	TargetRepository repository.TargetRepository
+	SplitStickiness  string
+	// CampaignRepository enables campaigns whose UTM parameters are added to their links on redirect
+	CampaignRepository repository.CampaignRepository
+	// CountryHeader is the request header the {{country}} placeholder is read from
+	CountryHeader string
+	// LinkRepository enables searching links by text, tags and folder
+	LinkRepository repository.LinkRepository
+	// MetadataWorker fetches the title, description, image and favicon of new links in the background
+	MetadataWorker     *metadata.Worker
+	MetadataRepository repository.MetadataRepository
+	// HealthRepository enables the report of links the health checker marked broken
+	HealthRepository repository.HealthRepository
+	// FallbackUrl is where visitors of broken links without their own fallback go, "" keeps redirecting them
+	FallbackUrl string
+	// QRBaseUrl is the public address of the redirect routes encoded in QR codes, e.g. https://sho.rt
+	QRBaseUrl string
+	// QRLogo is embedded in QR codes requested with logo=true
+	QRLogo       image.Image
+	qrLogoDigest string
+	// Background is the parent context of work that outlives its request, cancelled when the server stops
+	Background context.Context
 }
 
-func NewHandler(repository repository.UrlRepository, logger *zerolog.Logger, cacheManager *cachemanager.CacheManager) *Handler {
-	return &Handler{
+// Option customizes a Handler created by NewHandler
+type Option func(*Handler)
+
+// WithBackgroundContext sets the context background tasks run under, so they stop with the server
+func WithBackgroundContext(ctx context.Context) Option {
+	return func(h *Handler) {
+		h.Background = ctx
+	}
+}
+
+// WithUrlValidator replaces the default validator used to check and canonicalize long URLs
+func WithUrlValidator(v *urlvalidator.Validator) Option {
+	return func(h *Handler) {
+		h.UrlValidator = v
+	}
+}
+
+// WithDestinationPolicy sets the policy engine that destinations are checked against on create and resolve
+func WithDestinationPolicy(p *destpolicy.Engine) Option {
+	return func(h *Handler) {
+		h.Policy = p
+	}
+}
+
+// WithReports enables abuse reporting. A link is disabled automatically once threshold independent
+// reporters have reported it; a threshold of 0 turns automatic disabling off.
+func WithReports(reports repository.ReportRepository, threshold int) Option {
+	return func(h *Handler) {
+		h.ReportRepository = reports
+		h.ReportThreshold = threshold
+	}
+}
+
+// WithPasswordProtection sets the signer for unlock cookies of password protected links and the
+// limiter for wrong password attempts per short code
+func WithPasswordProtection(signer *linkauth.Signer, limiter *middleware.AttemptLimiter) Option {
+	return func(h *Handler) {
+		h.LinkSigner = signer
+		h.UnlockLimiter = limiter
+	}
+}
+
+// WithClientHashSecret keys client hashes with secret, so they are the same on every replica and across
+// restarts. An empty secret keeps the random key.
+func WithClientHashSecret(secret string) Option {
+	return func(h *Handler) {
+		if secret != "" {
+			h.ClientHashKey = []byte(secret)
+		}
+	}
+}
+
+// WithClickCounter sets the counter used to count redirects and enforce click limits
+func WithClickCounter(c *clickcounter.Counter) Option {
+	return func(h *Handler) {
+		h.ClickCounter = c
+	}
+}
+
+// WithSplitTargets enables A/B split links with weighted destinations. Visitors stay on their variant
+// by a cookie (StickinessCookie) or by a hash of their IP address (StickinessIP).
+func WithSplitTargets(targets repository.TargetRepository, stickiness string) Option {
+	return func(h *Handler) {
+		h.TargetRepository = targets
+		h.SplitStickiness = stickiness
+	}
+}
+
+// WithCampaigns enables campaigns with default UTM parameters for the links created under them
+func WithCampaigns(campaigns repository.CampaignRepository) Option {
+	return func(h *Handler) {
+		h.CampaignRepository = campaigns
+	}
+}
+
+// WithCountryHeader sets the request header carrying the visitor's country code, e.g. set by a CDN.
+// An empty name leaves the {{country}} placeholder empty.
+func WithCountryHeader(name string) Option {
+	return func(h *Handler) {
+		h.CountryHeader = name
+	}
+}
+
+// WithLinkSearch enables the admin search over links
+func WithLinkSearch(links repository.LinkRepository) Option {
+	return func(h *Handler) {
+		h.LinkRepository = links
+	}
+}
+
+// WithMetadata fetches the page details of new links with worker and serves them from store
+func WithMetadata(worker *metadata.Worker, store repository.MetadataRepository) Option {
+	return func(h *Handler) {
+		h.MetadataWorker = worker
+		h.MetadataRepository = store
+	}
+}
+
+// WithHealthChecks enables the broken link report. Visitors of broken links are sent to the link's fallback URL,
+// or to fallbackUrl when the link has none; an empty fallbackUrl keeps redirecting them to the broken destination.
+func WithHealthChecks(health repository.HealthRepository, fallbackUrl string) Option {
+	return func(h *Handler) {
+		h.HealthRepository = health
+		h.FallbackUrl = fallbackUrl
+	}
+}
+
+// WithQRCodes enables QR codes of short links pointing at baseUrl. logo may be nil.
+func WithQRCodes(baseUrl string, logo image.Image) Option {
+	return func(h *Handler) {
+		h.QRBaseUrl = baseUrl
+		h.QRLogo = logo
+		h.qrLogoDigest = logoDigest(logo)
+	}
+}
+
+// WithCacheLoader reads tasks through l, so it can be shared with the link cache
+func WithCacheLoader(l *cachemanager.Loader) Option {
+	return func(h *Handler) {
+		h.CacheLoader = l
+	}
+}
+
+func NewHandler(repository repository.UrlRepository, logger *zerolog.Logger, cacheManager cachemanager.Cache, opts ...Option) *Handler {
+	// A random secret never fails to generate in practice; unlock cookies then only last until a restart
+	signer, _ := linkauth.NewSigner("", constants.LINK_UNLOCK_TTL_DEFAULT*time.Minute)
+	// Likewise client hashes then change with every restart
+	hashKey := make([]byte, 32)
+	rand.Read(hashKey)
+
+	h := &Handler{
 		UrlRepository: repository,
 		Logger:        logger,
 		CacheManager:  cacheManager,
+		CacheLoader:   cachemanager.NewLoader(cacheManager, cachemanager.DefaultLoaderOptions()),
+		UrlValidator:  urlvalidator.NewValidator(urlvalidator.DefaultOptions()),
+		Policy:        destpolicy.NewEngine(*logger),
+		LinkSigner:    signer,
+		UnlockLimiter: middleware.NewAttemptLimiter(5, 15*time.Minute),
+		ClientHashKey: hashKey,
+		ClickCounter:  clickcounter.NewCounter(cacheManager, repository, *logger),
+		CountryHeader: constants.COUNTRY_HEADER_DEFAULT,
+		Background:    context.Background(),
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// log returns the logger of the request ctx belongs to, carrying its id, or the handler's logger
+func (h *Handler) log(ctx context.Context) *zerolog.Logger {
+	return middleware.Logger(ctx, h.Logger)
 }
 
 func (h *Handler) RegisterRoutes(r *mux.Router, middleware *middleware.RateLimiter) {
 
 	r.Handle("/shorten", middleware.Limit(http.HandlerFunc(h.Shorten))).Methods("POST")
 	r.Handle("/shorten/{shortUrl}", middleware.Limit(http.HandlerFunc(h.GetShorten))).Methods("GET")
+	r.Handle("/shorten/{shortUrl}/rules", middleware.Limit(http.HandlerFunc(h.GetRuleClicks))).Methods("GET")
+	if h.TargetRepository != nil {
+		r.Handle("/shorten/{shortUrl}/variants", middleware.Limit(http.HandlerFunc(h.GetVariantClicks))).Methods("GET")
+	}
+	if h.QRBaseUrl != "" {
+		r.Handle("/shorten/{shortUrl}/scans", middleware.Limit(http.HandlerFunc(h.GetScans))).Methods("GET")
+		r.Handle("/links/{shortUrl}/qr", middleware.Limit(http.HandlerFunc(h.QRCode))).Methods("GET")
+	}
+	if h.MetadataRepository != nil {
+		r.Handle("/shorten/{shortUrl}/metadata", middleware.Limit(http.HandlerFunc(h.GetMetadata))).Methods("GET")
+	}
 	r.Handle("/shorten", middleware.Limit(http.HandlerFunc(h.CreateTaskId))).Methods("GET")
 	r.Handle("/task/{taskId}", middleware.Limit(http.HandlerFunc(h.GetTaskBaseOnTaskId))).Methods("GET")
+
+	if h.ReportRepository != nil {
+		r.Handle("/reports", middleware.Limit(http.HandlerFunc(h.CreateReport))).Methods("POST")
+	}
+
+	if h.CampaignRepository != nil {
+		r.Handle("/campaigns", middleware.Limit(http.HandlerFunc(h.CreateCampaign))).Methods("POST")
+		r.Handle("/campaigns", middleware.Limit(http.HandlerFunc(h.ListCampaigns))).Methods("GET")
+	}
+}
+
+// RegisterAdminRoutes registers the moderation and link management routes, all of them behind the admin token
+func (h *Handler) RegisterAdminRoutes(r *mux.Router, auth *middleware.AdminAuth) {
+	if h.ReportRepository != nil {
+		r.Handle("/admin/reports", auth.Require(http.HandlerFunc(h.ListReports))).Methods("GET")
+		r.Handle("/admin/reports/{reportId:[0-9]+}/dismiss", auth.Require(http.HandlerFunc(h.DismissReport))).Methods("POST")
+		r.Handle("/admin/reports/{reportId:[0-9]+}/takedown", auth.Require(http.HandlerFunc(h.TakedownReport))).Methods("POST")
+	}
+
+	r.Handle("/admin/links/{shortUrl}", auth.Require(http.HandlerFunc(h.UpdateLink))).Methods("PATCH")
+
+	if h.LinkRepository != nil {
+		r.Handle("/links", auth.Require(http.HandlerFunc(h.SearchLinks))).Methods("GET")
+	}
+
+	if h.HealthRepository != nil {
+		r.Handle("/admin/broken-links", auth.Require(http.HandlerFunc(h.ListBrokenLinks))).Methods("GET")
+	}
+}
+
+// RegisterRedirectRoutes registers the browser facing routes on the root router, outside of the API prefix
+func (h *Handler) RegisterRedirectRoutes(r *mux.Router, middleware *middleware.RateLimiter) {
+	r.Handle("/{shortUrl:[A-Za-z0-9]+}+", middleware.Limit(http.HandlerFunc(h.Preview))).Methods("GET")
+	r.Handle("/{shortUrl:[A-Za-z0-9]+}", middleware.Limit(http.HandlerFunc(h.Redirect))).Methods("GET")
+	r.Handle("/{shortUrl:[A-Za-z0-9]+}", middleware.Limit(http.HandlerFunc(h.UnlockLink))).Methods("POST")
+	r.Handle("/{shortUrl:[A-Za-z0-9]+}/{rest:.*}", middleware.Limit(http.HandlerFunc(h.Redirect))).Methods("GET")
+	r.Handle("/{shortUrl:[A-Za-z0-9]+}/{rest:.*}", middleware.Limit(http.HandlerFunc(h.UnlockLink))).Methods("POST")
 }
 
 // Shorten handles POST requests to /shorten. It takes a JSON payload with a
@@ -43,9 +289,38 @@ func (h *Handler) RegisterRoutes(r *mux.Router, middleware *middleware.RateLimit
 // If the payload is invalid, it returns a 400 error. If the URL cannot be
 // shortened, it returns a 500 error. Otherwise, it returns a 201 Created
 // status with the shortened URL in the response body.
+// The long URL is validated and canonicalized first, so equivalent URLs share a short code,
+// and destinations rejected by the destination policy return a 403 error.
+// An optional "password", "maxClicks" limit, "activeFrom" time, list of routing "rules" or weighted
+// split "targets" creates a new link with those options instead of reusing an existing one.
+// The long URL of a split link defaults to its first target. "forwardQuery", "queryConflict" and "prefix"
+// carry the query string and the path after the short code over to the destination, and a "campaignId"
+// adds the campaign's UTM parameters on redirect. Destinations may contain placeholders such as {{country}}
+// or {{click_id}} that are filled in per click. An optional "title", "notes", "folder", "tags" and "owner" organize
+// the link, and a "fallbackUrl" replaces the destination while the health checker considers it broken.
+// The title, description, image and favicon of the destination are fetched in the background.
 func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
-		LongUrl string `json:"longUrl"`
+		LongUrl    string             `json:"longUrl"`
+		Password   string             `json:"password,omitempty"`
+		MaxClicks  int64              `json:"maxClicks,omitempty"`
+		ActiveFrom *time.Time         `json:"activeFrom,omitempty"`
+		Rules      routing.Rules      `json:"rules,omitempty"`
+		Targets    []types.LinkTarget `json:"targets,omitempty"`
+
+		ForwardQuery  bool   `json:"forwardQuery,omitempty"`
+		QueryConflict string `json:"queryConflict,omitempty"`
+		Prefix        bool   `json:"prefix,omitempty"`
+
+		CampaignID int `json:"campaignId,omitempty"`
+
+		Title  string   `json:"title,omitempty"`
+		Notes  string   `json:"notes,omitempty"`
+		Folder string   `json:"folder,omitempty"`
+		Tags   []string `json:"tags,omitempty"`
+		Owner  string   `json:"owner,omitempty"`
+
+		FallbackUrl string `json:"fallbackUrl,omitempty"`
 	}
 
 	if err := utils.ParseJson(r, &payload); err != nil {
@@ -53,26 +328,164 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(payload.Targets) > 0 && h.TargetRepository == nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "Split links are not enabled"))
+		return
+	}
+	if payload.LongUrl == "" && len(payload.Targets) > 0 {
+		payload.LongUrl = payload.Targets[0].Url
+	}
+
 	if payload.LongUrl == "" {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "LongUrl is required"))
 		return
 	}
 
-	sUrl, err := h.UrlRepository.CreateUrl(payload.LongUrl)
+	longUrl, err := h.UrlValidator.Normalize(payload.LongUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := linktemplate.Validate(longUrl); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.Policy.Check(longUrl); err != nil {
+		h.log(r.Context()).Warn().Err(err).Str("long_url", longUrl).Msg("Destination rejected by policy")
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if payload.MaxClicks < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "MaxClicks must be a positive number"))
+		return
+	}
+	// A limit that is never reached would be worse than no limit
+	if payload.MaxClicks > 0 && !h.ClickCounter.Counting() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s", "MaxClicks needs a cache that counts clicks"))
+		return
+	}
+
+	if payload.QueryConflict != "" && !passthrough.ValidConflict(payload.QueryConflict) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("QueryConflict must be %q, %q or %q", passthrough.ConflictTarget, passthrough.ConflictIncoming, passthrough.ConflictAppend))
+		return
+	}
+
+	if payload.CampaignID < 0 {
+		utils.WriteError(w, http.StatusBadRequest, errCampaignNotFound)
+		return
+	}
+	if payload.CampaignID > 0 {
+		if status, err := h.checkCampaign(r.Context(), payload.CampaignID); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	opts := repository.LinkOptions{
+		MaxClicks:  payload.MaxClicks,
+		ActiveFrom: payload.ActiveFrom,
+		CampaignID: payload.CampaignID,
+		Details: repository.LinkDetails{
+			Title:  payload.Title,
+			Notes:  payload.Notes,
+			Folder: payload.Folder,
+			Tags:   payload.Tags,
+			Owner:  payload.Owner,
+		},
+		Passthrough: repository.Passthrough{
+			ForwardQuery:  payload.ForwardQuery,
+			QueryConflict: payload.QueryConflict,
+			Prefix:        payload.Prefix,
+		},
+	}
+	if err := prepareDetails(&opts.Details); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if payload.FallbackUrl != "" {
+		var status int
+		if opts.FallbackUrl, status, err = h.prepareFallback(payload.FallbackUrl); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	if payload.Password != "" {
+		if len(payload.Password) < constants.LINK_PASSWORD_MIN_LEN || len(payload.Password) > constants.LINK_PASSWORD_MAX_LEN {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Password must be between %d and %d characters", constants.LINK_PASSWORD_MIN_LEN, constants.LINK_PASSWORD_MAX_LEN))
+			return
+		}
+
+		if opts.PasswordHash, err = linkauth.HashPassword(payload.Password); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if len(payload.Rules) > 0 {
+		var status int
+		if opts.RoutingRules, status, err = h.prepareRules(payload.Rules); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	if len(payload.Targets) > 0 {
+		if status, err := h.prepareTargets(payload.Targets); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+		opts.Targets = payload.Targets
+	}
+
+	var sUrl *string
+	if opts.PasswordHash != "" || opts.MaxClicks > 0 || opts.ActiveFrom != nil || opts.RoutingRules != nil || len(opts.Targets) > 0 || !opts.Passthrough.IsZero() || opts.CampaignID > 0 || !opts.Details.IsZero() || opts.FallbackUrl != "" {
+		sUrl, err = h.UrlRepository.CreateUrlWithOptions(r.Context(), longUrl, opts)
+	} else {
+		sUrl, err = h.UrlRepository.CreateUrl(r.Context(), longUrl)
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	utils.WriteJson(w, http.StatusCreated, types.ResponseUrl{
-		ShortCode: *sUrl,
-		LongUrl:   payload.LongUrl,
-	})
+	h.enqueueMetadata(r.Context(), *sUrl, longUrl, payload.Password != "")
+
+	response := types.ResponseUrl{
+		ShortCode:         *sUrl,
+		LongUrl:           longUrl,
+		PasswordProtected: payload.Password != "",
+		MaxClicks:         payload.MaxClicks,
+		Targets:           payload.Targets,
+		ForwardQuery:      payload.ForwardQuery,
+		Prefix:            payload.Prefix,
+		CampaignID:        payload.CampaignID,
+		Title:             opts.Details.Title,
+		Notes:             opts.Details.Notes,
+		Folder:            opts.Details.Folder,
+		Tags:              opts.Details.Tags,
+		Owner:             opts.Details.Owner,
+		FallbackUrl:       opts.FallbackUrl,
+	}
+	if payload.ForwardQuery {
+		response.QueryConflict = cmp.Or(payload.QueryConflict, passthrough.ConflictTarget)
+	}
+	if payload.ActiveFrom != nil {
+		response.ActiveFrom = payload.ActiveFrom.UTC().String()
+	}
+	utils.WriteJson(w, http.StatusCreated, response)
 
 }
 
 // GetShorten handles GET requests to /shorten/{shortUrl}. It attempts to fetch the URL from the database.
-// If the URL is not found, it returns a 404 error. Otherwise, it returns the URL in the response body.
+// If the URL is not found, it returns a 404 error. If the link has been disabled pending review, is not active yet
+// or its destination is blocked by the destination policy, it returns a 403 error, and a 410 error if it has been
+// taken down or has reached its click limit.
+// Otherwise, it returns the URL in the response body. The long URL of a password protected link is not revealed.
 func (h *Handler) GetShorten(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	shortUrl := vars["shortUrl"]
@@ -82,24 +495,69 @@ func (h *Handler) GetShorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, err := h.UrlRepository.GetUrl(shortUrl)
+	url, err := h.resolveUrl(r.Context(), shortUrl)
 	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("%s", "ShortUrl not found"))
+		utils.WriteError(w, resolveStatus(err), err)
 		return
 	}
 
-	utils.WriteJson(w, http.StatusOK, types.ResponseUrl{
-		ShortCode: url.ShortCode.String,
+	response, err := h.linkInfo(r.Context(), url, false)
+	if err != nil {
+		utils.WriteError(w, resolveStatus(err), err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+// linkInfo describes a resolved link the way GetShorten returns it. The number of clicks is looked up
+// for links with a click limit, or for every link when withClicks is set, and errUrlExhausted is
+// returned once the limit has been reached. The long URL of a password protected link is left out.
+func (h *Handler) linkInfo(ctx context.Context, url repository.Url, withClicks bool) (types.ResponseUrl, error) {
+	shortUrl := url.ShortCode.String
+	response := types.ResponseUrl{
+		ShortCode: shortUrl,
 		LongUrl:   url.LongUrl.String,
 		CreatedAt: url.CreatedAt.Time.UTC().String(),
-	})
+	}
+
+	if url.MaxClicks.Valid || withClicks {
+		clicks, err := h.ClickCounter.Count(ctx, shortUrl, url.ClickCount.Int64)
+		if err != nil {
+			h.log(ctx).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch click count")
+			clicks = url.ClickCount.Int64
+		}
+		if url.MaxClicks.Valid && clicks >= url.MaxClicks.Int64 {
+			return types.ResponseUrl{}, errUrlExhausted
+		}
+		response.MaxClicks = url.MaxClicks.Int64
+		response.Clicks = clicks
+	}
+	if url.ActiveFrom.Valid {
+		response.ActiveFrom = url.ActiveFrom.Time.UTC().String()
+	}
+	if url.ForwardQuery.Bool {
+		response.ForwardQuery = true
+		response.QueryConflict = url.QueryConflict.String
+	}
+	response.Prefix = url.IsPrefix.Bool
+	response.CampaignID = int(url.CampaignID.Int64)
+	response.Title = url.Title.String
+	response.Owner = url.Owner.String
+	response.Broken = url.BrokenSince.Valid
+
+	if url.PasswordHash.Valid {
+		response.LongUrl = ""
+		response.PasswordProtected = true
+	}
+	return response, nil
 }
 
 // CreateTaskId handles GET requests to /task. It creates a new task in the database and starts it in a separate goroutine.
 // The task is responsible for processing all URLs in the database and storing the result in the task's result field.
 // If the task creation fails, it returns a 500 error. Otherwise, it returns the created task in the response body.
 func (h *Handler) CreateTaskId(w http.ResponseWriter, r *http.Request) {
-	task, err := h.UrlRepository.CreateTaskId()
+	task, err := h.UrlRepository.CreateTaskId(r.Context())
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -109,7 +567,7 @@ func (h *Handler) CreateTaskId(w http.ResponseWriter, r *http.Request) {
 	 * By running it in a separate goroutine, the server can continue to handle other requests while the task is being processed.
 	 * in production, you may want to consider using a task queue or a background job processing system to handle long-running tasks.
 	 */
-	go h.processTask(task.TaskID)
+	go h.processTask(trace.SpanContextFromContext(r.Context()), h.log(r.Context()), task.TaskID)
 
 	utils.WriteJson(w, http.StatusCreated, types.Task{
 		TaskID:    task.TaskID,
@@ -118,9 +576,9 @@ func (h *Handler) CreateTaskId(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetTaskBaseOnTaskId handles GET requests to /task/{taskId}. It attempts to fetch the task from the cache first. If the cache
-// is a miss, it fetches the task from the database and stores it in the cache for future requests. If the task is not found in
-// the database, it returns a 404 error.
+// GetTaskBaseOnTaskId handles GET requests to /task/{taskId}. The task is read through the cache loader: concurrent requests
+// for a task that is not cached share a single database query and task ids that do not exist are remembered for a short
+// while, so they return 404 without asking the database again.
 func (h *Handler) GetTaskBaseOnTaskId(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskId := vars["taskId"]
@@ -130,43 +588,31 @@ func (h *Handler) GetTaskBaseOnTaskId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try fetching from cache first
-	data, err := h.CacheManager.Get(r.Context(), taskId)
-	if err != nil && err != redis.Nil {
-		h.Logger.Error().Err(err).Str("task_id", taskId).Msg("Failed to fetch task from cache")
-	}
-
-	// If cache hit, return the task immediately
-	if err == nil && data != "" {
-		var task types.Task
-		if json.Unmarshal([]byte(data), &task) == nil { // Avoid redundant error checking
-			h.Logger.Info().Str("task_id", taskId).Msg("Task fetched from cache")
-			task.CreatedAt = task.CreatedAt.UTC()
-			utils.WriteJson(w, http.StatusOK, task)
-			return
+	data, found, err := h.CacheLoader.Get(r.Context(), taskId, constants.CACHE_TTL_PERMANENT, func(ctx context.Context) (string, bool, error) {
+		task, err := h.UrlRepository.GetTask(ctx, taskId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
 		}
-		h.Logger.Error().Str("task_id", taskId).Msg("Failed to unmarshal task from cache")
-	}
-
-	// Fetch from database if cache miss
-	task, err := h.UrlRepository.GetTask(taskId)
-	if err != nil {
-		h.Logger.Warn().Str("task_id", taskId).Msg("Task not found in database")
+		if err != nil {
+			return "", false, err
+		}
+		jsonTask, err := json.Marshal(task)
+		return string(jsonTask), true, err
+	})
+	if err != nil || !found {
+		h.log(r.Context()).Warn().Err(err).Str("task_id", taskId).Msg("Task not found in database")
 		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("%s", "Task not found"))
 		return
 	}
 
-	task.CreatedAt = task.CreatedAt.UTC()
-
-	// Store task in cache for future requests
-	if jsonTask, err := json.Marshal(task); err == nil {
-		if err = h.CacheManager.Set(r.Context(), taskId, string(jsonTask), constants.CACHE_TTL_PERMANENT); err != nil {
-			h.Logger.Error().Err(err).Str("task_id", taskId).Msg("Failed to set task in cache")
-		}
-	} else {
-		h.Logger.Error().Err(err).Str("task_id", taskId).Msg("Failed to marshal task for caching")
+	var task types.Task
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		h.log(r.Context()).Error().Err(err).Str("task_id", taskId).Msg("Failed to unmarshal task from cache")
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("%s", "Failed to read task"))
+		return
 	}
 
+	task.CreatedAt = task.CreatedAt.UTC()
 	utils.WriteJson(w, http.StatusOK, task)
 }
 
@@ -176,52 +622,69 @@ func (h *Handler) GetTaskBaseOnTaskId(w http.ResponseWriter, r *http.Request) {
 // Otherwise, it marshals the URLs into JSON format and updates the task status to "completed" with the result.
 // In case of any error during the process, it updates the task status to "failed" and logs the error details.
 
-func (h *Handler) processTask(taskId string) {
+func (h *Handler) processTask(requestSpan trace.SpanContext, requestLogger *zerolog.Logger, taskId string) {
+	// The task outlives the request, so it gets its own trace linked to the request that created it
+	// It also outlives the request context, so it is bounded by the server and its own timeout instead
+	ctx, cancel := context.WithTimeout(h.Background, constants.EXPORT_TASK_TIMEOUT*time.Minute)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "processTask", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.Link{SpanContext: requestSpan}), trace.WithAttributes(attribute.String("task.id", taskId)))
+	defer span.End()
+	// Lines of the task carry the id of the request that created it
+	ctx = requestLogger.With().Str("task_id", taskId).Logger().WithContext(ctx)
+
 	if taskId == "" {
-		h.Logger.Error().Msg("Invalid task ID")
+		h.log(ctx).Error().Msg("Invalid task ID")
 		return
 	}
 
 	// Mark task as processing
-	if err := h.UrlRepository.UpdateTask(taskId, "processing", nil); err != nil {
-		h.Logger.Error().Err(err).Msg("Failed to update task status to processing")
+	if err := h.UrlRepository.UpdateTask(ctx, taskId, "processing", nil); err != nil {
+		h.log(ctx).Error().Err(err).Msg("Failed to update task status to processing")
 		return
 	}
 
-	// Fetch all URLs
-	urls, err := h.UrlRepository.GetAllUrls()
-	if err != nil {
-		h.Logger.Error().Err(err).Msg("Failed to fetch URLs")
-		h.UrlRepository.UpdateTask(taskId, "failed", nil)
-		return
+	// Fetch all URLs, one batch at a time
+	urlsMap := []map[string]string{}
+	var lastID int64
+	for {
+		urls, err := h.UrlRepository.ListUrls(ctx, lastID, constants.EXPORT_BATCH_SIZE)
+		if err != nil {
+			h.log(ctx).Error().Err(err).Msg("Failed to fetch URLs")
+			// Still record the failure when the task was cancelled or timed out
+			h.UrlRepository.UpdateTask(context.WithoutCancel(ctx), taskId, "failed", nil)
+			return
+		}
+
+		for _, url := range urls {
+			urlsMap = append(urlsMap, map[string]string{"short": url.ShortCode.String, "long": url.LongUrl.String})
+		}
+		if len(urls) < constants.EXPORT_BATCH_SIZE {
+			break
+		}
+		lastID = urls[len(urls)-1].ID.Int64
 	}
 
 	// Handle empty result case
-	if len(urls) == 0 {
-		h.Logger.Warn().Msg("No URLs found, marking task as completed with empty result")
-		h.UrlRepository.UpdateTask(taskId, "completed", nil)
+	if len(urlsMap) == 0 {
+		h.log(ctx).Warn().Msg("No URLs found, marking task as completed with empty result")
+		h.UrlRepository.UpdateTask(ctx, taskId, "completed", nil)
 		return
 	}
 
-	// Pre-allocate slice for efficiency
-	urlsMap := make([]map[string]string, len(urls))
-	for i, url := range urls {
-		urlsMap[i] = map[string]string{"short": url.ShortCode.String, "long": url.LongUrl.String}
-	}
-
 	// Convert to JSON
 	result, err := json.Marshal(urlsMap)
 	if err != nil {
-		h.Logger.Error().Err(err).Msg("Failed to marshal URL data")
-		h.UrlRepository.UpdateTask(taskId, "failed", nil)
+		h.log(ctx).Error().Err(err).Msg("Failed to marshal URL data")
+		h.UrlRepository.UpdateTask(ctx, taskId, "failed", nil)
 		return
 	}
 
 	// Mark task as completed
-	if err := h.UrlRepository.UpdateTask(taskId, "completed", result); err != nil {
-		h.Logger.Error().Err(err).Msg("Failed to update task status to completed")
+	if err := h.UrlRepository.UpdateTask(ctx, taskId, "completed", result); err != nil {
+		h.log(ctx).Error().Err(err).Msg("Failed to update task status to completed")
 		return
 	}
 
-	h.Logger.Info().Str("task_id", taskId).Msg("Task completed successfully")
+	h.log(ctx).Info().Msg("Task completed successfully")
 }
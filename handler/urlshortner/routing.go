@@ -0,0 +1,216 @@
+package urlshortner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/passthrough"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/routing"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+// prepareRules validates the routing rules submitted with a new link and canonicalizes their targets
+// like long URLs. It returns the rules encoded for storage, the status code to answer with and an error.
+func (h *Handler) prepareRules(rules routing.Rules) ([]byte, int, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	for i := range rules {
+		target, err := h.UrlValidator.Normalize(rules[i].Target)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("rule %q: %w", rules[i].Name, err)
+		}
+		if err := linktemplate.Validate(target); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("rule %q: %w", rules[i].Name, err)
+		}
+		if err := h.Policy.Check(target); err != nil {
+			return nil, http.StatusForbidden, fmt.Errorf("rule %q: %w", rules[i].Name, err)
+		}
+		rules[i].Target = target
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return data, http.StatusOK, nil
+}
+
+// destinationChoice is where a redirect goes and which analytics buckets it is counted in
+type destinationChoice struct {
+	Url string
+	// Rule is the matched routing rule, or routing.DefaultBucket if the link has rules and none matched
+	Rule string
+	// Variant is the split variant picked for the visitor
+	Variant string
+}
+
+// destination picks the target of the first routing rule matching the request. Without a matching rule,
+// split links pick a weighted variant per visitor, and every other link redirects to its long URL.
+func (h *Handler) destination(w http.ResponseWriter, r *http.Request, url repository.Url) (destinationChoice, error) {
+	choice := destinationChoice{Url: url.LongUrl.String}
+
+	if url.RoutingRules.Valid {
+		choice.Rule = routing.DefaultBucket
+
+		rules, err := routing.Parse([]byte(url.RoutingRules.String))
+		if err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", url.ShortCode.String).Msg("Failed to parse routing rules")
+		} else if rule, ok := rules.Match(routing.NewRequest(r, time.Now())); ok {
+			choice.Url, choice.Rule = rule.Target, rule.Name
+		}
+	}
+
+	if choice.Rule == "" || choice.Rule == routing.DefaultBucket {
+		if target, ok := h.pickTarget(w, r, url.ShortCode.String); ok {
+			choice.Url, choice.Variant = target.Url, target.Name
+		}
+	}
+
+	// Targets other than the long URL are checked on resolve too, the policy may have changed since creation
+	if choice.Url != url.LongUrl.String {
+		if err := h.Policy.Check(choice.Url); err != nil {
+			h.log(r.Context()).Warn().Err(err).Str("short_code", url.ShortCode.String).Str("target", choice.Url).Msg("Blocked routing target requested")
+			return choice, err
+		}
+	}
+	return choice, nil
+}
+
+// redirectTo sends the browser to the destination picked for the request and counts the click
+// for the matched routing rule and split variant, and as a QR code scan if it came from one
+func (h *Handler) redirectTo(w http.ResponseWriter, r *http.Request, url repository.Url, code int) {
+	r = h.countScan(r, url.ShortCode.String)
+
+	choice, err := h.destination(w, r, url)
+	if err != nil {
+		h.renderResolveError(w, r, url.ShortCode.String, url, err)
+		return
+	}
+
+	if choice.Rule != "" {
+		if err := h.ClickCounter.HitRule(r.Context(), url.ShortCode.String, choice.Rule); err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", url.ShortCode.String).Str("rule", choice.Rule).Msg("Failed to count rule click")
+		}
+		// The destination depends on the request, so shared caches must not reuse it for other clients
+		w.Header().Set("Vary", "User-Agent, Accept-Language")
+	}
+	if choice.Variant != "" {
+		if err := h.ClickCounter.HitVariant(r.Context(), url.ShortCode.String, choice.Variant); err != nil {
+			h.log(r.Context()).Error().Err(err).Str("short_code", url.ShortCode.String).Str("variant", choice.Variant).Msg("Failed to count variant click")
+		}
+	}
+	if choice.Rule != "" || choice.Variant != "" {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	if fallback := h.fallbackFor(url, choice.Url); fallback != "" {
+		// The destination may recover, so the fallback must not be cached
+		w.Header().Set("Cache-Control", "private, no-cache")
+		h.log(r.Context()).Debug().Str("short_code", url.ShortCode.String).Str("fallback_url", fallback).Msg("Redirecting broken link to fallback")
+		http.Redirect(w, r, fallback, code)
+		return
+	}
+
+	if linktemplate.HasPlaceholders(choice.Url) {
+		// Every click gets its own click id
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	target, err := h.finalDestination(r, url, choice.Url)
+	if err != nil {
+		h.log(r.Context()).Warn().Err(err).Str("short_code", url.ShortCode.String).Msg("Failed to apply passthrough")
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, target, code)
+}
+
+// finalDestination fills in the placeholders of the destination picked for the request, carries the
+// query string and path over and adds the UTM parameters of the link's campaign where they are missing
+func (h *Handler) finalDestination(r *http.Request, url repository.Url, destination string) (string, error) {
+	campaign := h.campaignFor(r.Context(), url)
+
+	if linktemplate.HasPlaceholders(destination) {
+		values := h.templateValues(r, url, campaign)
+		destination = linktemplate.Expand(destination, values)
+		h.log(r.Context()).Debug().Str("short_code", url.ShortCode.String).Str("click_id", values[linktemplate.ClickID]).Msg("Destination template expanded")
+	}
+
+	destination, err := applyPassthrough(destination, r, url)
+	if err != nil {
+		return "", err
+	}
+
+	if campaign != nil {
+		return passthrough.MergeQuery(destination, campaignParams(campaign), passthrough.ConflictTarget)
+	}
+	return destination, nil
+}
+
+// applyPassthrough appends the path after the short code of a prefix link to the destination and
+// merges the incoming query parameters into it when the link forwards them
+func applyPassthrough(destination string, r *http.Request, url repository.Url) (string, error) {
+	var err error
+	if url.IsPrefix.Bool {
+		if destination, err = passthrough.AppendPath(destination, pathSuffix(r)); err != nil {
+			return "", err
+		}
+	}
+	if url.ForwardQuery.Bool {
+		if destination, err = passthrough.MergeQuery(destination, r.URL.Query(), url.QueryConflict.String); err != nil {
+			return "", err
+		}
+	}
+	return destination, nil
+}
+
+// GetRuleClicks handles GET requests to /shorten/{shortUrl}/rules. It returns every routing rule of the link
+// with the number of redirects it received, followed by the "default" bucket for requests no rule matched.
+// Targets of password protected links are not revealed.
+func (h *Handler) GetRuleClicks(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+
+	rules, err := routing.Parse([]byte(url.RoutingRules.String))
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to parse routing rules")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rules = append(rules, routing.Rule{Name: routing.DefaultBucket, Target: url.LongUrl.String})
+
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name
+	}
+	counts, err := h.ClickCounter.RuleCounts(r.Context(), shortUrl, names)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch rule clicks")
+		utils.WriteError(w, http.StatusServiceUnavailable, fmt.Errorf("%s", "Click counts are temporarily unavailable"))
+		return
+	}
+
+	response := make([]types.RuleClicks, len(rules))
+	for i, rule := range rules {
+		response[i] = types.RuleClicks{Name: rule.Name, Clicks: counts[rule.Name]}
+		if !url.PasswordHash.Valid {
+			response[i].Target = rule.Target
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
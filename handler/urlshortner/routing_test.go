@@ -0,0 +1,125 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/destpolicy"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const appRules = `[
+	{"name": "ios", "target": "https://apps.apple.com/app/id1", "os": ["ios"]},
+	{"name": "android", "target": "https://play.google.com/store/apps/details?id=app", "os": ["android"]}
+]`
+
+func routedUrl() repository.Url {
+	url := activeUrl("active")
+	url.RoutingRules = sql.NullString{String: appRules, Valid: true}
+	return url
+}
+
+func TestShorten_WithRoutingRules(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+
+	body := []byte(`{"longUrl": "https://example.com/", "rules": [{"name": "iOS", "target": "https://Apps.Apple.com/app/id1", "os": ["iOS"]}]}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return string(opts.RoutingRules) == `[{"name":"ios","target":"https://apps.apple.com/app/id1","os":["ios"]}]`
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestShorten_InvalidRoutingRules(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler()
+	urlshortner.WithDestinationPolicy(destpolicy.NewEngine(zerolog.Nop(), destpolicy.NewLoopRule(nil, destpolicy.DefaultShorteners)))(handler)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"Unknown os", `{"longUrl": "https://example.com/", "rules": [{"name": "a", "target": "https://example.com/a", "os": ["symbian"]}]}`, http.StatusBadRequest},
+		{"Invalid target", `{"longUrl": "https://example.com/", "rules": [{"name": "a", "target": "javascript:alert(1)"}]}`, http.StatusBadRequest},
+		{"Blocked target", `{"longUrl": "https://example.com/", "rules": [{"name": "a", "target": "https://bit.ly/abc"}]}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			handler.Shorten(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_RoutingRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		ua       string
+		location string
+		bucket   string
+	}{
+		{"iPhone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148", "https://apps.apple.com/app/id1", "ios"},
+		{"Android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36", "https://play.google.com/store/apps/details?id=app", "android"},
+		{"Desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0", "https://example.com/", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockRepo, mockRedis := newTestHandler()
+			rec := httptest.NewRecorder()
+			req := redirectRequest()
+			req.Header.Set("User-Agent", tt.ua)
+
+			mockRepo.On("GetUrl", "abc123").Return(routedUrl(), nil)
+			mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(1), nil)
+			mockRedis.On("Incr", mock.Anything, "rule_clicks:abc123:"+tt.bucket).Return(int64(1), nil).Once()
+
+			handler.Redirect(rec, req)
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+			assert.Contains(t, rec.Header().Get("Vary"), "User-Agent")
+			mockRedis.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetRuleClicks(t *testing.T) {
+	handler, mockRepo, mockRedis := newTestHandler()
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123/rules", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(routedUrl(), nil)
+	mockRedis.On("Get", mock.Anything, "rule_clicks:abc123:ios").Return("12", nil)
+	mockRedis.On("Get", mock.Anything, "rule_clicks:abc123:android").Return("", redis.Nil)
+	mockRedis.On("Get", mock.Anything, "rule_clicks:abc123:default").Return("3", nil)
+
+	handler.GetRuleClicks(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"name": "ios", "target": "https://apps.apple.com/app/id1", "clicks": 12},
+		{"name": "android", "target": "https://play.google.com/store/apps/details?id=app", "clicks": 0},
+		{"name": "default", "target": "https://example.com/", "clicks": 3}
+	]`, rec.Body.String())
+}
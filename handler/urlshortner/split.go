@@ -0,0 +1,159 @@
+package urlshortner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/routing"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gorilla/mux"
+)
+
+// Ways of keeping a visitor on the same split variant
+const (
+	StickinessCookie = "cookie"
+	StickinessIP     = "ip"
+)
+
+// visitorCookie holds the random visitor id split links are sticky on
+const visitorCookie = "vid"
+
+// prepareTargets validates the weighted destinations of a split link and canonicalizes them like long URLs.
+// It returns the status code to answer with and an error.
+func (h *Handler) prepareTargets(targets []types.LinkTarget) (int, error) {
+	if len(targets) == 1 || len(targets) > routing.MaxVariants {
+		return http.StatusBadRequest, fmt.Errorf("a split link needs between 2 and %d targets", routing.MaxVariants)
+	}
+
+	names := make(map[string]bool, len(targets))
+	for i := range targets {
+		target := &targets[i]
+
+		if !routing.ValidName(target.Name) {
+			return http.StatusBadRequest, fmt.Errorf("target %d: name must be 1 to 32 lowercase letters, digits, '-' or '_' and not %q", i+1, routing.DefaultBucket)
+		}
+		if names[target.Name] {
+			return http.StatusBadRequest, fmt.Errorf("target %q: duplicate name", target.Name)
+		}
+		names[target.Name] = true
+
+		if target.Weight < 1 || target.Weight > routing.MaxWeight {
+			return http.StatusBadRequest, fmt.Errorf("target %q: weight must be between 1 and %d", target.Name, routing.MaxWeight)
+		}
+
+		url, err := h.UrlValidator.Normalize(target.Url)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("target %q: %w", target.Name, err)
+		}
+		if err := linktemplate.Validate(url); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("target %q: %w", target.Name, err)
+		}
+		if err := h.Policy.Check(url); err != nil {
+			return http.StatusForbidden, fmt.Errorf("target %q: %w", target.Name, err)
+		}
+		target.Url = url
+	}
+	return http.StatusOK, nil
+}
+
+// pickTarget picks the weighted destination of a split link for the visitor making the request.
+// It returns false for links without destinations and when they cannot be loaded.
+func (h *Handler) pickTarget(w http.ResponseWriter, r *http.Request, shortCode string) (types.LinkTarget, bool) {
+	if h.TargetRepository == nil {
+		return types.LinkTarget{}, false
+	}
+
+	targets, err := h.TargetRepository.GetTargets(r.Context(), shortCode)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortCode).Msg("Failed to fetch split targets")
+		return types.LinkTarget{}, false
+	}
+	if len(targets) == 0 {
+		return types.LinkTarget{}, false
+	}
+
+	weights := make([]int, len(targets))
+	for i, target := range targets {
+		weights[i] = target.Weight
+	}
+
+	i := routing.PickWeighted(weights, h.visitorKey(w, r), shortCode)
+	if i < 0 {
+		return types.LinkTarget{}, false
+	}
+	return targets[i], true
+}
+
+// visitorKey identifies the visitor split links are sticky on, either by a long-lived cookie that is
+// set on the first visit or by a hash of the client IP
+func (h *Handler) visitorKey(w http.ResponseWriter, r *http.Request) string {
+	if h.SplitStickiness == StickinessIP {
+		return h.clientHash(r)
+	}
+
+	if cookie, err := r.Cookie(visitorCookie); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return h.clientHash(r)
+	}
+	visitor := hex.EncodeToString(id)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    visitor,
+		Path:     "/",
+		MaxAge:   constants.VISITOR_COOKIE_MAX_AGE,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return visitor
+}
+
+// GetVariantClicks handles GET requests to /shorten/{shortUrl}/variants. It returns the weighted destinations
+// of a split link with the number of redirects each received. Destinations of password protected links are not revealed.
+func (h *Handler) GetVariantClicks(w http.ResponseWriter, r *http.Request) {
+	shortUrl := mux.Vars(r)["shortUrl"]
+
+	url, err := h.UrlRepository.GetUrl(r.Context(), shortUrl)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, errUrlNotFound)
+		return
+	}
+
+	targets, err := h.TargetRepository.GetTargets(r.Context(), shortUrl)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch split targets")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
+	}
+	counts, err := h.ClickCounter.VariantCounts(r.Context(), shortUrl, names)
+	if err != nil {
+		h.log(r.Context()).Error().Err(err).Str("short_code", shortUrl).Msg("Failed to fetch variant clicks")
+		utils.WriteError(w, http.StatusServiceUnavailable, fmt.Errorf("%s", "Click counts are temporarily unavailable"))
+		return
+	}
+
+	response := make([]types.VariantClicks, len(targets))
+	for i, target := range targets {
+		response[i] = types.VariantClicks{Name: target.Name, Weight: target.Weight, Clicks: counts[target.Name]}
+		if !url.PasswordHash.Valid {
+			response[i].Url = target.Url
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
@@ -0,0 +1,169 @@
+package urlshortner_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/handler/urlshortner"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var splitTargets = []types.LinkTarget{
+	{Name: "a", Url: "https://example.com/a", Weight: 70},
+	{Name: "b", Url: "https://example.com/b", Weight: 30},
+}
+
+func TestShorten_SplitTargets(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithSplitTargets(mockTargets, urlshortner.StickinessCookie))
+
+	body := []byte(`{"targets": [{"name": "a", "url": "https://Example.com/a", "weight": 70}, {"name": "b", "url": "https://example.com/b", "weight": 30}]}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	shortCode := "abc123"
+	// The targets are stored with the link
+	mockRepo.On("CreateUrlWithOptions", "https://example.com/a", mock.MatchedBy(func(opts repository.LinkOptions) bool {
+		return assert.ObjectsAreEqual(splitTargets, opts.Targets)
+	})).Return(&shortCode, nil)
+
+	handler.Shorten(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"targets":[{"name":"a","url":"https://example.com/a","weight":70}`)
+	mockRepo.AssertExpectations(t)
+	mockTargets.AssertNotCalled(t, "ReplaceTargets", mock.Anything, mock.Anything)
+}
+
+func TestShorten_InvalidSplitTargets(t *testing.T) {
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithSplitTargets(new(mocks.MockTargetRepository), urlshortner.StickinessCookie))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Single target", `{"targets": [{"name": "a", "url": "https://example.com/a", "weight": 1}]}`},
+		{"Zero weight", `{"targets": [{"name": "a", "url": "https://example.com/a", "weight": 0}, {"name": "b", "url": "https://example.com/b", "weight": 1}]}`},
+		{"Duplicate name", `{"targets": [{"name": "a", "url": "https://example.com/a", "weight": 1}, {"name": "a", "url": "https://example.com/b", "weight": 1}]}`},
+		{"Invalid url", `{"targets": [{"name": "a", "url": "ftp://example.com/a", "weight": 1}, {"name": "b", "url": "https://example.com/b", "weight": 1}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			handler.Shorten(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "CreateUrlWithOptions", mock.Anything, mock.Anything)
+}
+
+func TestRedirect_SplitIsStickyPerVisitor(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithSplitTargets(mockTargets, urlshortner.StickinessCookie))
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockTargets.On("GetTargets", "abc123").Return(splitTargets, nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	// The first visit assigns a visitor cookie
+	rec := httptest.NewRecorder()
+	handler.Redirect(rec, redirectRequest())
+	assert.Equal(t, http.StatusFound, rec.Code)
+	first := rec.Header().Get("Location")
+	assert.Contains(t, []string{"https://example.com/a", "https://example.com/b"}, first)
+
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "vid", cookies[0].Name)
+	}
+
+	// Later visits with the cookie land on the same variant
+	for i := 0; i < 5; i++ {
+		req := redirectRequest()
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+
+		handler.Redirect(rec, req)
+		assert.Equal(t, first, rec.Header().Get("Location"))
+		assert.Empty(t, rec.Result().Cookies())
+	}
+
+	variant := map[string]string{"https://example.com/a": "a", "https://example.com/b": "b"}[first]
+	mockRedis.AssertNumberOfCalls(t, "Incr", 12)
+	mockRedis.AssertCalled(t, "Incr", mock.Anything, "variant_clicks:abc123:"+variant)
+}
+
+func TestRedirect_SplitByClientIP(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithSplitTargets(mockTargets, urlshortner.StickinessIP))
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockTargets.On("GetTargets", "abc123").Return(splitTargets, nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	var locations []string
+	for i := 0; i < 3; i++ {
+		req := redirectRequest()
+		req.RemoteAddr = "203.0.113.7:5555"
+		rec := httptest.NewRecorder()
+
+		handler.Redirect(rec, req)
+		assert.Empty(t, rec.Result().Cookies())
+		locations = append(locations, rec.Header().Get("Location"))
+	}
+	assert.Equal(t, locations[0], locations[1])
+	assert.Equal(t, locations[0], locations[2])
+}
+
+func TestGetVariantClicks(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, mockRedis := newTestHandler(urlshortner.WithSplitTargets(mockTargets, urlshortner.StickinessCookie))
+
+	req, _ := http.NewRequest("GET", "/shorten/abc123/variants", nil)
+	req = mux.SetURLVars(req, map[string]string{"shortUrl": "abc123"})
+	rec := httptest.NewRecorder()
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockTargets.On("GetTargets", "abc123").Return(splitTargets, nil)
+	mockRedis.On("Get", mock.Anything, "variant_clicks:abc123:a").Return("70", nil)
+	mockRedis.On("Get", mock.Anything, "variant_clicks:abc123:b").Return("31", nil)
+
+	handler.GetVariantClicks(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"name": "a", "url": "https://example.com/a", "weight": 70, "clicks": 70},
+		{"name": "b", "url": "https://example.com/b", "weight": 30, "clicks": 31}
+	]`, rec.Body.String())
+}
+
+func TestUpdateLink_RequiresAdminToken(t *testing.T) {
+	mockTargets := new(mocks.MockTargetRepository)
+	handler, mockRepo, _ := newTestHandler(urlshortner.WithSplitTargets(mockTargets, urlshortner.StickinessCookie))
+	router := adminRouter(handler)
+
+	body := `{"targets": [{"name": "a", "url": "https://example.com/a", "weight": 70}, {"name": "b", "url": "https://example.com/b", "weight": 30}]}`
+
+	req, _ := http.NewRequest("PATCH", "/admin/links/abc123", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockTargets.On("ReplaceTargets", "abc123", splitTargets).Return(nil)
+
+	req, _ = http.NewRequest("PATCH", "/admin/links/abc123", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockTargets.AssertExpectations(t)
+}
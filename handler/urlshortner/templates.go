@@ -0,0 +1,30 @@
+package urlshortner
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// templates holds the HTML pages served by the browser facing routes
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// renderTemplate renders an HTML template. The page is rendered into a buffer first
+// so a template error can still be turned into a 500 response.
+func (h *Handler) renderTemplate(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		h.Logger.Error().Err(err).Str("template", name).Msg("Failed to render template")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
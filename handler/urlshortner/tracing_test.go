@@ -0,0 +1,187 @@
+package urlshortner_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedRouter serves the handler like the server does, with every span exported to the returned exporter
+func newTracedRouter(t *testing.T) (*mux.Router, *tracetest.InMemoryExporter, *mocks.MockUrlRepository, *mocks.MockRedisClient) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	tracing.SetProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		tracing.SetProvider(previous)
+	})
+
+	handler, mockRepo, mockRedis := newTestHandler()
+	handler.UrlRepository = repository.WithTracing(mockRepo, constants.DB_SYSTEM_POSTGRES)
+
+	logger := zerolog.Nop()
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	rateLimiter := middleware.NewRateLimiter(0, time.Minute, &logger)
+	handler.RegisterRoutes(router.PathPrefix("/api/v1").Subrouter(), rateLimiter)
+	handler.RegisterRedirectRoutes(router, rateLimiter)
+	return router, exporter, mockRepo, mockRedis
+}
+
+// spanTree renders spans as an indented tree in the order they started, marking spans with links
+func spanTree(spans tracetest.SpanStubs) string {
+	spans = slices.Clone(spans)
+	slices.SortStableFunc(spans, func(a, b tracetest.SpanStub) int { return a.StartTime.Compare(b.StartTime) })
+
+	children := map[trace.SpanID][]tracetest.SpanStub{}
+	var roots []tracetest.SpanStub
+	for _, span := range spans {
+		if span.Parent.IsValid() && !span.Parent.IsRemote() {
+			children[span.Parent.SpanID()] = append(children[span.Parent.SpanID()], span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+
+	var tree strings.Builder
+	var write func(span tracetest.SpanStub, depth int)
+	write = func(span tracetest.SpanStub, depth int) {
+		tree.WriteString(strings.Repeat("  ", depth) + span.Name)
+		if len(span.Links) > 0 {
+			tree.WriteString(" (linked)")
+		}
+		tree.WriteString("\n")
+		for _, child := range children[span.SpanContext.SpanID()] {
+			write(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		write(root, 0)
+	}
+	return tree.String()
+}
+
+// attributeOf returns the value of the attribute key of span, nil when it is not set
+func attributeOf(span tracetest.SpanStub, key string) any {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func TestTracing_TaskLookup(t *testing.T) {
+	router, exporter, mockRepo, mockRedis := newTracedRouter(t)
+	mockRedis.On("Get", mock.Anything, "123").Return("", redis.Nil)
+	mockRedis.On("Set", mock.Anything, "123", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("GetTask", "123").Return(types.Task{TaskID: "123", Status: "completed"}, nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/task/123", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, "GET /api/v1/task/{taskId}\n  redis GET\n  UrlRepository.GetTask\n  redis SET\n", spanTree(exporter.GetSpans()))
+
+	spans := exporter.GetSpans()
+	server := spans[len(spans)-1]
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "/api/v1/task/{taskId}", attributeOf(server, "http.route"))
+	assert.Equal(t, int64(http.StatusOK), attributeOf(server, "http.response.status_code"))
+	assert.Equal(t, false, attributeOf(spans[0], "cache.hit"))
+	assert.Equal(t, trace.SpanKindClient, spans[1].SpanKind)
+	assert.Equal(t, constants.DB_SYSTEM_POSTGRES, attributeOf(spans[1], "db.system"))
+}
+
+func TestTracing_ContinuesIncomingTrace(t *testing.T) {
+	router, exporter, mockRepo, mockRedis := newTracedRouter(t)
+	mockRepo.On("GetUrl", "abc123").Return(activeUrl("active"), nil)
+	mockRedis.On("Incr", mock.Anything, "clicks:abc123").Return(int64(1), nil)
+
+	req := httptest.NewRequest("GET", "/abc123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	assert.Equal(t, "GET /{shortUrl:[A-Za-z0-9]+}\n  UrlRepository.GetUrl\n  redis INCR\n", spanTree(exporter.GetSpans()))
+	for _, span := range exporter.GetSpans() {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	}
+}
+
+func TestTracing_RecordsErrors(t *testing.T) {
+	router, exporter, mockRepo, _ := newTracedRouter(t)
+	mockRepo.On("GetUrl", "abc123").Return(repository.Url{}, errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/abc123", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "connection refused", spans[0].Status.Description)
+	assert.Equal(t, "abc123", attributeOf(spans[0], "short_code"))
+}
+
+func TestTracing_LinksBackgroundTask(t *testing.T) {
+	router, exporter, mockRepo, _ := newTracedRouter(t)
+	done := make(chan struct{})
+	mockRepo.On("CreateTaskId").Return(&types.Task{TaskID: "t-1", Status: "pending"}, nil)
+	mockRepo.On("UpdateTask", "t-1", "processing", mock.Anything).Return(nil)
+	mockRepo.On("ListUrls", int64(0), mock.Anything).Return([]repository.Url{}, nil)
+	mockRepo.On("UpdateTask", "t-1", "completed", mock.Anything).Return(nil).Run(func(mock.Arguments) { close(done) })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/shorten", nil))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task did not complete")
+	}
+	require.Eventually(t, func() bool {
+		return len(exporter.GetSpans()) == 6
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "GET /api/v1/shorten\n  UrlRepository.CreateTaskId\n"+
+		"processTask (linked)\n  UrlRepository.UpdateTask\n  UrlRepository.ListUrls\n  UrlRepository.UpdateTask\n", spanTree(exporter.GetSpans()))
+
+	var request, task tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.Name {
+		case "GET /api/v1/shorten":
+			request = span
+		case "processTask":
+			task = span
+		}
+	}
+	require.Len(t, task.Links, 1)
+	assert.Equal(t, request.SpanContext, task.Links[0].SpanContext)
+	assert.NotEqual(t, request.SpanContext.TraceID(), task.SpanContext.TraceID())
+}
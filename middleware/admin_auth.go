@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/rs/zerolog"
+)
+
+// AdminAuth protects admin routes with a static bearer token
+type AdminAuth struct {
+	token  string
+	logger *zerolog.Logger
+}
+
+// NewAdminAuth initializes a new AdminAuth. An empty token disables every admin route.
+func NewAdminAuth(token string, logger *zerolog.Logger) *AdminAuth {
+	return &AdminAuth{
+		token:  token,
+		logger: logger,
+	}
+}
+
+// Require only lets requests through that carry "Authorization: Bearer <token>"
+func (a *AdminAuth) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("%s", "Admin API is disabled"))
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			Logger(r.Context(), a.logger).Warn().Str("ip", utils.GetClientIP(r)).Str("path", r.URL.Path).Msg("Unauthorized admin request")
+			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("%s", "Unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter limits failed attempts per key within a time window, e.g. wrong passwords per
+// short code. Unlike RateLimiter it is keyed by the caller and not by client IP, and successful
+// attempts are not counted.
+type AttemptLimiter struct {
+	failures  map[string]*attemptWindow
+	mutex     sync.Mutex
+	max       int
+	window    time.Duration
+	lastPrune time.Time
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+// NewAttemptLimiter initializes a new AttemptLimiter allowing max failed attempts per key and window
+func NewAttemptLimiter(max int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		failures:  make(map[string]*attemptWindow),
+		max:       max,
+		window:    window,
+		lastPrune: time.Now(),
+	}
+}
+
+// Reserve counts an attempt for key and reports whether it is allowed. Checking and counting happen
+// together, so concurrent attempts cannot exceed the limit. An allowed attempt that succeeds is given
+// back with Release, so only failed attempts stay counted.
+func (l *AttemptLimiter) Reserve(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	w, found := l.failures[key]
+	if !found || now.Sub(w.start) >= l.window {
+		w = &attemptWindow{start: now}
+		l.failures[key] = w
+	}
+	if w.count >= l.max {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// Release gives back an attempt reserved for key that succeeded
+func (l *AttemptLimiter) Release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if w, found := l.failures[key]; found && w.count > 0 {
+		w.count--
+	}
+}
+
+// Reset forgets the failed attempts recorded for key
+func (l *AttemptLimiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, key)
+}
+
+// prune removes expired windows, at most once per window so Reserve stays cheap
+func (l *AttemptLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.window {
+		return
+	}
+
+	for key, w := range l.failures {
+		if now.Sub(w.start) >= l.window {
+			delete(l.failures, key)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequests = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "urlshortner_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route and method.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"route", "method"})
+)
+
+// statusRecorder remembers the status code and the size of the body written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Metrics counts requests and records their latency per route. Routes are labelled with their
+// template, such as /api/v1/shorten/{shortUrl}, so short codes do not create new series.
+// It must be installed with Router.Use, which runs it after the route has been matched.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r, "unmatched")
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+	})
+}
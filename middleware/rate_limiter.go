@@ -2,29 +2,52 @@ package middleware
 
 import (
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
 	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 )
 
+var rateLimited = metrics.Factory.NewCounter(prometheus.CounterOpts{
+	Name: "urlshortner_rate_limited_requests_total",
+	Help: "Requests rejected by the rate limiter.",
+})
+
+// RateLimiter limits requests per client IP with a token bucket: a client may send burst requests at
+// once, and one more every limit after that
 type RateLimiter struct {
-	visitors map[string]time.Time
+	visitors map[string]*visitor
 	mutex    sync.Mutex
 	limit    time.Duration
+	burst    int
 	cleanup  time.Duration
 	logger   *zerolog.Logger
 	stopChan chan struct{}
 }
 
+// visitor is the token bucket of one client
+type visitor struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// NewRateLimiter initializes a new RateLimiter allowing one request per limit and client. A limit of 0
+// allows every request.
 func NewRateLimiter(limit time.Duration, cleanupInterval time.Duration, logger *zerolog.Logger) *RateLimiter {
+	return NewBurstRateLimiter(limit, 1, cleanupInterval, logger)
+}
+
+// NewBurstRateLimiter initializes a new RateLimiter allowing burst requests at once and one more per
+// limit and client, e.g. for browsers that follow a link twice or load a form and then post it
+func NewBurstRateLimiter(limit time.Duration, burst int, cleanupInterval time.Duration, logger *zerolog.Logger) *RateLimiter {
 	rl := &RateLimiter{
-		visitors: make(map[string]time.Time),
+		visitors: make(map[string]*visitor),
 		limit:    limit,
+		burst:    max(burst, 1),
 		cleanup:  cleanupInterval,
 		logger:   logger,
 		stopChan: make(chan struct{}),
@@ -36,38 +59,45 @@ func NewRateLimiter(limit time.Duration, cleanupInterval time.Duration, logger *
 
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rl.mutex.Lock()
-		defer rl.mutex.Unlock()
-
-		clientIP := rl.getClientIP(r)
-		log.Println("clientIP: ", clientIP)
-
-		// Ensures unlocking even if panic occurs
-		lastVisit, found := rl.visitors[clientIP]
+		clientIP := utils.GetClientIP(r)
 
-		if found && time.Since(lastVisit) < rl.limit {
-			rl.logger.Warn().Str("ip", clientIP).Msg("Too many requests")
+		// The lock only guards the buckets, requests are served concurrently
+		if !rl.allow(clientIP, time.Now()) {
+			Logger(r.Context(), rl.logger).Warn().Str("ip", clientIP).Msg("Too many requests")
+			rateLimited.Inc()
 			utils.WriteError(w, http.StatusTooManyRequests, fmt.Errorf("%s", "Too many requests"))
 			return
 		}
 
-		rl.visitors[clientIP] = time.Now()
-		log.Println("clientIP: ", clientIP)
 		next.ServeHTTP(w, r)
 	})
 }
 
-// getClientIP extracts the real client IP, considering proxy headers
-func (rl *RateLimiter) getClientIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
+// allow takes a token from the bucket of clientIP, refilled for the time since its last request
+func (rl *RateLimiter) allow(clientIP string, now time.Time) bool {
+	if rl.limit <= 0 {
+		return true
 	}
-	return r.RemoteAddr
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	v, found := rl.visitors[clientIP]
+	if !found {
+		rl.visitors[clientIP] = &visitor{tokens: float64(rl.burst - 1), lastSeen: now}
+		return true
+	}
+
+	v.tokens = min(float64(rl.burst), v.tokens+float64(now.Sub(v.lastSeen))/float64(rl.limit))
+	v.lastSeen = now
+	if v.tokens < 1 {
+		return false
+	}
+	v.tokens--
+	return true
 }
 
-// cleanupExpiredEntries periodically removes old entries
+// cleanupExpiredEntries periodically removes the buckets that are full again
 func (rl *RateLimiter) cleanupExpiredEntries() {
 	ticker := time.NewTicker(rl.cleanup)
 	defer ticker.Stop()
@@ -76,23 +106,18 @@ func (rl *RateLimiter) cleanupExpiredEntries() {
 		select {
 		case <-ticker.C:
 			now := time.Now()
+			refill := rl.limit * time.Duration(rl.burst)
 
 			rl.mutex.Lock()
-			var expired []string
-			for ip, lastVisit := range rl.visitors {
-				if now.Sub(lastVisit) > rl.limit {
-					expired = append(expired, ip)
+			for ip, v := range rl.visitors {
+				if now.Sub(v.lastSeen) > refill {
+					delete(rl.visitors, ip)
 				}
 			}
-
-			// Remove expired entries
-			for _, ip := range expired {
-				delete(rl.visitors, ip)
-			}
 			rl.mutex.Unlock()
 
 		case <-rl.stopChan:
-			log.Println("[INFO] Stopping rate limiter cleanup...")
+			rl.logger.Info().Msg("Stopping rate limiter cleanup")
 			return
 		}
 	}
@@ -0,0 +1,73 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/middleware"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func limitedRequest(limited http.Handler, ip string) int {
+	req := httptest.NewRequest("GET", "/abc123", nil)
+	req.RemoteAddr = ip + ":1234"
+	rec := httptest.NewRecorder()
+	limited.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	logger := zerolog.Nop()
+	rl := middleware.NewBurstRateLimiter(time.Hour, 2, time.Minute, &logger)
+	defer rl.StopCleanup()
+	limited := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(t, http.StatusOK, limitedRequest(limited, "10.0.0.1"))
+	assert.Equal(t, http.StatusOK, limitedRequest(limited, "10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(limited, "10.0.0.1"))
+	assert.Equal(t, http.StatusOK, limitedRequest(limited, "10.0.0.2"), "clients have buckets of their own")
+}
+
+func TestRateLimiter_Refills(t *testing.T) {
+	logger := zerolog.Nop()
+	rl := middleware.NewRateLimiter(20*time.Millisecond, time.Minute, &logger)
+	defer rl.StopCleanup()
+	limited := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(t, http.StatusOK, limitedRequest(limited, "10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(limited, "10.0.0.1"))
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, limitedRequest(limited, "10.0.0.1"))
+}
+
+func TestRateLimiter_ServesConcurrently(t *testing.T) {
+	logger := zerolog.Nop()
+	rl := middleware.NewBurstRateLimiter(time.Second, 10, time.Minute, &logger)
+	defer rl.StopCleanup()
+
+	release := make(chan struct{})
+	limited := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RemoteAddr == "10.0.0.1:1234" {
+			<-release
+		}
+	}))
+
+	slow := make(chan int)
+	go func() { slow <- limitedRequest(limited, "10.0.0.1") }()
+
+	// A slow request does not hold up the others
+	done := make(chan int)
+	go func() { done <- limitedRequest(limited, "10.0.0.2") }()
+	select {
+	case code := <-done:
+		assert.Equal(t, http.StatusOK, code)
+	case <-time.After(time.Second):
+		t.Fatal("request waited for another client's request")
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-slow)
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// requestRoute is where Route tells Log the template of the route the request matched
+type requestRoute struct {
+	template string
+}
+
+// RequestLogOptions configures the log line written for every request
+type RequestLogOptions struct {
+	// Level of the line of requests answered below 400; 4xx are logged as warnings and 5xx as errors
+	Level zerolog.Level
+	// SampleEvery keeps the debug and info lines of one in SampleEvery requests, 0 or 1 keeps them all.
+	// The decision is made once per request, so a request sampled in has all of its lines. Warnings and
+	// errors are always logged.
+	SampleEvery uint32
+}
+
+// DefaultRequestLogOptions logs every request at info
+func DefaultRequestLogOptions() RequestLogOptions {
+	return RequestLogOptions{
+		Level:       zerolog.InfoLevel,
+		SampleEvery: 1,
+	}
+}
+
+// RequestLogger gives every request an id and a logger of its own, and logs the request once answered
+type RequestLogger struct {
+	logger   zerolog.Logger
+	opts     RequestLogOptions
+	requests atomic.Uint32
+}
+
+// NewRequestLogger initializes a new RequestLogger writing to logger
+func NewRequestLogger(logger zerolog.Logger, opts RequestLogOptions) *RequestLogger {
+	return &RequestLogger{logger: logger, opts: opts}
+}
+
+// Log takes the request id from the X-Request-ID header, or makes one up, and returns it in the response.
+// The logger of the request carries its id, method and client IP, and is passed on in the context for
+// zerolog.Ctx. It wraps the whole router, so requests no route matched are logged too; Route adds the
+// route template once one matched.
+func (l *RequestLogger) Log(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(constants.REQUEST_ID_HEADER)
+		if !validRequestID(requestID) {
+			requestID = uuid.Must(uuid.NewV4()).String()
+		}
+		w.Header().Set(constants.REQUEST_ID_HEADER, requestID)
+
+		logger := l.logger.With().
+			Str("request_id", requestID).
+			Str("method", r.Method).
+			Str("client_ip", utils.GetClientIP(r)).
+			Logger()
+		if !l.sampled() {
+			logger = logger.Level(max(logger.GetLevel(), zerolog.WarnLevel))
+		}
+		route := &requestRoute{}
+		ctx := context.WithValue(logger.WithContext(r.Context()), requestIDKey{}, requestID)
+		ctx = context.WithValue(ctx, requestRoute{}, route)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := l.opts.Level
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			level = zerolog.ErrorLevel
+		case recorder.status >= http.StatusBadRequest:
+			level = zerolog.WarnLevel
+		}
+		// The logger in the context, with the fields Route added
+		event := zerolog.Ctx(ctx).WithLevel(level)
+		if route.template == "" {
+			event = event.Str("route", "unmatched")
+		}
+		event.Int("status", recorder.status).
+			Int("bytes", recorder.bytes).
+			Dur("latency", time.Since(start)).
+			Msg("Request handled")
+	})
+}
+
+// Route adds the route template and the trace id to the logger of the request. It must be installed with
+// Router.Use after Tracing, which runs it once the route has been matched.
+func (l *RequestLogger) Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := r.Context().Value(requestRoute{}).(*requestRoute)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route.template = routeTemplate(r, "unmatched")
+		zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+			c = c.Str("route", route.template)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				c = c.Str("trace_id", sc.TraceID().String())
+			}
+			return c
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sampled decides whether the debug and info lines of a request are kept
+func (l *RequestLogger) sampled() bool {
+	if l.opts.SampleEvery <= 1 {
+		return true
+	}
+	return (l.requests.Add(1)-1)%l.opts.SampleEvery == 0
+}
+
+// validRequestID accepts the ids of other services as long as they fit in a log line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > constants.REQUEST_ID_MAX_LEN {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the id of the request ctx belongs to, "" outside of a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger returns the logger of the request ctx belongs to, or fallback outside of a request
+func Logger(ctx context.Context, fallback *zerolog.Logger) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return fallback
+}
+
+// routeTemplate returns the template of the route r matched, such as /api/v1/shorten/{shortUrl}, so short
+// codes do not end up in labels and span names
+func routeTemplate(r *http.Request, fallback string) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,143 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// ErrSizeMismatch is returned by Merge when the bits come from a filter of another size
+var ErrSizeMismatch = errors.New("bloom filter size mismatch")
+
+// Options sizes a Filter
+type Options struct {
+	// ExpectedItems is the number of items the filter is sized for, beyond it false positives rise
+	ExpectedItems int
+	// FalsePositiveRate is the share of absent items reported as present once ExpectedItems were added
+	FalsePositiveRate float64
+	// MaxBytes caps the memory of the bits, 0 for no cap. A capped filter has a higher false positive rate
+	// than asked for.
+	MaxBytes int
+}
+
+// DefaultOptions sizes the filter for a million items with 1% false positives, about 1.2 MB
+func DefaultOptions() Options {
+	return Options{
+		ExpectedItems:     1000000,
+		FalsePositiveRate: 0.01,
+	}
+}
+
+// Filter is a Bloom filter of strings, safe for concurrent use
+type Filter struct {
+	words  []atomic.Uint64
+	bits   uint64
+	hashes int
+	target float64 // false positive rate asked for
+	ones   atomic.Uint64
+}
+
+// New returns an empty Filter sized by opts
+func New(opts Options) *Filter {
+	n := float64(max(opts.ExpectedItems, 1))
+	p := opts.FalsePositiveRate
+	if p <= 0 || p >= 1 {
+		p = DefaultOptions().FalsePositiveRate
+	}
+
+	size := uint64(math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if opts.MaxBytes > 0 {
+		size = min(size, uint64(opts.MaxBytes)*8)
+	}
+	// Whole words, so Bytes is a multiple of 8 bytes
+	size = max((size+63)/64*64, 64)
+	hashes := max(int(math.Round(float64(size)/n*math.Ln2)), 1)
+
+	return &Filter{
+		words:  make([]atomic.Uint64, size/64),
+		bits:   size,
+		hashes: hashes,
+		target: p,
+	}
+}
+
+// Bits returns the number of bits of the filter
+func (f *Filter) Bits() uint64 {
+	return f.bits
+}
+
+// Hashes returns the number of bits set per item
+func (f *Filter) Hashes() int {
+	return f.hashes
+}
+
+// Locations returns the bits set for item. Two 32 bit halves of a FNV-1a hash are combined as
+// h1 + i*h2 (Kirsch-Mitzenmacher), so the locations are the same in every process.
+func (f *Filter) Locations(item string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+
+	locations := make([]uint64, f.hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return locations
+}
+
+// Add adds item to the filter
+func (f *Filter) Add(item string) {
+	f.set(f.Locations(item))
+}
+
+func (f *Filter) set(locations []uint64) {
+	for _, location := range locations {
+		mask := uint64(1) << (63 - location%64)
+		if f.words[location/64].Or(mask)&mask == 0 {
+			f.ones.Add(1)
+		}
+	}
+}
+
+// Test reports whether item may have been added. false is definite, true is wrong for about the false
+// positive rate of absent items.
+func (f *Filter) Test(item string) bool {
+	for _, location := range f.Locations(item) {
+		mask := uint64(1) << (63 - location%64)
+		if f.words[location/64].Load()&mask == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FalsePositiveRate estimates the current false positive rate from the share of bits set
+func (f *Filter) FalsePositiveRate() float64 {
+	return math.Pow(float64(f.ones.Load())/float64(f.bits), float64(f.hashes))
+}
+
+// Bytes returns the bits of the filter, to be merged into a filter of the same size
+func (f *Filter) Bytes() []byte {
+	data := make([]byte, len(f.words)*8)
+	for i := range f.words {
+		binary.BigEndian.PutUint64(data[i*8:], f.words[i].Load())
+	}
+	return data
+}
+
+// Merge adds the items of a filter of the same size, given by its Bytes
+func (f *Filter) Merge(data []byte) error {
+	if len(data) != len(f.words)*8 {
+		return ErrSizeMismatch
+	}
+	for i := 0; i < len(data); i += 8 {
+		mask := binary.BigEndian.Uint64(data[i:])
+		old := f.words[i/8].Or(mask)
+		f.ones.Add(uint64(bits.OnesCount64(mask &^ old)))
+	}
+	return nil
+}
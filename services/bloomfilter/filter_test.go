@@ -0,0 +1,62 @@
+package bloomfilter_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/bloomfilter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Sizing(t *testing.T) {
+	filter := bloomfilter.New(bloomfilter.DefaultOptions())
+	// -n ln(p) / ln(2)^2 bits and m/n ln(2) hashes
+	assert.InDelta(t, 9585059, float64(filter.Bits()), 64)
+	assert.Equal(t, 7, filter.Hashes())
+
+	capped := bloomfilter.New(bloomfilter.Options{ExpectedItems: 1000000, FalsePositiveRate: 0.01, MaxBytes: 512 * 1024})
+	assert.Equal(t, uint64(512*1024*8), capped.Bits())
+	assert.Equal(t, 3, capped.Hashes())
+}
+
+func TestFilter_AddAndTest(t *testing.T) {
+	filter := bloomfilter.New(bloomfilter.Options{ExpectedItems: 10000, FalsePositiveRate: 0.01})
+	for i := range 10000 {
+		filter.Add(fmt.Sprintf("code%d", i))
+	}
+
+	// No false negatives
+	for i := range 10000 {
+		require.True(t, filter.Test(fmt.Sprintf("code%d", i)))
+	}
+
+	falsePositives := 0
+	for i := range 10000 {
+		if filter.Test(fmt.Sprintf("absent%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.InDelta(t, 0.01, float64(falsePositives)/10000, 0.005)
+	assert.InDelta(t, 0.01, filter.FalsePositiveRate(), 0.002)
+}
+
+func TestFilter_Merge(t *testing.T) {
+	opts := bloomfilter.Options{ExpectedItems: 100, FalsePositiveRate: 0.01}
+	first, second := bloomfilter.New(opts), bloomfilter.New(opts)
+	first.Add("abc123")
+	second.Add("xyz789")
+
+	require.NoError(t, first.Merge(second.Bytes()))
+	assert.True(t, first.Test("abc123"))
+	assert.True(t, first.Test("xyz789"))
+	both := bloomfilter.New(opts)
+	both.Add("abc123")
+	both.Add("xyz789")
+	assert.Equal(t, both.Bytes(), first.Bytes())
+	assert.Equal(t, both.FalsePositiveRate(), first.FalsePositiveRate())
+
+	assert.ErrorIs(t, first.Merge(second.Bytes()[:8]), bloomfilter.ErrSizeMismatch)
+	larger := bloomfilter.New(bloomfilter.Options{ExpectedItems: 1000, FalsePositiveRate: 0.01})
+	assert.ErrorIs(t, first.Merge(larger.Bytes()), bloomfilter.ErrSizeMismatch)
+}
@@ -0,0 +1,370 @@
+package bloomfilter
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// buildPageSize is the number of links read per query while the filter is built
+const buildPageSize = 5000
+
+// refreshOverlap is how many ids before the last one read a refresh starts at, so links committed out of
+// id order are not skipped
+const refreshOverlap = 1000
+
+var (
+	checks = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_bloom_checks_total",
+		Help: "Short codes checked against the Bloom filter by result: pass, reject, false_positive or unchecked while it may miss codes.",
+	}, []string{"result"})
+	filterBytes = metrics.Factory.NewGauge(prometheus.GaugeOpts{
+		Name: "urlshortner_bloom_filter_bytes",
+		Help: "Memory held by the bits of the Bloom filter of short codes.",
+	})
+	filterRate = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "urlshortner_bloom_false_positive_rate",
+		Help: "False positive rate of the Bloom filter of short codes, as configured or estimated from the bits set.",
+	}, []string{"kind"})
+)
+
+// Broadcaster tells the other replicas about the codes created on this one, see cachemanager.RedisInvalidator
+type Broadcaster interface {
+	Publish(ctx context.Context, keys []string) error
+	// Subscribe calls fn with the keys published by any replica until ctx is done. fn is called once with
+	// no keys as soon as the subscription is established.
+	Subscribe(ctx context.Context, fn func(keys []string)) error
+}
+
+// Store keeps a snapshot of the filter, so a replica starting up only reads the links created since it was
+// taken, see RedisStore
+type Store interface {
+	// Load returns the bits of the snapshot and the id of the last link read into them, nil bits when there
+	// is no snapshot of a filter of this size
+	Load(ctx context.Context) ([]byte, int64, error)
+	Save(ctx context.Context, data []byte, lastID int64) error
+}
+
+// Guard keeps a Filter of every short code, so codes that do not exist are turned down without asking the
+// cache or the database. A code is only turned down while the filter cannot miss one: once it is built from
+// the database, while the subscription to the other replicas is up, and while every code created here
+// reached them. Otherwise every code passes.
+type Guard struct {
+	filter *Filter
+	store  Store
+	peers  Broadcaster
+	log    zerolog.Logger
+
+	mutex sync.Mutex
+	// built is set once the database was read while subscribed, and cleared when the subscription drops
+	built bool
+	// subscriptions counts the subscriptions established, so a build that started before a drop is not trusted
+	subscriptions int
+	listening     bool
+	// unconfirmed are the codes created here the other replicas may not know about, retrying is set while
+	// sending them again is scheduled
+	unconfirmed []string
+	retrying    bool
+	// lastID is the highest link id read from the database, savedID the one of the last snapshot saved
+	lastID  int64
+	savedID int64
+}
+
+// NewGuard guards with filter. store may be nil to always build the filter from the database, peers when a
+// single replica runs.
+func NewGuard(filter *Filter, store Store, peers Broadcaster, log zerolog.Logger) *Guard {
+	filterBytes.Set(float64(filter.Bits() / 8))
+	filterRate.WithLabelValues("configured").Set(filter.target)
+	filterRate.WithLabelValues("estimated").Set(filter.FalsePositiveRate())
+	return &Guard{
+		filter: filter,
+		store:  store,
+		peers:  peers,
+		log:    log,
+	}
+}
+
+// Ready reports whether codes are turned down
+func (g *Guard) Ready() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.built && len(g.unconfirmed) == 0
+}
+
+// MayExist reports whether shortCode may exist. false is definite.
+func (g *Guard) MayExist(shortCode string) bool {
+	if !g.Ready() {
+		checks.WithLabelValues("unchecked").Inc()
+		return true
+	}
+	if !g.filter.Test(shortCode) {
+		checks.WithLabelValues("reject").Inc()
+		return false
+	}
+	checks.WithLabelValues("pass").Inc()
+	return true
+}
+
+// FalsePositive counts a code that passed but does not exist
+func (g *Guard) FalsePositive(shortCode string) {
+	checks.WithLabelValues("false_positive").Inc()
+}
+
+// Add adds a created code here and on the other replicas. Until they are told, codes are no longer turned
+// down here and the codes are sent again every second.
+func (g *Guard) Add(ctx context.Context, shortCode string) {
+	g.filter.Add(shortCode)
+	filterRate.WithLabelValues("estimated").Set(g.filter.FalsePositiveRate())
+	if g.peers == nil {
+		return
+	}
+
+	g.mutex.Lock()
+	g.unconfirmed = append(g.unconfirmed, shortCode)
+	g.mutex.Unlock()
+	g.publish(context.WithoutCancel(ctx))
+}
+
+// publish sends the unconfirmed codes to the other replicas. Publishes run concurrently, each one only
+// confirms the codes it sent.
+func (g *Guard) publish(ctx context.Context) {
+	g.mutex.Lock()
+	codes := slices.Clone(g.unconfirmed)
+	g.mutex.Unlock()
+	if len(codes) == 0 {
+		return
+	}
+
+	if err := g.peers.Publish(ctx, codes); err != nil {
+		g.log.Error().Err(err).Int("codes", len(codes)).Msg("Failed to publish short codes to the other replicas, codes are looked up until they are")
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		if !g.retrying {
+			g.retrying = true
+			time.AfterFunc(time.Second, func() {
+				g.mutex.Lock()
+				g.retrying = false
+				g.mutex.Unlock()
+				g.publish(ctx)
+			})
+		}
+		return
+	}
+	sent := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		sent[code] = struct{}{}
+	}
+	g.mutex.Lock()
+	g.unconfirmed = slices.DeleteFunc(g.unconfirmed, func(code string) bool {
+		_, ok := sent[code]
+		return ok
+	})
+	g.mutex.Unlock()
+}
+
+// Build adds every link of urls to the filter. The first build starts from the snapshot in the store, if
+// any, and later ones from the links already read, so only the links created since are read. Codes are
+// turned down once it returns, unless the guard shares the filter and is not subscribed, see Listen.
+func (g *Guard) Build(ctx context.Context, urls repository.UrlRepository) error {
+	start := time.Now()
+	g.load(ctx)
+	g.mutex.Lock()
+	subscriptions := g.subscriptions
+	afterID := max(g.lastID-refreshOverlap, 0)
+	g.mutex.Unlock()
+
+	count, err := g.read(ctx, urls, afterID)
+	if err != nil {
+		return err
+	}
+	g.save(ctx)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.peers != nil && (!g.listening || g.subscriptions != subscriptions) {
+		// Codes created on other replicas before the subscription may be missing
+		return nil
+	}
+	g.built = true
+	g.log.Info().Int("codes", count).Dur("duration", time.Since(start)).Msg("Bloom filter of short codes built")
+	return nil
+}
+
+// Refresh adds the links of urls created since the last read. Codes that did not go through Add, such as
+// those created by replicas of an older release or inserted with SQL, are turned down until then.
+func (g *Guard) Refresh(ctx context.Context, urls repository.UrlRepository) error {
+	g.mutex.Lock()
+	afterID := max(g.lastID-refreshOverlap, 0)
+	g.mutex.Unlock()
+
+	if _, err := g.read(ctx, urls, afterID); err != nil {
+		return err
+	}
+	g.save(ctx)
+	return nil
+}
+
+// load merges the snapshot in the store into a filter that has not read any link yet
+func (g *Guard) load(ctx context.Context) {
+	g.mutex.Lock()
+	loaded := g.lastID > 0
+	g.mutex.Unlock()
+	if g.store == nil || loaded {
+		return
+	}
+
+	data, lastID, err := g.store.Load(ctx)
+	if err != nil {
+		g.log.Warn().Err(err).Msg("Failed to load the snapshot of the Bloom filter of short codes, reading every link")
+		return
+	}
+	if data == nil {
+		return
+	}
+	if err := g.filter.Merge(data); err != nil {
+		g.log.Warn().Err(err).Msg("Ignoring the snapshot of the Bloom filter of short codes, reading every link")
+		return
+	}
+	filterRate.WithLabelValues("estimated").Set(g.filter.FalsePositiveRate())
+
+	g.mutex.Lock()
+	g.lastID = max(g.lastID, lastID)
+	g.savedID = max(g.savedID, lastID)
+	g.mutex.Unlock()
+}
+
+// save stores a snapshot of the filter when links were read since the last one. The bits are taken after
+// lastID, so the snapshot holds every link up to it.
+func (g *Guard) save(ctx context.Context) {
+	if g.store == nil {
+		return
+	}
+	g.mutex.Lock()
+	lastID, savedID := g.lastID, g.savedID
+	g.mutex.Unlock()
+	if lastID <= savedID {
+		return
+	}
+
+	if err := g.store.Save(ctx, g.filter.Bytes(), lastID); err != nil {
+		g.log.Warn().Err(err).Msg("Failed to save the snapshot of the Bloom filter of short codes")
+		return
+	}
+	g.mutex.Lock()
+	g.savedID = max(g.savedID, lastID)
+	g.mutex.Unlock()
+}
+
+// RefreshEvery calls Refresh every interval until ctx is done
+func (g *Guard) RefreshEvery(ctx context.Context, urls repository.UrlRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := g.Refresh(ctx, urls); err != nil && ctx.Err() == nil {
+				g.log.Error().Err(err).Msg("Failed to refresh the Bloom filter of short codes")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// read adds the links of urls after afterID to the filter and returns how many there were
+func (g *Guard) read(ctx context.Context, urls repository.UrlRepository, afterID int64) (int, error) {
+	count := 0
+	for {
+		page, err := urls.ListUrls(ctx, afterID, buildPageSize)
+		if err != nil {
+			return count, err
+		}
+		for _, url := range page {
+			g.filter.Add(url.ShortCode.String)
+		}
+		count += len(page)
+		if len(page) > 0 {
+			afterID = page[len(page)-1].ID.Int64
+		}
+		if len(page) < buildPageSize {
+			break
+		}
+	}
+	filterRate.WithLabelValues("estimated").Set(g.filter.FalsePositiveRate())
+
+	g.mutex.Lock()
+	g.lastID = max(g.lastID, afterID)
+	g.mutex.Unlock()
+	return count, nil
+}
+
+// Listen adds the codes created on other replicas until ctx is done. Every time the subscription is
+// established the filter is built again from urls, as codes may have been missed while it was down.
+func (g *Guard) Listen(ctx context.Context, urls repository.UrlRepository) {
+	if g.peers == nil {
+		if err := g.Build(ctx, urls); err != nil && ctx.Err() == nil {
+			g.log.Error().Err(err).Msg("Failed to build the Bloom filter of short codes, every code is looked up")
+		}
+		return
+	}
+
+	for ctx.Err() == nil {
+		err := g.peers.Subscribe(ctx, func(codes []string) {
+			if codes == nil {
+				g.mutex.Lock()
+				g.subscriptions++
+				g.listening = true
+				subscriptions := g.subscriptions
+				g.mutex.Unlock()
+				go g.rebuild(ctx, urls, subscriptions)
+				return
+			}
+			for _, code := range codes {
+				g.filter.Add(code)
+			}
+		})
+
+		g.mutex.Lock()
+		g.built, g.listening = false, false
+		g.mutex.Unlock()
+		if err != nil && ctx.Err() == nil {
+			g.log.Error().Err(err).Msg("Bloom filter updates interrupted, every code is looked up until they resume")
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// rebuild sends the codes the other replicas may have missed and builds the filter again, until it is built
+// or the subscription drops
+func (g *Guard) rebuild(ctx context.Context, urls repository.UrlRepository, subscriptions int) {
+	g.publish(ctx)
+	for {
+		err := g.Build(ctx, urls)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		g.log.Error().Err(err).Msg("Failed to build the Bloom filter of short codes, retrying")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+
+		g.mutex.Lock()
+		current := g.listening && g.subscriptions == subscriptions
+		g.mutex.Unlock()
+		if !current {
+			return
+		}
+	}
+}
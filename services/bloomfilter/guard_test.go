@@ -0,0 +1,322 @@
+package bloomfilter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/bloomfilter"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peers delivers codes between guards in the same process, the way Redis pub/sub does between replicas
+type peers struct {
+	mutex       sync.Mutex
+	subscribers map[int]func(keys []string)
+	next        int
+	failing     bool
+	drop        chan struct{}
+}
+
+func newPeers() *peers {
+	return &peers{subscribers: map[int]func(keys []string){}, drop: make(chan struct{})}
+}
+
+func (p *peers) Publish(ctx context.Context, keys []string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.failing {
+		return errors.New("connection refused")
+	}
+	for _, fn := range p.subscribers {
+		fn(keys)
+	}
+	return nil
+}
+
+func (p *peers) Subscribe(ctx context.Context, fn func(keys []string)) error {
+	p.mutex.Lock()
+	if p.failing {
+		p.mutex.Unlock()
+		return errors.New("connection refused")
+	}
+	id := p.next
+	p.next++
+	p.subscribers[id] = fn
+	drop := p.drop
+	p.mutex.Unlock()
+	fn(nil)
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.subscribers, id)
+		p.mutex.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-drop:
+		return errors.New("connection reset")
+	}
+}
+
+func (p *peers) setFailing(failing bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failing = failing
+}
+
+// dropAll ends every subscription, as when the connection to Redis is lost
+func (p *peers) dropAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	close(p.drop)
+	p.drop = make(chan struct{})
+}
+
+// snapshots keeps the snapshot in memory, the way RedisStore keeps it in Redis
+type snapshots struct {
+	mutex  sync.Mutex
+	data   []byte
+	lastID int64
+	saves  int
+}
+
+func (s *snapshots) Load(ctx context.Context) ([]byte, int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.data, s.lastID, nil
+}
+
+func (s *snapshots) Save(ctx context.Context, data []byte, lastID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data, s.lastID = data, lastID
+	s.saves++
+	return nil
+}
+
+func newFilter() *bloomfilter.Filter {
+	return bloomfilter.New(bloomfilter.Options{ExpectedItems: 1000, FalsePositiveRate: 0.001})
+}
+
+func TestGuard_Build(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	var codes []string
+	for range 20 {
+		code, err := repo.CreateUrl(ctx, "https://example.com/"+repository.GenerateShortCode(8))
+		require.NoError(t, err)
+		codes = append(codes, *code)
+	}
+
+	guard := bloomfilter.NewGuard(newFilter(), nil, nil, zerolog.Nop())
+	// Everything passes until the filter is built
+	assert.True(t, guard.MayExist("zzzzzz"))
+
+	require.NoError(t, guard.Build(ctx, repo))
+	assert.True(t, guard.Ready())
+	for _, code := range codes {
+		assert.True(t, guard.MayExist(code))
+	}
+	assert.False(t, guard.MayExist("zzzzzz"))
+}
+
+func TestGuard_BuildPaginates(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	page := make([]repository.Url, 5000)
+	for i := range page {
+		page[i].ID.Int64 = int64(i + 1)
+		page[i].ShortCode.String = repository.GenerateShortCode(6)
+	}
+	last := repository.Url{}
+	last.ID.Int64, last.ShortCode.String = 5001, "last01"
+	mockRepo.On("ListUrls", int64(0), 5000).Return(page, nil)
+	mockRepo.On("ListUrls", int64(5000), 5000).Return([]repository.Url{last}, nil)
+
+	guard := bloomfilter.NewGuard(bloomfilter.New(bloomfilter.DefaultOptions()), nil, nil, zerolog.Nop())
+	require.NoError(t, guard.Build(ctx, mockRepo))
+
+	assert.True(t, guard.MayExist(page[0].ShortCode.String))
+	assert.True(t, guard.MayExist("last01"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGuard_Refresh(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	_, err := repo.CreateUrl(ctx, "https://example.com/a")
+	require.NoError(t, err)
+
+	guard := bloomfilter.NewGuard(newFilter(), nil, nil, zerolog.Nop())
+	require.NoError(t, guard.Build(ctx, repo))
+
+	// Created without Add, e.g. by a replica of the previous release
+	code, err := repo.CreateUrl(ctx, "https://example.com/b")
+	require.NoError(t, err)
+	assert.False(t, guard.MayExist(*code))
+
+	require.NoError(t, guard.Refresh(ctx, repo))
+	assert.True(t, guard.MayExist(*code))
+	assert.False(t, guard.MayExist("zzzzzz"))
+}
+
+func TestGuard_RefreshRereadsRecentIds(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockUrlRepository)
+	last := repository.Url{}
+	last.ID.Int64, last.ShortCode.String = 1500, "last01"
+	late := repository.Url{}
+	late.ID.Int64, late.ShortCode.String = 1499, "late01"
+	mockRepo.On("ListUrls", int64(0), 5000).Return([]repository.Url{last}, nil)
+	// A link with a lower id committed after the build is read again
+	mockRepo.On("ListUrls", int64(500), 5000).Return([]repository.Url{late, last}, nil)
+
+	guard := bloomfilter.NewGuard(newFilter(), nil, nil, zerolog.Nop())
+	require.NoError(t, guard.Build(ctx, mockRepo))
+	require.NoError(t, guard.Refresh(ctx, mockRepo))
+
+	assert.True(t, guard.MayExist("late01"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGuard_BuildStartsFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := &snapshots{}
+	first := repository.Url{}
+	first.ID.Int64, first.ShortCode.String = 1500, "first1"
+	mockRepo := new(mocks.MockUrlRepository)
+	mockRepo.On("ListUrls", int64(0), 5000).Return([]repository.Url{first}, nil).Once()
+
+	guard := bloomfilter.NewGuard(newFilter(), store, nil, zerolog.Nop())
+	require.NoError(t, guard.Build(ctx, mockRepo))
+	assert.Equal(t, int64(1500), store.lastID)
+	assert.Equal(t, 1, store.saves)
+
+	// Nothing new was read, the snapshot is not saved again
+	mockRepo.On("ListUrls", int64(500), 5000).Return([]repository.Url{first}, nil).Once()
+	require.NoError(t, guard.Refresh(ctx, mockRepo))
+	assert.Equal(t, 1, store.saves)
+
+	// Another replica starting up only reads the links created since the snapshot
+	second := repository.Url{}
+	second.ID.Int64, second.ShortCode.String = 1501, "second"
+	mockRepo.On("ListUrls", int64(500), 5000).Return([]repository.Url{first, second}, nil).Once()
+	replica := bloomfilter.NewGuard(newFilter(), store, nil, zerolog.Nop())
+	require.NoError(t, replica.Build(ctx, mockRepo))
+
+	assert.True(t, replica.MayExist("first1"))
+	assert.True(t, replica.MayExist("second"))
+	assert.False(t, replica.MayExist("zzzzzz"))
+	assert.Equal(t, int64(1501), store.lastID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGuard_IgnoresSnapshotOfAnotherSize(t *testing.T) {
+	ctx := context.Background()
+	other := bloomfilter.New(bloomfilter.Options{ExpectedItems: 10, FalsePositiveRate: 0.01})
+	store := &snapshots{data: other.Bytes(), lastID: 1500}
+	code := repository.Url{}
+	code.ID.Int64, code.ShortCode.String = 1, "abc123"
+	mockRepo := new(mocks.MockUrlRepository)
+	mockRepo.On("ListUrls", int64(0), 5000).Return([]repository.Url{code}, nil)
+
+	guard := bloomfilter.NewGuard(newFilter(), store, nil, zerolog.Nop())
+	require.NoError(t, guard.Build(ctx, mockRepo))
+	assert.True(t, guard.MayExist("abc123"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGuard_SharedBetweenReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repository.NewMemoryRepository()
+	code, err := repo.CreateUrl(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	broadcast := newPeers()
+	first := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+	second := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+
+	// Building alone is not enough, codes created elsewhere before the subscription could be missing
+	require.NoError(t, first.Build(ctx, repo))
+	assert.False(t, first.Ready())
+
+	go first.Listen(ctx, repo)
+	go second.Listen(ctx, repo)
+	require.Eventually(t, func() bool { return first.Ready() && second.Ready() }, time.Second, time.Millisecond)
+	assert.True(t, second.MayExist(*code))
+	assert.False(t, second.MayExist("zzzzzz"))
+
+	first.Add(ctx, "new001")
+	assert.True(t, second.MayExist("new001"))
+}
+
+func TestGuard_LooksUpWhileCodesMayBeMissing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repository.NewMemoryRepository()
+	broadcast := newPeers()
+	first := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+	second := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+	go first.Listen(ctx, repo)
+	go second.Listen(ctx, repo)
+	require.Eventually(t, func() bool { return first.Ready() && second.Ready() }, time.Second, time.Millisecond)
+
+	// The code could not be sent: the second replica does not know it, and the first one stops
+	// turning codes down until it is sent
+	broadcast.setFailing(true)
+	code, err := repo.CreateUrl(ctx, "https://example.com")
+	require.NoError(t, err)
+	first.Add(ctx, *code)
+	assert.False(t, first.Ready())
+	assert.True(t, first.MayExist("zzzzzz"))
+
+	// The subscriptions drop with the connection, both replicas look codes up until they are back
+	broadcast.dropAll()
+	require.Eventually(t, func() bool { return !second.Ready() }, time.Second, time.Millisecond)
+	assert.True(t, second.MayExist(*code))
+
+	// Subscribed again, the filter is built from the database and has the code
+	broadcast.setFailing(false)
+	require.Eventually(t, func() bool { return first.Ready() && second.Ready() }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, second.MayExist(*code))
+	assert.False(t, second.MayExist("zzzzzz"))
+}
+
+func TestGuard_ConcurrentAdds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repository.NewMemoryRepository()
+	broadcast := newPeers()
+	first := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+	second := bloomfilter.NewGuard(newFilter(), nil, broadcast, zerolog.Nop())
+	go first.Listen(ctx, repo)
+	go second.Listen(ctx, repo)
+	require.Eventually(t, func() bool { return first.Ready() && second.Ready() }, time.Second, time.Millisecond)
+
+	// Publishes overlap and finish in any order, each confirms only its own codes
+	var wg sync.WaitGroup
+	codes := make([]string, 50)
+	for i := range codes {
+		codes[i] = repository.GenerateShortCode(8)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first.Add(ctx, codes[i])
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, first.Ready())
+	for _, code := range codes {
+		assert.True(t, second.MayExist(code))
+	}
+}
@@ -0,0 +1,63 @@
+package bloomfilter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClient is the part of the Redis client a snapshot is stored with
+type RedisClient interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+}
+
+// RedisStore stores snapshots of a Filter in Redis. The key names the size of the filter, so changing it
+// starts from a new snapshot rather than loading bits of another layout.
+type RedisStore struct {
+	rdb  RedisClient
+	key  string
+	size int // bytes of the filter
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore stores snapshots of filter under a key starting with prefix
+func NewRedisStore(rdb RedisClient, prefix string, filter *Filter) *RedisStore {
+	return &RedisStore{
+		rdb:  rdb,
+		key:  fmt.Sprintf("%s%d:%d", prefix, filter.Bits(), filter.Hashes()),
+		size: int(filter.Bits() / 8),
+	}
+}
+
+// Key returns the key the snapshot is stored under
+func (s *RedisStore) Key() string {
+	return s.key
+}
+
+// Load returns the stored bits and the id of the last link read into them. The value is the id as 8 bytes
+// followed by the bits, anything else is not loaded.
+func (s *RedisStore) Load(ctx context.Context) ([]byte, int64, error) {
+	data, err := s.rdb.Get(ctx, s.key).Bytes()
+	if err == redis.Nil {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) != 8+s.size {
+		return nil, 0, nil
+	}
+	return data[8:], int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// Save replaces the snapshot in a single SET, so the bits and the id always belong together
+func (s *RedisStore) Save(ctx context.Context, data []byte, lastID int64) error {
+	value := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(value, uint64(lastID))
+	return s.rdb.Set(ctx, s.key, append(value, data...), 0).Err()
+}
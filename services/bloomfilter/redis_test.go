@@ -0,0 +1,56 @@
+package bloomfilter_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/bloomfilter"
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore_SaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	client, mockRedis := redismock.NewClientMock()
+	filter := newFilter()
+	filter.Add("abc123")
+	store := bloomfilter.NewRedisStore(client, "bloom:filter:", filter)
+	assert.Equal(t, "bloom:filter:14400:10", store.Key())
+
+	value := binary.BigEndian.AppendUint64(nil, 42)
+	value = append(value, filter.Bytes()...)
+	mockRedis.ExpectSet(store.Key(), value, 0).SetVal("OK")
+	require.NoError(t, store.Save(ctx, filter.Bytes(), 42))
+
+	mockRedis.ExpectGet(store.Key()).SetVal(string(value))
+	data, lastID, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), lastID)
+	assert.Equal(t, filter.Bytes(), data)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestRedisStore_LoadWithoutSnapshot(t *testing.T) {
+	ctx := context.Background()
+	client, mockRedis := redismock.NewClientMock()
+	store := bloomfilter.NewRedisStore(client, "bloom:filter:", newFilter())
+
+	mockRedis.ExpectGet(store.Key()).RedisNil()
+	data, _, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	// Not a snapshot of this filter
+	mockRedis.ExpectGet(store.Key()).SetVal("garbage")
+	data, _, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	mockRedis.ExpectGet(store.Key()).SetErr(errors.New("connection refused"))
+	_, _, err = store.Load(ctx)
+	assert.Error(t, err)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
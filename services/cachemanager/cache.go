@@ -0,0 +1,32 @@
+package cachemanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get and TTL for keys that are not cached. It is redis.Nil, so every
+// implementation reports a miss the way the Redis client does.
+var ErrMiss = redis.Nil
+
+// NoExpiry is the TTL of a key that is kept until it is deleted or evicted
+const NoExpiry = time.Duration(-1)
+
+// Cache stores strings and counters by key. Values set with a TTL of 0 minutes do not expire.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl int) error
+	Delete(ctx context.Context, keys ...string) error
+	// MGet returns the values of the keys that are cached, missing keys are left out
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
+	// TTL returns how long key is kept, NoExpiry for a key without a TTL
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Incr and IncrBy atomically increment a counter, starting from 0, and return its new value
+	Incr(ctx context.Context, key string) (int64, error)
+	IncrBy(ctx context.Context, key string, value int64) (int64, error)
+	// IncrFrom atomically increments a counter starting from base, base is only used when the counter
+	// does not exist yet
+	IncrFrom(ctx context.Context, key string, base int64) (int64, error)
+}
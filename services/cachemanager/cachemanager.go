@@ -2,24 +2,49 @@ package cachemanager
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RedisClient defines an interface for mocking Redis operations
 type RedisClient interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	TTL(ctx context.Context, key string) *redis.DurationCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
 }
 
-// CacheManager handles caching operations
+// incrFromScript seeds a missing counter and increments it in a single step, so requests racing on a
+// counter that was evicted or flushed cannot count without the seed
+const incrFromScript = `redis.call('SET', KEYS[1], ARGV[1], 'NX')
+return redis.call('INCR', KEYS[1])`
+
+// cacheRequests counts lookups per kind of key, the part of the key before the first ':'
+var cacheRequests = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "urlshortner_cache_requests_total",
+	Help: "Cache lookups by kind of key and result (hit, miss or error).",
+}, []string{"cache", "result"})
+
+// CacheManager is the Cache kept in Redis, shared by every replica
 type CacheManager struct {
 	rdb RedisClient
 	log zerolog.Logger
 }
 
+var _ Cache = (*CacheManager)(nil)
+
 // NewCacheManager initializes a new CacheManager
 func NewCacheManager(rdb RedisClient, log zerolog.Logger) *CacheManager {
 	return &CacheManager{
@@ -30,10 +55,131 @@ func NewCacheManager(rdb RedisClient, log zerolog.Logger) *CacheManager {
 
 // Set stores a value in Redis with TTL in minutes
 func (cm *CacheManager) Set(ctx context.Context, key string, value string, ttl int) error {
-	return cm.rdb.Set(ctx, key, value, time.Duration(ttl)*time.Minute).Err()
+	span := startSpan(ctx, "SET", key)
+	err := cm.rdb.Set(ctx, key, value, time.Duration(ttl)*time.Minute).Err()
+	tracing.End(span, &err)
+	return err
 }
 
 // Get retrieves a value from Redis
 func (cm *CacheManager) Get(ctx context.Context, key string) (string, error) {
-	return cm.rdb.Get(ctx, key).Result()
+	span := startSpan(ctx, "GET", key)
+	defer span.End()
+
+	value, err := cm.rdb.Get(ctx, key).Result()
+	switch {
+	case err == nil:
+		cacheRequests.WithLabelValues(keyKind(key), "hit").Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+	case err == redis.Nil:
+		cacheRequests.WithLabelValues(keyKind(key), "miss").Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+	default:
+		cacheRequests.WithLabelValues(keyKind(key), "error").Inc()
+		tracing.RecordError(span, err)
+	}
+	return value, err
+}
+
+// Delete removes keys from Redis, keys that are not cached are ignored
+func (cm *CacheManager) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	span := startSpan(ctx, "DEL", keys[0])
+	err := cm.rdb.Del(ctx, keys...).Err()
+	tracing.End(span, &err)
+	return err
+}
+
+// MGet retrieves several values from Redis in a single round trip
+func (cm *CacheManager) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	span := startSpan(ctx, "MGET", keys[0])
+	defer span.End()
+
+	results, err := cm.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		for _, key := range keys {
+			cacheRequests.WithLabelValues(keyKind(key), "error").Inc()
+		}
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	for i, key := range keys {
+		value, ok := results[i].(string)
+		if !ok {
+			cacheRequests.WithLabelValues(keyKind(key), "miss").Inc()
+			continue
+		}
+		cacheRequests.WithLabelValues(keyKind(key), "hit").Inc()
+		values[key] = value
+	}
+	span.SetAttributes(attribute.Int("cache.hits", len(values)))
+	return values, nil
+}
+
+// TTL returns how long key is kept in Redis
+func (cm *CacheManager) TTL(ctx context.Context, key string) (time.Duration, error) {
+	span := startSpan(ctx, "TTL", key)
+	ttl, err := cm.rdb.TTL(ctx, key).Result()
+	tracing.End(span, &err)
+	if err != nil {
+		return 0, err
+	}
+	// Redis answers -2 for a missing key and -1 for a key without a TTL
+	switch ttl {
+	case -2:
+		return 0, ErrMiss
+	case -1:
+		return NoExpiry, nil
+	}
+	return ttl, nil
+}
+
+// Incr atomically increments a counter and returns its new value
+func (cm *CacheManager) Incr(ctx context.Context, key string) (int64, error) {
+	span := startSpan(ctx, "INCR", key)
+	count, err := cm.rdb.Incr(ctx, key).Result()
+	tracing.End(span, &err)
+	return count, err
+}
+
+// IncrBy atomically increments a counter by value and returns its new value
+func (cm *CacheManager) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	span := startSpan(ctx, "INCRBY", key)
+	count, err := cm.rdb.IncrBy(ctx, key, value).Result()
+	tracing.End(span, &err)
+	return count, err
+}
+
+// IncrFrom atomically increments a counter starting from base, see incrFromScript
+func (cm *CacheManager) IncrFrom(ctx context.Context, key string, base int64) (int64, error) {
+	span := startSpan(ctx, "EVAL", key)
+	count, err := cm.rdb.Eval(ctx, incrFromScript, []string{key}, base).Int64()
+	tracing.End(span, &err)
+	return count, err
+}
+
+// startSpan traces a Redis command as a child of the span in ctx. The command has no spans of its own,
+// so it is run with the caller's context.
+func startSpan(ctx context.Context, command string, key string) trace.Span {
+	_, span := tracing.Start(ctx, "redis "+command, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", command),
+		attribute.String("cache.key", key),
+	))
+	return span
+}
+
+// keyKind names the kind of a key for metrics, keys without a prefix are task results
+func keyKind(key string) string {
+	kind, _, found := strings.Cut(key, ":")
+	if !found {
+		return "task"
+	}
+	return kind
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 )
@@ -72,3 +74,96 @@ func TestCacheManager_Get_Error(t *testing.T) {
 	assert.Empty(t, val)
 	mockRedis.AssertExpectations(t)
 }
+
+func TestCacheManager_Incr(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	logger := zerolog.Nop()
+
+	cm := NewCacheManager(mockRedis, logger)
+	ctx := context.Background()
+
+	mockRedis.On("Incr", ctx, "counter").Return(int64(3), nil)
+	mockRedis.On("IncrBy", ctx, "counter", int64(10)).Return(int64(13), nil)
+
+	val, err := cm.Incr(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), val)
+
+	val, err = cm.IncrBy(ctx, "counter", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), val)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestCacheManager_IncrFrom(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	cm := NewCacheManager(mockRedis, zerolog.Nop())
+	ctx := context.Background()
+
+	// Seeded and incremented by a single script
+	mockRedis.On("Eval", ctx, []string{"counter"}, []interface{}{int64(41)}).Return(int64(42), nil)
+
+	val, err := cm.IncrFrom(ctx, "counter", 41)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), val)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestCacheManager_Get_Metrics(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	logger := zerolog.Nop()
+
+	cm := NewCacheManager(mockRedis, logger)
+	ctx := context.Background()
+
+	mockRedis.On("Get", ctx, "metrics:hit").Return("1", nil)
+	mockRedis.On("Get", ctx, "metrics:miss").Return("", redis.Nil)
+	mockRedis.On("Get", ctx, "metrics:error").Return("", errors.New("connection refused"))
+	hits, misses, failures := testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "hit")), testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "miss")), testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "error"))
+
+	cm.Get(ctx, "metrics:hit")
+	cm.Get(ctx, "metrics:hit")
+	cm.Get(ctx, "metrics:miss")
+	cm.Get(ctx, "metrics:error")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "hit"))-hits)
+	assert.Equal(t, float64(1), testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "miss"))-misses)
+	assert.Equal(t, float64(1), testutil.ToFloat64(cacheRequests.WithLabelValues("metrics", "error"))-failures)
+	assert.Equal(t, "task", keyKind("0b6f5c1e-task-id"))
+}
+
+func TestCacheManager_DeleteAndMGet(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	cm := NewCacheManager(mockRedis, zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("Del", ctx, []string{"a", "b"}).Return(int64(1), nil)
+	mockRedis.On("MGet", ctx, []string{"a", "b", "c"}).Return([]interface{}{"1", nil, "3"}, nil)
+
+	assert.NoError(t, cm.Delete(ctx, "a", "b"))
+	assert.NoError(t, cm.Delete(ctx), "nothing to delete")
+
+	values, err := cm.MGet(ctx, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, values)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestCacheManager_TTL(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	cm := NewCacheManager(mockRedis, zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("TTL", ctx, "expiring").Return(90*time.Second, nil)
+	mockRedis.On("TTL", ctx, "forever").Return(time.Duration(-1), nil)
+	mockRedis.On("TTL", ctx, "missing").Return(time.Duration(-2), nil)
+
+	ttl, err := cm.TTL(ctx, "expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, ttl)
+	ttl, err = cm.TTL(ctx, "forever")
+	assert.NoError(t, err)
+	assert.Equal(t, NoExpiry, ttl)
+	_, err = cm.TTL(ctx, "missing")
+	assert.ErrorIs(t, err, ErrMiss)
+}
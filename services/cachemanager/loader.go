@@ -0,0 +1,192 @@
+package cachemanager
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheLoads counts how reads through a Loader were answered
+var cacheLoads = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "urlshortner_cache_loads_total",
+	Help: "Reads through the cache loader by result: hit, negative_hit, load, coalesced or early_refresh.",
+}, []string{"result"})
+
+// LoaderOptions configures how a Loader protects the database behind the cache
+type LoaderOptions struct {
+	// NegativeTTL is how long, in minutes, a key without a value is remembered; 0 turns negative caching off
+	NegativeTTL int
+	// Beta scales the probabilistic early refresh, above 1 refreshes earlier; 0 turns it off
+	Beta float64
+}
+
+// DefaultLoaderOptions remembers missing keys for a minute and refreshes values as the XFetch paper suggests
+func DefaultLoaderOptions() LoaderOptions {
+	return LoaderOptions{
+		NegativeTTL: 1,
+		Beta:        1,
+	}
+}
+
+// Loader reads values through a Cache and loads the missing ones. Concurrent misses of a key share a single
+// load, values are refreshed by one reader at random shortly before they expire rather than by every reader
+// at once when they do, and keys without a value are cached for a short while.
+type Loader struct {
+	cache  Cache
+	group  singleflight.Group
+	opts   LoaderOptions
+	now    func() time.Time
+	random func() float64
+}
+
+// NewLoader reads through cache
+func NewLoader(cache Cache, opts LoaderOptions) *Loader {
+	return &Loader{
+		cache:  cache,
+		opts:   opts,
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// entry is a value cached by a Loader with what the early refresh needs to know
+type entry struct {
+	found   bool
+	expires time.Time     // zero for values without a TTL
+	delta   time.Duration // how long the load took
+	value   string
+}
+
+// encode writes e as "<v|n><expiry ms>:<delta ms>:<value>"
+func (e entry) encode() string {
+	flag := "v"
+	if !e.found {
+		flag = "n"
+	}
+	var expires int64
+	if !e.expires.IsZero() {
+		expires = e.expires.UnixMilli()
+	}
+	return flag + strconv.FormatInt(expires, 10) + ":" + strconv.FormatInt(e.delta.Milliseconds(), 10) + ":" + e.value
+}
+
+// decodeEntry reads an entry written by encode. Values cached before there was a Loader are found values
+// that are never refreshed early.
+func decodeEntry(data string) entry {
+	legacy := entry{found: true, value: data}
+	if len(data) == 0 || (data[0] != 'v' && data[0] != 'n') {
+		return legacy
+	}
+	expires, rest, ok := strings.Cut(data[1:], ":")
+	if !ok {
+		return legacy
+	}
+	delta, value, ok := strings.Cut(rest, ":")
+	if !ok {
+		return legacy
+	}
+	expiresMs, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return legacy
+	}
+	deltaMs, err := strconv.ParseInt(delta, 10, 64)
+	if err != nil {
+		return legacy
+	}
+
+	e := entry{found: data[0] == 'v', delta: time.Duration(deltaMs) * time.Millisecond, value: value}
+	if expiresMs > 0 {
+		e.expires = time.UnixMilli(expiresMs)
+	}
+	return e
+}
+
+// refreshEarly decides at random whether e is refreshed before it expires. The closer the expiry and the
+// slower the load, the likelier the refresh (XFetch).
+func (l *Loader) refreshEarly(e entry) bool {
+	if l.opts.Beta <= 0 || e.expires.IsZero() || !e.found {
+		return false
+	}
+	early := time.Duration(float64(e.delta) * l.opts.Beta * -math.Log(1-l.random()))
+	return !l.now().Add(early).Before(e.expires)
+}
+
+// Get returns the value of key, loading it with load and caching it for ttl minutes when it is not
+// cached. A ttl of 0 keeps the value until it is deleted. load reports found false when key has no value,
+// the miss is then cached for the negative TTL. A cache that fails is bypassed.
+func (l *Loader) Get(ctx context.Context, key string, ttl int, load func(ctx context.Context) (string, bool, error)) (string, bool, error) {
+	if data, err := l.cache.Get(ctx, key); err == nil {
+		e := decodeEntry(data)
+		if !l.refreshEarly(e) {
+			if e.found {
+				cacheLoads.WithLabelValues("hit").Inc()
+			} else {
+				cacheLoads.WithLabelValues("negative_hit").Inc()
+			}
+			return e.value, e.found, nil
+		}
+
+		cacheLoads.WithLabelValues("early_refresh").Inc()
+		if fresh, err := l.load(ctx, key, ttl, load); err == nil {
+			return fresh.value, fresh.found, nil
+		}
+		// Still valid, the next reader may try again
+		return e.value, e.found, nil
+	}
+
+	e, err := l.load(ctx, key, ttl, load)
+	if err != nil {
+		return "", false, err
+	}
+	return e.value, e.found, nil
+}
+
+// load runs load once for every caller waiting on key and caches the result
+func (l *Loader) load(ctx context.Context, key string, ttl int, load func(ctx context.Context) (string, bool, error)) (entry, error) {
+	leader := false
+	result, err, shared := l.group.Do(key, func() (any, error) {
+		leader = true
+		cacheLoads.WithLabelValues("load").Inc()
+		// The callers share the load, it must not fail because the first of them gave up
+		ctx := context.WithoutCancel(ctx)
+
+		start := l.now()
+		value, found, err := load(ctx)
+		if err != nil {
+			return entry{}, err
+		}
+
+		e := entry{found: found, delta: l.now().Sub(start), value: value}
+		if !found {
+			if l.opts.NegativeTTL <= 0 {
+				return e, nil
+			}
+			ttl = l.opts.NegativeTTL
+		}
+		if ttl > 0 {
+			e.expires = l.now().Add(time.Duration(ttl) * time.Minute)
+		}
+		// A failed write only costs the next reader a load
+		l.cache.Set(ctx, key, e.encode(), ttl)
+		return e, nil
+	})
+	if shared && !leader {
+		cacheLoads.WithLabelValues("coalesced").Inc()
+	}
+	if err != nil {
+		return entry{}, err
+	}
+	return result.(entry), nil
+}
+
+// Delete removes keys, loaded or cached as missing, so the next read loads them again
+func (l *Loader) Delete(ctx context.Context, keys ...string) error {
+	return l.cache.Delete(ctx, keys...)
+}
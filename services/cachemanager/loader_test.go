@@ -0,0 +1,171 @@
+package cachemanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader_CoalescesConcurrentMisses(t *testing.T) {
+	loader := NewLoader(NewMemoryCache(10), LoaderOptions{NegativeTTL: 1})
+	ctx := context.Background()
+	coalesced := testutil.ToFloat64(cacheLoads.WithLabelValues("coalesced"))
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, bool, error) {
+		calls.Add(1)
+		<-release
+		return "task", true, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, found, err := loader.Get(ctx, "task:1", 5, load)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "task", value)
+		}()
+	}
+	// Readers arriving after the load are served from the cache instead
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Positive(t, testutil.ToFloat64(cacheLoads.WithLabelValues("coalesced"))-coalesced)
+}
+
+func TestLoader_CachesMissingKeys(t *testing.T) {
+	cache := NewMemoryCache(10)
+	loader := NewLoader(cache, DefaultLoaderOptions())
+	ctx := context.Background()
+
+	calls := 0
+	load := func(ctx context.Context) (string, bool, error) {
+		calls++
+		return "", false, nil
+	}
+
+	for range 3 {
+		_, found, err := loader.Get(ctx, "link:nope", 0, load)
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Only for the negative TTL, even when found values are kept for good
+	ttl, err := cache.TTL(ctx, "link:nope")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl.Round(time.Second))
+
+	require.NoError(t, loader.Delete(ctx, "link:nope"))
+	loader.Get(ctx, "link:nope", 0, load)
+	assert.Equal(t, 2, calls)
+}
+
+func TestLoader_NegativeCachingOff(t *testing.T) {
+	cache := NewMemoryCache(10)
+	loader := NewLoader(cache, LoaderOptions{})
+	ctx := context.Background()
+
+	calls := 0
+	load := func(ctx context.Context) (string, bool, error) {
+		calls++
+		return "", false, nil
+	}
+	loader.Get(ctx, "link:nope", 5, load)
+	loader.Get(ctx, "link:nope", 5, load)
+
+	assert.Equal(t, 2, calls)
+	assert.Zero(t, cache.Len())
+}
+
+func TestLoader_RefreshesEarly(t *testing.T) {
+	loader := NewLoader(NewMemoryCache(10), DefaultLoaderOptions())
+	ctx := context.Background()
+
+	clock := time.Now()
+	loader.now = func() time.Time { return clock }
+	version := 0
+	load := func(ctx context.Context) (string, bool, error) {
+		version++
+		clock = clock.Add(10 * time.Second) // the load is slow
+		return string(rune('0' + version)), true, nil
+	}
+
+	value, _, err := loader.Get(ctx, "link:abc", 1, load)
+	require.NoError(t, err)
+	require.Equal(t, "1", value)
+
+	// Far from the expiry only an unlikely draw refreshes
+	loader.random = func() float64 { return 0.5 }
+	value, _, _ = loader.Get(ctx, "link:abc", 1, load)
+	assert.Equal(t, "1", value)
+
+	// 5s before the expiry a 10s load is likely to be refreshed: 10s * -ln(0.5) is about 7s
+	clock = clock.Add(time.Minute - 5*time.Second)
+	loader.random = func() float64 { return 0.1 }
+	value, _, _ = loader.Get(ctx, "link:abc", 1, load)
+	assert.Equal(t, "1", value)
+
+	refreshes := testutil.ToFloat64(cacheLoads.WithLabelValues("early_refresh"))
+	loader.random = func() float64 { return 0.5 }
+	value, _, _ = loader.Get(ctx, "link:abc", 1, load)
+	assert.Equal(t, "2", value)
+	assert.Equal(t, float64(1), testutil.ToFloat64(cacheLoads.WithLabelValues("early_refresh"))-refreshes)
+}
+
+func TestLoader_ErrorsAreNotCached(t *testing.T) {
+	cache := NewMemoryCache(10)
+	loader := NewLoader(cache, DefaultLoaderOptions())
+	ctx := context.Background()
+	boom := errors.New("connection refused")
+
+	_, _, err := loader.Get(ctx, "task:1", 5, func(ctx context.Context) (string, bool, error) {
+		return "", false, boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Zero(t, cache.Len())
+}
+
+func TestLoader_ReadsValuesCachedWithoutLoader(t *testing.T) {
+	cache := NewMemoryCache(10)
+	loader := NewLoader(cache, DefaultLoaderOptions())
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "task:1", `{"status":"completed"}`, 0))
+	value, found, err := loader.Get(ctx, "task:1", 0, func(ctx context.Context) (string, bool, error) {
+		t.Fatal("cached value loaded again")
+		return "", false, nil
+	})
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, `{"status":"completed"}`, value)
+}
+
+func TestLoader_WithoutCache(t *testing.T) {
+	loader := NewLoader(NoopCache{}, DefaultLoaderOptions())
+
+	calls := 0
+	for range 2 {
+		value, found, err := loader.Get(context.Background(), "task:1", 5, func(ctx context.Context) (string, bool, error) {
+			calls++
+			return "task", true, nil
+		})
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "task", value)
+	}
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,231 @@
+package cachemanager
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotInteger is returned by Incr and IncrBy when the value of the key is not a counter
+var ErrNotInteger = errors.New("value is not an integer or out of range")
+
+// MemoryCache is a Cache kept in the process. Beyond its capacity the least recently used keys are
+// evicted, expired keys are dropped when they are read. Counters are kept apart and never evicted, so
+// evicting them cannot reset a click limit. It is not shared between replicas.
+type MemoryCache struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // most recently used first
+	counters map[string]*memoryItem
+	now      func() time.Time
+}
+
+type memoryItem struct {
+	key     string
+	value   string
+	expires time.Time // zero for keys without a TTL
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+// NewMemoryCache returns an empty MemoryCache holding at most capacity keys
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: max(capacity, 1),
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		counters: make(map[string]*memoryItem),
+		now:      time.Now,
+	}
+}
+
+// Len returns the number of keys held, including expired keys not read since they expired
+func (c *MemoryCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len() + len(c.counters)
+}
+
+// Clear drops every key
+func (c *MemoryCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.counters = make(map[string]*memoryItem)
+}
+
+// lookup returns the item of key and marks it as recently used. The mutex must be held.
+func (c *MemoryCache) lookup(key string) *memoryItem {
+	if item, ok := c.counters[key]; ok {
+		if !item.expires.IsZero() && !c.now().Before(item.expires) {
+			delete(c.counters, key)
+			return nil
+		}
+		return item
+	}
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	item := elem.Value.(*memoryItem)
+	if !item.expires.IsZero() && !c.now().Before(item.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return item
+}
+
+// store sets the item of key and evicts the least recently used keys beyond the capacity. The mutex must be held.
+func (c *MemoryCache) store(key string, value string, expires time.Time) {
+	delete(c.counters, key)
+	if elem, ok := c.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.value, item.expires = value, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&memoryItem{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// storeCounter sets the counter of key, out of the LRU. The mutex must be held.
+func (c *MemoryCache) storeCounter(key string, n int64, expires time.Time) {
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.counters[key] = &memoryItem{key: key, value: strconv.FormatInt(n, 10), expires: expires}
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*memoryItem).key)
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item := c.lookup(key)
+	if item == nil {
+		cacheRequests.WithLabelValues(keyKind(key), "miss").Inc()
+		return "", ErrMiss
+	}
+	cacheRequests.WithLabelValues(keyKind(key), "hit").Inc()
+	return item.value, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value string, ttl int) error {
+	c.setFor(key, value, time.Duration(ttl)*time.Minute)
+	return nil
+}
+
+// setFor stores value for ttl, a ttl of 0 keeps it until it is evicted
+func (c *MemoryCache) setFor(key string, value string, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.store(key, value, expires)
+}
+
+// peek returns the value of key without counting the lookup
+func (c *MemoryCache) peek(key string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item := c.lookup(key)
+	if item == nil {
+		return "", false
+	}
+	return item.value, true
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+		delete(c.counters, key)
+	}
+	return nil
+}
+
+func (c *MemoryCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		item := c.lookup(key)
+		if item == nil {
+			cacheRequests.WithLabelValues(keyKind(key), "miss").Inc()
+			continue
+		}
+		cacheRequests.WithLabelValues(keyKind(key), "hit").Inc()
+		values[key] = item.value
+	}
+	return values, nil
+}
+
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item := c.lookup(key)
+	if item == nil {
+		return 0, ErrMiss
+	}
+	if item.expires.IsZero() {
+		return NoExpiry, nil
+	}
+	return item.expires.Sub(c.now()), nil
+}
+
+func (c *MemoryCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, 1)
+}
+
+// IncrBy increments the counter of key, keeping its TTL like Redis does
+func (c *MemoryCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.incr(key, 0, value)
+}
+
+func (c *MemoryCache) IncrFrom(ctx context.Context, key string, base int64) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.incr(key, base, 1)
+}
+
+// incr adds value to the counter of key, base when it does not exist. The mutex must be held.
+func (c *MemoryCache) incr(key string, base int64, value int64) (int64, error) {
+	n := base
+	var expires time.Time
+	if item := c.lookup(key); item != nil {
+		var err error
+		if n, err = strconv.ParseInt(item.value, 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+		expires = item.expires
+	}
+	n += value
+	c.storeCounter(key, n, expires)
+	return n, nil
+}
@@ -0,0 +1,159 @@
+package cachemanager
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache_GetSetDelete(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+
+	_, err := cache.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	require.NoError(t, cache.Set(ctx, "key", "value", 5))
+	val, err := cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	require.NoError(t, cache.Delete(ctx, "key", "unknown"))
+	_, err = cache.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2)
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", "1", 0)
+	cache.Set(ctx, "b", "2", 0)
+	cache.Get(ctx, "a") // b is now the least recently used
+	cache.Set(ctx, "c", "3", 0)
+
+	values, err := cache.MGet(ctx, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, values)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Set(ctx, "short", "1", 1)
+	cache.Set(ctx, "forever", "2", 0)
+
+	ttl, err := cache.TTL(ctx, "short")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	ttl, err = cache.TTL(ctx, "forever")
+	assert.NoError(t, err)
+	assert.Equal(t, NoExpiry, ttl)
+	_, err = cache.TTL(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	now = now.Add(time.Minute)
+	_, err = cache.Get(ctx, "short")
+	assert.ErrorIs(t, err, ErrMiss)
+	val, err := cache.Get(ctx, "forever")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", val)
+	assert.Equal(t, 1, cache.Len(), "expired keys are dropped when read")
+}
+
+func TestMemoryCache_Incr(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	n, err := cache.Incr(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = cache.IncrBy(ctx, "counter", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+
+	// Like Redis, incrementing keeps the TTL
+	cache.Set(ctx, "expiring", "5", 1)
+	n, err = cache.Incr(ctx, "expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	ttl, _ := cache.TTL(ctx, "expiring")
+	assert.Equal(t, time.Minute, ttl)
+
+	cache.Set(ctx, "text", "abc", 0)
+	_, err = cache.Incr(ctx, "text")
+	assert.ErrorIs(t, err, ErrNotInteger)
+}
+
+func TestMemoryCache_IncrFrom(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+
+	n, err := cache.IncrFrom(ctx, "counter", 41)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	// The seed is only used for a counter that does not exist
+	n, err = cache.IncrFrom(ctx, "counter", 41)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(43), n)
+}
+
+func TestMemoryCache_CountersAreNotEvicted(t *testing.T) {
+	cache := NewMemoryCache(2)
+	ctx := context.Background()
+
+	cache.IncrFrom(ctx, "clicks:abc123", 9)
+	for i := range 5 {
+		cache.Set(ctx, strconv.Itoa(i), "value", 0)
+	}
+
+	val, err := cache.Get(ctx, "clicks:abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", val)
+	assert.Equal(t, 3, cache.Len())
+
+	// Until deleted
+	cache.Delete(ctx, "clicks:abc123")
+	_, err = cache.Get(ctx, "clicks:abc123")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+func TestMemoryCache_Metrics(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+	hits, misses := testutil.ToFloat64(cacheRequests.WithLabelValues("memory", "hit")), testutil.ToFloat64(cacheRequests.WithLabelValues("memory", "miss"))
+
+	cache.Set(ctx, "memory:a", "1", 0)
+	cache.Get(ctx, "memory:a")
+	cache.Get(ctx, "memory:b")
+	cache.MGet(ctx, "memory:a", "memory:b")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(cacheRequests.WithLabelValues("memory", "hit"))-hits)
+	assert.Equal(t, float64(2), testutil.ToFloat64(cacheRequests.WithLabelValues("memory", "miss"))-misses)
+}
+
+func TestNoopCache(t *testing.T) {
+	var cache Cache = NoopCache{}
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "key", "value", 0))
+	_, err := cache.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrMiss)
+	values, err := cache.MGet(ctx, "key")
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+	n, err := cache.Incr(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,44 @@
+package cachemanager
+
+import (
+	"context"
+	"time"
+)
+
+// NoopCache caches nothing: every lookup misses and counters never go past 0, so click statistics need
+// one of the other caches and links with a click limit are refused, see clickcounter.Counter.Counting
+type NoopCache struct{}
+
+var _ Cache = NoopCache{}
+
+func (NoopCache) Get(ctx context.Context, key string) (string, error) {
+	return "", ErrMiss
+}
+
+func (NoopCache) Set(ctx context.Context, key string, value string, ttl int) error {
+	return nil
+}
+
+func (NoopCache) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func (NoopCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (NoopCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return 0, ErrMiss
+}
+
+func (NoopCache) Incr(ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
+
+func (NoopCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return 0, nil
+}
+
+func (NoopCache) IncrFrom(ctx context.Context, key string, base int64) (int64, error) {
+	return 0, nil
+}
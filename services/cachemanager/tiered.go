@@ -0,0 +1,282 @@
+package cachemanager
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// tierRequests counts lookups per tier, the hit ratio of a tier is its hits over all its lookups
+	tierRequests = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_cache_tier_requests_total",
+		Help: "Lookups of the two-tier cache by tier (l1 or l2) and result (hit or miss).",
+	}, []string{"tier", "result"})
+	cacheInvalidations = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "urlshortner_cache_invalidations_total",
+		Help: "Keys dropped from the in-process cache because another replica invalidated them.",
+	})
+)
+
+// Invalidator broadcasts the keys deleted on one replica to every other replica
+type Invalidator interface {
+	Publish(ctx context.Context, keys []string) error
+	// Subscribe calls fn with the keys published by any replica until ctx is done. fn is called once with
+	// no keys as soon as the subscription is established.
+	Subscribe(ctx context.Context, fn func(keys []string)) error
+}
+
+// TieredOptions configures the in-process tier of a TieredCache
+type TieredOptions struct {
+	// Size is the number of keys held in process
+	Size int
+	// TTL is how long a value is served from the process before it is read from L2 again. It bounds how
+	// long a replica that missed an invalidation serves a stale value.
+	TTL time.Duration
+	// Bypass lists key prefixes never held in process, for counters that have to be read from L2
+	Bypass []string
+}
+
+// DefaultTieredOptions keeps the click counters out of the process
+func DefaultTieredOptions() TieredOptions {
+	return TieredOptions{
+		Size: 10000,
+		TTL:  5 * time.Second,
+		Bypass: []string{
+			constants.CLICK_COUNTER_KEY_PREFIX,
+			constants.RULE_CLICK_KEY_PREFIX,
+			constants.VARIANT_CLICK_KEY_PREFIX,
+			constants.QR_SCAN_KEY_PREFIX,
+		},
+	}
+}
+
+// TieredCache serves hot keys from an LRU in the process (L1) in front of a shared cache (L2), usually
+// Redis. Deletes are broadcast so every replica drops the key from its L1; Set does not reach the other
+// replicas, values that change have to be deleted rather than overwritten.
+type TieredCache struct {
+	l1          *MemoryCache
+	l2          Cache
+	invalidator Invalidator
+	opts        TieredOptions
+	log         zerolog.Logger
+}
+
+var _ Cache = (*TieredCache)(nil)
+
+// NewTieredCache puts an L1 in front of l2. invalidator may be nil when a single replica runs.
+func NewTieredCache(l2 Cache, invalidator Invalidator, opts TieredOptions, log zerolog.Logger) *TieredCache {
+	return &TieredCache{
+		l1:          NewMemoryCache(opts.Size),
+		l2:          l2,
+		invalidator: invalidator,
+		opts:        opts,
+		log:         log,
+	}
+}
+
+// Listen drops the keys invalidated by other replicas from L1 until ctx is done
+func (c *TieredCache) Listen(ctx context.Context) {
+	if c.invalidator == nil {
+		return
+	}
+	for ctx.Err() == nil {
+		err := c.invalidator.Subscribe(ctx, func(keys []string) {
+			c.l1.Delete(ctx, keys...)
+			cacheInvalidations.Add(float64(len(keys)))
+		})
+		if err != nil && ctx.Err() == nil {
+			c.log.Error().Err(err).Msg("Cache invalidations interrupted, retrying")
+			// Invalidations may have been missed, the values held could be stale
+			c.l1.Clear()
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// cached reports whether key may be held in L1
+func (c *TieredCache) cached(key string) bool {
+	for _, prefix := range c.opts.Bypass {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if !c.cached(key) {
+		return c.l2.Get(ctx, key)
+	}
+
+	if value, ok := c.l1.peek(key); ok {
+		tierRequests.WithLabelValues("l1", "hit").Inc()
+		return value, nil
+	}
+	tierRequests.WithLabelValues("l1", "miss").Inc()
+
+	value, err := c.l2.Get(ctx, key)
+	switch {
+	case err == nil:
+		tierRequests.WithLabelValues("l2", "hit").Inc()
+		c.l1.setFor(key, value, c.opts.TTL)
+	case err == ErrMiss:
+		tierRequests.WithLabelValues("l2", "miss").Inc()
+	}
+	return value, err
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value string, ttl int) error {
+	if err := c.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if c.cached(key) {
+		c.l1.setFor(key, value, c.opts.TTL)
+	}
+	return nil
+}
+
+// Delete removes keys from both tiers here and from L1 on every other replica
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.l2.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	c.l1.Delete(ctx, keys...)
+	if c.invalidator == nil {
+		return nil
+	}
+	return c.invalidator.Publish(ctx, keys)
+}
+
+func (c *TieredCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if !c.cached(key) {
+			missing = append(missing, key)
+			continue
+		}
+		if value, ok := c.l1.peek(key); ok {
+			tierRequests.WithLabelValues("l1", "hit").Inc()
+			values[key] = value
+			continue
+		}
+		tierRequests.WithLabelValues("l1", "miss").Inc()
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	found, err := c.l2.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missing {
+		value, ok := found[key]
+		if !c.cached(key) {
+			if ok {
+				values[key] = value
+			}
+			continue
+		}
+		if !ok {
+			tierRequests.WithLabelValues("l2", "miss").Inc()
+			continue
+		}
+		tierRequests.WithLabelValues("l2", "hit").Inc()
+		values[key] = value
+		c.l1.setFor(key, value, c.opts.TTL)
+	}
+	return values, nil
+}
+
+func (c *TieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+func (c *TieredCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, 1)
+}
+
+func (c *TieredCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	n, err := c.l2.IncrBy(ctx, key, value)
+	if err == nil && c.cached(key) {
+		c.l1.Delete(ctx, key)
+	}
+	return n, err
+}
+
+func (c *TieredCache) IncrFrom(ctx context.Context, key string, base int64) (int64, error) {
+	n, err := c.l2.IncrFrom(ctx, key, base)
+	if err == nil && c.cached(key) {
+		c.l1.Delete(ctx, key)
+	}
+	return n, err
+}
+
+// RedisPubSub is the part of the Redis client used to broadcast invalidations
+type RedisPubSub interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// RedisInvalidator broadcasts invalidated keys over a Redis pub/sub channel. Messages published while a
+// replica is disconnected are lost, its L1 is cleared when it notices.
+type RedisInvalidator struct {
+	rdb     RedisPubSub
+	channel string
+}
+
+// NewRedisInvalidator broadcasts invalidations on channel
+func NewRedisInvalidator(rdb RedisPubSub, channel string) *RedisInvalidator {
+	return &RedisInvalidator{rdb: rdb, channel: channel}
+}
+
+func (i *RedisInvalidator) Publish(ctx context.Context, keys []string) error {
+	message, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return i.rdb.Publish(ctx, i.channel, message).Err()
+}
+
+func (i *RedisInvalidator) Subscribe(ctx context.Context, fn func(keys []string)) error {
+	sub := i.rdb.Subscribe(ctx, i.channel)
+	defer sub.Close()
+	// Wait for the subscription, so keys deleted from now on are not missed
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	fn(nil)
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return redis.ErrClosed
+			}
+			var keys []string
+			if err := json.Unmarshal([]byte(msg.Payload), &keys); err != nil {
+				continue
+			}
+			fn(keys)
+		}
+	}
+}
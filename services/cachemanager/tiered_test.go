@@ -0,0 +1,126 @@
+package cachemanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hub delivers invalidations between TieredCaches in the same process, the way Redis pub/sub does between replicas
+type hub struct {
+	mutex       sync.Mutex
+	subscribers []func(keys []string)
+}
+
+func (h *hub) Publish(ctx context.Context, keys []string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, fn := range h.subscribers {
+		fn(keys)
+	}
+	return nil
+}
+
+func (h *hub) Subscribe(ctx context.Context, fn func(keys []string)) error {
+	h.mutex.Lock()
+	h.subscribers = append(h.subscribers, fn)
+	h.mutex.Unlock()
+	fn(nil)
+	<-ctx.Done()
+	return nil
+}
+
+func (h *hub) count() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
+
+func TestTieredCache_ServesFromL1(t *testing.T) {
+	l2 := NewMemoryCache(10)
+	cache := NewTieredCache(l2, nil, DefaultTieredOptions(), zerolog.Nop())
+	ctx := context.Background()
+	l1Hits, l2Hits := testutil.ToFloat64(tierRequests.WithLabelValues("l1", "hit")), testutil.ToFloat64(tierRequests.WithLabelValues("l2", "hit"))
+
+	require.NoError(t, l2.Set(ctx, "link:abc", "v1", 0))
+	val, err := cache.Get(ctx, "link:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	// Changed behind the back of the L1, which keeps serving its copy until its TTL
+	l2.Set(ctx, "link:abc", "v2", 0)
+	val, _ = cache.Get(ctx, "link:abc")
+	assert.Equal(t, "v1", val)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(tierRequests.WithLabelValues("l1", "hit"))-l1Hits)
+	assert.Equal(t, float64(1), testutil.ToFloat64(tierRequests.WithLabelValues("l2", "hit"))-l2Hits)
+
+	cache.l1.now = func() time.Time { return time.Now().Add(DefaultTieredOptions().TTL) }
+	val, _ = cache.Get(ctx, "link:abc")
+	assert.Equal(t, "v2", val)
+
+	_, err = cache.Get(ctx, "link:missing")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+func TestTieredCache_CountersBypassL1(t *testing.T) {
+	l2 := NewMemoryCache(10)
+	cache := NewTieredCache(l2, nil, DefaultTieredOptions(), zerolog.Nop())
+	ctx := context.Background()
+
+	cache.Incr(ctx, "clicks:abc")
+	cache.Get(ctx, "clicks:abc")
+	l2.IncrBy(ctx, "clicks:abc", 5) // counted by another replica
+
+	val, err := cache.Get(ctx, "clicks:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "6", val)
+	assert.Zero(t, cache.l1.Len())
+}
+
+func TestTieredCache_MGet(t *testing.T) {
+	l2 := NewMemoryCache(10)
+	cache := NewTieredCache(l2, nil, DefaultTieredOptions(), zerolog.Nop())
+	ctx := context.Background()
+
+	cache.Set(ctx, "link:a", "1", 0)
+	l2.Set(ctx, "link:b", "2", 0)
+	l2.Set(ctx, "clicks:a", "3", 0)
+
+	values, err := cache.MGet(ctx, "link:a", "link:b", "link:c", "clicks:a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"link:a": "1", "link:b": "2", "clicks:a": "3"}, values)
+	assert.Equal(t, 2, cache.l1.Len())
+}
+
+func TestTieredCache_InvalidatesOtherReplicas(t *testing.T) {
+	l2 := NewMemoryCache(10)
+	invalidations := &hub{}
+	first := NewTieredCache(l2, invalidations, DefaultTieredOptions(), zerolog.Nop())
+	second := NewTieredCache(l2, invalidations, DefaultTieredOptions(), zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go first.Listen(ctx)
+	go second.Listen(ctx)
+	require.Eventually(t, func() bool { return invalidations.count() == 2 }, time.Second, time.Millisecond)
+
+	first.Set(ctx, "link:abc", "v1", 0)
+	val, _ := second.Get(ctx, "link:abc")
+	require.Equal(t, "v1", val)
+	received := testutil.ToFloat64(cacheInvalidations)
+
+	// The destination changes on the first replica
+	require.NoError(t, first.Delete(ctx, "link:abc"))
+	l2.Set(ctx, "link:abc", "v2", 0)
+
+	val, _ = second.Get(ctx, "link:abc")
+	assert.Equal(t, "v2", val)
+	assert.Equal(t, float64(2), testutil.ToFloat64(cacheInvalidations)-received)
+}
@@ -0,0 +1,212 @@
+package clickcounter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/rs/zerolog"
+)
+
+// Store persists reconciled click counts
+type Store interface {
+	SyncClickCount(ctx context.Context, shortCode string, count int64) error
+}
+
+// Counter counts redirects per short code in the cache, so limits can be enforced atomically across
+// replicas, and periodically writes the counts back to the database.
+type Counter struct {
+	cache    cachemanager.Cache
+	store    Store
+	log      zerolog.Logger
+	mutex    sync.Mutex
+	dirty    map[string]struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCounter initializes a new Counter
+func NewCounter(cache cachemanager.Cache, store Store, log zerolog.Logger) *Counter {
+	return &Counter{
+		cache:    cache,
+		store:    store,
+		log:      log,
+		dirty:    make(map[string]struct{}),
+		stopChan: make(chan struct{}),
+	}
+}
+
+func counterKey(shortCode string) string {
+	return constants.CLICK_COUNTER_KEY_PREFIX + shortCode
+}
+
+func bucketKey(prefix string, shortCode string, bucket string) string {
+	return prefix + shortCode + ":" + bucket
+}
+
+// Counting reports whether the cache keeps counters. Without one, such as with CACHE_BACKEND=none, every
+// count stays at 0 and click limits cannot be enforced.
+func (c *Counter) Counting() bool {
+	_, noop := c.cache.(cachemanager.NoopCache)
+	return !noop
+}
+
+// Hit atomically counts a redirect of shortCode and returns the total number of redirects,
+// including this one. base is the count stored in the database; it seeds the Redis counter
+// the first time the code is counted (e.g. after a Redis flush), in the same step as the count,
+// so concurrent requests never see a count without it.
+func (c *Counter) Hit(ctx context.Context, shortCode string, base int64) (int64, error) {
+	key := counterKey(shortCode)
+
+	var n int64
+	var err error
+	if base > 0 {
+		n, err = c.cache.IncrFrom(ctx, key, base)
+	} else {
+		// Nothing to seed
+		n, err = c.cache.Incr(ctx, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	c.mutex.Lock()
+	c.dirty[shortCode] = struct{}{}
+	c.mutex.Unlock()
+
+	return n, nil
+}
+
+// Count returns the current number of redirects of shortCode without counting a new one
+func (c *Counter) Count(ctx context.Context, shortCode string, base int64) (int64, error) {
+	val, err := c.cache.Get(ctx, counterKey(shortCode))
+	if err == cachemanager.ErrMiss {
+		return base, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return max(n, base), nil
+}
+
+// HitRule counts a redirect of shortCode that was sent to the target of the routing rule named rule
+func (c *Counter) HitRule(ctx context.Context, shortCode string, rule string) error {
+	_, err := c.cache.Incr(ctx, bucketKey(constants.RULE_CLICK_KEY_PREFIX, shortCode, rule))
+	return err
+}
+
+// RuleCounts returns the number of redirects per routing rule of shortCode
+func (c *Counter) RuleCounts(ctx context.Context, shortCode string, rules []string) (map[string]int64, error) {
+	return c.bucketCounts(ctx, constants.RULE_CLICK_KEY_PREFIX, shortCode, rules)
+}
+
+// HitVariant counts a redirect of shortCode that was sent to the split variant named variant
+func (c *Counter) HitVariant(ctx context.Context, shortCode string, variant string) error {
+	_, err := c.cache.Incr(ctx, bucketKey(constants.VARIANT_CLICK_KEY_PREFIX, shortCode, variant))
+	return err
+}
+
+// VariantCounts returns the number of redirects per split variant of shortCode
+func (c *Counter) VariantCounts(ctx context.Context, shortCode string, variants []string) (map[string]int64, error) {
+	return c.bucketCounts(ctx, constants.VARIANT_CLICK_KEY_PREFIX, shortCode, variants)
+}
+
+// HitScan counts a redirect of shortCode that came from scanning its QR code. Scans are counted
+// as clicks as well, this counter only tells them apart.
+func (c *Counter) HitScan(ctx context.Context, shortCode string) error {
+	_, err := c.cache.Incr(ctx, constants.QR_SCAN_KEY_PREFIX+shortCode)
+	return err
+}
+
+// Scans returns the number of redirects of shortCode that came from scanning its QR code
+func (c *Counter) Scans(ctx context.Context, shortCode string) (int64, error) {
+	val, err := c.cache.Get(ctx, constants.QR_SCAN_KEY_PREFIX+shortCode)
+	if err == cachemanager.ErrMiss {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func (c *Counter) bucketCounts(ctx context.Context, prefix string, shortCode string, buckets []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(buckets))
+	for _, bucket := range buckets {
+		val, err := c.cache.Get(ctx, bucketKey(prefix, shortCode, bucket))
+		if err == cachemanager.ErrMiss {
+			counts[bucket] = 0
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if counts[bucket], err = strconv.ParseInt(val, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// Run reconciles the counters with the database every interval until Stop is called. Stop also
+// cancels a flush in progress, the short codes it did not write are kept for the next flush.
+func (c *Counter) Run(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.stopChan
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush(ctx)
+		case <-c.stopChan:
+			c.log.Info().Msg("Stopping click counter reconciliation...")
+			return
+		}
+	}
+}
+
+// Stop stops the reconciliation loop started by Run
+func (c *Counter) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+	})
+}
+
+// Flush writes the counters of every short code counted since the last flush to the database
+func (c *Counter) Flush(ctx context.Context) {
+	c.mutex.Lock()
+	dirty := c.dirty
+	c.dirty = make(map[string]struct{})
+	c.mutex.Unlock()
+
+	for shortCode := range dirty {
+		count, err := c.Count(ctx, shortCode, 0)
+		if err == nil {
+			err = c.store.SyncClickCount(ctx, shortCode, count)
+		}
+		if err != nil {
+			c.log.Error().Err(err).Str("short_code", shortCode).Msg("Failed to reconcile click count")
+
+			// Retry on the next flush
+			c.mutex.Lock()
+			c.dirty[shortCode] = struct{}{}
+			c.mutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package clickcounter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/cachemanager"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/clickcounter"
+	mocks "github.com/Dev-AustinPeter/url-shortner-go/tests/mock"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter_HitSeedsFromDatabase(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	mockRepo := new(mocks.MockUrlRepository)
+	counter := clickcounter.NewCounter(cachemanager.NewCacheManager(mockRedis, zerolog.Nop()), mockRepo, zerolog.Nop())
+	ctx := context.Background()
+
+	// First hit after the key disappeared: the database count seeds it in the same step
+	mockRedis.On("Eval", ctx, []string{"clicks:abc123"}, []interface{}{int64(41)}).Return(int64(42), nil).Once()
+
+	n, err := counter.Hit(ctx, "abc123", 41)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	mockRedis.On("Eval", ctx, []string{"clicks:abc123"}, []interface{}{int64(41)}).Return(int64(43), nil).Once()
+
+	n, err = counter.Hit(ctx, "abc123", 41)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(43), n)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestCounter_Counting(t *testing.T) {
+	assert.True(t, clickcounter.NewCounter(cachemanager.NewMemoryCache(10), new(mocks.MockUrlRepository), zerolog.Nop()).Counting())
+	assert.False(t, clickcounter.NewCounter(cachemanager.NoopCache{}, new(mocks.MockUrlRepository), zerolog.Nop()).Counting())
+}
+
+func TestCounter_Count(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	counter := clickcounter.NewCounter(cachemanager.NewCacheManager(mockRedis, zerolog.Nop()), new(mocks.MockUrlRepository), zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("Get", ctx, "clicks:abc123").Return("", redis.Nil).Once()
+	n, err := counter.Count(ctx, "abc123", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+
+	mockRedis.On("Get", ctx, "clicks:abc123").Return("12", nil).Once()
+	n, err = counter.Count(ctx, "abc123", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), n)
+}
+
+func TestCounter_FlushReconcilesDirtyCodes(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	mockRepo := new(mocks.MockUrlRepository)
+	counter := clickcounter.NewCounter(cachemanager.NewCacheManager(mockRedis, zerolog.Nop()), mockRepo, zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("Incr", ctx, "clicks:abc123").Return(int64(5), nil)
+	_, err := counter.Hit(ctx, "abc123", 0)
+	assert.NoError(t, err)
+
+	// A failed sync is retried on the next flush
+	mockRedis.On("Get", ctx, "clicks:abc123").Return("5", nil)
+	mockRepo.On("SyncClickCount", "abc123", int64(5)).Return(errors.New("db down")).Once()
+	counter.Flush(ctx)
+
+	mockRepo.On("SyncClickCount", "abc123", int64(5)).Return(nil).Once()
+	counter.Flush(ctx)
+
+	// Nothing left to reconcile
+	counter.Flush(ctx)
+	mockRepo.AssertNumberOfCalls(t, "SyncClickCount", 2)
+}
+
+func TestCounter_RuleCounts(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	counter := clickcounter.NewCounter(cachemanager.NewCacheManager(mockRedis, zerolog.Nop()), new(mocks.MockUrlRepository), zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("Incr", ctx, "rule_clicks:abc123:ios").Return(int64(5), nil).Once()
+	assert.NoError(t, counter.HitRule(ctx, "abc123", "ios"))
+
+	mockRedis.On("Get", ctx, "rule_clicks:abc123:ios").Return("5", nil).Once()
+	mockRedis.On("Get", ctx, "rule_clicks:abc123:default").Return("", redis.Nil).Once()
+
+	counts, err := counter.RuleCounts(ctx, "abc123", []string{"ios", "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"ios": 5, "default": 0}, counts)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestCounter_Scans(t *testing.T) {
+	mockRedis := new(mocks.MockRedisClient)
+	counter := clickcounter.NewCounter(cachemanager.NewCacheManager(mockRedis, zerolog.Nop()), new(mocks.MockUrlRepository), zerolog.Nop())
+	ctx := context.Background()
+
+	mockRedis.On("Get", ctx, "qr_scans:abc123").Return("", redis.Nil).Once()
+	n, err := counter.Scans(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	mockRedis.On("Incr", ctx, "qr_scans:abc123").Return(int64(1), nil).Once()
+	assert.NoError(t, counter.HitScan(ctx, "abc123"))
+
+	mockRedis.On("Get", ctx, "qr_scans:abc123").Return("1", nil).Once()
+	n, err = counter.Scans(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	mockRedis.AssertExpectations(t)
+}
@@ -0,0 +1,143 @@
+package destpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrBlocked is matched (errors.Is) by every Violation returned from the engine
+var ErrBlocked = errors.New("destination is not allowed")
+
+// Violation describes which rule rejected a destination and why
+type Violation struct {
+	Rule   string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("destination is not allowed: %s", v.Reason)
+}
+
+func (v *Violation) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// Rule checks a single destination URL. It returns a *Violation when the destination is rejected.
+type Rule interface {
+	Name() string
+	Check(u *url.URL) error
+}
+
+// Reloader is implemented by rules backed by files that can change at runtime
+type Reloader interface {
+	// Reload re-reads the backing file if it changed and reports whether it did
+	Reload() (bool, error)
+}
+
+// Engine runs every configured rule against a destination. An engine without rules allows everything.
+type Engine struct {
+	mu       sync.RWMutex
+	rules    []Rule
+	log      zerolog.Logger
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewEngine initializes a new Engine with the given rules
+func NewEngine(log zerolog.Logger, rules ...Rule) *Engine {
+	return &Engine{
+		rules:    rules,
+		log:      log,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// AddRule appends a rule to the engine
+func (e *Engine) AddRule(rule Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule)
+}
+
+// Check parses rawUrl and runs it through every rule, returning the first violation
+func (e *Engine) Check(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return &Violation{Rule: "parse", Reason: "destination is not a valid url"}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, rule := range e.rules {
+		if err := rule.Check(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch periodically reloads every file-backed rule until StopWatch is called
+func (e *Engine) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.reload()
+		case <-e.stopChan:
+			e.log.Info().Msg("Stopping destination policy reloader...")
+			return
+		}
+	}
+}
+
+// StopWatch stops the reload goroutine started by Watch
+func (e *Engine) StopWatch() {
+	e.stopOnce.Do(func() {
+		close(e.stopChan)
+	})
+}
+
+func (e *Engine) reload() {
+	e.mu.RLock()
+	rules := append([]Rule(nil), e.rules...)
+	e.mu.RUnlock()
+
+	for _, rule := range rules {
+		reloader, ok := rule.(Reloader)
+		if !ok {
+			continue
+		}
+
+		changed, err := reloader.Reload()
+		if err != nil {
+			// Keep serving the previous version of the list
+			e.log.Error().Err(err).Str("rule", rule.Name()).Msg("Failed to reload destination policy")
+			continue
+		}
+		if changed {
+			e.log.Info().Str("rule", rule.Name()).Msg("Destination policy reloaded")
+		}
+	}
+}
+
+// normalizeHost lowercases a host and strips the port and trailing dot
+func normalizeHost(host string) string {
+	if strings.Count(host, ":") == 1 {
+		host, _, _ = strings.Cut(host, ":")
+	}
+	return strings.TrimSuffix(strings.Trim(strings.ToLower(host), "[]"), ".")
+}
+
+// matchDomain reports whether host equals domain or is one of its subdomains
+func matchDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
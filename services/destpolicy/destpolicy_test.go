@@ -0,0 +1,134 @@
+package destpolicy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/destpolicy"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/urlvalidator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeList(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestEngine_NoRulesAllowsEverything(t *testing.T) {
+	engine := destpolicy.NewEngine(zerolog.Nop())
+
+	assert.NoError(t, engine.Check("https://example.com/"))
+}
+
+func TestDomainBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeList(t, path, "# phishing\nevil.com\n\nBAD.example.org\n")
+
+	rule, err := destpolicy.NewDomainBlocklist(path)
+	require.NoError(t, err)
+	engine := destpolicy.NewEngine(zerolog.Nop(), rule)
+
+	err = engine.Check("https://evil.com/login")
+	assert.True(t, errors.Is(err, destpolicy.ErrBlocked))
+	assert.Error(t, engine.Check("https://login.evil.com/"))
+	assert.Error(t, engine.Check("https://bad.example.org:8443/"))
+	assert.NoError(t, engine.Check("https://notevil.com/"))
+	assert.NoError(t, engine.Check("https://example.org/"))
+}
+
+func TestDomainAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.txt")
+	writeList(t, path, "example.com\n")
+
+	rule, err := destpolicy.NewDomainAllowlist(path)
+	require.NoError(t, err)
+	engine := destpolicy.NewEngine(zerolog.Nop(), rule)
+
+	assert.NoError(t, engine.Check("https://example.com/"))
+	assert.NoError(t, engine.Check("https://docs.example.com/"))
+	assert.Error(t, engine.Check("https://other.com/"))
+}
+
+func TestRegexBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regex.txt")
+	writeList(t, path, `^https?://[^/]+/wp-admin/`+"\n"+`\.exe$`+"\n")
+
+	rule, err := destpolicy.NewRegexBlocklist(path)
+	require.NoError(t, err)
+	engine := destpolicy.NewEngine(zerolog.Nop(), rule)
+
+	assert.Error(t, engine.Check("https://site.com/wp-admin/index.php"))
+	assert.Error(t, engine.Check("https://site.com/download/setup.exe"))
+	assert.NoError(t, engine.Check("https://site.com/blog/"))
+}
+
+func TestRegexBlocklist_InvalidPattern(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regex.txt")
+	writeList(t, path, "([unclosed\n")
+
+	_, err := destpolicy.NewRegexBlocklist(path)
+	assert.Error(t, err)
+}
+
+func TestThreatFeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.txt")
+	writeList(t, path, "https://compromised.com/phish/\nmalware.net\nhttp://evil.co\nhttp://Bad.COM:80/x\nhttps://bad.org/login?next=1\nftp://files.example/\n")
+
+	rule, err := destpolicy.NewThreatFeed(path, urlvalidator.NewValidator(urlvalidator.DefaultOptions()))
+	require.NoError(t, err)
+	engine := destpolicy.NewEngine(zerolog.Nop(), rule)
+
+	assert.Error(t, engine.Check("https://compromised.com/phish/page.html"))
+	assert.NoError(t, engine.Check("https://compromised.com/about"))
+	assert.Error(t, engine.Check("http://cdn.malware.net/x"))
+
+	// Entries match on host boundaries
+	assert.Error(t, engine.Check("http://evil.co/"))
+	assert.Error(t, engine.Check("https://evil.co/anything"))
+	assert.NoError(t, engine.Check("http://evil.com/anything"))
+	assert.NoError(t, engine.Check("http://sub.evil.co/"))
+
+	// Entries are canonicalized like destinations and match on path segments
+	assert.Error(t, engine.Check("http://bad.com/x"))
+	assert.Error(t, engine.Check("https://bad.com/x/y"))
+	assert.NoError(t, engine.Check("http://bad.com/xyz"))
+	assert.Error(t, engine.Check("https://bad.org/login?next=1"))
+	assert.NoError(t, engine.Check("https://bad.org/login?next=2"))
+}
+
+func TestLoopRule(t *testing.T) {
+	engine := destpolicy.NewEngine(zerolog.Nop(), destpolicy.NewLoopRule([]string{"sho.rt:8080"}, destpolicy.DefaultShorteners))
+
+	assert.Error(t, engine.Check("https://sho.rt/abc123"))
+	assert.Error(t, engine.Check("https://bit.ly/xyz"))
+	assert.Error(t, engine.Check("https://www.tinyurl.com/xyz"))
+	assert.NoError(t, engine.Check("https://example.com/"))
+}
+
+func TestEngine_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeList(t, path, "evil.com\n")
+
+	rule, err := destpolicy.NewDomainBlocklist(path)
+	require.NoError(t, err)
+	engine := destpolicy.NewEngine(zerolog.Nop(), rule)
+
+	assert.NoError(t, engine.Check("https://worse.com/"))
+
+	writeList(t, path, "evil.com\nworse.com\n")
+	// Make sure the modification time moves even on coarse-grained file systems
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	go engine.Watch(10 * time.Millisecond)
+	defer engine.StopWatch()
+
+	assert.Eventually(t, func() bool {
+		return engine.Check("https://worse.com/") != nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,316 @@
+package destpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/urlvalidator"
+)
+
+// DefaultShorteners lists well known URL shorteners that are refused as destinations to avoid redirect chains
+var DefaultShorteners = []string{
+	"bit.ly", "bitly.com", "t.co", "tinyurl.com", "goo.gl", "ow.ly", "is.gd", "buff.ly",
+	"rebrand.ly", "cutt.ly", "shorturl.at", "rb.gy", "tiny.cc", "s.id", "v.gd", "t.ly",
+}
+
+// fileList is a line oriented file that is re-read when its modification time changes.
+// Empty lines and lines starting with '#' are ignored.
+type fileList struct {
+	path    string
+	modTime time.Time
+	apply   func(lines []string) error
+}
+
+func (f *fileList) reload(force bool) (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	if !force && info.ModTime().Equal(f.modTime) {
+		return false, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	if err := f.apply(lines); err != nil {
+		return false, fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.modTime = info.ModTime()
+	return true, nil
+}
+
+// DomainListRule blocks destinations by host. In block mode a matching host is rejected,
+// in allow mode every host that does not match is rejected (an empty allowlist allows everything).
+type DomainListRule struct {
+	name    string
+	allow   bool
+	mu      sync.RWMutex
+	domains []string
+	file    *fileList
+}
+
+// NewDomainBlocklist loads a domain blocklist file, one domain per line
+func NewDomainBlocklist(path string) (*DomainListRule, error) {
+	return newDomainListRule("domain_blocklist", path, false)
+}
+
+// NewDomainAllowlist loads a domain allowlist file, one domain per line
+func NewDomainAllowlist(path string) (*DomainListRule, error) {
+	return newDomainListRule("domain_allowlist", path, true)
+}
+
+func newDomainListRule(name, path string, allow bool) (*DomainListRule, error) {
+	rule := &DomainListRule{name: name, allow: allow}
+	rule.file = &fileList{path: path, apply: rule.setDomains}
+	if _, err := rule.file.reload(true); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *DomainListRule) setDomains(lines []string) error {
+	domains := make([]string, 0, len(lines))
+	for _, line := range lines {
+		domains = append(domains, normalizeHost(line))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.domains = domains
+	return nil
+}
+
+func (r *DomainListRule) Name() string { return r.name }
+
+func (r *DomainListRule) Reload() (bool, error) { return r.file.reload(false) }
+
+func (r *DomainListRule) Check(u *url.URL) error {
+	host := normalizeHost(u.Hostname())
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.allow && len(r.domains) == 0 {
+		return nil
+	}
+
+	matched := false
+	for _, domain := range r.domains {
+		if matchDomain(host, domain) {
+			matched = true
+			break
+		}
+	}
+
+	switch {
+	case r.allow && !matched:
+		return &Violation{Rule: r.name, Reason: fmt.Sprintf("host %q is not on the allowlist", host)}
+	case !r.allow && matched:
+		return &Violation{Rule: r.name, Reason: fmt.Sprintf("host %q is blocklisted", host)}
+	}
+	return nil
+}
+
+// RegexRule blocks destinations whose full URL matches one of the patterns in a file
+type RegexRule struct {
+	mu       sync.RWMutex
+	patterns []*regexp.Regexp
+	file     *fileList
+}
+
+// NewRegexBlocklist loads a file with one regular expression per line
+func NewRegexBlocklist(path string) (*RegexRule, error) {
+	rule := &RegexRule{}
+	rule.file = &fileList{path: path, apply: rule.setPatterns}
+	if _, err := rule.file.reload(true); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *RegexRule) setPatterns(lines []string) error {
+	patterns := make([]*regexp.Regexp, 0, len(lines))
+	for _, line := range lines {
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, re)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns = patterns
+	return nil
+}
+
+func (r *RegexRule) Name() string { return "regex_blocklist" }
+
+func (r *RegexRule) Reload() (bool, error) { return r.file.reload(false) }
+
+func (r *RegexRule) Check(u *url.URL) error {
+	target := u.String()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, re := range r.patterns {
+		if re.MatchString(target) {
+			return &Violation{Rule: r.Name(), Reason: "url matches a blocked pattern"}
+		}
+	}
+	return nil
+}
+
+// ThreatFeedRule checks destinations against a local threat feed file. Each line is either a
+// full URL or a bare domain (matched with its subdomains). URLs are canonicalized like destinations
+// and match destinations on the same host, whatever the scheme, whose path starts with theirs.
+type ThreatFeedRule struct {
+	validator *urlvalidator.Validator
+	mu        sync.RWMutex
+	urls      []threatUrl
+	domains   []string
+	file      *fileList
+}
+
+// threatUrl is a canonical URL entry of a threat feed
+type threatUrl struct {
+	host  string
+	path  string
+	query string
+}
+
+// matches reports whether u is on the host of the entry and under its path. An entry with a
+// query only matches that exact query.
+func (e threatUrl) matches(u *url.URL) bool {
+	if strings.ToLower(u.Host) != e.host {
+		return false
+	}
+	if e.query != "" && u.RawQuery != e.query {
+		return false
+	}
+	path := u.EscapedPath()
+	return path == e.path ||
+		(strings.HasSuffix(e.path, "/") && strings.HasPrefix(path, e.path)) ||
+		strings.HasPrefix(path, e.path+"/")
+}
+
+// NewThreatFeed loads a threat feed file, e.g. an exported phishing or malware URL list. URL entries
+// are canonicalized with validator, the one destinations are checked with.
+func NewThreatFeed(path string, validator *urlvalidator.Validator) (*ThreatFeedRule, error) {
+	rule := &ThreatFeedRule{validator: validator}
+	rule.file = &fileList{path: path, apply: rule.setEntries}
+	if _, err := rule.file.reload(true); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *ThreatFeedRule) setEntries(lines []string) error {
+	var urls []threatUrl
+	var domains []string
+	for _, line := range lines {
+		if !strings.Contains(line, "://") {
+			domains = append(domains, normalizeHost(line))
+			continue
+		}
+		// An entry that is not a valid destination, such as an ftp URL, can never match one
+		canonical, err := r.validator.Normalize(line)
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(canonical)
+		if err != nil {
+			continue
+		}
+		urls = append(urls, threatUrl{host: u.Host, path: u.EscapedPath(), query: u.RawQuery})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = urls
+	r.domains = domains
+	return nil
+}
+
+func (r *ThreatFeedRule) Name() string { return "threat_feed" }
+
+func (r *ThreatFeedRule) Reload() (bool, error) { return r.file.reload(false) }
+
+func (r *ThreatFeedRule) Check(u *url.URL) error {
+	host := normalizeHost(u.Hostname())
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.urls {
+		if entry.matches(u) {
+			return &Violation{Rule: r.Name(), Reason: "url is listed in the threat feed"}
+		}
+	}
+	for _, domain := range r.domains {
+		if matchDomain(host, domain) {
+			return &Violation{Rule: r.Name(), Reason: fmt.Sprintf("host %q is listed in the threat feed", host)}
+		}
+	}
+	return nil
+}
+
+// LoopRule rejects destinations that point back at this shortener or at another known shortener
+type LoopRule struct {
+	selfHosts  []string
+	shorteners []string
+}
+
+// NewLoopRule initializes a new LoopRule. selfHosts are the hosts this service is reachable on.
+func NewLoopRule(selfHosts []string, shorteners []string) *LoopRule {
+	rule := &LoopRule{}
+	for _, h := range selfHosts {
+		rule.selfHosts = append(rule.selfHosts, normalizeHost(h))
+	}
+	for _, h := range shorteners {
+		rule.shorteners = append(rule.shorteners, normalizeHost(h))
+	}
+	return rule
+}
+
+func (r *LoopRule) Name() string { return "redirect_loop" }
+
+func (r *LoopRule) Check(u *url.URL) error {
+	host := normalizeHost(u.Hostname())
+
+	for _, self := range r.selfHosts {
+		if host == self {
+			return &Violation{Rule: r.Name(), Reason: "url points back at this shortener"}
+		}
+	}
+	for _, shortener := range r.shorteners {
+		if matchDomain(host, shortener) {
+			return &Violation{Rule: r.Name(), Reason: fmt.Sprintf("url points at another url shortener (%s)", shortener)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/netguard"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	maxRedirects  = 5
+	maxErrorLen   = 255
+	pruneHostsAt  = 1000 // hosts tracked by the rate limit before idle ones are dropped
+	drainBodySize = 4 << 10
+)
+
+// Store lists the links to check and records the results
+type Store interface {
+	ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]repository.Url, error)
+	// RecordCheck reports whether the link was marked broken or working again
+	RecordCheck(ctx context.Context, link repository.Url, check types.HealthCheck, threshold int) (bool, error)
+}
+
+// Options configures a Checker
+type Options struct {
+	// Workers is the number of destinations checked at the same time
+	Workers int
+	// Timeout bounds a single check, including redirects
+	Timeout time.Duration
+	// HostInterval is the minimum time between two requests to the same host
+	HostInterval time.Duration
+	// FailureThreshold is the number of failed checks in a row after which a link is marked broken
+	FailureThreshold int
+	// BatchSize is the number of links read from the store at a time
+	BatchSize int
+	// UserAgent is sent with every check
+	UserAgent string
+	// AllowPrivate allows checking destinations on private and loopback addresses. Only meant for tests.
+	AllowPrivate bool
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Workers:          8,
+		Timeout:          10 * time.Second,
+		HostInterval:     2 * time.Second,
+		FailureThreshold: 3,
+		BatchSize:        500,
+		UserAgent:        "UrlShortnerBot/1.0 (link checker)",
+	}
+}
+
+// Checker periodically requests the destination of every active link and records whether it still works,
+// so links that rot silently, e.g. in printed materials, are noticed and can be sent to a fallback.
+type Checker struct {
+	opts   Options
+	store  Store
+	client *http.Client
+	log    zerolog.Logger
+
+	mutex    sync.Mutex
+	nextHost map[string]time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func NewChecker(store Store, opts Options, log zerolog.Logger) *Checker {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = netguard.Control
+	}
+
+	return &Checker{
+		opts:  opts,
+		store: store,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 nil,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   opts.Timeout,
+				ResponseHeaderTimeout: opts.Timeout,
+				MaxIdleConnsPerHost:   2,
+				IdleConnTimeout:       30 * time.Second,
+				ForceAttemptHTTP2:     true,
+			},
+			Timeout: opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return nil
+			},
+		},
+		log:      log,
+		nextHost: make(map[string]time.Time),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Check requests rawURL once. HEAD is tried first; servers that refuse it get a GET, whose body is not read.
+// Only answers meaning the page is gone (404, 410) or the server is failing (5xx), and requests without an
+// answer, count as failures. Other client errors such as 401, 403 or 429 show the page is still there.
+func (c *Checker) Check(ctx context.Context, rawURL string) types.HealthCheck {
+	start := time.Now()
+	check := types.HealthCheck{CheckedAt: start.UTC()}
+
+	status, err := c.request(ctx, http.MethodHead, rawURL)
+	if err == nil && status >= 400 {
+		status, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		// The URL is known, keep the reason
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		check.Error = truncate(err.Error(), maxErrorLen)
+		return check
+	}
+
+	check.StatusCode = status
+	check.OK = status != http.StatusNotFound && status != http.StatusGone && status < 500
+	return check
+}
+
+func (c *Checker) request(ctx context.Context, method string, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// Draining a little lets small responses reuse the connection
+	io.CopyN(io.Discard, resp.Body, drainBodySize)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// CheckAll checks every active link once and records the results. Links are checked by Workers goroutines,
+// requests to the same host are spaced out by HostInterval. Templated destinations are skipped, they only
+// become a URL per click.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	targets := make(chan repository.Url)
+
+	var wg sync.WaitGroup
+	for range max(c.opts.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range targets {
+				c.checkLink(ctx, url)
+			}
+		}()
+	}
+
+	err := c.feed(ctx, targets)
+	close(targets)
+	wg.Wait()
+	return err
+}
+
+func (c *Checker) feed(ctx context.Context, targets chan<- repository.Url) error {
+	var afterID int64
+	for {
+		urls, err := c.store.ListCheckTargets(ctx, afterID, c.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, url := range urls {
+			if linktemplate.HasPlaceholders(url.LongUrl.String) {
+				continue
+			}
+			select {
+			case targets <- url:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if len(urls) < c.opts.BatchSize {
+			return nil
+		}
+		afterID = urls[len(urls)-1].ID.Int64
+	}
+}
+
+func (c *Checker) checkLink(ctx context.Context, link repository.Url) {
+	u, err := url.Parse(link.LongUrl.String)
+	if err != nil {
+		return
+	}
+	if err := c.wait(ctx, u.Host); err != nil {
+		return
+	}
+
+	check := c.Check(ctx, link.LongUrl.String)
+	if ctx.Err() != nil {
+		// Stopped while checking, the result says nothing about the destination
+		return
+	}
+	if !check.OK {
+		c.log.Debug().Str("short_code", link.ShortCode.String).Int("status", check.StatusCode).Str("error", check.Error).Msg("Destination check failed")
+	}
+
+	changed, err := c.store.RecordCheck(ctx, link, check, c.opts.FailureThreshold)
+	if err != nil {
+		c.log.Error().Err(err).Str("short_code", link.ShortCode.String).Msg("Failed to record destination check")
+		return
+	}
+	if changed {
+		c.log.Info().Str("short_code", link.ShortCode.String).Bool("broken", !check.OK).Msg("Link health changed")
+	}
+}
+
+// wait blocks until the next request to host is due. Slots are reserved under the lock, so workers
+// checking links on the same host queue up instead of firing together.
+func (c *Checker) wait(ctx context.Context, host string) error {
+	c.mutex.Lock()
+	now := time.Now()
+	at := now
+	if next, ok := c.nextHost[host]; ok && next.After(now) {
+		at = next
+	}
+	if len(c.nextHost) >= pruneHostsAt {
+		for h, next := range c.nextHost {
+			if next.Before(now) {
+				delete(c.nextHost, h)
+			}
+		}
+	}
+	c.nextHost[host] = at.Add(c.opts.HostInterval)
+	c.mutex.Unlock()
+
+	if !at.After(now) {
+		return nil
+	}
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run checks every link each interval until Stop is called. Stop also cancels a pass in progress.
+func (c *Checker) Run(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.stopChan
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			err := c.CheckAll(ctx)
+			switch {
+			case ctx.Err() != nil:
+				// Stopped, the next iteration returns
+			case err != nil:
+				c.log.Error().Err(err).Msg("Destination health check failed")
+			default:
+				c.log.Info().Dur("duration", time.Since(start)).Msg("Destination health check finished")
+			}
+		case <-c.stopChan:
+			c.log.Info().Msg("Stopping destination health checks...")
+			return
+		}
+	}
+}
+
+// Stop stops the checks started by Run
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+	})
+}
+
+// truncate cuts s to at most n bytes without leaving a partial character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,219 @@
+package healthcheck_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/healthcheck"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptions() healthcheck.Options {
+	opts := healthcheck.DefaultOptions()
+	opts.AllowPrivate = true
+	opts.HostInterval = 0
+	opts.Timeout = 2 * time.Second
+	return opts
+}
+
+func newDestination() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing", http.StatusMovedPermanently)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestChecker_Check(t *testing.T) {
+	server := newDestination()
+	defer server.Close()
+
+	checker := healthcheck.NewChecker(nil, testOptions(), zerolog.Nop())
+
+	tests := []struct {
+		path   string
+		status int
+		ok     bool
+	}{
+		{"/ok", http.StatusOK, true},
+		{"/no-head", http.StatusOK, true},
+		{"/login", http.StatusForbidden, true},
+		{"/gone", http.StatusGone, false},
+		{"/error", http.StatusBadGateway, false},
+		{"/moved", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			check := checker.Check(context.Background(), server.URL+tt.path)
+			assert.Equal(t, tt.status, check.StatusCode)
+			assert.Equal(t, tt.ok, check.OK)
+			assert.Empty(t, check.Error)
+			assert.False(t, check.CheckedAt.IsZero())
+		})
+	}
+}
+
+func TestChecker_CheckWithoutResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	checker := healthcheck.NewChecker(nil, testOptions(), zerolog.Nop())
+	check := checker.Check(context.Background(), server.URL+"/ok")
+
+	assert.False(t, check.OK)
+	assert.Zero(t, check.StatusCode)
+	assert.Contains(t, check.Error, "connection refused")
+}
+
+func TestChecker_RefusesPrivateAddresses(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	checker := healthcheck.NewChecker(nil, healthcheck.DefaultOptions(), zerolog.Nop())
+	check := checker.Check(context.Background(), server.URL+"/ok")
+
+	assert.False(t, check.OK)
+	assert.Contains(t, check.Error, "not publicly routable")
+	assert.Zero(t, requests)
+}
+
+type memoryStore struct {
+	mutex    sync.Mutex
+	urls     []repository.Url
+	checks   map[int64]types.HealthCheck
+	listings int
+}
+
+func newMemoryStore(longUrls ...string) *memoryStore {
+	store := &memoryStore{checks: map[int64]types.HealthCheck{}}
+	for i, longUrl := range longUrls {
+		store.urls = append(store.urls, repository.Url{
+			ID:        sql.NullInt64{Int64: int64(i + 1), Valid: true},
+			ShortCode: sql.NullString{String: longUrl, Valid: true},
+			LongUrl:   sql.NullString{String: longUrl, Valid: true},
+		})
+	}
+	return store
+}
+
+func (s *memoryStore) ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]repository.Url, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listings++
+
+	page := []repository.Url{}
+	for _, url := range s.urls {
+		if url.ID.Int64 > afterID && len(page) < limit {
+			page = append(page, url)
+		}
+	}
+	return page, nil
+}
+
+func (s *memoryStore) RecordCheck(ctx context.Context, link repository.Url, check types.HealthCheck, threshold int) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checks[link.ID.Int64] = check
+	return false, nil
+}
+
+func TestChecker_CheckAll(t *testing.T) {
+	server := newDestination()
+	defer server.Close()
+
+	store := newMemoryStore(
+		server.URL+"/ok",
+		server.URL+"/gone",
+		server.URL+"/{{country}}/ok", // templated, skipped
+		server.URL+"/no-head",
+		server.URL+"/error",
+	)
+
+	opts := testOptions()
+	opts.BatchSize = 2
+	opts.Workers = 2
+	checker := healthcheck.NewChecker(store, opts, zerolog.Nop())
+
+	require.NoError(t, checker.CheckAll(context.Background()))
+
+	assert.Equal(t, 3, store.listings)
+	assert.Len(t, store.checks, 4)
+	assert.True(t, store.checks[1].OK)
+	assert.False(t, store.checks[2].OK)
+	assert.NotContains(t, store.checks, int64(3))
+	assert.True(t, store.checks[4].OK)
+	assert.Equal(t, http.StatusBadGateway, store.checks[5].StatusCode)
+}
+
+func TestChecker_SpacesOutRequestsToAHost(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		times = append(times, time.Now())
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	store := newMemoryStore(server.URL+"/a", server.URL+"/b", server.URL+"/c")
+
+	opts := testOptions()
+	opts.Workers = 3
+	opts.HostInterval = 100 * time.Millisecond
+	checker := healthcheck.NewChecker(store, opts, zerolog.Nop())
+
+	require.NoError(t, checker.CheckAll(context.Background()))
+
+	require.Len(t, times, 3)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	assert.GreaterOrEqual(t, times[2].Sub(times[0]), 190*time.Millisecond)
+}
+
+func TestChecker_CheckAllStopsWithContext(t *testing.T) {
+	server := newDestination()
+	defer server.Close()
+
+	store := newMemoryStore(server.URL+"/ok", server.URL+"/ok", server.URL+"/ok")
+
+	opts := testOptions()
+	opts.Workers = 1
+	opts.HostInterval = time.Hour
+	checker := healthcheck.NewChecker(store, opts, zerolog.Nop())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, checker.CheckAll(ctx), context.DeadlineExceeded)
+	assert.Len(t, store.checks, 1)
+}
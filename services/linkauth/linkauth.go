@@ -0,0 +1,84 @@
+package linkauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword hashes a link password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash created by HashPassword
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Signer issues and verifies the short-lived tokens stored in the unlock cookie of a
+// password protected link. A token is bound to the short code and to the current password
+// hash, so changing the password invalidates every token issued before.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSigner initializes a new Signer. An empty secret is replaced by a random one, which
+// means tokens do not survive a restart and are not shared between replicas.
+func NewSigner(secret string, ttl time.Duration) (*Signer, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Signer{
+		secret: key,
+		ttl:    ttl,
+	}, nil
+}
+
+// TTL returns how long issued tokens stay valid
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign returns a token for shortCode that expires after the signer's TTL
+func (s *Signer) Sign(shortCode string, passwordHash string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	return expires + "." + s.mac(shortCode, passwordHash, expires)
+}
+
+// Verify reports whether token was issued for shortCode and passwordHash and has not expired
+func (s *Signer) Verify(token string, shortCode string, passwordHash string, now time.Time) bool {
+	expires, mac, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return false
+	}
+
+	return hmac.Equal([]byte(mac), []byte(s.mac(shortCode, passwordHash, expires)))
+}
+
+func (s *Signer) mac(shortCode string, passwordHash string, expires string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(shortCode + "|" + passwordHash + "|" + expires))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,43 @@
+package linkauth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linkauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := linkauth.HashPassword("open sesame")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, "open sesame", hash)
+	assert.True(t, linkauth.CheckPassword(hash, "open sesame"))
+	assert.False(t, linkauth.CheckPassword(hash, "open sesame!"))
+}
+
+func TestSigner(t *testing.T) {
+	signer, err := linkauth.NewSigner("secret", 10*time.Minute)
+	require.NoError(t, err)
+
+	now := time.Now()
+	token := signer.Sign("abc123", "hash", now)
+
+	assert.True(t, signer.Verify(token, "abc123", "hash", now.Add(5*time.Minute)))
+
+	// expired
+	assert.False(t, signer.Verify(token, "abc123", "hash", now.Add(11*time.Minute)))
+	// issued for another link
+	assert.False(t, signer.Verify(token, "xyz789", "hash", now))
+	// password changed since
+	assert.False(t, signer.Verify(token, "abc123", "new-hash", now))
+	// tampered
+	assert.False(t, signer.Verify("9999999999"+token[10:], "abc123", "hash", now))
+	assert.False(t, signer.Verify("garbage", "abc123", "hash", now))
+
+	other, err := linkauth.NewSigner("other-secret", 10*time.Minute)
+	require.NoError(t, err)
+	assert.False(t, other.Verify(token, "abc123", "hash", now))
+}
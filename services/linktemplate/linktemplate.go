@@ -0,0 +1,65 @@
+package linktemplate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Placeholders that destinations may contain, filled in per click
+const (
+	// Country is the two-letter country code of the visitor, empty if unknown
+	Country = "country"
+	// ClickID is a random id unique to the click
+	ClickID = "click_id"
+	// ShortCode is the short code of the link
+	ShortCode = "short_code"
+	// Campaign is the name of the link's campaign, empty for links without one
+	Campaign = "campaign"
+)
+
+// ErrUnknownPlaceholder is returned for placeholders other than the ones listed above
+var ErrUnknownPlaceholder = errors.New("unknown placeholder")
+
+// placeholder matches {{name}} as written and as escaped by URL canonicalization, e.g. in the path
+var placeholder = regexp.MustCompile(`(?i)(?:\{\{|%7B%7B)\s*([a-z_]+)\s*(?:\}\}|%7D%7D)`)
+
+var known = map[string]bool{Country: true, ClickID: true, ShortCode: true, Campaign: true}
+
+// HasPlaceholders reports whether destination contains any placeholder
+func HasPlaceholders(destination string) bool {
+	return placeholder.MatchString(destination)
+}
+
+// Validate checks that destination only uses known placeholders and none in its host,
+// where a filled in value could send the visitor to another site
+func Validate(destination string) error {
+	for _, match := range placeholder.FindAllStringSubmatch(destination, -1) {
+		if !known[strings.ToLower(match[1])] {
+			return fmt.Errorf("%w %q, use %s", ErrUnknownPlaceholder, match[1], strings.Join([]string{Country, ClickID, ShortCode, Campaign}, ", "))
+		}
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return err
+	}
+	if HasPlaceholders(u.Host) {
+		return fmt.Errorf("%s", "placeholders are not allowed in the host")
+	}
+	return nil
+}
+
+// Expand fills in the placeholders of destination with values. Values are query escaped so they cannot
+// add parameters or path segments; missing values leave the placeholder empty.
+func Expand(destination string, values map[string]string) string {
+	if !HasPlaceholders(destination) {
+		return destination
+	}
+	return placeholder.ReplaceAllStringFunc(destination, func(match string) string {
+		name := strings.ToLower(placeholder.FindStringSubmatch(match)[1])
+		return url.QueryEscape(values[name])
+	})
+}
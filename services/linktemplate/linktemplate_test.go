@@ -0,0 +1,65 @@
+package linktemplate_test
+
+import (
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/linktemplate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		wantErr     bool
+	}{
+		{"No placeholders", "https://example.com/page?a=1", false},
+		{"Query placeholders", "https://example.com/page?cc={{country}}&cid={{click_id}}", false},
+		{"Escaped path placeholder", "https://example.com/%7B%7Bcountry%7D%7D/shop", false},
+		{"Spaces inside braces", "https://example.com/?code={{ short_code }}", false},
+		{"Unknown placeholder", "https://example.com/?x={{email}}", true},
+		{"Placeholder in host", "https://{{country}}.example.com/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := linktemplate.Validate(tt.destination)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	values := map[string]string{
+		linktemplate.Country:   "DE",
+		linktemplate.ClickID:   "0f3a9c",
+		linktemplate.ShortCode: "abc123",
+		linktemplate.Campaign:  "Spring Sale&x=1",
+	}
+
+	tests := []struct {
+		name        string
+		destination string
+		want        string
+	}{
+		{"No placeholders", "https://example.com/page", "https://example.com/page"},
+		{"Query", "https://example.com/?cc={{country}}&cid={{click_id}}", "https://example.com/?cc=DE&cid=0f3a9c"},
+		{"Escaped path", "https://example.com/%7B%7Bcountry%7D%7D/shop", "https://example.com/DE/shop"},
+		{"Lowercase escapes", "https://example.com/%7b%7bshort_code%7d%7d", "https://example.com/abc123"},
+		{"Values are escaped", "https://example.com/?c={{campaign}}", "https://example.com/?c=Spring+Sale%26x%3D1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, linktemplate.Expand(tt.destination, values))
+		})
+	}
+}
+
+func TestExpand_MissingValue(t *testing.T) {
+	assert.Equal(t, "https://example.com/?cc=", linktemplate.Expand("https://example.com/?cc={{country}}", nil))
+}
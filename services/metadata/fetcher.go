@@ -0,0 +1,264 @@
+package metadata
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/netguard"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	// ErrDisallowed is returned when the site's robots.txt does not allow fetching the page
+	ErrDisallowed = errors.New("fetching is disallowed by robots.txt")
+	// ErrNotHTML is returned when the destination is not an HTML document
+	ErrNotHTML = errors.New("destination is not an HTML document")
+	// ErrUnsupportedScheme is returned for destinations other than http and https
+	ErrUnsupportedScheme = errors.New("only http and https destinations can be fetched")
+)
+
+const (
+	maxRedirects   = 5
+	maxRobotsBytes = 64 << 10 // 64KB, larger robots.txt files are cut
+	// robotsTTL is how long a host's robots.txt is cached, robotsErrorTTL when the server failed to serve it
+	robotsTTL      = time.Hour
+	robotsErrorTTL = 5 * time.Minute
+	// pruneAfter is the number of hosts tracked before expired entries are dropped
+	pruneAfter = 1000
+)
+
+// Options configures a Fetcher
+type Options struct {
+	// Timeout bounds connecting, the TLS handshake and reading the whole response of a fetch
+	Timeout time.Duration
+	// MaxBytes is the number of bytes of a page read at most; metadata lives in the head, so a prefix is enough
+	MaxBytes int64
+	// UserAgent is sent with every request; its product token is matched against robots.txt groups
+	UserAgent string
+	// HostDelay is the minimum time between two requests to the same host. A longer Crawl-delay from
+	// robots.txt is honoured up to 10 seconds.
+	HostDelay time.Duration
+	// AllowPrivate allows connections to private and loopback addresses. Only meant for tests.
+	AllowPrivate bool
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Timeout:   5 * time.Second,
+		MaxBytes:  1 << 20, // 1MB
+		UserAgent: "UrlShortnerBot/1.0",
+		HostDelay: time.Second,
+	}
+}
+
+type robotsEntry struct {
+	robots  robots
+	expires time.Time
+}
+
+// Fetcher downloads pages and extracts their metadata. It refuses to connect to non-public addresses,
+// follows robots.txt and spaces out requests to the same host. It is safe for concurrent use.
+type Fetcher struct {
+	opts   Options
+	agent  string // robots.txt product token
+	client *http.Client
+
+	mutex     sync.Mutex
+	robots    map[string]robotsEntry // by scheme://host
+	nextFetch map[string]time.Time   // by host
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = netguard.Control
+	}
+
+	transport := &http.Transport{
+		// No proxy: the fetcher must connect to the destination itself for the address guard to apply
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	agent, _, _ := strings.Cut(opts.UserAgent, "/")
+	return &Fetcher{
+		opts:  opts,
+		agent: strings.TrimSpace(agent),
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       opts.Timeout,
+			CheckRedirect: checkRedirect,
+		},
+		robots:    make(map[string]robotsEntry),
+		nextFetch: make(map[string]time.Time),
+	}
+}
+
+// checkRedirect follows at most maxRedirects redirects and only to http and https URLs
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrUnsupportedScheme
+	}
+	return nil
+}
+
+// Fetch downloads rawURL and returns the metadata found in its head
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (types.LinkMetadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return types.LinkMetadata{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return types.LinkMetadata{}, ErrUnsupportedScheme
+	}
+
+	rules, err := f.robotsFor(ctx, u)
+	if err != nil {
+		return types.LinkMetadata{}, err
+	}
+	if !rules.Allowed(u.RequestURI()) {
+		return types.LinkMetadata{}, ErrDisallowed
+	}
+
+	if err := f.wait(ctx, u.Host, rules.delay); err != nil {
+		return types.LinkMetadata{}, err
+	}
+
+	resp, err := f.get(ctx, u.String(), "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	if err != nil {
+		return types.LinkMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return types.LinkMetadata{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return types.LinkMetadata{}, fmt.Errorf("%w: %s", ErrNotHTML, cmp.Or(mediaType, "no content type"))
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.opts.MaxBytes), contentType)
+	if errors.Is(err, io.EOF) {
+		// An empty page is not an error, its favicon may still exist
+		body, err = strings.NewReader(""), nil
+	}
+	if err != nil {
+		return types.LinkMetadata{}, err
+	}
+
+	// The final URL after redirects is the base for relative links
+	metadata := Parse(body, resp.Request.URL)
+	metadata.FetchedAt = time.Now().UTC()
+	return metadata, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, rawURL string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+	req.Header.Set("Accept", accept)
+	return f.client.Do(req)
+}
+
+// robotsFor returns the robots.txt rules of u's host, fetching them when they are not cached.
+// A missing robots.txt (4xx) allows everything, a server error disallows everything for a while.
+// Network errors are returned, the page could not be fetched either.
+func (f *Fetcher) robotsFor(ctx context.Context, u *url.URL) (robots, error) {
+	key := u.Scheme + "://" + u.Host
+
+	f.mutex.Lock()
+	entry, ok := f.robots[key]
+	f.mutex.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.robots, nil
+	}
+
+	resp, err := f.get(ctx, key+"/robots.txt", "text/plain")
+	if err != nil {
+		return robots{}, err
+	}
+	defer resp.Body.Close()
+
+	ttl := robotsTTL
+	var rules robots
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		rules = parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), f.agent)
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		rules = allowAll
+	default:
+		rules, ttl = disallowAll, robotsErrorTTL
+	}
+
+	f.mutex.Lock()
+	if len(f.robots) >= pruneAfter {
+		for k, e := range f.robots {
+			if time.Now().After(e.expires) {
+				delete(f.robots, k)
+			}
+		}
+	}
+	f.robots[key] = robotsEntry{robots: rules, expires: time.Now().Add(ttl)}
+	f.mutex.Unlock()
+	return rules, nil
+}
+
+// wait blocks until the next request to host is due. Slots are reserved under the lock, so concurrent
+// fetches of the same host queue up instead of firing together.
+func (f *Fetcher) wait(ctx context.Context, host string, crawlDelay time.Duration) error {
+	delay := max(f.opts.HostDelay, crawlDelay)
+
+	f.mutex.Lock()
+	now := time.Now()
+	at := now
+	if next, ok := f.nextFetch[host]; ok && next.After(now) {
+		at = next
+	}
+	if len(f.nextFetch) >= pruneAfter {
+		for h, next := range f.nextFetch {
+			if next.Before(now) {
+				delete(f.nextFetch, h)
+			}
+		}
+	}
+	f.nextFetch[host] = at.Add(delay)
+	f.mutex.Unlock()
+
+	if at.Equal(now) {
+		return nil
+	}
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
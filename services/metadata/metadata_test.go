@@ -0,0 +1,373 @@
+package metadata_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metadata"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/netguard"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/taskqueue"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>  Plain
+		title </title>
+	<meta name="description" content="Plain description">
+	<meta property="og:title" content="OpenGraph title">
+	<meta property="og:image" content="/images/cover.png">
+	<link rel="apple-touch-icon" href="/touch.png">
+	<link rel="icon" href="icons/favicon.png">
+</head>
+<body><title>Ignored</title></body>
+</html>`
+
+func testOptions() metadata.Options {
+	opts := metadata.DefaultOptions()
+	opts.AllowPrivate = true
+	opts.HostDelay = 0
+	return opts
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/one")
+
+	tests := []struct {
+		name     string
+		html     string
+		expected types.LinkMetadata
+	}{
+		{
+			name: "OpenGraph wins and URLs are resolved",
+			html: page,
+			expected: types.LinkMetadata{
+				Title:       "OpenGraph title",
+				Description: "Plain description",
+				Image:       "https://example.com/images/cover.png",
+				Favicon:     "https://example.com/articles/icons/favicon.png",
+			},
+		},
+		{
+			name: "Falls back to the title tag and the default favicon",
+			html: `<html><head><title>Only &amp; title</title><meta property="og:description" content="OG description"></head></html>`,
+			expected: types.LinkMetadata{
+				Title:       "Only & title",
+				Description: "OG description",
+				Favicon:     "https://example.com/favicon.ico",
+			},
+		},
+		{
+			name: "Ignores images with other schemes",
+			html: `<head><meta property="og:image" content="javascript:alert(1)"><link rel="apple-touch-icon" href="//cdn.example.com/touch.png"></head>`,
+			expected: types.LinkMetadata{
+				Favicon: "https://cdn.example.com/touch.png",
+			},
+		},
+		{
+			name: "Stops at the body",
+			html: `<html><body><title>Not a title</title></body></html>`,
+			expected: types.LinkMetadata{
+				Favicon: "https://example.com/favicon.ico",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, metadata.Parse(strings.NewReader(tt.html), base))
+		})
+	}
+}
+
+func TestParse_TruncatesLongTitles(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	title := strings.Repeat("é", 200) // 400 bytes
+
+	result := metadata.Parse(strings.NewReader("<title>"+title+"</title>"), base)
+
+	assert.LessOrEqual(t, len(result.Title), 255)
+	assert.True(t, strings.HasPrefix(title, result.Title))
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, metadata.DefaultOptions().UserAgent, r.UserAgent())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<title>Caf\xe9</title>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := metadata.NewFetcher(testOptions())
+
+	t.Run("Page", func(t *testing.T) {
+		result, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+		require.NoError(t, err)
+		assert.Equal(t, "OpenGraph title", result.Title)
+		assert.Equal(t, server.URL+"/images/cover.png", result.Image)
+		assert.Equal(t, server.URL+"/icons/favicon.png", result.Favicon)
+		assert.False(t, result.FetchedAt.IsZero())
+	})
+
+	t.Run("Follows redirects", func(t *testing.T) {
+		result, err := fetcher.Fetch(context.Background(), server.URL+"/moved")
+		require.NoError(t, err)
+		assert.Equal(t, "OpenGraph title", result.Title)
+	})
+
+	t.Run("Decodes the charset", func(t *testing.T) {
+		result, err := fetcher.Fetch(context.Background(), server.URL+"/latin1")
+		require.NoError(t, err)
+		assert.Equal(t, "Café", result.Title)
+	})
+
+	t.Run("Refuses other content types", func(t *testing.T) {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/image")
+		assert.ErrorIs(t, err, metadata.ErrNotHTML)
+	})
+
+	t.Run("Fails on error status", func(t *testing.T) {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/missing")
+		assert.ErrorContains(t, err, "404")
+	})
+
+	t.Run("Refuses other schemes", func(t *testing.T) {
+		_, err := fetcher.Fetch(context.Background(), "ftp://example.com/file")
+		assert.ErrorIs(t, err, metadata.ErrUnsupportedScheme)
+	})
+}
+
+func TestFetcher_RefusesPrivateAddresses(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	fetcher := metadata.NewFetcher(metadata.DefaultOptions())
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	assert.ErrorIs(t, err, netguard.ErrForbiddenAddress)
+
+	// localhost resolves to a loopback address, the check runs after name resolution
+	_, err = fetcher.Fetch(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/page")
+	assert.ErrorIs(t, err, netguard.ErrForbiddenAddress)
+
+	assert.Zero(t, requests)
+}
+
+func TestFetcher_RefusesRedirectsToPrivateAddresses(t *testing.T) {
+	// The fetcher under test may reach the first server, which redirects to the cloud metadata address
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	opts := testOptions()
+	opts.Timeout = time.Second
+	fetcher := metadata.NewFetcher(opts)
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	assert.Error(t, err)
+}
+
+func TestFetcher_LimitsBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><!--"+strings.Repeat("x", 2048)+"--><title>Too late</title></head></html>")
+	}))
+	defer server.Close()
+
+	opts := testOptions()
+	opts.MaxBytes = 1024
+	fetcher := metadata.NewFetcher(opts)
+
+	result, err := fetcher.Fetch(context.Background(), server.URL+"/")
+	require.NoError(t, err)
+	assert.Empty(t, result.Title)
+}
+
+func TestFetcher_Robots(t *testing.T) {
+	var pageRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, `
+User-agent: *
+Disallow: /
+
+User-agent: UrlShortnerBot
+Disallow: /private
+Allow: /private/open$
+Disallow: /*.pdf$
+`)
+			return
+		}
+		pageRequests++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<title>Allowed</title>")
+	}))
+	defer server.Close()
+
+	fetcher := metadata.NewFetcher(testOptions())
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/public", true},
+		{"/private", false},
+		{"/private/secret", false},
+		{"/private/open", true},
+		{"/private/open/more", false},
+		{"/files/report.pdf", false},
+		{"/files/report.pdf.html", true},
+	}
+	for _, tt := range tests {
+		_, err := fetcher.Fetch(context.Background(), server.URL+tt.path)
+		if tt.allowed {
+			assert.NoError(t, err, tt.path)
+		} else {
+			assert.ErrorIs(t, err, metadata.ErrDisallowed, tt.path)
+		}
+	}
+	assert.Equal(t, 3, pageRequests)
+}
+
+func TestFetcher_RobotsServerErrorDisallows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	fetcher := metadata.NewFetcher(testOptions())
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	assert.ErrorIs(t, err, metadata.ErrDisallowed)
+}
+
+func TestFetcher_SpacesOutRequestsToAHost(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.2\n")
+			return
+		}
+		mutex.Lock()
+		times = append(times, time.Now())
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer server.Close()
+
+	fetcher := metadata.NewFetcher(testOptions())
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fetcher.Fetch(context.Background(), server.URL+"/")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, times, 3)
+	first, last := times[0], times[0]
+	for _, at := range times {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	assert.GreaterOrEqual(t, last.Sub(first), 350*time.Millisecond)
+}
+
+type memoryStore struct {
+	mutex sync.Mutex
+	saved map[string]types.LinkMetadata
+	done  chan struct{}
+}
+
+func (s *memoryStore) SaveMetadata(ctx context.Context, shortCode string, m types.LinkMetadata) error {
+	s.mutex.Lock()
+	s.saved[shortCode] = m
+	s.mutex.Unlock()
+	s.done <- struct{}{}
+	return nil
+}
+
+func TestWorker_Enqueue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	queue := taskqueue.New(1, 10, zerolog.Nop())
+	defer queue.Stop()
+
+	store := &memoryStore{saved: map[string]types.LinkMetadata{}, done: make(chan struct{}, 1)}
+	worker := metadata.NewWorker(metadata.NewFetcher(testOptions()), store, queue, zerolog.Nop())
+
+	require.NoError(t, worker.Enqueue(context.Background(), "abc123", server.URL+"/page"))
+
+	select {
+	case <-store.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("metadata was not stored")
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	assert.Equal(t, "OpenGraph title", store.saved["abc123"].Title)
+	assert.Equal(t, "Plain description", store.saved["abc123"].Description)
+}
@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Limits of the stored fields, longer values are cut
+const (
+	maxTitleLen       = 255
+	maxDescriptionLen = 1000
+	maxUrlLen         = 2048
+)
+
+// Parse reads the head of an HTML document and returns its title, description, OpenGraph image and favicon.
+// OpenGraph values take precedence over <title> and the description meta tag. Relative URLs are resolved
+// against base, the URL the document was served from; without an icon link the favicon defaults to /favicon.ico.
+func Parse(r io.Reader, base *url.URL) types.LinkMetadata {
+	var (
+		title, ogTitle             string
+		description, ogDescription string
+		image, icon, touchIcon     string
+		inTitle                    bool
+	)
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break loop
+
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				break loop
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := atom.Lookup(name)
+			if tag == atom.Body {
+				break loop
+			}
+			if tag == atom.Title {
+				inTitle = tt == html.StartTagToken
+				continue
+			}
+			if !hasAttr || (tag != atom.Meta && tag != atom.Link) {
+				continue
+			}
+
+			attrs := attributes(z)
+			if tag == atom.Meta {
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				switch key {
+				case "og:title":
+					ogTitle = attrs["content"]
+				case "og:description":
+					ogDescription = attrs["content"]
+				case "description":
+					description = attrs["content"]
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if image == "" {
+						image = attrs["content"]
+					}
+				}
+				continue
+			}
+
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				switch {
+				case rel == "icon" && icon == "":
+					icon = attrs["href"]
+				case strings.HasSuffix(rel, "-icon") && touchIcon == "":
+					touchIcon = attrs["href"]
+				}
+			}
+		}
+	}
+
+	return types.LinkMetadata{
+		Title:       clean(firstNonEmpty(ogTitle, title), maxTitleLen),
+		Description: clean(firstNonEmpty(ogDescription, description), maxDescriptionLen),
+		Image:       resolve(base, image),
+		Favicon:     resolve(base, firstNonEmpty(icon, touchIcon, "/favicon.ico")),
+	}
+}
+
+// attributes returns the attributes of the current tag by lowercase name, the first occurrence wins
+func attributes(z *html.Tokenizer) map[string]string {
+	attrs := map[string]string{}
+	for {
+		key, val, more := z.TagAttr()
+		name := strings.ToLower(string(key))
+		if _, seen := attrs[name]; !seen {
+			attrs[name] = string(val)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and cuts s to at most n bytes without splitting a character
+func clean(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// resolve makes ref absolute against base and keeps it only if it is an http or https URL
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	s := u.String()
+	if len(s) > maxUrlLen {
+		return ""
+	}
+	return s
+}
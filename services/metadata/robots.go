@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCrawlDelay caps the Crawl-delay a site can ask for
+const maxCrawlDelay = 10 * time.Second
+
+// robots holds the robots.txt rules that apply to the fetcher's user agent
+type robots struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+var (
+	allowAll    = robots{}
+	disallowAll = robots{rules: []robotsRule{{pattern: "/", allow: false}}}
+)
+
+// parseRobots reads a robots.txt file and keeps the group for agent, falling back to the "*" group.
+// Agent is matched case-insensitively against the product token of each User-agent line.
+func parseRobots(r io.Reader, agent string) robots {
+	agent = strings.ToLower(agent)
+
+	var (
+		specific, wildcard robots
+		foundSpecific      bool
+		inGroup            bool // the current group applies to the agent
+		inWildcard         bool // the current group applies to "*"
+		lastWasAgent       bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// Consecutive User-agent lines share one group
+			if !lastWasAgent {
+				inGroup, inWildcard = false, false
+			}
+			lastWasAgent = true
+
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				inWildcard = true
+			case name != "" && strings.HasPrefix(agent, name):
+				inGroup, foundSpecific = true, true
+			}
+			continue
+		}
+		lastWasAgent = false
+
+		var targets []*robots
+		if inGroup {
+			targets = append(targets, &specific)
+		}
+		if inWildcard {
+			targets = append(targets, &wildcard)
+		}
+
+		for _, target := range targets {
+			switch key {
+			case "allow", "disallow":
+				// An empty Disallow allows everything
+				if value != "" {
+					target.rules = append(target.rules, robotsRule{pattern: value, allow: key == "allow"})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					target.delay = min(time.Duration(seconds*float64(time.Second)), maxCrawlDelay)
+				}
+			}
+		}
+	}
+
+	if foundSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// Allowed reports whether path (with its query) may be fetched. The longest matching rule wins,
+// Allow wins a tie.
+func (r robots) Allowed(path string) bool {
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// matchRobots matches a robots.txt path pattern, where '*' matches any sequence and a trailing '$'
+// anchors the pattern at the end of the path
+func matchRobots(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+
+	if anchored {
+		last := parts[len(parts)-1]
+		return rest == "" || (len(parts) > 1 && strings.HasSuffix(path, last))
+	}
+	return true
+}
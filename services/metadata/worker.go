@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/taskqueue"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store persists the metadata fetched for a link
+type Store interface {
+	SaveMetadata(ctx context.Context, shortCode string, metadata types.LinkMetadata) error
+}
+
+// Worker fetches the metadata of new links on a task queue and stores it
+type Worker struct {
+	fetcher *Fetcher
+	store   Store
+	queue   *taskqueue.Queue
+	log     zerolog.Logger
+}
+
+func NewWorker(fetcher *Fetcher, store Store, queue *taskqueue.Queue, log zerolog.Logger) *Worker {
+	return &Worker{
+		fetcher: fetcher,
+		store:   store,
+		queue:   queue,
+		log:     log,
+	}
+}
+
+// Enqueue schedules fetching the metadata of longUrl for the link shortCode. It does not block and
+// returns taskqueue.ErrFull when too many fetches are waiting. The fetch is traced linked to the span in ctx.
+func (w *Worker) Enqueue(ctx context.Context, shortCode string, longUrl string) error {
+	return w.queue.Submit(taskqueue.Job{
+		Name: "metadata:" + shortCode,
+		Run: func(ctx context.Context) error {
+			return w.Refresh(ctx, shortCode, longUrl)
+		},
+		Link: trace.SpanContextFromContext(ctx),
+	})
+}
+
+// Refresh fetches and stores the metadata of a link right away
+func (w *Worker) Refresh(ctx context.Context, shortCode string, longUrl string) error {
+	metadata, err := w.fetcher.Fetch(ctx, longUrl)
+	if err != nil {
+		return err
+	}
+
+	if err := w.store.SaveMetadata(ctx, shortCode, metadata); err != nil {
+		return err
+	}
+	w.log.Debug().Str("short_code", shortCode).Str("title", metadata.Title).Msg("Stored link metadata")
+	return nil
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is the registry the application's metrics are registered on and served from, along with
+// those of the Go runtime and the process
+var Registry = prometheus.NewRegistry()
+
+// Factory registers metrics on Registry
+var Factory = promauto.With(Registry)
+
+// DefaultBuckets are histogram buckets in seconds, suited to request and query latencies
+var DefaultBuckets = prometheus.DefBuckets
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
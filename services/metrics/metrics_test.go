@@ -0,0 +1,39 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	requests := metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "metrics_test_requests_total",
+		Help: "Requests served.",
+	}, []string{"route", "status"})
+	requests.WithLabelValues("/a", "500").Add(3)
+	requests.WithLabelValues("/b", "200").Inc()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `# HELP metrics_test_requests_total Requests served.
+# TYPE metrics_test_requests_total counter
+metrics_test_requests_total{route="/a",status="500"} 3
+metrics_test_requests_total{route="/b",status="200"} 1
+`)
+	// The runtime and the process are served too
+	assert.Contains(t, rec.Body.String(), "go_goroutines ")
+	assert.Contains(t, rec.Body.String(), "process_start_time_seconds ")
+}
+
+func TestFactory_DuplicateName(t *testing.T) {
+	opts := prometheus.GaugeOpts{Name: "metrics_test_duplicate", Help: "Registered twice."}
+	metrics.Factory.NewGauge(opts)
+	assert.Panics(t, func() { metrics.Factory.NewGauge(opts) })
+}
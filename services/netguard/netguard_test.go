@@ -0,0 +1,43 @@
+package netguard_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/netguard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata service
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"2002:a00:1::", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.public, netguard.PublicAddress(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestControl(t *testing.T) {
+	assert.NoError(t, netguard.Control("tcp4", "93.184.216.34:443", nil))
+	assert.ErrorIs(t, netguard.Control("tcp4", "127.0.0.1:80", nil), netguard.ErrForbiddenAddress)
+	assert.ErrorIs(t, netguard.Control("tcp6", "[::1]:80", nil), netguard.ErrForbiddenAddress)
+}
@@ -0,0 +1,94 @@
+package passthrough
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Policies for query parameters present on both the incoming request and the destination
+const (
+	// ConflictTarget keeps the destination's value and drops the incoming one
+	ConflictTarget = "target"
+	// ConflictIncoming replaces the destination's value with the incoming one
+	ConflictIncoming = "incoming"
+	// ConflictAppend keeps both, the destination's values first
+	ConflictAppend = "append"
+)
+
+// ErrInvalidPath is returned for a path suffix that could escape the destination's path, e.g. with ".."
+var ErrInvalidPath = errors.New("invalid path")
+
+// ValidConflict reports whether policy is a known conflict policy
+func ValidConflict(policy string) bool {
+	switch policy {
+	case ConflictTarget, ConflictIncoming, ConflictAppend:
+		return true
+	}
+	return false
+}
+
+// MergeQuery adds the incoming query parameters to the destination URL. Parameters present on both
+// are resolved with the conflict policy; an unknown policy behaves like ConflictTarget.
+func MergeQuery(destination string, incoming url.Values, policy string) (string, error) {
+	if len(incoming) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for key, values := range incoming {
+		_, conflict := query[key]
+		switch {
+		case !conflict:
+			query[key] = values
+		case policy == ConflictIncoming:
+			query[key] = values
+		case policy == ConflictAppend:
+			query[key] = append(query[key], values...)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// AppendPath appends the path after the short code of a prefix link to the destination's path.
+// The suffix is escaped segment by segment, and dot segments are refused so the result always
+// stays below the destination's path.
+func AppendPath(destination string, suffix string) (string, error) {
+	suffix = strings.TrimPrefix(suffix, "/")
+	if suffix == "" {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(suffix, "/")
+	for i, segment := range segments {
+		if segment == "." || segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPath, suffix)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+
+	base := u.EscapedPath()
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	joined, err := url.Parse(base + strings.Join(segments, "/"))
+	if err != nil {
+		return "", err
+	}
+	u.Path, u.RawPath = joined.Path, joined.RawPath
+	return u.String(), nil
+}
@@ -0,0 +1,74 @@
+package passthrough_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/passthrough"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		incoming    string
+		policy      string
+		want        string
+	}{
+		{"No incoming params", "https://example.com/page?a=1", "", passthrough.ConflictTarget, "https://example.com/page?a=1"},
+		{"Adds new params", "https://example.com/page?a=1", "ref=newsletter", passthrough.ConflictTarget, "https://example.com/page?a=1&ref=newsletter"},
+		{"Destination without query", "https://example.com/page", "ref=newsletter", passthrough.ConflictTarget, "https://example.com/page?ref=newsletter"},
+		{"Target wins", "https://example.com/?utm_source=site", "utm_source=mail", passthrough.ConflictTarget, "https://example.com/?utm_source=site"},
+		{"Incoming wins", "https://example.com/?utm_source=site", "utm_source=mail", passthrough.ConflictIncoming, "https://example.com/?utm_source=mail"},
+		{"Append keeps both", "https://example.com/?tag=a", "tag=b", passthrough.ConflictAppend, "https://example.com/?tag=a&tag=b"},
+		{"Unknown policy keeps target", "https://example.com/?tag=a", "tag=b", "", "https://example.com/?tag=a"},
+		{"Keeps fragment", "https://example.com/page#section", "ref=x", passthrough.ConflictTarget, "https://example.com/page?ref=x#section"},
+		{"Escapes values", "https://example.com/", "q=a b&r=%26", passthrough.ConflictTarget, "https://example.com/?q=a+b&r=%26"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming, err := url.ParseQuery(tt.incoming)
+			require.NoError(t, err)
+
+			got, err := passthrough.MergeQuery(tt.destination, incoming, tt.policy)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAppendPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		suffix      string
+		want        string
+	}{
+		{"Empty suffix", "https://example.com/docs", "", "https://example.com/docs"},
+		{"Destination without trailing slash", "https://example.com/docs", "getting-started", "https://example.com/docs/getting-started"},
+		{"Destination with trailing slash", "https://example.com/docs/", "getting-started", "https://example.com/docs/getting-started"},
+		{"Root destination", "https://example.com/", "a/b/c", "https://example.com/a/b/c"},
+		{"Keeps query and fragment", "https://example.com/docs?v=2#top", "intro", "https://example.com/docs/intro?v=2#top"},
+		{"Keeps trailing slash of suffix", "https://example.com/docs", "guides/", "https://example.com/docs/guides/"},
+		{"Escapes segments", "https://example.com/docs", "hello world/a?b", "https://example.com/docs/hello%20world/a%3Fb"},
+		{"Keeps escaped destination", "https://example.com/a%2Fb", "c", "https://example.com/a%2Fb/c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := passthrough.AppendPath(tt.destination, tt.suffix)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAppendPath_RefusesDotSegments(t *testing.T) {
+	for _, suffix := range []string{"..", "../admin", "docs/../../admin", "./x", "a\\b"} {
+		_, err := passthrough.AppendPath("https://example.com/docs/", suffix)
+		assert.ErrorIs(t, err, passthrough.ErrInvalidPath, suffix)
+	}
+}
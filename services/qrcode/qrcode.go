@@ -0,0 +1,505 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the error correction level of a QR code. Higher levels survive more damage, or a larger logo,
+// at the cost of a bigger code.
+type Level int
+
+const (
+	// Low recovers about 7% of the codewords
+	Low Level = iota
+	// Medium recovers about 15% of the codewords
+	Medium
+	// Quartile recovers about 25% of the codewords
+	Quartile
+	// High recovers about 30% of the codewords
+	High
+)
+
+var ErrTooLong = errors.New("data does not fit in a QR code")
+
+// formatBits are the two bits identifying each level in the format information
+var formatBits = [...]int{Low: 1, Medium: 0, Quartile: 3, High: 2}
+
+// ParseLevel parses the letter of an error correction level: "L", "M", "Q" or "H"
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, fmt.Errorf("error correction level %q must be L, M, Q or H", s)
+}
+
+func (l Level) String() string {
+	return [...]string{"L", "M", "Q", "H"}[l]
+}
+
+// Code is an encoded QR code symbol without quiet zone
+type Code struct {
+	Version int
+	Level   Level
+	// Size is the number of modules per side
+	Size    int
+	modules []bool
+	// function marks the modules of finder, timing, alignment, format and version patterns
+	function []bool
+}
+
+// Black reports whether the module in column x and row y is dark. Modules outside the symbol are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Encode encodes data in byte mode into the smallest QR code version that holds it at the given level
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("unknown error correction level %d", level)
+	}
+
+	version := 1
+	for ; version <= 40; version++ {
+		if dataBits(data, version) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, ErrTooLong
+	}
+
+	c := &Code{
+		Version:  version,
+		Level:    level,
+		Size:     version*4 + 17,
+		modules:  make([]bool, (version*4+17)*(version*4+17)),
+		function: make([]bool, (version*4+17)*(version*4+17)),
+	}
+	c.drawFunctionPatterns()
+	c.drawCodewords(addEccAndInterleave(encodeData(data, version, level), version, level))
+	c.applyBestMask()
+	return c, nil
+}
+
+// dataBits is the length of data in byte mode: mode indicator, character count and 8 bits per byte
+func dataBits(data []byte, version int) int {
+	return 4 + charCountBits(version) + len(data)*8
+}
+
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encodeData builds the data codewords: the byte mode segment, the terminator and the pad bytes
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := numDataCodewords(version, level) * 8
+
+	var bb bitBuffer
+	bb.append(0x4, 4) // byte mode
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	bb.append(0, min(4, capacity-bb.len()))
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (bb *bitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bb.bits = append(bb.bits, (value>>i)&1 != 0)
+	}
+}
+
+func (bb *bitBuffer) len() int {
+	return len(bb.bits)
+}
+
+func (bb *bitBuffer) bytes() []byte {
+	result := make([]byte, len(bb.bits)/8)
+	for i, bit := range bb.bits {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// addEccAndInterleave splits the data codewords into blocks, appends the error correction codewords of
+// every block and interleaves the blocks into the final sequence of codewords
+func addEccAndInterleave(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockEccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Short blocks are padded so every block has the same length, the padding is skipped below
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// numRawDataModules is the number of modules left for data and error correction codewords after
+// the function patterns of version have been drawn, including remainder bits
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+func (c *Code) set(x, y int, black bool) {
+	c.modules[y*c.Size+x] = black
+}
+
+func (c *Code) setFunction(x, y int, black bool) {
+	c.modules[y*c.Size+x] = black
+	c.function[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.Size-4, 3)
+	c.drawFinderPattern(3, c.Size-4)
+
+	positions := alignmentPatternPositions(c.Version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners already hold finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Reserve the format information, it is drawn once the mask is known
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern with its separator around the center x, y
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPatternPositions returns the ascending row and column centers of the alignment patterns
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// drawFormatBits draws both copies of the error correction level and mask, protected by a BCH code
+func (c *Code) drawFormatBits(mask int) {
+	data := formatBits[c.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// Around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Split between the top right and bottom left finder patterns
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true) // always dark
+}
+
+// drawVersion draws both copies of the version information of versions 7 and up
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the two module wide columns zigzagging up and down from the
+// bottom right corner, skipping function patterns and the vertical timing pattern
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.function[y*c.Size+x] && i < len(data)*8 {
+					c.set(x, y, bit(int(data[i>>3]), 7-i&7))
+					i++
+				}
+				// Remainder bits stay light
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask. Applying the same mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty score and draws the final format information
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penaltyScore(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// penaltyScore rates how hard the symbol is to scan: long runs of one colour, 2x2 blocks, patterns
+// looking like finder patterns and an unbalanced ratio of dark to light modules
+func (c *Code) penaltyScore() int {
+	result := 0
+
+	for _, rows := range []bool{true, false} {
+		for a := 0; a < c.Size; a++ {
+			runColor, runLen := false, 0
+			var history runHistory
+			for b := 0; b < c.Size; b++ {
+				x, y := b, a
+				if !rows {
+					x, y = a, b
+				}
+				if c.Black(x, y) == runColor {
+					runLen++
+					if runLen == 5 {
+						result += penaltyRun
+					} else if runLen > 5 {
+						result++
+					}
+					continue
+				}
+				history.add(runLen, c.Size)
+				if !runColor {
+					result += history.finderPatterns() * penaltyFinder
+				}
+				runColor, runLen = c.Black(x, y), 1
+			}
+			result += history.terminate(runColor, runLen, c.Size) * penaltyFinder
+		}
+	}
+
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			color := c.Black(x, y)
+			if color == c.Black(x+1, y) && color == c.Black(x, y+1) && color == c.Black(x+1, y+1) {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	dark := 0
+	for _, black := range c.modules {
+		if black {
+			dark++
+		}
+	}
+	total := c.Size * c.Size
+	// The smallest k such that the dark share is within (45 - 5k)% and (55 + 5k)%
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyBalance
+
+	return result
+}
+
+// runHistory holds the lengths of the last seven runs of a row or column, the newest first
+type runHistory [7]int
+
+func (h *runHistory) add(runLen int, size int) {
+	if h[0] == 0 {
+		// The light quiet zone extends the first run
+		runLen += size
+	}
+	copy(h[1:], h[:6])
+	h[0] = runLen
+}
+
+// finderPatterns counts the 1:1:3:1:1 dark and light runs with four light modules on either side
+func (h *runHistory) finderPatterns() int {
+	n := h[1]
+	core := n > 0 && h[2] == n && h[3] == n*3 && h[4] == n && h[5] == n
+	count := 0
+	if core && h[0] >= n*4 && h[6] >= n {
+		count++
+	}
+	if core && h[6] >= n*4 && h[0] >= n {
+		count++
+	}
+	return count
+}
+
+// terminate closes the row or column with the light quiet zone and counts the finder patterns it completes
+func (h *runHistory) terminate(runColor bool, runLen int, size int) int {
+	if runColor {
+		h.add(runLen, size)
+		runLen = 0
+	}
+	h.add(runLen+size, size)
+	return h.finderPatterns()
+}
+
+func bit(value int, i int) bool {
+	return (value>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
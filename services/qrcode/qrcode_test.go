@@ -0,0 +1,432 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// block is a group of error correction blocks of the same length, as listed in ISO/IEC 18004 table 9
+type block struct {
+	count, total, data int
+}
+
+// symbols lists the structure of a few symbols from the standard, the decoder below reads codes
+// with it independently of the encoder's own tables
+var symbols = []struct {
+	version   int
+	level     qrcode.Level
+	alignment []int
+	blocks    []block
+}{
+	{1, qrcode.Medium, nil, []block{{1, 26, 16}}},
+	{5, qrcode.Quartile, []int{6, 30}, []block{{2, 33, 15}, {2, 34, 16}}},
+	{7, qrcode.Low, []int{6, 22, 38}, []block{{2, 98, 78}}},
+	{10, qrcode.High, []int{6, 28, 50}, []block{{6, 43, 15}, {2, 44, 16}}},
+	{40, qrcode.Low, []int{6, 30, 58, 86, 114, 142, 170}, []block{{19, 148, 118}, {6, 149, 119}}},
+}
+
+func dataCapacity(blocks []block, version int) int {
+	n := 0
+	for _, b := range blocks {
+		n += b.count * b.data
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	return (n*8 - 4 - countBits) / 8
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	for _, sym := range symbols {
+		t.Run(fmt.Sprintf("%d-%s", sym.version, sym.level), func(t *testing.T) {
+			// The largest payload that fits is encoded in exactly this version
+			data := make([]byte, dataCapacity(sym.blocks, sym.version))
+			for i := range data {
+				data[i] = byte(i*7 + 3)
+			}
+
+			code, err := qrcode.Encode(data, sym.level)
+			require.NoError(t, err)
+			assert.Equal(t, sym.version, code.Version)
+			assert.Equal(t, sym.version*4+17, code.Size)
+
+			assert.Equal(t, data, decode(t, code, sym.alignment, sym.blocks))
+		})
+	}
+}
+
+func TestEncode_Capacity(t *testing.T) {
+	tests := []struct {
+		level   qrcode.Level
+		length  int
+		version int
+	}{
+		{qrcode.Low, 17, 1},
+		{qrcode.Low, 18, 2},
+		{qrcode.High, 7, 1},
+		{qrcode.High, 8, 2},
+		{qrcode.Medium, 213, 10},
+		{qrcode.Low, 2953, 40},
+		{qrcode.High, 1273, 40},
+	}
+
+	for _, tt := range tests {
+		code, err := qrcode.Encode(make([]byte, tt.length), tt.level)
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, code.Version, "%d bytes at level %s", tt.length, tt.level)
+	}
+
+	_, err := qrcode.Encode(make([]byte, 2954), qrcode.Low)
+	assert.ErrorIs(t, err, qrcode.ErrTooLong)
+}
+
+func TestEncode_VersionInformation(t *testing.T) {
+	code, err := qrcode.Encode(make([]byte, 120), qrcode.Medium)
+	require.NoError(t, err)
+	require.Equal(t, 7, code.Version)
+
+	// Version 7 is 000111 110010 010100 in both copies
+	bits := 0
+	for i := 17; i >= 0; i-- {
+		a, b := code.Size-11+i%3, i/3
+		assert.Equal(t, code.Black(a, b), code.Black(b, a))
+		bits <<= 1
+		if code.Black(a, b) {
+			bits |= 1
+		}
+	}
+	assert.Equal(t, 0x07C94, bits)
+}
+
+func TestEncode_FinderPatterns(t *testing.T) {
+	code, err := qrcode.Encode([]byte("https://sho.rt/abc123?src=qr"), qrcode.Medium)
+	require.NoError(t, err)
+
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 7; x++ {
+				ring := max(abs(x-3), abs(y-3))
+				assert.Equal(t, ring != 2, code.Black(corner[0]+x, corner[1]+y))
+			}
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := qrcode.ParseLevel("q")
+	require.NoError(t, err)
+	assert.Equal(t, qrcode.Quartile, level)
+
+	_, err = qrcode.ParseLevel("X")
+	assert.Error(t, err)
+}
+
+func TestPNG(t *testing.T) {
+	code, err := qrcode.Encode([]byte("https://sho.rt/abc123"), qrcode.Medium)
+	require.NoError(t, err)
+
+	opts := qrcode.DefaultOptions()
+	opts.Size = 300
+	opts.Foreground = color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+
+	var buf bytes.Buffer
+	require.NoError(t, qrcode.PNG(&buf, code, opts))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+	// Version 2 has 25 modules, 33 with the margin, which are 9 pixels each. The code starts at
+	// (300 - 25*9) / 2 = 37 pixels.
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.RGBAModel.Convert(img.At(36, 36)))
+	assert.Equal(t, opts.Foreground, color.RGBAModel.Convert(img.At(37, 37)))
+	assert.Equal(t, opts.Foreground, color.RGBAModel.Convert(img.At(37+6*9, 37)))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.RGBAModel.Convert(img.At(37+9, 37+9)))
+}
+
+func TestPNG_Logo(t *testing.T) {
+	code, err := qrcode.Encode([]byte("https://sho.rt/abc123"), qrcode.High)
+	require.NoError(t, err)
+
+	logo := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0xff
+	}
+	for i := 0; i < len(logo.Pix); i += 4 {
+		logo.Pix[i+1], logo.Pix[i+2] = 0, 0 // red
+	}
+
+	opts := qrcode.DefaultOptions()
+	opts.Logo = logo
+
+	var buf bytes.Buffer
+	require.NoError(t, qrcode.PNG(&buf, code, opts))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	center := img.Bounds().Dx() / 2
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, color.RGBAModel.Convert(img.At(center, center)))
+}
+
+func TestSVG(t *testing.T) {
+	code, err := qrcode.Encode([]byte("https://sho.rt/abc123"), qrcode.Low)
+	require.NoError(t, err)
+
+	opts := qrcode.DefaultOptions()
+	opts.Margin = 2
+	opts.Background = color.RGBA{R: 0xff, G: 0xee, B: 0xdd, A: 0xff}
+
+	var buf bytes.Buffer
+	require.NoError(t, qrcode.SVG(&buf, code, opts))
+	svg := buf.String()
+
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `viewBox="0 0 29 29"`)
+	assert.Contains(t, svg, `width="256"`)
+	assert.Contains(t, svg, `fill="#ffeedd"`)
+	// The top left finder pattern starts with a run of seven dark modules
+	assert.Contains(t, svg, `d="M2 2h7v1h-7z`)
+	assert.NotContains(t, svg, "<image")
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := qrcode.ParseColor("#1a2B3c")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, c)
+	assert.Equal(t, "#1a2b3c", qrcode.HexColor(c))
+
+	for _, invalid := range []string{"", "fff", "#12345g", "1234567"} {
+		_, err := qrcode.ParseColor(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// decode reads the data of a byte mode code the way a scanner would once the symbol has been sampled:
+// it reads the format information, unmasks the data modules, de-interleaves the blocks, checks their
+// error correction codewords and parses the segment
+func decode(t *testing.T, code *qrcode.Code, alignment []int, blocks []block) []byte {
+	size := code.Size
+
+	format := 0
+	for i := 14; i >= 0; i-- {
+		var x, y int
+		switch {
+		case i <= 5:
+			x, y = 8, i
+		case i == 6:
+			x, y = 8, 7
+		case i == 7:
+			x, y = 8, 8
+		case i == 8:
+			x, y = 7, 8
+		default:
+			x, y = 14-i, 8
+		}
+		format <<= 1
+		if code.Black(x, y) {
+			format |= 1
+		}
+	}
+	format ^= 0x5412
+	require.Equal(t, format, bchFormat(format>>10), "format information is not a valid BCH code word")
+	levels := map[int]qrcode.Level{1: qrcode.Low, 0: qrcode.Medium, 3: qrcode.Quartile, 2: qrcode.High}
+	require.Equal(t, code.Level, levels[format>>13])
+	mask := (format >> 10) & 7
+
+	function := functionModules(size, code.Version, alignment)
+
+	var codewords []byte
+	var current, n int
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if function[y][x] {
+					continue
+				}
+				black := code.Black(x, y) != masked(mask, x, y)
+				current <<= 1
+				if black {
+					current |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, byte(current))
+					current = 0
+				}
+			}
+		}
+	}
+
+	// Data codewords are interleaved first, short blocks run out one codeword early,
+	// followed by the error correction codewords which all blocks have the same number of
+	var lengths []int
+	eccLen, total := 0, 0
+	for _, b := range blocks {
+		for i := 0; i < b.count; i++ {
+			lengths = append(lengths, b.data)
+		}
+		eccLen = b.total - b.data
+		total += b.count * b.total
+	}
+	require.Len(t, codewords, total)
+
+	data := make([][]byte, len(lengths))
+	k := 0
+	for i := 0; i < lengths[len(lengths)-1]; i++ {
+		for j, l := range lengths {
+			if i < l {
+				data[j] = append(data[j], codewords[k])
+				k++
+			}
+		}
+	}
+	ecc := make([][]byte, len(lengths))
+	for i := 0; i < eccLen; i++ {
+		for j := range lengths {
+			ecc[j] = append(ecc[j], codewords[k])
+			k++
+		}
+	}
+
+	var stream []byte
+	for j := range lengths {
+		require.True(t, validBlock(append(append([]byte(nil), data[j]...), ecc[j]...), eccLen), "block %d has errors", j)
+		stream = append(stream, data[j]...)
+	}
+
+	bits := func(from, n int) int {
+		v := 0
+		for i := from; i < from+n; i++ {
+			v = v<<1 | int(stream[i/8]>>(7-i%8))&1
+		}
+		return v
+	}
+	require.Equal(t, 0x4, bits(0, 4), "byte mode")
+	countBits := 8
+	if code.Version >= 10 {
+		countBits = 16
+	}
+	count := bits(4, countBits)
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(bits(4+countBits+i*8, 8))
+	}
+	return result
+}
+
+func bchFormat(data int) int {
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return data<<10 | rem&0x3FF
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (y+x)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (y+x)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return (y*x)%2+(y*x)%3 == 0
+	case 6:
+		return ((y*x)%2+(y*x)%3)%2 == 0
+	default:
+		return ((y+x)%2+(y*x)%3)%2 == 0
+	}
+}
+
+func functionModules(size, version int, alignment []int) [][]bool {
+	function := make([][]bool, size)
+	for i := range function {
+		function[i] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				function[y][x] = true
+			}
+		}
+	}
+
+	// Finder patterns with separators and format information
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	for i, y := range alignment {
+		for j, x := range alignment {
+			// Except for the ones in the corners of the finder patterns
+			last := len(alignment) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return function
+}
+
+// validBlock reports whether all syndromes of a block of data and error correction codewords are zero
+func validBlock(codewords []byte, eccLen int) bool {
+	var exp [512]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = i
+		if x <<= 1; x > 0xff {
+			x ^= 0x11D
+		}
+	}
+
+	for i := 0; i < eccLen; i++ {
+		var s byte
+		for _, c := range codewords {
+			// s = s * alpha^i + c
+			if s != 0 {
+				s = exp[log[s]+i]
+			}
+			s ^= c
+		}
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,203 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RenderOptions controls how a code is drawn
+type RenderOptions struct {
+	// Size is the width and height of the image in pixels. PNG images are exactly this size, with every
+	// module the same whole number of pixels and the rest added to the margin.
+	Size int
+	// Margin is the quiet zone around the code in modules; scanners need at least 4
+	Margin     int
+	Foreground color.Color
+	Background color.Color
+	// Logo is drawn in the center of the code on a background coloured box. It covers the modules below it,
+	// so it should only be used with the Quartile or High level.
+	Logo image.Image
+}
+
+// DefaultOptions returns black on white 256 pixel images with the standard quiet zone
+func DefaultOptions() RenderOptions {
+	return RenderOptions{
+		Size:       256,
+		Margin:     4,
+		Foreground: color.Black,
+		Background: color.White,
+	}
+}
+
+// logoShare is the part of the code's width covered by the logo, about 5% of its modules
+const logoShare = 0.22
+
+// logoBox returns the square the logo is drawn in, in the coordinates of a code width pixels wide
+// starting at offset
+func logoBox(offset, width int) image.Rectangle {
+	side := int(float64(width) * logoShare)
+	start := offset + (width-side)/2
+	return image.Rect(start, start, start+side, start+side)
+}
+
+// PNG writes the code as a PNG image
+func PNG(w io.Writer, c *Code, opts RenderOptions) error {
+	modules := c.Size + 2*opts.Margin
+	scale := max(1, opts.Size/modules)
+	size := max(opts.Size, scale*modules)
+	// Center the code, the pixels left over by the scale widen the margin
+	offset := (size - scale*c.Size) / 2
+
+	fg := color.RGBAModel.Convert(opts.Foreground)
+	bg := color.RGBAModel.Convert(opts.Background)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			mx, my := x-offset, y-offset
+			if mx >= 0 && my >= 0 && c.Black(mx/scale, my/scale) {
+				img.Set(x, y, fg)
+			} else {
+				img.Set(x, y, bg)
+			}
+		}
+	}
+
+	if opts.Logo != nil {
+		box := logoBox(offset, scale*c.Size)
+		fillRect(img, box, bg)
+		drawScaled(img, box.Inset(max(1, box.Dx()/10)), opts.Logo)
+	}
+
+	return png.Encode(w, img)
+}
+
+// SVG writes the code as an SVG image, using one path for all dark modules
+func SVG(w io.Writer, c *Code, opts RenderOptions) error {
+	modules := c.Size + 2*opts.Margin
+
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !c.Black(x, y) {
+				x++
+				continue
+			}
+			run := 1
+			for c.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, HexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="%s"/>`, HexColor(opts.Foreground), path.String())
+
+	if opts.Logo != nil {
+		// Scale the box to the viewBox, in modules
+		const precision = 1000
+		box := logoBox(opts.Margin*precision, c.Size*precision)
+		inset := box.Inset(max(1, box.Dx()/10))
+		fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`,
+			fixed(box.Min.X, precision), fixed(box.Min.Y, precision), fixed(box.Dx(), precision), fixed(box.Dy(), precision), HexColor(opts.Background))
+
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, opts.Logo); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="xMidYMid meet" xlink:href="data:image/png;base64,%s"/>`,
+			fixed(inset.Min.X, precision), fixed(inset.Min.Y, precision), fixed(inset.Dx(), precision), fixed(inset.Dy(), precision),
+			base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	buf.WriteString("</svg>\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// fixed formats n/precision with three decimals
+func fixed(n int, precision int) string {
+	return strconv.FormatFloat(float64(n)/float64(precision), 'f', 3, 64)
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// drawScaled draws src into r with nearest neighbour scaling, keeping its aspect ratio and blending
+// transparent pixels with the image below
+func drawScaled(dst *image.RGBA, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() || r.Empty() {
+		return
+	}
+
+	// Fit the logo into r and center it
+	w, h := r.Dx(), r.Dx()*sb.Dy()/sb.Dx()
+	if h > r.Dy() {
+		w, h = r.Dy()*sb.Dx()/sb.Dy(), r.Dy()
+	}
+	x0, y0 := r.Min.X+(r.Dx()-w)/2, r.Min.Y+(r.Dy()-h)/2
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sr, sg, sbl, sa := src.At(sb.Min.X+x*sb.Dx()/w, sb.Min.Y+y*sb.Dy()/h).RGBA()
+			dr, dg, db, _ := dst.At(x0+x, y0+y).RGBA()
+			blend := func(s, d uint32) uint8 {
+				// s is premultiplied by the alpha
+				return uint8((s + d*(0xffff-sa)/0xffff) >> 8)
+			}
+			dst.SetRGBA(x0+x, y0+y, color.RGBA{blend(sr, dr), blend(sg, dg), blend(sbl, db), 0xff})
+		}
+	}
+}
+
+// ParseColor parses a colour written as six hex digits, with or without a leading '#'
+func ParseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("colour %q must be six hex digits", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("colour %q must be six hex digits", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// HexColor formats c as #rrggbb, ignoring its alpha
+func HexColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// LoadLogo reads a PNG or JPEG logo
+func LoadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	logo, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode logo %s: %w", path, err)
+	}
+	return logo, nil
+}
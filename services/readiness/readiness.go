@@ -0,0 +1,169 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown is reported once Shutdown has been called
+var ErrShuttingDown = errors.New("shutting down")
+
+// Check reports whether a dependency is usable. It must return once ctx is done.
+type Check func(ctx context.Context) error
+
+// Options configures a Prober
+type Options struct {
+	// Timeout bounds each check, a check still running then fails
+	Timeout time.Duration
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 2 * time.Second,
+	}
+}
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// Report is the body of the readiness endpoint, Status is ok only when every check passed
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Prober answers the liveness and readiness probes of an orchestrator such as Kubernetes
+type Prober struct {
+	opts         Options
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewProber(opts Options) *Prober {
+	return &Prober{opts: opts}
+}
+
+// Add registers a dependency checked on every readiness probe. It must be called before serving probes.
+func (p *Prober) Add(name string, check Check) {
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown makes every following readiness probe fail, so traffic is drained before the server stops
+func (p *Prober) Shutdown() {
+	p.shuttingDown.Store(true)
+}
+
+// Ready runs every check at the same time, each bounded by the timeout
+func (p *Prober) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusOk, Checks: make(map[string]CheckResult, len(p.checks))}
+
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+	for _, c := range p.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := p.run(ctx, c.check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOk {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = CheckResult{Status: StatusFail, Error: ErrShuttingDown.Error()}
+	}
+	return report
+}
+
+func (p *Prober) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	// A check that ignores its context must not hold up the probe
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOk, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler answers 200 as long as the process can serve requests; it does not look at dependencies,
+// so an outage of Postgres or Redis does not get the process restarted
+func (p *Prober) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteJson(w, http.StatusOK, map[string]string{"status": StatusOk})
+	})
+}
+
+// ReadyHandler answers 200 when every check passes and 503 otherwise, with the result of each check
+func (p *Prober) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := p.Ready(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOk {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		utils.WriteJson(w, status, report)
+	})
+}
+
+// Heartbeat checks that last, the time a background worker was last seen running, is at most maxAge ago
+func Heartbeat(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		seen := last()
+		if seen.IsZero() {
+			return errors.New("no heartbeat yet")
+		}
+		if age := time.Since(seen); age > maxAge {
+			return fmt.Errorf("last heartbeat %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package readiness_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/readiness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, handler http.Handler) (int, readiness.Report) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	var report readiness.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReadyHandler(t *testing.T) {
+	prober := readiness.NewProber(readiness.DefaultOptions())
+	prober.Add("postgres", func(ctx context.Context) error { return nil })
+	prober.Add("redis", func(ctx context.Context) error { return nil })
+
+	code, report := probe(t, prober.ReadyHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, readiness.StatusOk, report.Status)
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, readiness.StatusOk, report.Checks["redis"].Status)
+}
+
+func TestReadyHandler_FailingCheck(t *testing.T) {
+	prober := readiness.NewProber(readiness.DefaultOptions())
+	prober.Add("postgres", func(ctx context.Context) error { return nil })
+	prober.Add("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	code, report := probe(t, prober.ReadyHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, readiness.StatusFail, report.Status)
+	assert.Equal(t, readiness.StatusOk, report.Checks["postgres"].Status)
+	assert.Equal(t, readiness.StatusFail, report.Checks["redis"].Status)
+	assert.Equal(t, "connection refused", report.Checks["redis"].Error)
+}
+
+func TestReadyHandler_Timeout(t *testing.T) {
+	opts := readiness.DefaultOptions()
+	opts.Timeout = 20 * time.Millisecond
+	prober := readiness.NewProber(opts)
+	release := make(chan struct{})
+	defer close(release)
+	prober.Add("stuck", func(ctx context.Context) error {
+		<-release // ignores its context
+		return nil
+	})
+
+	start := time.Now()
+	code, report := probe(t, prober.ReadyHandler())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+}
+
+func TestReadyHandler_Shutdown(t *testing.T) {
+	prober := readiness.NewProber(readiness.DefaultOptions())
+	prober.Add("postgres", func(ctx context.Context) error { return nil })
+	prober.Shutdown()
+
+	code, report := probe(t, prober.ReadyHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, readiness.StatusOk, report.Checks["postgres"].Status)
+	assert.Equal(t, readiness.ErrShuttingDown.Error(), report.Checks["shutdown"].Error)
+
+	// Liveness does not depend on readiness
+	rec := httptest.NewRecorder()
+	prober.LiveHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestHeartbeat(t *testing.T) {
+	var last time.Time
+	check := readiness.Heartbeat(func() time.Time { return last }, time.Minute)
+
+	assert.EqualError(t, check(context.Background()), "no heartbeat yet")
+	last = time.Now().Add(-10 * time.Second)
+	assert.NoError(t, check(context.Background()))
+	last = time.Now().Add(-2 * time.Minute)
+	assert.EqualError(t, check(context.Background()), "last heartbeat 2m0s ago")
+}
@@ -0,0 +1,312 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the time zone database so time windows work in minimal containers without /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
+// DefaultBucket is the name clicks are counted under when no rule matches
+const DefaultBucket = "default"
+
+// MaxRules is the maximum number of rules a link can carry
+const MaxRules = 20
+
+var ruleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Rule sends requests matching all of its conditions to Target. Each condition is optional and
+// matches when any of its values matches; a rule without conditions matches every request.
+type Rule struct {
+	// Name identifies the rule in the click breakdown
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	// OS matches the operating system derived from the User-Agent, see ParseUserAgent
+	OS []string `json:"os,omitempty"`
+	// Device matches the device class derived from the User-Agent, see ParseUserAgent
+	Device []string `json:"device,omitempty"`
+	// Languages matches the preferred Accept-Language. "de" matches any German variant, "de-ch" only Swiss German.
+	Languages []string `json:"languages,omitempty"`
+	// Query matches query parameters of the short link request; "*" matches any value
+	Query map[string]string `json:"query,omitempty"`
+	Time  *TimeWindow       `json:"time,omitempty"`
+}
+
+// TimeWindow matches requests between Start and End ("15:04"), optionally only on some weekdays.
+// A window whose End is before its Start spans midnight.
+type TimeWindow struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Days     []string `json:"days,omitempty"`
+	TimeZone string   `json:"timezone,omitempty"`
+}
+
+// Rules is the ordered list of routing rules of a link; the first matching rule wins
+type Rules []Rule
+
+// Request holds the request attributes rules are matched on
+type Request struct {
+	OS       string
+	Device   string
+	Language string
+	Query    url.Values
+	Time     time.Time
+}
+
+// NewRequest extracts the attributes rules are matched on from r
+func NewRequest(r *http.Request, now time.Time) Request {
+	os, device := ParseUserAgent(r.UserAgent())
+	return Request{
+		OS:       os,
+		Device:   device,
+		Language: PreferredLanguage(r.Header.Get("Accept-Language")),
+		Query:    r.URL.Query(),
+		Time:     now,
+	}
+}
+
+// Parse decodes rules stored as JSON. Empty input yields no rules.
+func Parse(data []byte) (Rules, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Match returns the first rule matching req
+func (rules Rules) Match(req Request) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.matches(req) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Validate checks the rules and normalizes their values to lowercase. Targets are not checked here,
+// they have to go through the same validation as long URLs.
+func (rules Rules) Validate() error {
+	if len(rules) > MaxRules {
+		return fmt.Errorf("a link can have at most %d routing rules", MaxRules)
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+
+		rule.Name = strings.ToLower(strings.TrimSpace(rule.Name))
+		if !ValidName(rule.Name) {
+			return fmt.Errorf("rule %d: name must be 1 to 32 lowercase letters, digits, '-' or '_' and not %q", i+1, DefaultBucket)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %q: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if strings.TrimSpace(rule.Target) == "" {
+			return fmt.Errorf("rule %q: target is required", rule.Name)
+		}
+
+		rule.OS = lowerAll(rule.OS)
+		for _, os := range rule.OS {
+			if !slices.Contains(KnownOS, os) {
+				return fmt.Errorf("rule %q: unknown os %q", rule.Name, os)
+			}
+		}
+		rule.Device = lowerAll(rule.Device)
+		for _, device := range rule.Device {
+			if !slices.Contains(KnownDevices, device) {
+				return fmt.Errorf("rule %q: unknown device %q", rule.Name, device)
+			}
+		}
+		rule.Languages = lowerAll(rule.Languages)
+
+		if rule.Time != nil {
+			if err := rule.Time.validate(); err != nil {
+				return fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (rule Rule) matches(req Request) bool {
+	if len(rule.OS) > 0 && !slices.Contains(rule.OS, req.OS) {
+		return false
+	}
+	if len(rule.Device) > 0 && !slices.Contains(rule.Device, req.Device) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !matchLanguage(rule.Languages, req.Language) {
+		return false
+	}
+	for key, want := range rule.Query {
+		if !req.Query.Has(key) || (want != "*" && req.Query.Get(key) != want) {
+			return false
+		}
+	}
+	if rule.Time != nil && !rule.Time.contains(req.Time) {
+		return false
+	}
+	return true
+}
+
+// matchLanguage matches a language range such as "de" against every variant of the language
+func matchLanguage(ranges []string, language string) bool {
+	if language == "" {
+		return false
+	}
+	for _, r := range ranges {
+		if r == language || strings.HasPrefix(language, r+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TimeWindow) validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("%s", "time window start and end must differ")
+	}
+
+	w.Days = lowerAll(w.Days)
+	for _, day := range w.Days {
+		if _, ok := weekdays[day]; !ok {
+			return fmt.Errorf("unknown day %q, use mon, tue, wed, thu, fri, sat or sun", day)
+		}
+	}
+
+	if w.TimeZone != "" {
+		if _, err := time.LoadLocation(w.TimeZone); err != nil {
+			return fmt.Errorf("unknown timezone %q", w.TimeZone)
+		}
+	}
+	return nil
+}
+
+func (w *TimeWindow) contains(t time.Time) bool {
+	if w.TimeZone != "" {
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return false
+		}
+		t = t.In(loc)
+	} else {
+		t = t.UTC()
+	}
+
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+
+	day := t.Weekday()
+	var inWindow bool
+	if start <= end {
+		inWindow = now >= start && now < end
+	} else {
+		// The window spans midnight, so the part after midnight belongs to the previous day
+		inWindow = now >= start || now < end
+		if now < end {
+			day = (day + 6) % 7
+		}
+	}
+	if !inWindow {
+		return false
+	}
+
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[d] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "15:04" into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// PreferredLanguage returns the lowercase language tag with the highest weight in an Accept-Language header
+func PreferredLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			languages = append(languages, weighted{tag: tag, q: q})
+		}
+	}
+
+	if len(languages) == 0 {
+		return ""
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+	return languages[0].tag
+}
+
+func lowerAll(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return values
+}
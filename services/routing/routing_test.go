@@ -0,0 +1,175 @@
+package routing_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	uaIPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	uaIPad    = "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1"
+	uaAndroid = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	uaTablet  = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaMac     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+	uaBot     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua     string
+		os     string
+		device string
+	}{
+		{uaIPhone, routing.OSIOS, routing.DeviceMobile},
+		{uaIPad, routing.OSIOS, routing.DeviceTablet},
+		{uaAndroid, routing.OSAndroid, routing.DeviceMobile},
+		{uaTablet, routing.OSAndroid, routing.DeviceTablet},
+		{uaWindows, routing.OSWindows, routing.DeviceDesktop},
+		{uaMac, routing.OSMacOS, routing.DeviceDesktop},
+		{uaBot, routing.OSOther, routing.DeviceBot},
+		{"", routing.OSOther, routing.DeviceDesktop},
+	}
+
+	for _, tt := range tests {
+		os, device := routing.ParseUserAgent(tt.ua)
+		assert.Equal(t, tt.os, os, tt.ua)
+		assert.Equal(t, tt.device, device, tt.ua)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "de-ch", routing.PreferredLanguage("de-CH, de;q=0.9, en;q=0.8"))
+	assert.Equal(t, "en", routing.PreferredLanguage("fr;q=0.5, en, *;q=0.1"))
+	assert.Equal(t, "", routing.PreferredLanguage(""))
+	assert.Equal(t, "", routing.PreferredLanguage("de;q=0"))
+}
+
+func appStoreRules(t *testing.T) routing.Rules {
+	rules, err := routing.Parse([]byte(`[
+		{"name": "ios", "target": "https://apps.apple.com/app/id1", "os": ["iOS"]},
+		{"name": "android", "target": "https://play.google.com/store/apps/details?id=app", "os": ["android"]},
+		{"name": "german", "target": "https://example.com/de/", "languages": ["de"]},
+		{"name": "campaign", "target": "https://example.com/promo", "query": {"utm_source": "print"}}
+	]`))
+	require.NoError(t, err)
+	require.NoError(t, rules.Validate())
+	return rules
+}
+
+func request(ua, language, query string) routing.Request {
+	r, _ := http.NewRequest("GET", "/abc123?"+query, nil)
+	r.Header.Set("User-Agent", ua)
+	r.Header.Set("Accept-Language", language)
+	return routing.NewRequest(r, time.Now())
+}
+
+func TestRules_Match(t *testing.T) {
+	rules := appStoreRules(t)
+
+	tests := []struct {
+		name string
+		req  routing.Request
+		want string
+	}{
+		{"iPhone goes to the App Store", request(uaIPhone, "de-DE", ""), "ios"},
+		{"Android goes to Play", request(uaAndroid, "en-US", ""), "android"},
+		{"German desktop", request(uaWindows, "de-AT,en;q=0.5", ""), "german"},
+		{"Query parameter", request(uaMac, "en-US", "utm_source=print"), "campaign"},
+		{"Other query value", request(uaMac, "en-US", "utm_source=web"), ""},
+		{"No rule matches", request(uaWindows, "en-US", ""), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := rules.Match(tt.req)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, rule.Name)
+		})
+	}
+}
+
+func TestRules_MatchTimeWindow(t *testing.T) {
+	rules := routing.Rules{
+		{Name: "office", Target: "https://example.com/call", Time: &routing.TimeWindow{Start: "09:00", End: "17:00", Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, TimeZone: "Europe/Berlin"}},
+		{Name: "night", Target: "https://example.com/night", Time: &routing.TimeWindow{Start: "22:00", End: "06:00", Days: []string{"fri"}}},
+	}
+	require.NoError(t, rules.Validate())
+
+	at := func(value string) routing.Request {
+		tm, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return routing.Request{Query: url.Values{}, Time: tm}
+	}
+
+	tests := []struct {
+		name string
+		time string
+		want string
+	}{
+		{"Monday morning in Berlin", "2024-01-08T08:30:00Z", "office"},
+		{"Monday evening in Berlin", "2024-01-08T16:30:00Z", ""},
+		{"Saturday in Berlin", "2024-01-13T10:00:00Z", ""},
+		{"Friday night", "2024-01-12T23:00:00Z", "night"},
+		{"Early Saturday belongs to Friday night", "2024-01-13T03:00:00Z", "night"},
+		{"Early Friday belongs to Thursday night", "2024-01-12T03:00:00Z", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, _ := rules.Match(at(tt.time))
+			assert.Equal(t, tt.want, rule.Name)
+		})
+	}
+}
+
+func TestRules_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules routing.Rules
+	}{
+		{"Missing name", routing.Rules{{Target: "https://example.com/"}}},
+		{"Reserved name", routing.Rules{{Name: "default", Target: "https://example.com/"}}},
+		{"Duplicate name", routing.Rules{{Name: "a", Target: "https://example.com/"}, {Name: "A", Target: "https://example.com/"}}},
+		{"Missing target", routing.Rules{{Name: "a"}}},
+		{"Unknown os", routing.Rules{{Name: "a", Target: "https://example.com/", OS: []string{"symbian"}}}},
+		{"Unknown device", routing.Rules{{Name: "a", Target: "https://example.com/", Device: []string{"fridge"}}}},
+		{"Invalid time", routing.Rules{{Name: "a", Target: "https://example.com/", Time: &routing.TimeWindow{Start: "9am", End: "17:00"}}}},
+		{"Empty window", routing.Rules{{Name: "a", Target: "https://example.com/", Time: &routing.TimeWindow{Start: "09:00", End: "09:00"}}}},
+		{"Unknown day", routing.Rules{{Name: "a", Target: "https://example.com/", Time: &routing.TimeWindow{Start: "09:00", End: "17:00", Days: []string{"someday"}}}}},
+		{"Unknown timezone", routing.Rules{{Name: "a", Target: "https://example.com/", Time: &routing.TimeWindow{Start: "09:00", End: "17:00", TimeZone: "Mars/Olympus"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.rules.Validate())
+		})
+	}
+}
+
+func TestPickWeighted(t *testing.T) {
+	weights := []int{70, 30}
+
+	// Sticky: the same visitor always gets the same variant
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, routing.PickWeighted(weights, "visitor-1", "abc123"), routing.PickWeighted(weights, "visitor-1", "abc123"))
+	}
+
+	// Distribution follows the weights
+	counts := make([]int, len(weights))
+	for i := 0; i < 10000; i++ {
+		counts[routing.PickWeighted(weights, fmt.Sprintf("visitor-%d", i), "abc123")]++
+	}
+	assert.InDelta(t, 7000, counts[0], 300)
+	assert.InDelta(t, 3000, counts[1], 300)
+
+	assert.Equal(t, -1, routing.PickWeighted(nil, "visitor-1", "abc123"))
+	assert.Equal(t, 1, routing.PickWeighted([]int{0, 5}, "visitor-1", "abc123"))
+}
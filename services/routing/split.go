@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"hash/fnv"
+)
+
+// MaxVariants is the maximum number of weighted destinations of a split link
+const MaxVariants = 10
+
+// MaxWeight is the largest weight a single destination can have
+const MaxWeight = 1000
+
+// ValidName reports whether name can be used for a routing rule or split variant
+func ValidName(name string) bool {
+	return ruleNamePattern.MatchString(name) && name != DefaultBucket
+}
+
+// PickWeighted picks an index from weights with a probability proportional to its weight.
+// The pick is a pure function of key and salt, so the same visitor keeps getting the same
+// variant as long as the weights do not change. It returns -1 if the weights sum up to zero.
+func PickWeighted(weights []int, key string, salt string) int {
+	total := 0
+	for _, w := range weights {
+		total += max(w, 0)
+	}
+	if total == 0 {
+		return -1
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	point := h.Sum64() % uint64(total)
+
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if point < uint64(w) {
+			return i
+		}
+		point -= uint64(w)
+	}
+	return -1
+}
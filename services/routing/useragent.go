@@ -0,0 +1,70 @@
+package routing
+
+import "strings"
+
+// Operating systems reported by ParseUserAgent
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+)
+
+// Device classes reported by ParseUserAgent
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// KnownOS and KnownDevices list the values rules can match on
+var (
+	KnownOS      = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS, OSOther}
+	KnownDevices = []string{DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot}
+)
+
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly", "preview", "curl/", "wget/", "python-requests", "go-http-client"}
+
+// ParseUserAgent derives the operating system and device class from a User-Agent header.
+// It only looks for the handful of markers routing needs and is not a general purpose parser.
+func ParseUserAgent(ua string) (string, string) {
+	ua = strings.ToLower(ua)
+
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return OSOther, DeviceBot
+		}
+	}
+
+	switch {
+	// Windows Phone also claims to be Android and iPhone
+	case strings.Contains(ua, "windows phone"):
+		return OSWindows, DeviceMobile
+	case strings.Contains(ua, "ipad"):
+		return OSIOS, DeviceTablet
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return OSIOS, DeviceMobile
+	case strings.Contains(ua, "android"):
+		// Android tablets leave "mobile" out of their User-Agent
+		if strings.Contains(ua, "mobile") {
+			return OSAndroid, DeviceMobile
+		}
+		return OSAndroid, DeviceTablet
+	case strings.Contains(ua, "windows"):
+		return OSWindows, DeviceDesktop
+	case strings.Contains(ua, "cros"):
+		return OSChromeOS, DeviceDesktop
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return OSMacOS, DeviceDesktop
+	case strings.Contains(ua, "linux"):
+		return OSLinux, DeviceDesktop
+	case strings.Contains(ua, "mobile"):
+		return OSOther, DeviceMobile
+	default:
+		return OSOther, DeviceDesktop
+	}
+}
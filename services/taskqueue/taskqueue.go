@@ -0,0 +1,188 @@
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeartbeatInterval is how often idle workers report they are alive, see Queue.Heartbeat
+const HeartbeatInterval = 5 * time.Second
+
+var (
+	// ErrFull is returned by Submit when the backlog is full
+	ErrFull = errors.New("task queue is full")
+	// ErrStopped is returned by Submit after Stop has been called
+	ErrStopped = errors.New("task queue is stopped")
+)
+
+// Metrics are labelled by the kind of job, the part of its name before the first ':'
+var (
+	queueDepth = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "urlshortner_taskqueue_depth",
+		Help: "Jobs waiting in the task queue.",
+	}, []string{"job"})
+	jobDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "urlshortner_taskqueue_job_duration_seconds",
+		Help:    "Time taken by background jobs.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"job"})
+	jobFailures = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_taskqueue_job_failures_total",
+		Help: "Background jobs that returned an error or panicked.",
+	}, []string{"job"})
+	jobsRejected = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshortner_taskqueue_rejected_total",
+		Help: "Jobs refused because the backlog was full.",
+	}, []string{"job"})
+)
+
+// Job is a unit of background work. Run should return early once ctx is cancelled.
+type Job struct {
+	// Name identifies the job in logs
+	Name string
+	Run  func(ctx context.Context) error
+	// Link is the span that queued the job. The job is traced in a trace of its own, linked to it.
+	Link trace.SpanContext
+}
+
+// Queue runs jobs on a fixed number of workers in the background. Submitting never blocks:
+// jobs are refused with ErrFull when the backlog is full, so a slow dependency cannot pile up work.
+type Queue struct {
+	jobs    chan Job
+	log     zerolog.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mutex   sync.RWMutex
+	stopped bool
+
+	// heartbeat is the last time, in Unix nanoseconds, a worker was waiting for or finished a job
+	heartbeat atomic.Int64
+}
+
+// New starts a queue with the given number of workers and room for backlog waiting jobs
+func New(workers int, backlog int, log zerolog.Logger) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		jobs:   make(chan Job, max(backlog, 0)),
+		log:    log,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for range max(workers, 1) {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit adds a job to the backlog
+func (q *Queue) Submit(job Job) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.stopped {
+		return ErrStopped
+	}
+
+	select {
+	case q.jobs <- job:
+		queueDepth.WithLabelValues(job.kind()).Inc()
+		return nil
+	default:
+		jobsRejected.WithLabelValues(job.kind()).Inc()
+		return ErrFull
+	}
+}
+
+// Stop refuses new jobs, cancels the context of running jobs and waits for the workers to exit.
+// Jobs still waiting in the backlog are dropped.
+func (q *Queue) Stop() {
+	q.mutex.Lock()
+	if q.stopped {
+		q.mutex.Unlock()
+		return
+	}
+	q.stopped = true
+	close(q.jobs)
+	q.mutex.Unlock()
+
+	q.cancel()
+	q.workers.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		q.heartbeat.Store(time.Now().UnixNano())
+		select {
+		case job, ok := <-q.jobs:
+			if !ok {
+				return
+			}
+			queueDepth.WithLabelValues(job.kind()).Dec()
+			if q.ctx.Err() != nil {
+				q.log.Warn().Str("job", job.Name).Msg("Dropping job, task queue is stopping")
+				continue
+			}
+			q.run(job)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Heartbeat is the last time any worker was alive, that is waiting for a job or done with one.
+// It falls behind by more than HeartbeatInterval when every worker is stuck in a job or has exited.
+func (q *Queue) Heartbeat() time.Time {
+	beat := q.heartbeat.Load()
+	if beat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, beat)
+}
+
+// run runs a single job, a panicking job does not take the worker down
+func (q *Queue) run(job Job) {
+	ctx, span := tracing.Start(q.ctx, "job "+job.kind(), trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.Link{SpanContext: job.Link}), trace.WithAttributes(attribute.String("job.name", job.Name)))
+	start := time.Now()
+	defer func() {
+		jobDuration.WithLabelValues(job.kind()).Observe(time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			jobFailures.WithLabelValues(job.kind()).Inc()
+			span.SetStatus(codes.Error, "panic")
+			q.log.Error().Str("job", job.Name).Interface("panic", r).Msg("Job panicked")
+		}
+		span.End()
+	}()
+
+	if err := job.Run(ctx); err != nil {
+		jobFailures.WithLabelValues(job.kind()).Inc()
+		tracing.RecordError(span, err)
+		q.log.Error().Err(err).Str("job", job.Name).Msg("Job failed")
+	}
+}
+
+// kind names the job in metrics, without the details that make its name unique
+func (j Job) kind() string {
+	kind, _, _ := strings.Cut(j.Name, ":")
+	return kind
+}
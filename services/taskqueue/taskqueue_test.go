@@ -0,0 +1,168 @@
+package taskqueue_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/metrics"
+	"github.com/Dev-AustinPeter/url-shortner-go/services/taskqueue"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue_RunsJobs(t *testing.T) {
+	q := taskqueue.New(3, 10, zerolog.Nop())
+	defer q.Stop()
+
+	var ran atomic.Int32
+	done := make(chan struct{}, 10)
+	for range 10 {
+		err := q.Submit(taskqueue.Job{Name: "count", Run: func(ctx context.Context) error {
+			ran.Add(1)
+			done <- struct{}{}
+			return nil
+		}})
+		assert.NoError(t, err)
+	}
+
+	for range 10 {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+	assert.Equal(t, int32(10), ran.Load())
+}
+
+func TestQueue_RefusesWhenFull(t *testing.T) {
+	q := taskqueue.New(1, 1, zerolog.Nop())
+	defer q.Stop()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := taskqueue.Job{Name: "block", Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}}
+
+	assert.NoError(t, q.Submit(blocking))
+	<-started // the worker is busy
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "waiting", Run: func(ctx context.Context) error { return nil }}))
+	assert.ErrorIs(t, q.Submit(taskqueue.Job{Name: "refused", Run: func(ctx context.Context) error { return nil }}), taskqueue.ErrFull)
+	close(release)
+}
+
+func TestQueue_SurvivesFailingJobs(t *testing.T) {
+	q := taskqueue.New(1, 3, zerolog.Nop())
+	defer q.Stop()
+
+	done := make(chan struct{})
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "panic", Run: func(ctx context.Context) error { panic("boom") }}))
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "error", Run: func(ctx context.Context) error { return errors.New("failed") }}))
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "ok", Run: func(ctx context.Context) error {
+		close(done)
+		return nil
+	}}))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not survive a failing job")
+	}
+}
+
+func TestQueue_StopCancelsRunningJobs(t *testing.T) {
+	q := taskqueue.New(1, 1, zerolog.Nop())
+
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "wait", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	}}))
+
+	<-started
+	q.Stop()
+	assert.True(t, cancelled.Load())
+	assert.ErrorIs(t, q.Submit(taskqueue.Job{Name: "late", Run: func(ctx context.Context) error { return nil }}), taskqueue.ErrStopped)
+}
+
+func TestQueue_Heartbeat(t *testing.T) {
+	q := taskqueue.New(1, 1, zerolog.Nop())
+	assert.Eventually(t, func() bool { return !q.Heartbeat().IsZero() }, time.Second, time.Millisecond)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "block", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}}))
+	<-started
+	busy := q.Heartbeat()
+
+	close(release)
+	assert.Eventually(t, func() bool { return q.Heartbeat().After(busy) }, time.Second, time.Millisecond)
+	q.Stop()
+}
+
+// sample reads one sample of the metrics served, 0 when it has not been recorded
+func sample(series string) float64 {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if value, found := strings.CutPrefix(line, series+" "); found {
+			v, _ := strconv.ParseFloat(value, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+func TestQueue_Metrics(t *testing.T) {
+	series := []string{
+		`urlshortner_taskqueue_job_duration_seconds_count{job="measured"}`,
+		`urlshortner_taskqueue_job_failures_total{job="measured"}`,
+		`urlshortner_taskqueue_rejected_total{job="measured"}`,
+	}
+	before := make([]float64, len(series))
+	for i, name := range series {
+		before[i] = sample(name)
+	}
+
+	q := taskqueue.New(1, 1, zerolog.Nop())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "measured:abc123", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return errors.New("failed")
+	}}))
+	<-started
+	assert.NoError(t, q.Submit(taskqueue.Job{Name: "measured:def456", Run: func(ctx context.Context) error {
+		close(done)
+		return nil
+	}}))
+	assert.ErrorIs(t, q.Submit(taskqueue.Job{Name: "measured:ghi789", Run: func(ctx context.Context) error { return nil }}), taskqueue.ErrFull)
+
+	close(release)
+	<-done
+	q.Stop() // waits for the workers, so every job has been measured
+
+	assert.Equal(t, float64(0), sample(`urlshortner_taskqueue_depth{job="measured"}`))
+	for i, want := range []float64{2, 1, 1} {
+		assert.Equal(t, want, sample(series[i])-before[i], series[i])
+	}
+}
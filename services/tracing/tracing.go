@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the service's own spans
+const instrumentationName = "github.com/Dev-AustinPeter/url-shortner-go"
+
+func init() {
+	// W3C traceparent and tracestate headers, even before a provider is set
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Options controls the provider and how finished spans are batched for the exporter
+type Options struct {
+	// ServiceName is the service.name of the resource every span is reported with
+	ServiceName string
+	// BatchSize spans are exported together, a full batch is exported right away
+	BatchSize int
+	// FlushInterval is how long a span waits at most before it is exported
+	FlushInterval time.Duration
+	// MaxQueueSize spans are kept while the exporter is slow, more are dropped
+	MaxQueueSize int
+}
+
+// DefaultOptions returns the options of the OpenTelemetry batch span processor
+func DefaultOptions() Options {
+	return Options{
+		ServiceName:   "url-shortner",
+		BatchSize:     sdktrace.DefaultMaxExportBatchSize,
+		FlushInterval: sdktrace.DefaultScheduleDelay * time.Millisecond,
+		MaxQueueSize:  sdktrace.DefaultMaxQueueSize,
+	}
+}
+
+// NewProvider returns a provider exporting to exporter in batches. A provider without an exporter still
+// creates span ids, so trace ids are logged and incoming traces are propagated, but records nothing.
+// Call Shutdown to export the spans still queued.
+func NewProvider(exporter sdktrace.SpanExporter, opts Options) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exporter != nil {
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(opts.BatchSize),
+			sdktrace.WithBatchTimeout(opts.FlushInterval),
+			sdktrace.WithMaxQueueSize(opts.MaxQueueSize),
+		))
+	}
+	return sdktrace.NewTracerProvider(providerOpts...), nil
+}
+
+// SetProvider makes provider the one Start uses
+func SetProvider(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+}
+
+// Start starts a span with the global provider, a child of the span in ctx. The returned context
+// carries the new span.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// RecordError marks span failed with err. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends span after recording *err, for use with defer and a named error result
+func End(span trace.Span, err *error) {
+	if err != nil {
+		RecordError(span, *err)
+	}
+	span.End()
+}
@@ -0,0 +1,147 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/services/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useExporter makes a provider exporting every span to the returned exporter the one Start uses
+func useExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	tracing.SetProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		tracing.SetProvider(previous)
+	})
+	return exporter
+}
+
+func TestStart_BuildsTree(t *testing.T) {
+	exporter := useExporter(t)
+
+	ctx, root := tracing.Start(context.Background(), "request", trace.WithSpanKind(trace.SpanKindServer))
+	childCtx, child := tracing.Start(ctx, "repository")
+	_, grandchild := tracing.Start(childCtx, "query")
+	grandchild.End()
+	err := errors.New("no rows")
+	tracing.End(child, &err)
+	_, sibling := tracing.Start(ctx, "cache", trace.WithAttributes(attribute.Bool("cache.hit", true)))
+	sibling.End()
+	root.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	for _, span := range spans {
+		assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext.TraceID())
+	}
+	assert.Equal(t, "query", spans[0].Name)
+	assert.Equal(t, child.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, "no rows", spans[1].Status.Description)
+	require.Len(t, spans[1].Events, 1)
+	assert.Equal(t, "exception", spans[1].Events[0].Name)
+	assert.Contains(t, spans[2].Attributes, attribute.Bool("cache.hit", true))
+	assert.Equal(t, trace.SpanKindServer, spans[3].SpanKind)
+	assert.False(t, spans[3].Parent.IsValid())
+}
+
+func TestEnd_WithoutError(t *testing.T) {
+	exporter := useExporter(t)
+
+	var err error
+	_, span := tracing.Start(context.Background(), "ok")
+	tracing.End(span, &err)
+	_, other := tracing.Start(context.Background(), "no result")
+	tracing.End(other, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		assert.Equal(t, codes.Unset, span.Status.Code)
+		assert.Empty(t, span.Events)
+	}
+}
+
+func TestStart_Links(t *testing.T) {
+	exporter := useExporter(t)
+
+	_, request := tracing.Start(context.Background(), "request")
+	request.End()
+	_, job := tracing.Start(context.Background(), "job", trace.WithLinks(
+		trace.Link{SpanContext: request.SpanContext()},
+		trace.Link{SpanContext: trace.SpanContext{}},
+	))
+	job.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.NotEqual(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+	require.Len(t, spans[1].Links, 1, "invalid links are dropped")
+	assert.Equal(t, request.SpanContext(), spans[1].Links[0].SpanContext)
+}
+
+func TestPropagator_ContinuesRemoteParent(t *testing.T) {
+	exporter := useExporter(t)
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	remote := trace.SpanContextFromContext(ctx)
+	require.True(t, remote.IsRemote())
+
+	_, span := tracing.Start(ctx, "request")
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	assert.True(t, spans[0].SpanContext.IsSampled())
+
+	out := http.Header{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), spans[0].SpanContext), propagation.HeaderCarrier(out))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+spans[0].SpanContext.SpanID().String()+"-01", out.Get("traceparent"))
+}
+
+func TestNewProvider(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := tracing.DefaultOptions()
+	opts.ServiceName = "shortner-test"
+	provider, err := tracing.NewProvider(exporter, opts)
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "request")
+	span.End()
+	assert.Empty(t, exporter.GetSpans(), "spans are exported in batches")
+	require.NoError(t, provider.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Resource.Attributes(), attribute.String("service.name", "shortner-test"))
+}
+
+func TestNewProvider_WithoutExporter(t *testing.T) {
+	provider, err := tracing.NewProvider(nil, tracing.DefaultOptions())
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	assert.True(t, span.SpanContext().IsValid(), "trace ids are still created for the logs")
+}
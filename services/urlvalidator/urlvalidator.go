@@ -0,0 +1,200 @@
+package urlvalidator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/constants"
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrTooLong          = errors.New("url is too long")
+	ErrControlCharacter = errors.New("url contains control, format or whitespace characters")
+	ErrNotAbsolute      = errors.New("url must be absolute")
+	ErrCredentials      = errors.New("url must not contain credentials")
+	ErrInvalidHost      = errors.New("url host is invalid")
+	ErrInvalidPort      = errors.New("url port is invalid")
+)
+
+// defaultPorts lists the ports that are implied by a scheme and dropped during canonicalization
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Options configures a Validator
+type Options struct {
+	// AllowedSchemes lists the accepted (lowercase) URL schemes
+	AllowedSchemes []string
+	// MaxLength is the maximum accepted length of the raw URL
+	MaxLength int
+	// StripTrackingParams removes tracking query parameters during canonicalization
+	StripTrackingParams bool
+	// TrackingParamPrefixes lists query parameter prefixes treated as tracking parameters
+	TrackingParamPrefixes []string
+	// TrackingParams lists exact query parameter names treated as tracking parameters
+	TrackingParams []string
+}
+
+// DefaultOptions returns the options used when nothing else is configured
+func DefaultOptions() Options {
+	return Options{
+		AllowedSchemes:        []string{"http", "https"},
+		MaxLength:             constants.MAX_URL_LENGTH,
+		StripTrackingParams:   false,
+		TrackingParamPrefixes: []string{"utm_"},
+		TrackingParams:        []string{"fbclid", "gclid", "dclid", "msclkid", "mc_eid"},
+	}
+}
+
+// Validator validates long URLs and rewrites them into a canonical form
+type Validator struct {
+	opts    Options
+	schemes map[string]struct{}
+	idna    *idna.Profile
+}
+
+// NewValidator initializes a new Validator
+func NewValidator(opts Options) *Validator {
+	schemes := make(map[string]struct{}, len(opts.AllowedSchemes))
+	for _, s := range opts.AllowedSchemes {
+		schemes[strings.ToLower(s)] = struct{}{}
+	}
+
+	return &Validator{
+		opts:    opts,
+		schemes: schemes,
+		idna:    idna.Lookup,
+	}
+}
+
+// Normalize validates raw and returns its canonical form. Two URLs that only differ in
+// host case, an explicit default port, an empty path or (optionally) tracking parameters
+// normalize to the same string, so the result can be used for deduplication.
+func (v *Validator) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+
+	if v.opts.MaxLength > 0 && len(raw) > v.opts.MaxLength {
+		return "", fmt.Errorf("%w: maximum is %d characters", ErrTooLong, v.opts.MaxLength)
+	}
+
+	// Format characters such as U+202E and U+200B change how a URL is displayed, not where it goes
+	for _, c := range raw {
+		if unicode.IsSpace(c) || unicode.IsControl(c) || unicode.Is(unicode.Cf, c) {
+			return "", ErrControlCharacter
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("url is malformed: %w", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" || u.Opaque != "" || u.Host == "" {
+		if _, ok := v.schemes[scheme]; scheme != "" && !ok {
+			return "", fmt.Errorf("url scheme %q is not allowed", scheme)
+		}
+		return "", ErrNotAbsolute
+	}
+	if _, ok := v.schemes[scheme]; !ok {
+		return "", fmt.Errorf("url scheme %q is not allowed", scheme)
+	}
+	u.Scheme = scheme
+
+	if u.User != nil {
+		return "", ErrCredentials
+	}
+
+	host, err := v.canonicalHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", ErrInvalidPort
+		}
+	}
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" && u.RawPath == "" {
+		u.Path = "/"
+	}
+
+	if v.opts.StripTrackingParams && u.RawQuery != "" {
+		u.RawQuery = v.stripTrackingParams(u.RawQuery)
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// canonicalHost lowercases the host, drops a trailing dot and converts IDNs to punycode
+func (v *Validator) canonicalHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", ErrInvalidHost
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := v.idna.ToASCII(strings.ToLower(host))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidHost, err)
+	}
+	return ascii, nil
+}
+
+// stripTrackingParams removes tracking parameters while keeping the order and encoding
+// of the remaining parameters untouched
+func (v *Validator) stripTrackingParams(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && v.isTrackingParam(name) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+func (v *Validator) isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range v.opts.TrackingParams {
+		if name == p {
+			return true
+		}
+	}
+	for _, prefix := range v.opts.TrackingParamPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
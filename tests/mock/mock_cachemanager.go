@@ -31,6 +31,58 @@ func (m *MockRedisClient) Get(ctx context.Context, key string) *redis.StringCmd
 	return cmd
 }
 
+func (m *MockRedisClient) Incr(ctx context.Context, key string) *redis.IntCmd {
+	args := m.Called(ctx, key)
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(args.Get(0).(int64))
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
+func (m *MockRedisClient) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	args := m.Called(ctx, key, value)
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(args.Get(0).(int64))
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
+// Eval records the keys and arguments of a script, not its source
+func (m *MockRedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	called := m.Called(ctx, keys, args)
+	cmd := redis.NewCmd(ctx)
+	cmd.SetVal(called.Get(0))
+	cmd.SetErr(called.Error(1))
+	return cmd
+}
+
+func (m *MockRedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(args.Get(0).(int64))
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
+// MGet takes the values of keys in order, nil for a missing key
+func (m *MockRedisClient) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	args := m.Called(ctx, keys)
+	cmd := redis.NewSliceCmd(ctx)
+	if values, ok := args.Get(0).([]interface{}); ok {
+		cmd.SetVal(values)
+	}
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
+func (m *MockRedisClient) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	args := m.Called(ctx, key)
+	cmd := redis.NewDurationCmd(ctx, time.Second)
+	cmd.SetVal(args.Get(0).(time.Duration))
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
 func (m *MockCacheManager) Set(ctx context.Context, key string, value string, ttl int) error {
 	args := m.Called(ctx, key, value, ttl)
 	return args.Error(0)
@@ -40,3 +92,34 @@ func (m *MockCacheManager) Get(ctx context.Context, key string) (string, error)
 	args := m.Called(ctx, key)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockCacheManager) Delete(ctx context.Context, keys ...string) error {
+	args := m.Called(ctx, keys)
+	return args.Error(0)
+}
+
+func (m *MockCacheManager) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	args := m.Called(ctx, keys)
+	values, _ := args.Get(0).(map[string]string)
+	return values, args.Error(1)
+}
+
+func (m *MockCacheManager) TTL(ctx context.Context, key string) (time.Duration, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockCacheManager) Incr(ctx context.Context, key string) (int64, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCacheManager) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	args := m.Called(ctx, key, value)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCacheManager) IncrFrom(ctx context.Context, key string, base int64) (int64, error) {
+	args := m.Called(ctx, key, base)
+	return args.Get(0).(int64), args.Error(1)
+}
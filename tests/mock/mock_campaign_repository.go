@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCampaignRepository records calls without their context, so expectations only list the other arguments
+type MockCampaignRepository struct {
+	mock.Mock
+}
+
+var _ repository.CampaignRepository = (*MockCampaignRepository)(nil)
+
+func (m *MockCampaignRepository) CreateCampaign(ctx context.Context, campaign types.Campaign) (*types.Campaign, error) {
+	args := m.Called(campaign)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignRepository) GetCampaign(ctx context.Context, id int) (types.Campaign, error) {
+	args := m.Called(id)
+	return args.Get(0).(types.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignRepository) ListCampaigns(ctx context.Context) ([]types.Campaign, error) {
+	args := m.Called()
+	return args.Get(0).([]types.Campaign), args.Error(1)
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockHealthRepository records calls without their context, so expectations only list the other arguments
+type MockHealthRepository struct {
+	mock.Mock
+}
+
+var _ repository.HealthRepository = (*MockHealthRepository)(nil)
+
+func (m *MockHealthRepository) ListCheckTargets(ctx context.Context, afterID int64, limit int) ([]repository.Url, error) {
+	args := m.Called(afterID, limit)
+	return args.Get(0).([]repository.Url), args.Error(1)
+}
+
+func (m *MockHealthRepository) RecordCheck(ctx context.Context, link repository.Url, check types.HealthCheck, threshold int) (bool, error) {
+	args := m.Called(link, check, threshold)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockHealthRepository) ListBrokenLinks(ctx context.Context, owner string, limit int, offset int) ([]repository.BrokenLink, error) {
+	args := m.Called(owner, limit, offset)
+	return args.Get(0).([]repository.BrokenLink), args.Error(1)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLinkRepository records calls without their context, so expectations only list the other arguments
+type MockLinkRepository struct {
+	mock.Mock
+}
+
+var _ repository.LinkRepository = (*MockLinkRepository)(nil)
+
+func (m *MockLinkRepository) SearchLinks(ctx context.Context, q repository.LinkQuery) (repository.LinkPage, error) {
+	args := m.Called(q)
+	return args.Get(0).(repository.LinkPage), args.Error(1)
+}
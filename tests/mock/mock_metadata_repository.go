@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMetadataRepository records calls without their context, so expectations only list the other arguments
+type MockMetadataRepository struct {
+	mock.Mock
+}
+
+var _ repository.MetadataRepository = (*MockMetadataRepository)(nil)
+
+func (m *MockMetadataRepository) SaveMetadata(ctx context.Context, shortCode string, metadata types.LinkMetadata) error {
+	args := m.Called(shortCode, metadata)
+	return args.Error(0)
+}
+
+func (m *MockMetadataRepository) GetMetadata(ctx context.Context, shortCode string) (types.LinkMetadata, error) {
+	args := m.Called(shortCode)
+	return args.Get(0).(types.LinkMetadata), args.Error(1)
+}
@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReportRepository records calls without their context, so expectations only list the other arguments
+type MockReportRepository struct {
+	mock.Mock
+}
+
+var _ repository.ReportRepository = (*MockReportRepository)(nil)
+
+func (m *MockReportRepository) CreateReport(ctx context.Context, shortCode string, reason string, reporter string) (*types.Report, error) {
+	args := m.Called(shortCode, reason, reporter)
+	return args.Get(0).(*types.Report), args.Error(1)
+}
+
+func (m *MockReportRepository) GetReport(ctx context.Context, id int) (types.Report, error) {
+	args := m.Called(id)
+	return args.Get(0).(types.Report), args.Error(1)
+}
+
+func (m *MockReportRepository) ListReports(ctx context.Context, status string, limit int, offset int) ([]types.Report, error) {
+	args := m.Called(status, limit, offset)
+	return args.Get(0).([]types.Report), args.Error(1)
+}
+
+func (m *MockReportRepository) CountOpenReporters(ctx context.Context, shortCode string) (int, error) {
+	args := m.Called(shortCode)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockReportRepository) UpdateReportStatus(ctx context.Context, id int, status string) error {
+	args := m.Called(id, status)
+	return args.Error(0)
+}
+
+func (m *MockReportRepository) TakedownReport(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockReportRepository) SetUrlStatus(ctx context.Context, shortCode string, status string) error {
+	args := m.Called(shortCode, status)
+	return args.Error(0)
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
@@ -8,43 +9,69 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// MockUrlRepository records calls without their context, so expectations only list the other arguments
 type MockUrlRepository struct {
 	mock.Mock
 }
 
 var _ repository.UrlRepository = (*MockUrlRepository)(nil)
 
-func (m *MockUrlRepository) GetTask(taskId string) (types.Task, error) {
+func (m *MockUrlRepository) GetTask(ctx context.Context, taskId string) (types.Task, error) {
 	args := m.Called(taskId)
 	return args.Get(0).(types.Task), args.Error(1)
 }
 
-func (m *MockUrlRepository) UpdateTask(taskId string, status string, result json.RawMessage) error {
+func (m *MockUrlRepository) UpdateTask(ctx context.Context, taskId string, status string, result json.RawMessage) error {
 	args := m.Called(taskId, status, result)
 	return args.Error(0)
 }
 
-func (m *MockUrlRepository) CreateTaskId() (*types.Task, error) {
+func (m *MockUrlRepository) CreateTaskId(ctx context.Context) (*types.Task, error) {
 	args := m.Called()
 	return args.Get(0).(*types.Task), args.Error(1)
 }
 
-func (m *MockUrlRepository) GetUrl(shortCode string) (repository.Url, error) {
+func (m *MockUrlRepository) GetUrl(ctx context.Context, shortCode string) (repository.Url, error) {
 	args := m.Called(shortCode)
 	return args.Get(0).(repository.Url), args.Error(1)
 }
 
-func (m *MockUrlRepository) CreateUrl(url string) (*string, error) {
+func (m *MockUrlRepository) CreateUrl(ctx context.Context, url string) (*string, error) {
 	args := m.Called(url)
 	return args.Get(0).(*string), args.Error(1)
 }
 
-func (m *MockUrlRepository) GetAllUrls() ([]repository.Url, error) {
-	args := m.Called()
+func (m *MockUrlRepository) CreateUrlWithOptions(ctx context.Context, url string, opts repository.LinkOptions) (*string, error) {
+	args := m.Called(url, opts)
+	return args.Get(0).(*string), args.Error(1)
+}
+
+func (m *MockUrlRepository) ListUrls(ctx context.Context, afterID int64, limit int) ([]repository.Url, error) {
+	args := m.Called(afterID, limit)
 	return args.Get(0).([]repository.Url), args.Error(1)
 }
 
-func (m *MockUrlRepository) GetLongUrl(longUrl string) (repository.Url, error) {
+func (m *MockUrlRepository) SyncClickCount(ctx context.Context, shortCode string, count int64) error {
+	args := m.Called(shortCode, count)
+	return args.Error(0)
+}
+
+func (m *MockUrlRepository) GetLongUrl(ctx context.Context, longUrl string) (repository.Url, error) {
 	args := m.Called(longUrl)
 	return args.Get(0).(repository.Url), args.Error(1)
 }
+
+func (m *MockUrlRepository) UpdatePassthrough(ctx context.Context, shortCode string, p repository.Passthrough) error {
+	args := m.Called(shortCode, p)
+	return args.Error(0)
+}
+
+func (m *MockUrlRepository) UpdateLinkDetails(ctx context.Context, shortCode string, d repository.LinkDetails) error {
+	args := m.Called(shortCode, d)
+	return args.Error(0)
+}
+
+func (m *MockUrlRepository) UpdateFallbackUrl(ctx context.Context, shortCode string, fallbackUrl string) error {
+	args := m.Called(shortCode, fallbackUrl)
+	return args.Error(0)
+}
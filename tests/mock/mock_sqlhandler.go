@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -11,27 +12,27 @@ type MockDB struct {
 	db   *sql.DB
 }
 
-func (m *MockDB) Prepare(query string) (*sql.Stmt, error) {
-	return m.db.Prepare(query)
+func (m *MockDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return m.db.PrepareContext(ctx, query)
 }
 
-func (m *MockDB) QueryRow(query string, args ...interface{}) *sql.Row {
-	return m.db.QueryRow(query, args...)
+func (m *MockDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return m.db.QueryRowContext(ctx, query, args...)
 }
 
-func (m *MockDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return m.db.Query(query, args...)
+func (m *MockDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return m.db.QueryContext(ctx, query, args...)
 }
 
-func (m *MockDB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return m.db.Exec(query, args...)
+func (m *MockDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return m.db.ExecContext(ctx, query, args...)
 }
 
 func (m *MockDB) Close() error {
 	return m.db.Close()
 }
 
-func (m *MockDB) Ping() error {
+func (m *MockDB) PingContext(ctx context.Context) error {
 	return nil
 }
 
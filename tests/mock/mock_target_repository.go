@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/db/repository"
+	"github.com/Dev-AustinPeter/url-shortner-go/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTargetRepository records calls without their context, so expectations only list the other arguments
+type MockTargetRepository struct {
+	mock.Mock
+}
+
+var _ repository.TargetRepository = (*MockTargetRepository)(nil)
+
+func (m *MockTargetRepository) GetTargets(ctx context.Context, shortCode string) ([]types.LinkTarget, error) {
+	args := m.Called(shortCode)
+	return args.Get(0).([]types.LinkTarget), args.Error(1)
+}
+
+func (m *MockTargetRepository) ReplaceTargets(ctx context.Context, shortCode string, targets []types.LinkTarget) error {
+	args := m.Called(shortCode, targets)
+	return args.Error(0)
+}
@@ -6,10 +6,51 @@ import (
 )
 
 type ResponseUrl struct {
-	ID        int    `json:"id,omitempty"`
+	ID                int          `json:"id,omitempty"`
+	ShortCode         string       `json:"shortCode"`
+	LongUrl           string       `json:"longUrl,omitempty"`
+	CreatedAt         string       `json:"createdAt,omitempty"`
+	PasswordProtected bool         `json:"passwordProtected,omitempty"`
+	MaxClicks         int64        `json:"maxClicks,omitempty"`
+	Clicks            int64        `json:"clicks,omitempty"`
+	ActiveFrom        string       `json:"activeFrom,omitempty"`
+	Targets           []LinkTarget `json:"targets,omitempty"`
+	ForwardQuery      bool         `json:"forwardQuery,omitempty"`
+	QueryConflict     string       `json:"queryConflict,omitempty"`
+	Prefix            bool         `json:"prefix,omitempty"`
+	CampaignID        int          `json:"campaignId,omitempty"`
+	Title             string       `json:"title,omitempty"`
+	Notes             string       `json:"notes,omitempty"`
+	Folder            string       `json:"folder,omitempty"`
+	Tags              []string     `json:"tags,omitempty"`
+	Owner             string       `json:"owner,omitempty"`
+	FallbackUrl       string       `json:"fallbackUrl,omitempty"`
+	Broken            bool         `json:"broken,omitempty"`
+}
+
+type RuleClicks struct {
+	Name   string `json:"name"`
+	Target string `json:"target,omitempty"`
+	Clicks int64  `json:"clicks"`
+}
+
+type LinkTarget struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+type VariantClicks struct {
+	Name   string `json:"name"`
+	Url    string `json:"url,omitempty"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ScanCount struct {
 	ShortCode string `json:"shortCode"`
-	LongUrl   string `json:"longUrl"`
-	CreatedAt string `json:"createdAt,omitempty"`
+	Clicks    int64  `json:"clicks"`
+	Scans     int64  `json:"scans"`
 }
 
 type Task struct {
@@ -18,3 +59,68 @@ type Task struct {
 	Result    json.RawMessage `json:"result,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 }
+
+type Report struct {
+	ID         int        `json:"id"`
+	ShortCode  string     `json:"shortCode"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+}
+
+type Campaign struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	UtmSource   string    `json:"utmSource,omitempty"`
+	UtmMedium   string    `json:"utmMedium,omitempty"`
+	UtmCampaign string    `json:"utmCampaign,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type TagFacet struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type LinkSearchResult struct {
+	Links  []ResponseUrl `json:"links"`
+	Total  int           `json:"total"`
+	Facets []TagFacet    `json:"facets"`
+}
+
+type LinkMetadata struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Favicon     string    `json:"favicon,omitempty"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+}
+
+type HealthCheck struct {
+	// StatusCode is 0 when no response was received
+	StatusCode int       `json:"statusCode,omitempty"`
+	LatencyMs  int64     `json:"latencyMs"`
+	Error      string    `json:"error,omitempty"`
+	OK         bool      `json:"ok"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+type BrokenLink struct {
+	ShortCode           string    `json:"shortCode"`
+	LongUrl             string    `json:"longUrl,omitempty"`
+	PasswordProtected   bool      `json:"passwordProtected,omitempty"`
+	FallbackUrl         string    `json:"fallbackUrl,omitempty"`
+	StatusCode          int       `json:"statusCode,omitempty"`
+	LatencyMs           int64     `json:"latencyMs"`
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastCheckedAt       time.Time `json:"lastCheckedAt"`
+	BrokenSince         time.Time `json:"brokenSince"`
+}
+
+type OwnerBrokenLinks struct {
+	// Owner is empty for links without an owner
+	Owner string       `json:"owner"`
+	Links []BrokenLink `json:"links"`
+}
@@ -3,7 +3,11 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
 )
 
 func ParseJson(r *http.Request, payload any) error {
@@ -28,3 +32,69 @@ func WriteError(w http.ResponseWriter, status int, err error) {
 		Error: err.Error(),
 	})
 }
+
+// trustedProxies are the proxies whose X-Forwarded-For header is believed, see SetTrustedProxies
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// SetTrustedProxies sets the addresses, single IPs or CIDR ranges, of the proxies in front of the service.
+// X-Forwarded-For is only believed when a request comes from one of them, as anyone else can make it up.
+func SetTrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// trusted reports whether ip is one of the trusted proxies
+func trusted(ip string) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP extracts the real client IP. X-Forwarded-For is read from the right, the end the trusted
+// proxies appended to, and the first address that is not a trusted proxy is the client.
+func GetClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !trusted(ip) {
+		return ip
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !trusted(hop) {
+			return hop
+		}
+		ip = hop
+	}
+	return ip
+}
@@ -0,0 +1,39 @@
+package utils_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/url-shortner-go/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClientIP(t *testing.T) {
+	require.NoError(t, utils.SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"}))
+	t.Cleanup(func() { utils.SetTrustedProxies(nil) })
+
+	for _, tc := range []struct {
+		name, remoteAddr, forwardedFor, want string
+	}{
+		{"Direct", "203.0.113.7:5555", "", "203.0.113.7"},
+		{"Forged header", "203.0.113.7:5555", "198.51.100.1", "203.0.113.7"},
+		{"Behind a proxy", "192.0.2.1:443", "198.51.100.1", "198.51.100.1"},
+		{"Behind two proxies", "10.1.2.3:443", "198.51.100.1, 192.0.2.1", "198.51.100.1"},
+		{"Client prepending a forged hop", "10.1.2.3:443", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"Proxy without header", "10.1.2.3:443", "", "10.1.2.3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			assert.Equal(t, tc.want, utils.GetClientIP(req))
+		})
+	}
+}
+
+func TestSetTrustedProxies_Invalid(t *testing.T) {
+	assert.Error(t, utils.SetTrustedProxies([]string{"not-an-ip"}))
+}